@@ -4,6 +4,7 @@ import (
 	"i9chat/src/initializers"
 	"i9chat/src/routes/appRoutes"
 	"i9chat/src/routes/authRoutes"
+	"i9chat/src/routes/storageRoutes"
 	"log"
 	"os"
 
@@ -48,6 +49,8 @@ func main() {
 
 	app.Route("/api/app", appRoutes.Route)
 
+	app.Route("/api/storage", storageRoutes.Route)
+
 	var PORT string
 
 	if os.Getenv("GO_ENV") != "production" {
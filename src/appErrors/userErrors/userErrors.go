@@ -10,4 +10,5 @@ const (
 	ResetTokenExpired    string = "uERR_4006" // reset token expired! re-submit your email
 	IncorrectCredentials string = "uERR_4007" // incorrect credentials
 	MediaUploadTimedOut  string = "uERR_4008" // media upload timed out
+	BlockedUser          string = "uERR_4009" // you can't interact with this user
 )
@@ -30,6 +30,25 @@ type GroupInfo struct {
 	CreatedAt          int64  `msgpack:"created_at"`
 	MembersCount       int64  `msgpack:"members_count"`
 	OnlineMembersCount int    `msgpack:"online_members_count"`
+	PinPermission      string `msgpack:"pin_permission,omitempty"` // "admins" (default) or "all"
+	IsPublic           bool   `msgpack:"is_public"`
+	JoinApproval       bool   `msgpack:"join_approval"` // users joining request to join, for an admin to approve
+
+	// permission settings, all off by default
+	OnlyAdminsCanSendMessages bool `msgpack:"only_admins_can_send_messages"` // announcement mode
+	MembersCanEditInfo        bool `msgpack:"members_can_edit_info"`
+	MembersCanAddMembers      bool `msgpack:"members_can_add_members"`
+
+	Archived bool `msgpack:"archived"` // the group is deleted, with its chats left read-only
+}
+
+type GroupInviteLink struct {
+	Code      string `msgpack:"code" db:"code"`
+	CreatedBy string `msgpack:"created_by" db:"created_by"`
+	CreatedAt int64  `msgpack:"created_at" db:"created_at"`
+	ExpiresAt int64  `msgpack:"expires_at,omitempty" db:"expires_at"` // no expiry, if zero
+	MaxUses   int64  `msgpack:"max_uses,omitempty" db:"max_uses"`     // no usage limit, if zero
+	Uses      int64  `msgpack:"uses" db:"uses"`
 }
 
 type GroupMemberSnippet struct {
@@ -39,6 +58,12 @@ type GroupMemberSnippet struct {
 	Cursor        float64 `msgpack:"cursor"`
 }
 
+type BlockedUserSnippet struct {
+	Username      string  `msgpack:"username"`
+	ProfilePicUrl string  `msgpack:"profile_pic_url"`
+	Cursor        float64 `msgpack:"cursor"`
+}
+
 type ChatPartnerUser struct {
 	Username      string `msgpack:"username"`
 	ProfilePicUrl string `msgpack:"profile_pic_url"`
@@ -48,6 +73,7 @@ type ChatGroup struct {
 	Id         string `msgpack:"id"`
 	Name       string `msgpack:"name"`
 	PictureUrl string `msgpack:"picture_url"`
+	Archived   bool   `msgpack:"archived"`
 }
 
 type ChatSnippet struct {
@@ -86,9 +112,12 @@ type ChatHistoryEntry struct {
 	CreatedAt      int64          `msgpack:"created_at,omitempty"`
 	DeliveredAt    int64          `msgpack:"delivered_at,omitempty"`
 	ReadAt         int64          `msgpack:"read_at,omitempty"`
+	EditedAt       int64          `msgpack:"edited_at,omitempty"`
+	DeletedAt      int64          `msgpack:"deleted_at,omitempty"`
 	Sender         any            `msgpack:"sender,omitempty"`
 	ReactionsCount map[string]int `msgpack:"reactions_count,omitempty"`
 	Reactions      []MsgReaction  `msgpack:"reactions,omitempty"`
+	Starred        bool           `msgpack:"starred,omitempty"` // by the client
 
 	// appears if che_type:message is a reply
 	ReplyTargetMsg map[string]any `msgpack:"reply_target_msg,omitempty"`
@@ -104,3 +133,29 @@ type ChatHistoryEntry struct {
 	// cursor for pagination
 	Cursor float64 `msgpack:"cursor"`
 }
+
+type FoundMessage struct {
+	ChatType  string `msgpack:"chat_type"`
+	ChatIdent string `msgpack:"chat_ident"` // the partner's username, or the group's id
+
+	// Message.Cursor locates the message in its chat's history
+	Message ChatHistoryEntry `msgpack:"message"`
+}
+
+type StarredMessage struct {
+	ChatType  string `msgpack:"chat_type"`
+	ChatIdent string `msgpack:"chat_ident"` // the partner's username, or the group's id
+
+	// Message.Cursor locates the message in its chat's history
+	Message ChatHistoryEntry `msgpack:"message"`
+
+	// cursor for pagination, when the message was starred
+	Cursor float64 `msgpack:"cursor"`
+}
+
+type PinnedMessage struct {
+	PinnedAt int64 `msgpack:"pinned_at"`
+
+	// Message.Cursor locates the message in its chat's history
+	Message ChatHistoryEntry `msgpack:"message"`
+}
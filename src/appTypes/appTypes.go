@@ -5,7 +5,8 @@ import (
 )
 
 type ClientUser struct {
-	Username string `msgpack:"username"`
+	Username  string `msgpack:"username"`
+	SessionId string `msgpack:"session_id"`
 }
 
 type BinableMap map[string]any
@@ -20,9 +21,13 @@ func (c BinableSlice) MarshalBinary() ([]byte, error) {
 	return json.Marshal(c)
 }
 
+// DeletedMsgContentJson is the content a message deleted for everyone is left with
+const DeletedMsgContentJson = `{"type":"deleted","props":{}}`
+
 type ServerEventMsg struct {
-	Event string `msgpack:"event"`
-	Data  any    `msgpack:"data"`
+	EventId string `msgpack:"event_id,omitempty"`
+	Event   string `msgpack:"event"`
+	Data    any    `msgpack:"data"`
 }
 
 type UserGeolocation struct {
@@ -6,9 +6,14 @@ func Start(rdb *redis.Client) {
 	newUsersStreamBgWorker(rdb)
 	userEditsStreamBgWorker(rdb)
 	userPresenceChangesStreamBgWorker(rdb)
+	presenceSweepBgWorker(rdb)
 
 	newDirectMessagesStreamBgWorker(rdb)
 	directMsgAcksStreamBgWorker(rdb)
+	directMsgReactionsStreamBgWorker(rdb)
+	directMsgReactionsRemovedStreamBgWorker(rdb)
+	directMsgEditsStreamBgWorker(rdb)
+	directMsgDeletionsStreamBgWorker(rdb)
 
 	newGroupsStreamBgWorker(rdb)
 	groupEditsStreamBgWorker(rdb)
@@ -18,7 +23,15 @@ func Start(rdb *redis.Client) {
 	groupUsersLeftStreamBgWorker(rdb)
 	groupNewAdminsStreamBgWorker(rdb)
 	groupRemovedAdminsStreamBgWorker(rdb)
+	groupsDeletedStreamBgWorker(rdb)
 
 	newGroupMessagesStreamBgWorker(rdb)
 	groupMsgAcksStreamBgWorker(rdb)
+	groupMsgReactionsStreamBgWorker(rdb)
+	groupMsgReactionsRemovedStreamBgWorker(rdb)
+	groupMsgEditsStreamBgWorker(rdb)
+	groupMsgDeletionsStreamBgWorker(rdb)
+
+	imageProcessingStreamBgWorker(rdb)
+	mediaGCBgWorker(rdb)
 }
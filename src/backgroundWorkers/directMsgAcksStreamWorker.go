@@ -8,7 +8,6 @@ import (
 	"i9chat/src/helpers"
 	"i9chat/src/services/eventStreamService/eventTypes"
 	"log"
-	"maps"
 
 	"github.com/redis/go-redis/v9"
 )
@@ -103,19 +102,19 @@ func directMsgAcksStreamBgWorker(rdb *redis.Client) {
 				}
 			}
 
-			// batch processing
-			msgId_updateKVMap_StringCmd := make(map[string][2]any)
+			msgUpdates := make(map[string][]map[string]any)
 
-			_, err = rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			for _, msgId_ack_ackAt := range ackMessages {
+				msgId, ack, ackAt := msgId_ack_ackAt[0].(string), msgId_ack_ackAt[1], msgId_ack_ackAt[2]
 
-				for _, msgId_ack_ackAt := range ackMessages {
-					msgId, ack, ackAt := msgId_ack_ackAt[0].(string), msgId_ack_ackAt[1], msgId_ack_ackAt[2]
+				msgUpdates[msgId] = append(msgUpdates[msgId], map[string]any{
+					"delivery_status":         ack,
+					fmt.Sprintf("%s_at", ack): ackAt,
+				})
+			}
 
-					msgId_updateKVMap_StringCmd[msgId] = [2]any{map[string]any{
-						"delivery_status":         ack,
-						fmt.Sprintf("%s_at", ack): ackAt,
-					}, pipe.HGet(ctx, "direct_chat_history_entries", msgId)}
-				}
+			// batch processing
+			_, err = rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
 
 				for ownerUser, partnerUser_score_Pairs := range updatedFromUserChats {
 					cache.StoreUserChatIdents(pipe, ctx, ownerUser, partnerUser_score_Pairs)
@@ -140,36 +139,8 @@ func directMsgAcksStreamBgWorker(rdb *redis.Client) {
 				return
 			}
 
-			msgUpdates := []string{}
-
-			for msgId, updateKVMap_StringCmd := range msgId_updateKVMap_StringCmd {
-				updateKVMap, stringCmd := updateKVMap_StringCmd[0].(map[string]any), updateKVMap_StringCmd[1].(*redis.StringCmd)
-
-				msgDataMsgPack, err := stringCmd.Result()
-				if err != nil {
-					helpers.LogError(err)
-					continue
-				}
-
-				msgData := helpers.FromMsgPack[map[string]any](msgDataMsgPack)
-
-				// if a client skips the "delivered" ack, and acks "read"
-				// it means the message is delivered and read at the same time
-				if updateKVMap["read_at"] != nil && msgData["delivered_at"] == nil {
-					msgData["delivered_at"] = updateKVMap["read_at"]
-				}
-
-				maps.Copy(msgData, updateKVMap)
-
-				msgUpdates = append(msgUpdates, msgId, helpers.ToMsgPack(msgData))
-			}
-
-			if len(msgUpdates) != 0 {
-				err = rdb.HSet(ctx, "direct_chat_history_entries", msgUpdates).Err()
-				if err != nil {
-					helpers.LogError(err)
-					return
-				}
+			if err := cache.UpdateDirectChatHistoryEntries(ctx, msgUpdates); err != nil {
+				return
 			}
 
 			// acknowledge messages
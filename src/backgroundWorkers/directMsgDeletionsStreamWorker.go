@@ -0,0 +1,112 @@
+package backgroundWorkers
+
+import (
+	"context"
+	"fmt"
+	"i9chat/src/appTypes"
+	"i9chat/src/cache"
+	"i9chat/src/helpers"
+	"i9chat/src/services/eventStreamService/eventTypes"
+	"log"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func directMsgDeletionsStreamBgWorker(rdb *redis.Client) {
+	var (
+		streamName   = "direct_msg_deletions"
+		groupName    = "direct_msg_deletion_listeners"
+		consumerName = "worker-1"
+	)
+
+	ctx := context.Background()
+
+	err := rdb.XGroupCreateMkStream(ctx, streamName, groupName, "$").Err()
+	if err != nil && (err.Error() != "BUSYGROUP Consumer Group name already exists") {
+		helpers.LogError(err)
+		log.Fatal()
+	}
+
+	go func() {
+		for {
+			streams, err := rdb.XReadGroup(ctx, &redis.XReadGroupArgs{
+				Group:    groupName,
+				Consumer: consumerName,
+				Streams:  []string{streamName, ">"},
+				Count:    500,
+				Block:    0,
+			}).Result()
+
+			if err != nil {
+				helpers.LogError(err)
+				continue
+			}
+
+			var stmsgIds []string
+			var msgs []eventTypes.DirectMsgDeletionEvent
+
+			for _, stmsg := range streams[0].Messages {
+				stmsgIds = append(stmsgIds, stmsg.ID)
+
+				var msg eventTypes.DirectMsgDeletionEvent
+
+				msg.FromUser = stmsg.Values["fromUser"].(string)
+				msg.ToUser = stmsg.Values["toUser"].(string)
+				msg.CHEIds = helpers.FromJson[appTypes.BinableSlice](stmsg.Values["CHEIds"].(string))
+				msg.For = stmsg.Values["for"].(string)
+				msg.DeletedAt = helpers.ParseInt(stmsg.Values["deletedAt"].(string))
+
+				msgs = append(msgs, msg)
+
+			}
+
+			chatMsgsDeletedForMe := make(map[string][]any)
+
+			msgsDeletedForEveryone := make(map[string][]map[string]any)
+
+			// batch data for batch processing
+			for _, msg := range msgs {
+				if msg.For == "me" {
+					chatMsgsDeletedForMe[msg.FromUser+" "+msg.ToUser] = append(chatMsgsDeletedForMe[msg.FromUser+" "+msg.ToUser], msg.CHEIds...)
+
+					continue
+				}
+
+				for _, CHEId := range msg.CHEIds {
+					msgsDeletedForEveryone[CHEId.(string)] = append(msgsDeletedForEveryone[CHEId.(string)], map[string]any{
+						"content":    helpers.FromJson[map[string]any](appTypes.DeletedMsgContentJson),
+						"deleted_at": msg.DeletedAt,
+					})
+				}
+			}
+
+			// batch processing
+			_, err = rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+				for ownerUserPartnerUser, CHEIds := range chatMsgsDeletedForMe {
+					var ownerUser, partnerUser string
+
+					fmt.Sscanf(ownerUserPartnerUser, "%s %s", &ownerUser, &partnerUser)
+
+					cache.RemoveUserDirectChatHistory(pipe, ctx, ownerUser, partnerUser, CHEIds)
+					cache.StoreDirectChatMsgsDeletedForMe(pipe, ctx, ownerUser, partnerUser, CHEIds)
+					cache.RemoveUserChatUnreadMsgs(pipe, ctx, ownerUser, partnerUser, CHEIds)
+				}
+
+				return nil
+			})
+			if err != nil {
+				helpers.LogError(err)
+				return
+			}
+
+			if err := cache.UpdateDirectChatHistoryEntries(ctx, msgsDeletedForEveryone); err != nil {
+				return
+			}
+
+			// acknowledge messages
+			if err := rdb.XAck(ctx, streamName, groupName, stmsgIds...).Err(); err != nil {
+				helpers.LogError(err)
+			}
+		}
+	}()
+}
@@ -70,7 +70,15 @@ func groupEditsStreamBgWorker(rdb *redis.Client) {
 
 			// batch data for batch processing
 			for i, msg := range msgs {
-				groupEdits[msg.GroupId] = map[string]any(msg.UpdateKVMap)
+				// a group's edits in the batch are merged, in order.
+				// Activities that don't edit the group (e.g. message pins) leave its cached data as is
+				if len(msg.UpdateKVMap) != 0 {
+					if groupEdits[msg.GroupId] == nil {
+						groupEdits[msg.GroupId] = map[string]any{}
+					}
+
+					maps.Copy(groupEdits[msg.GroupId], msg.UpdateKVMap)
+				}
 
 				gactche := msg.EditorUserCHE
 
@@ -0,0 +1,83 @@
+package backgroundWorkers
+
+import (
+	"context"
+	"i9chat/src/cache"
+	"i9chat/src/helpers"
+	"i9chat/src/services/eventStreamService/eventTypes"
+	"log"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func groupMsgEditsStreamBgWorker(rdb *redis.Client) {
+	var (
+		streamName   = "group_msg_edits"
+		groupName    = "group_msg_edit_listeners"
+		consumerName = "worker-1"
+	)
+
+	ctx := context.Background()
+
+	err := rdb.XGroupCreateMkStream(ctx, streamName, groupName, "$").Err()
+	if err != nil && (err.Error() != "BUSYGROUP Consumer Group name already exists") {
+		helpers.LogError(err)
+		log.Fatal()
+	}
+
+	go func() {
+		for {
+			streams, err := rdb.XReadGroup(ctx, &redis.XReadGroupArgs{
+				Group:    groupName,
+				Consumer: consumerName,
+				Streams:  []string{streamName, ">"},
+				Count:    500,
+				Block:    0,
+			}).Result()
+
+			if err != nil {
+				helpers.LogError(err)
+				continue
+			}
+
+			var stmsgIds []string
+			var msgs []eventTypes.GroupMsgEditEvent
+
+			for _, stmsg := range streams[0].Messages {
+				stmsgIds = append(stmsgIds, stmsg.ID)
+
+				var msg eventTypes.GroupMsgEditEvent
+
+				msg.FromUser = stmsg.Values["fromUser"].(string)
+				msg.ToGroup = stmsg.Values["toGroup"].(string)
+				msg.CHEId = stmsg.Values["CHEId"].(string)
+				msg.Content = stmsg.Values["content"].(string)
+				msg.EditedAt = helpers.ParseInt(stmsg.Values["editedAt"].(string))
+
+				msgs = append(msgs, msg)
+
+			}
+
+			CHEId_updates := make(map[string][]map[string]any)
+
+			// batch data for batch processing
+			for _, msg := range msgs {
+				// later edits of the same message override earlier ones
+				CHEId_updates[msg.CHEId] = append(CHEId_updates[msg.CHEId], map[string]any{
+					"content":   helpers.FromJson[map[string]any](msg.Content),
+					"edited_at": msg.EditedAt,
+				})
+			}
+
+			// batch processing
+			if err := cache.UpdateGroupChatHistoryEntries(ctx, CHEId_updates); err != nil {
+				return
+			}
+
+			// acknowledge messages
+			if err := rdb.XAck(ctx, streamName, groupName, stmsgIds...).Err(); err != nil {
+				helpers.LogError(err)
+			}
+		}
+	}()
+}
@@ -64,18 +64,40 @@ func groupMsgReactionsRemovedStreamBgWorker(rdb *redis.Client) {
 
 			msgReactionsRemoved := make(map[string][]string)
 
+			groupMembersCmds := make(map[string]*redis.StringSliceCmd)
+
+			for _, msg := range msgs {
+				groupMembersCmds[msg.ToGroup] = nil
+			}
+
+			// the members of each group, read at once
+			_, err = rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+				for groupId := range groupMembersCmds {
+					groupMembersCmds[groupId] = pipe.SMembers(ctx, fmt.Sprintf("group:%s:members", groupId))
+				}
+
+				return nil
+			})
+			if err != nil && err != redis.Nil {
+				helpers.LogError(err)
+				continue
+			}
+
 			// batch data for batch processing
 			for _, msg := range msgs {
 				msgReactionEntriesRemoved = append(msgReactionEntriesRemoved, msg.CHEId)
 
-				chatMsgReactionsRemoved[msg.FromUser+" "+msg.ToGroup] = append(chatMsgReactionsRemoved[msg.FromUser+" "+msg.ToGroup], msg.CHEId)
+				// the reaction entry is in every member's chat history
+				for _, memUser := range groupMembersCmds[msg.ToGroup].Val() {
+					chatMsgReactionsRemoved[memUser+" "+msg.ToGroup] = append(chatMsgReactionsRemoved[memUser+" "+msg.ToGroup], msg.CHEId)
+				}
 
 				msgReactionsRemoved[msg.ToMsgId] = append(msgReactionsRemoved[msg.ToMsgId], msg.FromUser)
 			}
 
 			// batch processing
 			if err := cache.RemoveGroupChatHistoryEntries(ctx, msgReactionEntriesRemoved); err != nil {
-				return
+				continue
 			}
 
 			_, err = rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
@@ -95,7 +117,7 @@ func groupMsgReactionsRemovedStreamBgWorker(rdb *redis.Client) {
 			})
 			if err != nil {
 				helpers.LogError(err)
-				return
+				continue
 			}
 
 			// acknowledge messages
@@ -10,7 +10,6 @@ import (
 	"log"
 
 	"github.com/redis/go-redis/v9"
-	"golang.org/x/sync/errgroup"
 )
 
 func groupMsgReactionsStreamBgWorker(rdb *redis.Client) {
@@ -69,24 +68,42 @@ func groupMsgReactionsStreamBgWorker(rdb *redis.Client) {
 
 			msgReactions := make(map[string][]string)
 
-			postDBExtrasFuncs := []func(context.Context) error{}
+			rxns := make([]any, len(msgs))
 
 			// batch data for batch processing
-			for _, msg := range msgs {
+			for i, msg := range msgs {
 				newMsgReactionEntries = append(newMsgReactionEntries, msg.CHEId, msg.RxnData)
 
 				chatMsgReactions[msg.FromUser+" "+msg.ToGroup] = append(chatMsgReactions[msg.FromUser+" "+msg.ToGroup], [2]any{msg.CHEId, float64(msg.CHECursor)})
 
 				msgReactions[msg.ToMsgId] = append(msgReactions[msg.ToMsgId], msg.FromUser, msg.Emoji)
 
-				postDBExtrasFuncs = append(postDBExtrasFuncs, func(ctx context.Context) error {
-					return groupChat.PostReactToMessage(ctx, msg.FromUser, msg.ToGroup, msg.ToMsgId)
-				})
+				rxns[i] = map[string]any{"reactor": msg.FromUser, "group_id": msg.ToGroup, "msg_id": msg.ToMsgId}
+			}
+
+			postRxnsToMessages, err := groupChat.PostReactToMessages(ctx, rxns)
+			if err != nil {
+				// left pending
+				continue
+			}
+
+			rxnMembers := make(map[string][]any, len(postRxnsToMessages))
+
+			for _, prm := range postRxnsToMessages {
+				rxnMembers[prm.Reactor+" "+prm.MsgId] = prm.MemberUsernames
+			}
+
+			for _, msg := range msgs {
+				for _, memUser := range rxnMembers[msg.FromUser+" "+msg.ToMsgId] {
+					memUser := memUser.(string)
+
+					chatMsgReactions[memUser+" "+msg.ToGroup] = append(chatMsgReactions[memUser+" "+msg.ToGroup], [2]any{msg.CHEId, float64(msg.CHECursor)})
+				}
 			}
 
 			// batch processing
 			if err := cache.StoreGroupChatHistoryEntries(ctx, newMsgReactionEntries); err != nil {
-				return
+				continue
 			}
 
 			_, err = rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
@@ -106,21 +123,7 @@ func groupMsgReactionsStreamBgWorker(rdb *redis.Client) {
 			})
 			if err != nil {
 				helpers.LogError(err)
-				return
-			}
-
-			eg, sharedCtx := errgroup.WithContext(ctx)
-
-			for _, fn := range postDBExtrasFuncs {
-				eg.Go(func() error {
-					fn := fn
-
-					return fn(sharedCtx)
-				})
-			}
-
-			if eg.Wait() != nil {
-				return
+				continue
 			}
 
 			// acknowledge messages
@@ -55,6 +55,7 @@ func groupUsersLeftStreamBgWorker(rdb *redis.Client) {
 				msg.OldMember = stmsg.Values["oldMember"].(string)
 				msg.OldMemberCHE = helpers.FromJson[appTypes.BinableMap](stmsg.Values["oldMemberCHE"].(string))
 				msg.MemInfo = stmsg.Values["memInfo"].(string)
+				msg.NewOwner, _ = stmsg.Values["newOwner"].(string)
 
 				msgs = append(msgs, msg)
 
@@ -64,6 +65,8 @@ func groupUsersLeftStreamBgWorker(rdb *redis.Client) {
 
 			groupOldMembers := make(map[string][]any, msgsLen)
 
+			groupNewOwners := make(map[string][]any)
+
 			newGroupActivityEntries := []string{}
 
 			chatGroupActivities := make(map[string][][2]any)
@@ -72,6 +75,10 @@ func groupUsersLeftStreamBgWorker(rdb *redis.Client) {
 			for i, msg := range msgs {
 				groupOldMembers[msg.GroupId] = append(groupOldMembers[msg.GroupId], msg.OldMember)
 
+				if msg.NewOwner != "" {
+					groupNewOwners[msg.GroupId] = append(groupNewOwners[msg.GroupId], msg.NewOwner)
+				}
+
 				gactche := msg.OldMemberCHE
 
 				CHEId := gactche["che_id"].(string)
@@ -108,6 +115,12 @@ func groupUsersLeftStreamBgWorker(rdb *redis.Client) {
 			_, err = rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
 				for groupId, oldMembers := range groupOldMembers {
 					cache.RemoveGroupMembers(pipe, ctx, groupId, oldMembers)
+					cache.RemoveGroupAdmins(pipe, ctx, groupId, oldMembers)
+				}
+
+				// a group's owner is, as well, an admin
+				for groupId, newOwners := range groupNewOwners {
+					cache.StoreGroupAdmins(pipe, ctx, groupId, newOwners)
 				}
 
 				for ownerUserGroupId, CHEId_score_Pairs := range chatGroupActivities {
@@ -0,0 +1,238 @@
+package backgroundWorkers
+
+import (
+	"context"
+	"fmt"
+	"i9chat/src/appTypes"
+	"i9chat/src/cache"
+	"i9chat/src/helpers"
+	groupChat "i9chat/src/models/chatModel/groupChatModel"
+	"i9chat/src/services/cloudStorageService"
+	"i9chat/src/services/eventStreamService/eventTypes"
+	"log"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// the number of a deleted group's chat entries removed at once
+const deletedGroupEntriesBatchSize = 500
+
+func groupsDeletedStreamBgWorker(rdb *redis.Client) {
+	var (
+		streamName   = "groups_deleted"
+		groupName    = "group_deleted_listeners"
+		consumerName = "worker-1"
+	)
+
+	ctx := context.Background()
+
+	err := rdb.XGroupCreateMkStream(ctx, streamName, groupName, "$").Err()
+	if err != nil && (err.Error() != "BUSYGROUP Consumer Group name already exists") {
+		helpers.LogError(err)
+		log.Fatal()
+	}
+
+	go func() {
+		for {
+			streams, err := rdb.XReadGroup(ctx, &redis.XReadGroupArgs{
+				Group:    groupName,
+				Consumer: consumerName,
+				Streams:  []string{streamName, ">"},
+				Count:    50,
+				Block:    0,
+			}).Result()
+
+			if err != nil {
+				helpers.LogError(err)
+				continue
+			}
+
+			var stmsgIds []string
+			var msgs []eventTypes.GroupDeletedEvent
+
+			for _, stmsg := range streams[0].Messages {
+				stmsgIds = append(stmsgIds, stmsg.ID)
+
+				var msg eventTypes.GroupDeletedEvent
+
+				msg.GroupId = stmsg.Values["groupId"].(string)
+				msg.DeleterUser = stmsg.Values["deleterUser"].(string)
+				msg.DeleterUserCHE = helpers.FromJson[appTypes.BinableMap](stmsg.Values["deleterUserCHE"].(string))
+				msg.MemberUserCHE = helpers.FromJson[appTypes.BinableMap](stmsg.Values["memberUserCHE"].(string))
+				msg.FormerMembers = helpers.FromJson[appTypes.BinableSlice](stmsg.Values["formerMembers"].(string))
+				msg.PictureCloudName = stmsg.Values["pictureCloudName"].(string)
+				msg.Archive = stmsg.Values["archive"].(string) != "0"
+
+				msgs = append(msgs, msg)
+			}
+
+			deletedGroups := []string{}
+
+			archivedGroups := []string{}
+
+			removedGroups := []string{}
+
+			removedGroupChatOwners := make(map[string][]any)
+
+			newGroupActivityEntries := []string{}
+
+			chatGroupActivities := make(map[string][][2]any)
+
+			// batch data for batch processing
+			for _, msg := range msgs {
+				deletedGroups = append(deletedGroups, msg.GroupId)
+
+				// archived chats are kept, read-only, with the activity of the deletion
+				if msg.Archive {
+					archivedGroups = append(archivedGroups, msg.GroupId)
+
+					for _, gactche := range []map[string]any{msg.DeleterUserCHE, msg.MemberUserCHE} {
+						newGroupActivityEntries = append(newGroupActivityEntries, gactche["che_id"].(string), helpers.ToMsgPack(gactche))
+					}
+
+					chatGroupActivities[msg.DeleterUser+" "+msg.GroupId] = append(chatGroupActivities[msg.DeleterUser+" "+msg.GroupId], [2]any{msg.DeleterUserCHE["che_id"], msg.DeleterUserCHE["cursor"]})
+
+					for _, fmem := range msg.FormerMembers {
+						fmem := fmem.(string)
+
+						chatGroupActivities[fmem+" "+msg.GroupId] = append(chatGroupActivities[fmem+" "+msg.GroupId], [2]any{msg.MemberUserCHE["che_id"], msg.MemberUserCHE["cursor"]})
+					}
+
+					continue
+				}
+
+				// removed chats' entries are deleted in batches, along with their cached data and media
+				for {
+					deletedEntries, err := groupChat.DeleteEntriesBgDBOper(ctx, msg.GroupId, deletedGroupEntriesBatchSize)
+					if err != nil {
+						return
+					}
+
+					if len(deletedEntries.CHEIds) == 0 {
+						break
+					}
+
+					CHEIds := make([]string, len(deletedEntries.CHEIds))
+
+					for i, CHEId := range deletedEntries.CHEIds {
+						CHEIds[i] = CHEId.(string)
+					}
+
+					if err := cache.RemoveGroupChatHistoryEntries(ctx, CHEIds); err != nil {
+						return
+					}
+
+					// the deleted messages are removed from the starred messages of their starrers
+					starrersMsgIds := make(map[string][]any)
+
+					for _, star := range deletedEntries.Stars {
+						star := star.(map[string]any)
+
+						for _, starrer := range star["starrers"].([]any) {
+							starrersMsgIds[starrer.(string)] = append(starrersMsgIds[starrer.(string)], star["msg_id"])
+						}
+					}
+
+					_, err = rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+						cache.RemoveGroupMessagesData(pipe, ctx, msg.GroupId, deletedEntries.MsgIds)
+
+						for starrer, msgIds := range starrersMsgIds {
+							cache.RemoveUserStarredMessages(pipe, ctx, starrer, msgIds)
+						}
+
+						return nil
+					})
+					if err != nil {
+						helpers.LogError(err)
+						return
+					}
+
+					for _, msgContent := range deletedEntries.MsgContents {
+						cloudStorageService.DeleteMessageMedia(ctx, helpers.FromJson[map[string]any](msgContent.(string)))
+					}
+				}
+
+				chatOwners, err := groupChat.DeleteBgDBOper(ctx, msg.GroupId)
+				if err != nil {
+					return
+				}
+
+				removedGroups = append(removedGroups, msg.GroupId)
+
+				removedGroupChatOwners[msg.GroupId] = chatOwners
+
+				cloudStorageService.ScheduleMediaDeletion(ctx, cloudStorageService.PicCloudNames(msg.PictureCloudName)...)
+			}
+
+			// batch processing
+			if len(newGroupActivityEntries) != 0 {
+				if err := cache.StoreGroupChatHistoryEntries(ctx, newGroupActivityEntries); err != nil {
+					return
+				}
+			}
+
+			archivedGroupStringCmds := make(map[string]*redis.StringCmd, len(archivedGroups))
+
+			_, err = rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+				cache.RemoveDeletedGroupsData(pipe, ctx, deletedGroups)
+
+				for _, groupId := range archivedGroups {
+					archivedGroupStringCmds[groupId] = pipe.HGet(ctx, "groups", groupId)
+				}
+
+				for ownerUserGroupId, CHEId_score_Pairs := range chatGroupActivities {
+					var ownerUser, groupId string
+
+					fmt.Sscanf(ownerUserGroupId, "%s %s", &ownerUser, &groupId)
+
+					cache.StoreGroupChatHistory(pipe, ctx, ownerUser, groupId, CHEId_score_Pairs)
+				}
+
+				if len(removedGroups) != 0 {
+					cache.RemoveGroups(pipe, ctx, removedGroups)
+				}
+
+				for groupId, chatOwners := range removedGroupChatOwners {
+					for _, chatOwner := range chatOwners {
+						cache.RemoveUserGroupChat(pipe, ctx, chatOwner.(string), groupId)
+					}
+				}
+
+				return nil
+			})
+			if err != nil {
+				helpers.LogError(err)
+				return
+			}
+
+			groupUpdates := []string{}
+
+			for groupId, stringCmd := range archivedGroupStringCmds {
+				groupDataMsgPack, err := stringCmd.Result()
+				if err != nil {
+					helpers.LogError(err)
+					continue
+				}
+
+				groupData := helpers.FromMsgPack[map[string]any](groupDataMsgPack)
+
+				groupData["archived"] = true
+
+				groupUpdates = append(groupUpdates, groupId, helpers.ToMsgPack(groupData))
+			}
+
+			if len(groupUpdates) != 0 {
+				err = rdb.HSet(ctx, "groups", groupUpdates).Err()
+				if err != nil {
+					helpers.LogError(err)
+					return
+				}
+			}
+
+			// acknowledge messages
+			if err := rdb.XAck(ctx, streamName, groupName, stmsgIds...).Err(); err != nil {
+				helpers.LogError(err)
+			}
+		}
+	}()
+}
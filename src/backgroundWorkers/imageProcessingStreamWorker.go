@@ -0,0 +1,205 @@
+package backgroundWorkers
+
+import (
+	"context"
+	"i9chat/src/appTypes"
+	"i9chat/src/helpers"
+	"i9chat/src/services/chatServices/directChatService"
+	"i9chat/src/services/chatServices/groupChatService"
+	"i9chat/src/services/eventStreamService/eventTypes"
+	"i9chat/src/services/imageProcessingService"
+	"i9chat/src/services/realtimeService"
+	"i9chat/src/services/userService"
+	"log"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// how long a failed job is left pending, before it's claimed, and retried
+	imageJobRetryAfter = time.Minute
+
+	// the attempts at a job, after which it's failed for good
+	maxImageJobAttempts = 3
+)
+
+// imageProcessingStreamBgWorker processes the original images uploaded in the original upload mode,
+// and applies the produced images to the profile, group, or message they're for.
+//
+// A failed job is left pending, to be claimed and retried; and marked failed after its last attempt
+func imageProcessingStreamBgWorker(rdb *redis.Client) {
+	var (
+		streamName   = "image_processing_jobs"
+		groupName    = "image_processing_job_listeners"
+		consumerName = "worker-1"
+	)
+
+	ctx := context.Background()
+
+	err := rdb.XGroupCreateMkStream(ctx, streamName, groupName, "$").Err()
+	if err != nil && (err.Error() != "BUSYGROUP Consumer Group name already exists") {
+		helpers.LogError(err)
+		log.Fatal()
+	}
+
+	go func() {
+		for {
+			retryImageJobs(ctx, rdb, streamName, groupName, consumerName)
+
+			streams, err := rdb.XReadGroup(ctx, &redis.XReadGroupArgs{
+				Group:    groupName,
+				Consumer: consumerName,
+				Streams:  []string{streamName, ">"},
+				// images are processed one after the other, in small batches
+				Count: 10,
+				// wakes up to retry failed jobs
+				Block: imageJobRetryAfter,
+			}).Result()
+
+			if err != nil {
+				if err != redis.Nil {
+					helpers.LogError(err)
+				}
+
+				continue
+			}
+
+			var doneStmsgIds []string
+
+			for _, stmsg := range streams[0].Messages {
+				if handleImageJob(ctx, imageJobFromStreamMsg(stmsg), 1) {
+					doneStmsgIds = append(doneStmsgIds, stmsg.ID)
+				}
+			}
+
+			// acknowledge messages
+			if len(doneStmsgIds) != 0 {
+				if err := rdb.XAck(ctx, streamName, groupName, doneStmsgIds...).Err(); err != nil {
+					helpers.LogError(err)
+				}
+			}
+		}
+	}()
+}
+
+// retryImageJobs claims the failed jobs pending for long enough, and retries them
+func retryImageJobs(ctx context.Context, rdb *redis.Client, streamName, groupName, consumerName string) {
+	stmsgs, _, err := rdb.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+		Stream:   streamName,
+		Group:    groupName,
+		Consumer: consumerName,
+		MinIdle:  imageJobRetryAfter,
+		Start:    "0-0",
+		Count:    10,
+	}).Result()
+	if err != nil {
+		helpers.LogError(err)
+		return
+	}
+
+	if len(stmsgs) == 0 {
+		return
+	}
+
+	pendingJobs, err := rdb.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream:   streamName,
+		Group:    groupName,
+		Start:    stmsgs[0].ID,
+		End:      stmsgs[len(stmsgs)-1].ID,
+		Count:    int64(len(stmsgs)),
+		Consumer: consumerName,
+	}).Result()
+	if err != nil {
+		helpers.LogError(err)
+		return
+	}
+
+	// the claim counts as an attempt
+	jobAttempts := make(map[string]int64, len(pendingJobs))
+
+	for _, pj := range pendingJobs {
+		jobAttempts[pj.ID] = pj.RetryCount
+	}
+
+	var doneStmsgIds []string
+
+	for _, stmsg := range stmsgs {
+		if handleImageJob(ctx, imageJobFromStreamMsg(stmsg), jobAttempts[stmsg.ID]) {
+			doneStmsgIds = append(doneStmsgIds, stmsg.ID)
+		}
+	}
+
+	if len(doneStmsgIds) != 0 {
+		if err := rdb.XAck(ctx, streamName, groupName, doneStmsgIds...).Err(); err != nil {
+			helpers.LogError(err)
+		}
+	}
+}
+
+func imageJobFromStreamMsg(stmsg redis.XMessage) eventTypes.ImageProcessingJob {
+	var job eventTypes.ImageProcessingJob
+
+	job.Target, _ = stmsg.Values["target"].(string)
+	job.OwnerUser, _ = stmsg.Values["ownerUser"].(string)
+	job.GroupId, _ = stmsg.Values["groupId"].(string)
+	job.PartnerUser, _ = stmsg.Values["partnerUser"].(string)
+	job.MsgId, _ = stmsg.Values["msgId"].(string)
+	job.OriginalMcn, _ = stmsg.Values["originalMcn"].(string)
+
+	return job
+}
+
+// handleImageJob makes the attempt-th attempt at the job.
+// It reports whether the job is done with, either processed, or failed after its last attempt
+func handleImageJob(ctx context.Context, job eventTypes.ImageProcessingJob, attempt int64) bool {
+	cloudName, err := imageProcessingService.Process(ctx, job)
+	if err != nil {
+		helpers.LogError(err)
+
+		if attempt < maxImageJobAttempts {
+			return false
+		}
+
+		failImageJob(ctx, job)
+
+		return true
+	}
+
+	originalCloudName := imageProcessingService.OriginalPrefix + job.OriginalMcn
+
+	switch job.Target {
+	case "profile_pic":
+		userService.ApplyProcessedProfilePicture(ctx, job.OwnerUser, cloudName)
+	case "group_pic":
+		groupChatService.ApplyProcessedGroupPicture(ctx, job.GroupId, job.OwnerUser, cloudName)
+	case "direct_chat_photo":
+		directChatService.ApplyProcessedPhoto(ctx, job.OwnerUser, job.PartnerUser, job.MsgId, originalCloudName, cloudName)
+	case "group_chat_photo":
+		groupChatService.ApplyProcessedPhoto(ctx, job.GroupId, job.OwnerUser, job.MsgId, originalCloudName, cloudName)
+	}
+
+	return true
+}
+
+// failImageJob marks the photo message of the failed job, if it's for one, and lets its owner know
+func failImageJob(ctx context.Context, job eventTypes.ImageProcessingJob) {
+	originalCloudName := imageProcessingService.OriginalPrefix + job.OriginalMcn
+
+	switch job.Target {
+	case "direct_chat_photo":
+		directChatService.FailProcessedPhoto(ctx, job.OwnerUser, job.PartnerUser, job.MsgId, originalCloudName)
+	case "group_chat_photo":
+		groupChatService.FailProcessedPhoto(ctx, job.GroupId, job.OwnerUser, job.MsgId, originalCloudName)
+	}
+
+	realtimeService.SendEventMsg(job.OwnerUser, appTypes.ServerEventMsg{
+		Event: "image processing failed",
+		Data: map[string]any{
+			"target":       job.Target,
+			"group_id":     job.GroupId,
+			"chat_partner": job.PartnerUser,
+			"msg_id":       job.MsgId,
+		},
+	})
+}
@@ -0,0 +1,81 @@
+package backgroundWorkers
+
+import (
+	"context"
+	"fmt"
+	"i9chat/src/helpers"
+	"i9chat/src/services/cloudStorageService"
+	"log"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// how often the media gc worker looks for media due for deletion
+const mediaGCInterval = 10 * time.Minute
+
+// mediaGCBgWorker deletes the media whose scheduled deletion is due,
+// logging the number of objects deleted and bytes reclaimed on each run
+func mediaGCBgWorker(rdb *redis.Client) {
+	ctx := context.Background()
+
+	go func() {
+		ticker := time.NewTicker(mediaGCInterval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			var (
+				deletedCount   int
+				reclaimedBytes int64
+			)
+
+			for {
+				dueMcns, err := rdb.ZRangeByScore(ctx, cloudStorageService.MediaGCQueue, &redis.ZRangeBy{
+					Min:   "-inf",
+					Max:   fmt.Sprint(time.Now().UnixMilli()),
+					Count: 500,
+				}).Result()
+				if err != nil {
+					helpers.LogError(err)
+					break
+				}
+
+				if len(dueMcns) == 0 {
+					break
+				}
+
+				for _, mcn := range dueMcns {
+					// whichever server instance removes the media from the queue, deletes it
+					removed, err := rdb.ZRem(ctx, cloudStorageService.MediaGCQueue, mcn).Result()
+					if err != nil {
+						helpers.LogError(err)
+						continue
+					}
+
+					if removed == 0 {
+						continue
+					}
+
+					// the upload may have never been made
+					mInfo := cloudStorageService.GetMediaInfo(ctx, mcn)
+					if mInfo == nil {
+						continue
+					}
+
+					if err := cloudStorageService.DeleteCloudMedia(ctx, mcn); err != nil {
+						// retry on a later run
+						cloudStorageService.ScheduleMediaDeletion(ctx, mcn)
+						continue
+					}
+
+					deletedCount++
+					reclaimedBytes += mInfo.Size
+				}
+			}
+
+			if deletedCount > 0 {
+				log.Printf("media gc: deleted %d unreferenced objects, reclaiming %d bytes", deletedCount, reclaimedBytes)
+			}
+		}
+	}()
+}
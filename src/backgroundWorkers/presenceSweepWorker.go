@@ -0,0 +1,27 @@
+package backgroundWorkers
+
+import (
+	"context"
+	"i9chat/src/services/realtimeService"
+	"i9chat/src/services/userService"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// presenceSweepBgWorker takes offline the users whose sockets were held by server instances that are gone,
+// and so were never unregistered
+func presenceSweepBgWorker(_ *redis.Client) {
+	ctx := context.Background()
+
+	go func() {
+		ticker := time.NewTicker(realtimeService.ConnectionStaleAfter / 3)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			for _, username := range realtimeService.SweepStaleConnections(ctx) {
+				userService.GoOffline(ctx, username)
+			}
+		}
+	}()
+}
@@ -38,6 +38,46 @@ func GetGroupOnlineMembersCount(ctx context.Context, groupId string) (int, error
 	return len(onMems), nil
 }
 
+func GetGroupMembers(ctx context.Context, groupId string) ([]string, error) {
+	members, err := rdb().SMembers(ctx, fmt.Sprintf("group:%s:members", groupId)).Result()
+	if err != nil && err != redis.Nil {
+		helpers.LogError(err)
+		return nil, err
+	}
+
+	return members, nil
+}
+
+func GetGroupOnlineMembers(ctx context.Context, groupId string) ([]string, error) {
+	onMems, err := rdb().SDiff(ctx, fmt.Sprintf("group:%s:members", groupId), "offline_users_unsorted").Result()
+	if err != nil && err != redis.Nil {
+		helpers.LogError(err)
+		return nil, err
+	}
+
+	return onMems, nil
+}
+
+func IsGroupMember(ctx context.Context, groupId, username string) (bool, error) {
+	isMember, err := rdb().SIsMember(ctx, fmt.Sprintf("group:%s:members", groupId), username).Result()
+	if err != nil && err != redis.Nil {
+		helpers.LogError(err)
+		return false, err
+	}
+
+	return isMember, nil
+}
+
+func ChatExists(ctx context.Context, ownerUser, chatIdent string) (bool, error) {
+	exists, err := rdb().HExists(ctx, fmt.Sprintf("user:%s:chats", ownerUser), chatIdent).Result()
+	if err != nil && err != redis.Nil {
+		helpers.LogError(err)
+		return false, err
+	}
+
+	return exists, nil
+}
+
 func GetChat[T any](ctx context.Context, ownerUser, chatIdent string) (chat T, err error) {
 	chatMsgPack, err := rdb().HGet(ctx, fmt.Sprintf("user:%s:chats", ownerUser), chatIdent).Result()
 	if err != nil && err != redis.Nil {
@@ -87,3 +127,23 @@ func GetMsgReactions(ctx context.Context, msgId string) (map[string]string, erro
 
 	return msgReactions, nil
 }
+
+func GetDirectChatPinnedMessagesCount(ctx context.Context, ownerUser, partnerUser string) (int64, error) {
+	count, err := rdb().ZCard(ctx, fmt.Sprintf("direct_chat:owner:%s:partner:%s:pinned_messages", ownerUser, partnerUser)).Result()
+	if err != nil && err != redis.Nil {
+		helpers.LogError(err)
+		return count, err
+	}
+
+	return count, nil
+}
+
+func GetGroupPinnedMessagesCount(ctx context.Context, groupId string) (int64, error) {
+	count, err := rdb().ZCard(ctx, fmt.Sprintf("group:%s:pinned_messages", groupId)).Result()
+	if err != nil && err != redis.Nil {
+		helpers.LogError(err)
+		return count, err
+	}
+
+	return count, nil
+}
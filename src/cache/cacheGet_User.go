@@ -2,6 +2,7 @@ package cache
 
 import (
 	"context"
+	"fmt"
 	"i9chat/src/helpers"
 
 	"github.com/redis/go-redis/v9"
@@ -16,3 +17,84 @@ func GetUser[T any](ctx context.Context, username string) (user T, err error) {
 
 	return helpers.FromMsgPack[T](userMsgPack), nil
 }
+
+// GetBlockedBetween reports, for each of otherUsers, whether it and username
+// have blocked each other, in either direction
+func GetBlockedBetween(ctx context.Context, username string, otherUsers []string) (map[string]bool, error) {
+	cmds, err := rdb().Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, ou := range otherUsers {
+			pipe.ZScore(ctx, fmt.Sprintf("user:%s:blocked_users", username), ou)
+			pipe.ZScore(ctx, fmt.Sprintf("user:%s:blocked_users", ou), username)
+		}
+
+		return nil
+	})
+	if err != nil && err != redis.Nil {
+		helpers.LogError(err)
+		return nil, err
+	}
+
+	blocked := make(map[string]bool, len(otherUsers))
+
+	for i, ou := range otherUsers {
+		blocked[ou] = cmds[2*i].Err() == nil || cmds[2*i+1].Err() == nil
+	}
+
+	return blocked, nil
+}
+
+func IsBlockedBetween(ctx context.Context, userA, userB string) (bool, error) {
+	blocked, err := GetBlockedBetween(ctx, userA, []string{userB})
+	if err != nil {
+		return false, err
+	}
+
+	return blocked[userB], nil
+}
+
+// GetStarredAmong reports, for each of msgIds, whether username has starred it
+func GetStarredAmong(ctx context.Context, username string, msgIds []string) ([]bool, error) {
+	starred := make([]bool, len(msgIds))
+
+	if len(msgIds) == 0 {
+		return starred, nil
+	}
+
+	// a member not in the set is scored 0
+	starredAts, err := rdb().ZMScore(ctx, fmt.Sprintf("user:%s:starred_messages", username), msgIds...).Result()
+	if err != nil && err != redis.Nil {
+		helpers.LogError(err)
+		return nil, err
+	}
+
+	for i, at := range starredAts {
+		starred[i] = at != 0
+	}
+
+	return starred, nil
+}
+
+// GetStarredMessagesChats returns the chats of username's starred messages, msgIds
+func GetStarredMessagesChats[T any](ctx context.Context, username string, msgIds []string) ([]T, error) {
+	msgChats := make([]T, len(msgIds))
+
+	if len(msgIds) == 0 {
+		return msgChats, nil
+	}
+
+	msgChatMsgPacks, err := rdb().HMGet(ctx, fmt.Sprintf("user:%s:starred_messages:chats", username), msgIds...).Result()
+	if err != nil && err != redis.Nil {
+		helpers.LogError(err)
+		return nil, err
+	}
+
+	for i, mcmp := range msgChatMsgPacks {
+		if mcmp == nil {
+			continue
+		}
+
+		msgChats[i] = helpers.FromMsgPack[T](mcmp.(string))
+	}
+
+	return msgChats, nil
+}
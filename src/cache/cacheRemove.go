@@ -12,6 +12,16 @@ func RemoveOfflineUsers(pipe redis.Pipeliner, ctx context.Context, users []any)
 	pipe.ZRem(ctx, "offline_users", users...)
 }
 
+func RemoveUserBlockedUser(ctx context.Context, ownerUser, blockedUser string) error {
+	if err := rdb().ZRem(ctx, fmt.Sprintf("user:%s:blocked_users", ownerUser), blockedUser).Err(); err != nil {
+		helpers.LogError(err)
+
+		return err
+	}
+
+	return nil
+}
+
 func RemoveGroupMembers(pipe redis.Pipeliner, ctx context.Context, groupId string, members []any) {
 	pipe.SRem(ctx, fmt.Sprintf("group:%s:members", groupId), members...)
 }
@@ -20,6 +30,30 @@ func RemoveGroupAdmins(pipe redis.Pipeliner, ctx context.Context, groupId string
 	pipe.SRem(ctx, fmt.Sprintf("group:%s:admins", groupId), admins...)
 }
 
+// RemoveDeletedGroupsData removes the members, admins and pinned messages of deleted groups
+func RemoveDeletedGroupsData(pipe redis.Pipeliner, ctx context.Context, groupIds []string) {
+	for _, groupId := range groupIds {
+		pipe.Del(ctx, fmt.Sprintf("group:%s:members", groupId), fmt.Sprintf("group:%s:admins", groupId), fmt.Sprintf("group:%s:pinned_messages", groupId))
+	}
+}
+
+func RemoveGroups(pipe redis.Pipeliner, ctx context.Context, groupIds []string) {
+	pipe.HDel(ctx, "groups", groupIds...)
+}
+
+func RemoveUserGroupChat(pipe redis.Pipeliner, ctx context.Context, ownerUser, groupId string) {
+	pipe.HDel(ctx, fmt.Sprintf("user:%s:chats", ownerUser), groupId)
+	pipe.ZRem(ctx, fmt.Sprintf("user:%s:chats_sorted", ownerUser), groupId)
+	pipe.Del(ctx, fmt.Sprintf("group_chat:owner:%s:group_id:%s:history", ownerUser, groupId), fmt.Sprintf("chat:owner:%s:ident:%s:unread_messages", ownerUser, groupId))
+}
+
+// RemoveGroupMessagesData removes the reactions, and delivery and read receipts, of a group's messages
+func RemoveGroupMessagesData(pipe redis.Pipeliner, ctx context.Context, groupId string, msgIds []any) {
+	for _, msgId := range msgIds {
+		pipe.Del(ctx, fmt.Sprintf("message:%s:reactions", msgId), fmt.Sprintf("group:%s:msg:%s:delivered_to_users", groupId, msgId), fmt.Sprintf("group:%s:msg:%s:read_by_users", groupId, msgId))
+	}
+}
+
 func RemoveDirectChatHistoryEntries(ctx context.Context, CHEIds []string) error {
 	if err := rdb().HDel(ctx, "direct_chat_history_entries", CHEIds...).Err(); err != nil {
 		helpers.LogError(err)
@@ -41,8 +75,12 @@ func RemoveGroupChatHistoryEntries(ctx context.Context, CHEIds []string) error {
 }
 
 func RemoveDirectChatHistory(pipe redis.Pipeliner, ctx context.Context, ownerUser, partnerUser string, CHEIds []any) {
-	pipe.ZRem(ctx, fmt.Sprintf("chat:owner:%s:partner:%s:history", ownerUser, partnerUser), CHEIds...)
-	pipe.ZRem(ctx, fmt.Sprintf("chat:owner:%s:partner:%s:history", partnerUser, ownerUser), CHEIds...)
+	pipe.ZRem(ctx, fmt.Sprintf("direct_chat:owner:%s:partner:%s:history", ownerUser, partnerUser), CHEIds...)
+	pipe.ZRem(ctx, fmt.Sprintf("direct_chat:owner:%s:partner:%s:history", partnerUser, ownerUser), CHEIds...)
+}
+
+func RemoveUserDirectChatHistory(pipe redis.Pipeliner, ctx context.Context, ownerUser, partnerUser string, CHEIds []any) {
+	pipe.ZRem(ctx, fmt.Sprintf("direct_chat:owner:%s:partner:%s:history", ownerUser, partnerUser), CHEIds...)
 }
 
 func RemoveGroupChatHistory(pipe redis.Pipeliner, ctx context.Context, ownerUser, groupId string, CHEIds []any) {
@@ -56,3 +94,53 @@ func RemoveMsgReactions(pipe redis.Pipeliner, ctx context.Context, msgId string,
 func RemoveUserChatUnreadMsgs(pipe redis.Pipeliner, ctx context.Context, ownerUser, chatIdent string, readMsgs []any) {
 	pipe.SRem(ctx, fmt.Sprintf("chat:owner:%s:ident:%s:unread_messages", ownerUser, chatIdent), readMsgs...)
 }
+
+func RemoveDirectChatPinnedMessage(ctx context.Context, ownerUser, partnerUser, msgId string) error {
+	_, err := rdb().Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRem(ctx, fmt.Sprintf("direct_chat:owner:%s:partner:%s:pinned_messages", ownerUser, partnerUser), msgId)
+		pipe.ZRem(ctx, fmt.Sprintf("direct_chat:owner:%s:partner:%s:pinned_messages", partnerUser, ownerUser), msgId)
+
+		return nil
+	})
+	if err != nil {
+		helpers.LogError(err)
+
+		return err
+	}
+
+	return nil
+}
+
+func RemoveGroupPinnedMessage(ctx context.Context, groupId, msgId string) error {
+	if err := rdb().ZRem(ctx, fmt.Sprintf("group:%s:pinned_messages", groupId), msgId).Err(); err != nil {
+		helpers.LogError(err)
+
+		return err
+	}
+
+	return nil
+}
+
+// RemoveUserStarredMessages removes deleted messages from the user's starred messages
+func RemoveUserStarredMessages(pipe redis.Pipeliner, ctx context.Context, ownerUser string, msgIds []any) {
+	for _, msgId := range msgIds {
+		pipe.ZRem(ctx, fmt.Sprintf("user:%s:starred_messages", ownerUser), msgId)
+		pipe.HDel(ctx, fmt.Sprintf("user:%s:starred_messages:chats", ownerUser), msgId.(string))
+	}
+}
+
+func RemoveUserStarredMessage(ctx context.Context, ownerUser, msgId string) error {
+	_, err := rdb().TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRem(ctx, fmt.Sprintf("user:%s:starred_messages", ownerUser), msgId)
+		pipe.HDel(ctx, fmt.Sprintf("user:%s:starred_messages:chats", ownerUser), msgId)
+
+		return nil
+	})
+	if err != nil {
+		helpers.LogError(err)
+
+		return err
+	}
+
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"i9chat/src/helpers"
+	"time"
 
 	"github.com/redis/go-redis/v9"
 )
@@ -18,6 +19,19 @@ func StoreNewUsers(ctx context.Context, newUsers []string) error {
 	return nil
 }
 
+func StoreUserBlockedUser(ctx context.Context, ownerUser, blockedUser string, at int64) error {
+	if err := rdb().ZAdd(ctx, fmt.Sprintf("user:%s:blocked_users", ownerUser), redis.Z{
+		Score:  float64(at),
+		Member: blockedUser,
+	}).Err(); err != nil {
+		helpers.LogError(err)
+
+		return err
+	}
+
+	return nil
+}
+
 func StoreOfflineUsers(pipe redis.Pipeliner, ctx context.Context, user_lastSeen_Pairs map[string]int64) {
 	members := []redis.Z{}
 	membersUnsorted := []any{}
@@ -76,52 +90,61 @@ func StoreUserChatIdents(pipe redis.Pipeliner, ctx context.Context, ownerUser st
 }
 
 func StoreDirectChatHistoryEntries(ctx context.Context, newCHEs []string) error {
-	if err := rdb().HSet(ctx, "direct_chat_history_entries", newCHEs).Err(); err != nil {
-		helpers.LogError(err)
-
-		return err
-	}
-
-	return nil
+	return storeChatHistoryEntries(ctx, "direct_chat_history_entries", newCHEs)
 }
 
 func StoreGroupChatHistoryEntries(ctx context.Context, newCHEs []string) error {
-	if err := rdb().HSet(ctx, "group_chat_history_entries", newCHEs).Err(); err != nil {
-		helpers.LogError(err)
+	return storeChatHistoryEntries(ctx, "group_chat_history_entries", newCHEs)
+}
 
-		return err
+/*
+A message deleted for its owner (i.e. "delete for me") may not be in the owner's cached chat history yet.
+It is then kept, within deletedForMeMsgsTTL, among the owner's messages deleted for them,
+for its addition to the owner's chat history to be skipped
+*/
+
+const deletedForMeMsgsTTL = time.Hour
+
+// KEYS: chat history, owner's messages deleted for them.
+// ARGV: score and entry id pairs
+var storeChatHistoryScript = redis.NewScript(`
+for i = 1, #ARGV, 2 do
+	if redis.call("SISMEMBER", KEYS[2], ARGV[i + 1]) == 0 then
+		redis.call("ZADD", KEYS[1], ARGV[i], ARGV[i + 1])
+	end
+end
+return 0
+`)
+
+func storeChatHistory(pipe redis.Pipeliner, ctx context.Context, historyKey string, CHEId_score_Pairs [][2]any) {
+	args := make([]any, 0, len(CHEId_score_Pairs)*2)
+	for _, pair := range CHEId_score_Pairs {
+		args = append(args, pair[1].(float64), pair[0])
 	}
 
-	return nil
+	storeChatHistoryScript.Eval(ctx, pipe, []string{historyKey, historyKey + ":deleted_for_me"}, args...)
 }
 
 func StoreDirectChatHistory(pipe redis.Pipeliner, ctx context.Context, ownerUser, partnerUser string, CHEId_score_Pairs [][2]any) {
-	members := []redis.Z{}
-	for _, pair := range CHEId_score_Pairs {
-		CHEId := pair[0]
-
-		members = append(members, redis.Z{
-			Score:  pair[1].(float64),
-			Member: CHEId,
-		})
-	}
-
-	pipe.ZAdd(ctx, fmt.Sprintf("direct_chat:owner:%s:partner:%s:history", ownerUser, partnerUser), members...)
-	pipe.ZAdd(ctx, fmt.Sprintf("direct_chat:owner:%s:partner:%s:history", partnerUser, ownerUser), members...)
+	storeChatHistory(pipe, ctx, fmt.Sprintf("direct_chat:owner:%s:partner:%s:history", ownerUser, partnerUser), CHEId_score_Pairs)
+	storeChatHistory(pipe, ctx, fmt.Sprintf("direct_chat:owner:%s:partner:%s:history", partnerUser, ownerUser), CHEId_score_Pairs)
 }
 
 func StoreGroupChatHistory(pipe redis.Pipeliner, ctx context.Context, ownerUser, groupId string, CHEId_score_Pairs [][2]any) {
-	members := []redis.Z{}
-	for _, pair := range CHEId_score_Pairs {
-		CHEId := pair[0]
+	storeChatHistory(pipe, ctx, fmt.Sprintf("group_chat:owner:%s:group_id:%s:history", ownerUser, groupId), CHEId_score_Pairs)
+}
 
-		members = append(members, redis.Z{
-			Score:  pair[1].(float64),
-			Member: CHEId,
-		})
-	}
+func storeChatMsgsDeletedForMe(pipe redis.Pipeliner, ctx context.Context, historyKey string, CHEIds []any) {
+	pipe.SAdd(ctx, historyKey+":deleted_for_me", CHEIds...)
+	pipe.Expire(ctx, historyKey+":deleted_for_me", deletedForMeMsgsTTL)
+}
+
+func StoreDirectChatMsgsDeletedForMe(pipe redis.Pipeliner, ctx context.Context, ownerUser, partnerUser string, CHEIds []any) {
+	storeChatMsgsDeletedForMe(pipe, ctx, fmt.Sprintf("direct_chat:owner:%s:partner:%s:history", ownerUser, partnerUser), CHEIds)
+}
 
-	pipe.ZAdd(ctx, fmt.Sprintf("group_chat:owner:%s:group_id:%s:history", ownerUser, groupId), members...)
+func StoreGroupChatMsgsDeletedForMe(pipe redis.Pipeliner, ctx context.Context, ownerUser, groupId string, CHEIds []any) {
+	storeChatMsgsDeletedForMe(pipe, ctx, fmt.Sprintf("group_chat:owner:%s:group_id:%s:history", ownerUser, groupId), CHEIds)
 }
 
 func StoreGroupMsgDeliveredToUsers(pipe redis.Pipeliner, ctx context.Context, groupId, msgId string, user_deliveredAt_Pairs [][2]any) {
@@ -155,3 +178,48 @@ func StoreGroupMsgReadByUsers(pipe redis.Pipeliner, ctx context.Context, groupId
 func StoreMsgReactions(pipe redis.Pipeliner, ctx context.Context, msgId string, userWithEmojiPairs []string) {
 	pipe.HSet(ctx, fmt.Sprintf("message:%s:reactions", msgId), userWithEmojiPairs)
 }
+
+func StoreDirectChatPinnedMessage(ctx context.Context, ownerUser, partnerUser, msgId string, at int64) error {
+	_, err := rdb().Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZAdd(ctx, fmt.Sprintf("direct_chat:owner:%s:partner:%s:pinned_messages", ownerUser, partnerUser), redis.Z{Score: float64(at), Member: msgId})
+		pipe.ZAdd(ctx, fmt.Sprintf("direct_chat:owner:%s:partner:%s:pinned_messages", partnerUser, ownerUser), redis.Z{Score: float64(at), Member: msgId})
+
+		return nil
+	})
+	if err != nil {
+		helpers.LogError(err)
+
+		return err
+	}
+
+	return nil
+}
+
+func StoreGroupPinnedMessage(ctx context.Context, groupId, msgId string, at int64) error {
+	if err := rdb().ZAdd(ctx, fmt.Sprintf("group:%s:pinned_messages", groupId), redis.Z{
+		Score:  float64(at),
+		Member: msgId,
+	}).Err(); err != nil {
+		helpers.LogError(err)
+
+		return err
+	}
+
+	return nil
+}
+
+func StoreUserStarredMessage(ctx context.Context, ownerUser, msgId, msgChat string, at int64) error {
+	_, err := rdb().TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZAdd(ctx, fmt.Sprintf("user:%s:starred_messages", ownerUser), redis.Z{Score: float64(at), Member: msgId})
+		pipe.HSet(ctx, fmt.Sprintf("user:%s:starred_messages:chats", ownerUser), msgId, msgChat)
+
+		return nil
+	})
+	if err != nil {
+		helpers.LogError(err)
+
+		return err
+	}
+
+	return nil
+}
@@ -2,58 +2,199 @@ package cache
 
 import (
 	"context"
+	"fmt"
 	"i9chat/src/helpers"
 	"maps"
+	"time"
+
+	"github.com/redis/go-redis/v9"
 )
 
-func UpdateDirectMessageDelivery(ctx context.Context, CHEId string, updateKVMap map[string]any) error {
-	msgDataMsgPack, err := rdb().HGet(ctx, "direct_chat_history_entries", CHEId).Result()
-	if err != nil {
-		helpers.LogError(err)
-		return err
-	}
+/*
+A chat history entry's cached data is updated by several workers (acks, edits, deletions, processed media),
+each updating some of its fields. An entry's data is only replaced if it hasn't changed since it was read;
+otherwise its updates are reapplied to its latest data, so that no worker's update is lost.
+
+An entry's updates that arrive before the entry is cached are kept pending, by the entry's id,
+for the entry's caching to apply them. pendingCHEUpdatesTTL only cleans up updates of entries never cached
+*/
 
-	msgData := helpers.FromMsgPack[map[string]any](msgDataMsgPack)
+const pendingCHEUpdatesTTL = time.Hour
+
+func pendingCHEUpdatesKey(entriesKey, CHEId string) string {
+	return fmt.Sprintf("%s:pending_updates:%s", entriesKey, CHEId)
+}
+
+// KEYS: entries hash, entry's pending updates.
+// ARGV: entry id, entry's data as read ("" if not cached), entry's updated data, pending updates ttl, entry's updates...
+var setCHEDataScript = redis.NewScript(`
+local current = redis.call("HGET", KEYS[1], ARGV[1])
+if current == false then
+	if ARGV[2] ~= "" then
+		return 1
+	end
+	for i = 5, #ARGV do
+		redis.call("RPUSH", KEYS[2], ARGV[i])
+	end
+	redis.call("EXPIRE", KEYS[2], ARGV[4])
+	return 1
+end
+if current ~= ARGV[2] then
+	return 0
+end
+redis.call("HSET", KEYS[1], ARGV[1], ARGV[3])
+return 1
+`)
+
+// KEYS: entries hash, each entry's pending updates.
+// ARGV: entry id and entry's data pairs
+var storeCHEsScript = redis.NewScript(`
+local pending = {}
+for i = 1, #ARGV, 2 do
+	local pendingKey = KEYS[(i + 1) / 2 + 1]
+	redis.call("HSET", KEYS[1], ARGV[i], ARGV[i + 1])
+	pending[#pending + 1] = redis.call("LRANGE", pendingKey, 0, -1)
+	redis.call("DEL", pendingKey)
+end
+return pending
+`)
+
+// applyCHEUpdate applies updateKVMap to the entry's data, CHEData
+func applyCHEUpdate(CHEData, updateKVMap map[string]any) {
+	// a deleted message's content stays the deletion tombstone,
+	// whether its edit or media processing is applied before or after its deletion
+	if CHEData["deleted_at"] != nil {
+		updateKVMap = maps.Clone(updateKVMap)
+
+		delete(updateKVMap, "content")
+		delete(updateKVMap, "edited_at")
+	} else if updateKVMap["deleted_at"] != nil {
+		delete(CHEData, "edited_at")
+	}
 
 	// if a client skips the "delivered" ack, and acks "read"
 	// it means the message is delivered and read at the same time
-	if updateKVMap["read_at"] != nil && msgData["delivered_at"] == nil {
-		msgData["delivered_at"] = updateKVMap["read_at"]
+	if updateKVMap["read_at"] != nil && CHEData["delivered_at"] == nil {
+		CHEData["delivered_at"] = updateKVMap["read_at"]
 	}
 
-	maps.Copy(msgData, updateKVMap)
+	maps.Copy(CHEData, updateKVMap)
+}
 
-	err = rdb().HSet(ctx, "direct_chat_history_entries", CHEId, helpers.ToMsgPack(msgData)).Err()
-	if err != nil {
-		helpers.LogError(err)
-		return err
+func updateChatHistoryEntries(ctx context.Context, entriesKey string, CHEId_updates map[string][]map[string]any) error {
+	CHEId_updates = maps.Clone(CHEId_updates)
+
+	for len(CHEId_updates) != 0 {
+		CHEId_StringCmd := make(map[string]*redis.StringCmd, len(CHEId_updates))
+
+		_, err := rdb().Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			for CHEId := range CHEId_updates {
+				CHEId_StringCmd[CHEId] = pipe.HGet(ctx, entriesKey, CHEId)
+			}
+
+			return nil
+		})
+		if err != nil && err != redis.Nil {
+			helpers.LogError(err)
+			return err
+		}
+
+		CHEId_Cmd := make(map[string]*redis.Cmd, len(CHEId_updates))
+
+		_, err = rdb().Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			for CHEId, updates := range CHEId_updates {
+				CHEDataMsgPack := CHEId_StringCmd[CHEId].Val()
+
+				args := []any{CHEId, CHEDataMsgPack, "", int(pendingCHEUpdatesTTL.Seconds())}
+
+				if CHEDataMsgPack == "" {
+					for _, updateKVMap := range updates {
+						args = append(args, helpers.ToMsgPack(updateKVMap))
+					}
+				} else {
+					CHEData := helpers.FromMsgPack[map[string]any](CHEDataMsgPack)
+
+					for _, updateKVMap := range updates {
+						applyCHEUpdate(CHEData, updateKVMap)
+					}
+
+					args[2] = helpers.ToMsgPack(CHEData)
+				}
+
+				CHEId_Cmd[CHEId] = setCHEDataScript.Eval(ctx, pipe, []string{entriesKey, pendingCHEUpdatesKey(entriesKey, CHEId)}, args...)
+			}
+
+			return nil
+		})
+		if err != nil {
+			helpers.LogError(err)
+			return err
+		}
+
+		// entries changed since they were read are retried
+		for CHEId, cmd := range CHEId_Cmd {
+			if set, _ := cmd.Int(); set == 1 {
+				delete(CHEId_updates, CHEId)
+			}
+		}
 	}
 
 	return nil
 }
 
-func UpdateGroupMessageDelivery(ctx context.Context, CHEId string, updateKVMap map[string]any) error {
-	msgDataMsgPack, err := rdb().HGet(ctx, "group_chat_history_entries", CHEId).Result()
-	if err != nil {
-		helpers.LogError(err)
-		return err
+// storeChatHistoryEntries stores the new entries, newCHEs, and applies their pending updates
+func storeChatHistoryEntries(ctx context.Context, entriesKey string, newCHEs []string) error {
+	if len(newCHEs) == 0 {
+		return nil
 	}
 
-	msgData := helpers.FromMsgPack[map[string]any](msgDataMsgPack)
+	keys := []string{entriesKey}
+	args := make([]any, 0, len(newCHEs))
 
-	// if a client skips the "delivered" ack, and acks "read"
-	// it means the message is delivered and read at the same time
-	if updateKVMap["read_at"] != nil && msgData["delivered_at"] == nil {
-		msgData["delivered_at"] = updateKVMap["read_at"]
+	for i := 0; i < len(newCHEs); i += 2 {
+		keys = append(keys, pendingCHEUpdatesKey(entriesKey, newCHEs[i]))
+		args = append(args, newCHEs[i], newCHEs[i+1])
 	}
 
-	maps.Copy(msgData, updateKVMap)
-
-	err = rdb().HSet(ctx, "group_chat_history_entries", CHEId, helpers.ToMsgPack(msgData)).Err()
+	pending, err := storeCHEsScript.Run(ctx, rdb(), keys, args...).Slice()
 	if err != nil {
 		helpers.LogError(err)
 		return err
 	}
 
-	return nil
+	CHEId_updates := make(map[string][]map[string]any)
+
+	for i, updates := range pending {
+		for _, updateMsgPack := range updates.([]any) {
+			CHEId := newCHEs[i*2]
+
+			CHEId_updates[CHEId] = append(CHEId_updates[CHEId], helpers.FromMsgPack[map[string]any](updateMsgPack.(string)))
+		}
+	}
+
+	return updateChatHistoryEntries(ctx, entriesKey, CHEId_updates)
+}
+
+func UpdateDirectChatHistoryEntries(ctx context.Context, CHEId_updates map[string][]map[string]any) error {
+	return updateChatHistoryEntries(ctx, "direct_chat_history_entries", CHEId_updates)
+}
+
+func UpdateGroupChatHistoryEntries(ctx context.Context, CHEId_updates map[string][]map[string]any) error {
+	return updateChatHistoryEntries(ctx, "group_chat_history_entries", CHEId_updates)
+}
+
+func UpdateDirectMessageDelivery(ctx context.Context, CHEId string, updateKVMap map[string]any) error {
+	return UpdateDirectChatHistoryEntries(ctx, map[string][]map[string]any{CHEId: {updateKVMap}})
+}
+
+func UpdateGroupMessageDelivery(ctx context.Context, CHEId string, updateKVMap map[string]any) error {
+	return UpdateGroupChatHistoryEntries(ctx, map[string][]map[string]any{CHEId: {updateKVMap}})
+}
+
+func UpdateDirectChatHistoryEntry(ctx context.Context, CHEId string, updateKVMap map[string]any) error {
+	return UpdateDirectChatHistoryEntries(ctx, map[string][]map[string]any{CHEId: {updateKVMap}})
+}
+
+func UpdateGroupChatHistoryEntry(ctx context.Context, CHEId string, updateKVMap map[string]any) error {
+	return UpdateGroupChatHistoryEntries(ctx, map[string][]map[string]any{CHEId: {updateKVMap}})
 }
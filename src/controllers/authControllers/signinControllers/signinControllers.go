@@ -22,7 +22,7 @@ func Signin(c fiber.Ctx) error {
 		return err
 	}
 
-	respData, authJwt, err := signinService.Signin(ctx, body.EmailOrUsername, body.Password)
+	respData, authJwt, err := signinService.Signin(ctx, body.EmailOrUsername, body.Password, c.Get("User-Agent"), c.IP())
 	if err != nil {
 		return err
 	}
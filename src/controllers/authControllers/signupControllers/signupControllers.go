@@ -84,7 +84,7 @@ func RegisterUser(c fiber.Ctx) error {
 		return err
 	}
 
-	respData, authJwt, err := signupService.RegisterUser(ctx, sessionData, body.Username, body.Password, body.Bio)
+	respData, authJwt, err := signupService.RegisterUser(ctx, sessionData, body.Username, body.Password, body.Bio, c.Get("User-Agent"), c.IP())
 	if err != nil {
 		return err
 	}
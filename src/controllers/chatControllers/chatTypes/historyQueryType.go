@@ -0,0 +1,33 @@
+package chatTypes
+
+import (
+	"i9chat/src/helpers"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/go-ozzo/ozzo-validation/v4/is"
+)
+
+// HistoryQuery is the query of a chat history request.
+// The window of entries read, relative to the cursor, or the message, MsgId, is set by Mode
+type HistoryQuery struct {
+	Limit  int64   `query:"limit"`
+	Cursor float64 `query:"cursor"`
+	Mode   string  `query:"mode"`
+	MsgId  string  `query:"msg_id"`
+}
+
+func (q HistoryQuery) Validate() error {
+	err := validation.ValidateStruct(&q,
+		validation.Field(&q.Mode,
+			validation.In("before", "after", "around").Error(`invalid mode; use one of ["before", "after", "around"]`),
+		),
+		validation.Field(&q.MsgId, is.UUID),
+		validation.Field(&q.Cursor,
+			validation.When((q.Mode == "after" || q.Mode == "around") && q.MsgId == "",
+				validation.Required.Error("a cursor or msg_id is required in this mode"),
+			),
+		),
+	)
+
+	return helpers.ValidationError(err, "historyQueryType.go", "HistoryQuery")
+}
@@ -1,9 +1,7 @@
 package chatTypes
 
 import (
-	"context"
 	"fmt"
-	"i9chat/src/services/cloudStorageService"
 	"regexp"
 	"slices"
 
@@ -32,9 +30,20 @@ func (m MsgContent) Validate() error {
 		validation.Field(&m.MediaCloudName,
 			validation.When(m.Type == "text", validation.Nil.Error("invalid property for the specified type")).Else(
 				validation.Required,
-				validation.Match(regexp.MustCompile(
-					`^blur_placeholder:uploads/chat/[\w-/]+\w actual:uploads/chat/[\w-/]+\w$`,
-				)).Error("invalid media cloud name"),
+				validation.When(m.Type == "photo",
+					// or a photo original, processed by the server
+					validation.Match(regexp.MustCompile(
+						`^(blur_placeholder:uploads/chat/photo/[\w-/]+\w actual:uploads/chat/photo/[\w-/]+\w|original:uploads/chat/photo/[\w-/]+\w)$`,
+					)).Error("invalid media cloud name"),
+				).Else(validation.When(m.Type == "video",
+					validation.Match(regexp.MustCompile(
+						`^blur_placeholder:uploads/chat/video/[\w-/]+\w actual:uploads/chat/video/[\w-/]+\w$`,
+					)).Error("invalid media cloud name"),
+				).Else(
+					validation.Match(regexp.MustCompile(
+						fmt.Sprintf(`^uploads/chat/%s/[\w-/]+\w$`, regexp.QuoteMeta(m.Type)),
+					)).Error("invalid media cloud name"),
+				)),
 			),
 		),
 		validation.Field(&m.msgProps, validation.Required),
@@ -44,58 +53,5 @@ func (m MsgContent) Validate() error {
 		validation.Field(&m.Name, validation.When(m.Type != "file", validation.Nil.Error("invalid property for the specified type")).Else(validation.Required)),
 	)
 
-	if err != nil {
-		return err
-	}
-
-	if mediaCloudName := m.MediaCloudName; mediaCloudName != nil {
-		go func(msgType, mediaCloudName string) {
-
-			ctx := context.Background()
-
-			switch msgType {
-			case "photo", "video":
-				var (
-					mcnBlur   string
-					mcnActual string
-				)
-
-				fmt.Sscanf(mediaCloudName, "blur_placeholder:%s actual:%s", &mcnBlur, &mcnActual)
-
-				if mInfo := cloudStorageService.GetMediaInfo(ctx, mcnBlur); mInfo != nil {
-					if mInfo.Size < 1*1024 || mInfo.Size > 100*1024 {
-						cloudStorageService.DeleteCloudMedia(ctx, mcnBlur)
-					}
-				}
-
-				if mInfo := cloudStorageService.GetMediaInfo(ctx, mcnActual); mInfo != nil {
-					if msgType == "photo" && mInfo.Size < 1*1024 || mInfo.Size > 10*1024*1024 {
-						cloudStorageService.DeleteCloudMedia(ctx, mcnActual)
-					} else if mInfo.Size < 1*1024 || mInfo.Size > 40*1024*1024 {
-						cloudStorageService.DeleteCloudMedia(ctx, mcnActual)
-					}
-				}
-			case "voice":
-				if mInfo := cloudStorageService.GetMediaInfo(ctx, mediaCloudName); mInfo != nil {
-					if mInfo.Size < 500 || mInfo.Size > 10*1024*1024 {
-						cloudStorageService.DeleteCloudMedia(ctx, mediaCloudName)
-					}
-				}
-			case "audio":
-				if mInfo := cloudStorageService.GetMediaInfo(ctx, mediaCloudName); mInfo != nil {
-					if mInfo.Size < 500 || mInfo.Size > 20*1024*1024 {
-						cloudStorageService.DeleteCloudMedia(ctx, mediaCloudName)
-					}
-				}
-			default:
-				if mInfo := cloudStorageService.GetMediaInfo(ctx, mediaCloudName); mInfo != nil {
-					if mInfo.Size < 500 || mInfo.Size > 50*1024*1024 {
-						cloudStorageService.DeleteCloudMedia(ctx, mediaCloudName)
-					}
-				}
-			}
-		}(m.Type, *mediaCloudName)
-	}
-
-	return nil
+	return err
 }
@@ -1,6 +1,7 @@
 package chatUploadControllers
 
 import (
+	"i9chat/src/appTypes"
 	"i9chat/src/services/chatServices/chatUploadService"
 
 	"github.com/gofiber/fiber/v3"
@@ -9,6 +10,8 @@ import (
 func AuthorizeUpload(c fiber.Ctx) error {
 	ctx := c.Context()
 
+	clientUser := c.Locals("user").(appTypes.ClientUser)
+
 	var body authorizeUploadBody
 
 	err := c.Bind().MsgPack(&body)
@@ -20,7 +23,7 @@ func AuthorizeUpload(c fiber.Ctx) error {
 		return err
 	}
 
-	respData, err := chatUploadService.Authorize(ctx, body.MsgType, body.MediaMIME)
+	respData, err := chatUploadService.Authorize(ctx, clientUser.Username, body.MsgType, body.MediaMIME)
 	if err != nil {
 		return err
 	}
@@ -31,6 +34,8 @@ func AuthorizeUpload(c fiber.Ctx) error {
 func AuthorizeVisualUpload(c fiber.Ctx) error {
 	ctx := c.Context()
 
+	clientUser := c.Locals("user").(appTypes.ClientUser)
+
 	var body authorizeVisualUploadBody
 
 	err := c.Bind().MsgPack(&body)
@@ -42,7 +47,31 @@ func AuthorizeVisualUpload(c fiber.Ctx) error {
 		return err
 	}
 
-	respData, err := chatUploadService.AuthorizeVisual(ctx, body.MsgType, body.MediaMIME)
+	respData, err := chatUploadService.AuthorizeVisual(ctx, clientUser.Username, body.MsgType, body.MediaMIME)
+	if err != nil {
+		return err
+	}
+
+	return c.MsgPack(respData)
+}
+
+func AuthorizeOriginalUpload(c fiber.Ctx) error {
+	ctx := c.Context()
+
+	clientUser := c.Locals("user").(appTypes.ClientUser)
+
+	var body authorizeOriginalUploadBody
+
+	err := c.Bind().MsgPack(&body)
+	if err != nil {
+		return err
+	}
+
+	if err = body.Validate(); err != nil {
+		return err
+	}
+
+	respData, err := chatUploadService.AuthorizeOriginal(ctx, clientUser.Username, body.MediaMIME)
 	if err != nil {
 		return err
 	}
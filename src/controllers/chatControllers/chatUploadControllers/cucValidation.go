@@ -153,3 +153,37 @@ func (b authorizeVisualUploadBody) Validate() error {
 
 	return helpers.ValidationError(err, "cucValidation.go", "authorizeVisualUploadBody")
 }
+
+type authorizeOriginalUploadBody struct {
+	MsgType   string `msgpack:"msg_type"`
+	MediaMIME string `msgpack:"media_mime"`
+	MediaSize int64  `msgpack:"media_size"`
+}
+
+func (b authorizeOriginalUploadBody) Validate() error {
+
+	err := validation.ValidateStruct(&b,
+		validation.Field(&b.MsgType,
+			validation.Required,
+			validation.In("photo").Error("invalid message type; only photo originals are processed"),
+		),
+		validation.Field(&b.MediaMIME,
+			validation.Required,
+			validation.In("image/jpeg", "image/png", "image/webp").Error(`unsupported media_mime for photo original; use one of ["image/jpeg", "image/png", "image/webp"]`),
+		),
+		validation.Field(&b.MediaSize,
+			validation.Required,
+			validation.By(func(value any) error {
+				val := value.(int64)
+
+				if val < 1*1024 || val > 20*1024*1024 {
+					return errors.New("photo original media_size out of range; min: 1KiB; max: 20MeB")
+				}
+
+				return nil
+			}),
+		),
+	)
+
+	return helpers.ValidationError(err, "cucValidation.go", "authorizeOriginalUploadBody")
+}
@@ -44,3 +44,111 @@ func (d directChatMsgsAck) Validate() error {
 
 	return helpers.ValidationError(err, "dccValidation.go", "directChatMsgAck")
 }
+
+type editDirectChatMsg struct {
+	PartnerUsername string `msgpack:"partnerUsername"`
+	MsgId           string `msgpack:"msgId"`
+	NewText         string `msgpack:"newText"`
+	At              int64  `msgpack:"at"`
+}
+
+func (d editDirectChatMsg) Validate() error {
+	err := validation.ValidateStruct(&d,
+		validation.Field(&d.PartnerUsername, validation.Required),
+		validation.Field(&d.MsgId, validation.Required, is.UUID),
+		validation.Field(&d.NewText, validation.Required),
+		validation.Field(&d.At, validation.Required, validation.Max(time.Now().UTC().UnixMilli()).Error("invalid future time")),
+	)
+
+	return helpers.ValidationError(err, "dccValidation.go", "editDirectChatMsg")
+}
+
+type deleteDirectChatMsgs struct {
+	PartnerUsername string `msgpack:"partnerUsername"`
+	MsgIds          []any  `msgpack:"msgIds"`
+}
+
+func (d deleteDirectChatMsgs) Validate() error {
+	err := validation.ValidateStruct(&d,
+		validation.Field(&d.PartnerUsername, validation.Required),
+		validation.Field(&d.MsgIds, validation.Required, validation.Each(is.UUID)),
+	)
+
+	return helpers.ValidationError(err, "dccValidation.go", "deleteDirectChatMsgs")
+}
+
+type deleteDirectChatMsgForEveryone struct {
+	PartnerUsername string `msgpack:"partnerUsername"`
+	MsgId           string `msgpack:"msgId"`
+	At              int64  `msgpack:"at"`
+}
+
+func (d deleteDirectChatMsgForEveryone) Validate() error {
+	err := validation.ValidateStruct(&d,
+		validation.Field(&d.PartnerUsername, validation.Required),
+		validation.Field(&d.MsgId, validation.Required, is.UUID),
+		validation.Field(&d.At, validation.Required, validation.Max(time.Now().UTC().UnixMilli()).Error("invalid future time")),
+	)
+
+	return helpers.ValidationError(err, "dccValidation.go", "deleteDirectChatMsgForEveryone")
+}
+
+type reactToDirectChatMsg struct {
+	PartnerUsername string `msgpack:"partnerUsername"`
+	MsgId           string `msgpack:"msgId"`
+	Emoji           string `msgpack:"emoji"`
+	At              int64  `msgpack:"at"`
+}
+
+func (d reactToDirectChatMsg) Validate() error {
+	err := validation.ValidateStruct(&d,
+		validation.Field(&d.PartnerUsername, validation.Required),
+		validation.Field(&d.MsgId, validation.Required, is.UUID),
+		validation.Field(&d.Emoji, validation.Required, validation.RuneLength(1, 10)),
+		validation.Field(&d.At, validation.Required, validation.Max(time.Now().UTC().UnixMilli()).Error("invalid future time")),
+	)
+
+	return helpers.ValidationError(err, "dccValidation.go", "reactToDirectChatMsg")
+}
+
+type removeReactionToDirectChatMsg struct {
+	PartnerUsername string `msgpack:"partnerUsername"`
+	MsgId           string `msgpack:"msgId"`
+}
+
+func (d removeReactionToDirectChatMsg) Validate() error {
+	err := validation.ValidateStruct(&d,
+		validation.Field(&d.PartnerUsername, validation.Required),
+		validation.Field(&d.MsgId, validation.Required, is.UUID),
+	)
+
+	return helpers.ValidationError(err, "dccValidation.go", "removeReactionToDirectChatMsg")
+}
+
+type pinDirectChatMsg struct {
+	PartnerUsername string `msgpack:"partnerUsername"`
+	MsgId           string `msgpack:"msgId"`
+}
+
+func (d pinDirectChatMsg) Validate() error {
+	err := validation.ValidateStruct(&d,
+		validation.Field(&d.PartnerUsername, validation.Required),
+		validation.Field(&d.MsgId, validation.Required, is.UUID),
+	)
+
+	return helpers.ValidationError(err, "dccValidation.go", "pinDirectChatMsg")
+}
+
+type directChatTyping struct {
+	PartnerUsername string `msgpack:"partnerUsername"`
+	State           string `msgpack:"state"`
+}
+
+func (d directChatTyping) Validate() error {
+	err := validation.ValidateStruct(&d,
+		validation.Field(&d.PartnerUsername, validation.Required),
+		validation.Field(&d.State, validation.Required, validation.In("typing", "recording", "stopped")),
+	)
+
+	return helpers.ValidationError(err, "dccValidation.go", "directChatTyping")
+}
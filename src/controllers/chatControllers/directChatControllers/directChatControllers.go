@@ -3,8 +3,11 @@ package directChatControllers
 import (
 	"context"
 	"i9chat/src/appTypes"
+	"i9chat/src/controllers/chatControllers/chatTypes"
 	"i9chat/src/helpers"
+	"i9chat/src/services/chatServices/chatUploadService"
 	"i9chat/src/services/chatServices/directChatService"
+	"i9chat/src/services/cloudStorageService"
 
 	"github.com/gofiber/fiber/v3"
 	"github.com/vmihailenco/msgpack/v5"
@@ -15,16 +18,30 @@ func GetDirectChatHistory(c fiber.Ctx) error {
 
 	clientUser := c.Locals("user").(appTypes.ClientUser)
 
-	var query struct {
-		Limit  int64
-		Cursor float64
-	}
+	var query chatTypes.HistoryQuery
 
 	if err := c.Bind().Query(&query); err != nil {
 		return err
 	}
 
-	respData, err := directChatService.GetChatHistory(ctx, clientUser.Username, c.Params("partner_username"), helpers.CoalesceInt(query.Limit, 50), query.Cursor)
+	if err := query.Validate(); err != nil {
+		return err
+	}
+
+	respData, err := directChatService.GetChatHistory(ctx, clientUser.Username, c.Params("partner_username"), query.Mode, query.MsgId, helpers.CoalesceInt(query.Limit, 50), query.Cursor)
+	if err != nil {
+		return err
+	}
+
+	return c.MsgPack(respData)
+}
+
+func GetPinnedMessages(c fiber.Ctx) error {
+	ctx := c.Context()
+
+	clientUser := c.Locals("user").(appTypes.ClientUser)
+
+	respData, err := directChatService.GetPinnedMessages(ctx, clientUser.Username, c.Params("partner_username"))
 	if err != nil {
 		return err
 	}
@@ -40,6 +57,10 @@ func SendMessage(ctx context.Context, clientUsername string, actionData msgpack.
 		return nil, err
 	}
 
+	if err := chatUploadService.FinalizeMessageMedia(ctx, clientUsername, cloudStorageService.DirectMediaChat(clientUsername, acd.PartnerUsername), acd.Msg.Type, acd.Msg.MediaCloudName); err != nil {
+		return nil, err
+	}
+
 	return directChatService.SendMessage(ctx, clientUsername, acd.PartnerUsername, acd.ReplyTargetMsgId, acd.IsReply, helpers.ToJson(acd.Msg), acd.At)
 }
 
@@ -64,3 +85,91 @@ func AckMessagesRead(ctx context.Context, clientUsername string, actionData msgp
 
 	return directChatService.AckMessagesRead(ctx, clientUsername, acd.PartnerUsername, acd.MsgIds, acd.At)
 }
+
+func EditMessage(ctx context.Context, clientUsername string, actionData msgpack.RawMessage) (any, error) {
+
+	acd := helpers.FromBtMsgPack[editDirectChatMsg](actionData)
+
+	if err := acd.Validate(); err != nil {
+		return nil, err
+	}
+
+	return directChatService.EditMessage(ctx, clientUsername, acd.PartnerUsername, acd.MsgId, acd.NewText, acd.At)
+}
+
+func DeleteMessagesForMe(ctx context.Context, clientUsername string, actionData msgpack.RawMessage) (any, error) {
+
+	acd := helpers.FromBtMsgPack[deleteDirectChatMsgs](actionData)
+
+	if err := acd.Validate(); err != nil {
+		return nil, err
+	}
+
+	return directChatService.DeleteMessagesForMe(ctx, clientUsername, acd.PartnerUsername, acd.MsgIds)
+}
+
+func DeleteMessageForEveryone(ctx context.Context, clientUsername string, actionData msgpack.RawMessage) (any, error) {
+
+	acd := helpers.FromBtMsgPack[deleteDirectChatMsgForEveryone](actionData)
+
+	if err := acd.Validate(); err != nil {
+		return nil, err
+	}
+
+	return directChatService.DeleteMessageForEveryone(ctx, clientUsername, acd.PartnerUsername, acd.MsgId, acd.At)
+}
+
+func ReactToMessage(ctx context.Context, clientUsername string, actionData msgpack.RawMessage) (any, error) {
+
+	acd := helpers.FromBtMsgPack[reactToDirectChatMsg](actionData)
+
+	if err := acd.Validate(); err != nil {
+		return nil, err
+	}
+
+	return directChatService.ReactToMessage(ctx, clientUsername, acd.PartnerUsername, acd.MsgId, acd.Emoji, acd.At)
+}
+
+func RemoveReactionToMessage(ctx context.Context, clientUsername string, actionData msgpack.RawMessage) (any, error) {
+
+	acd := helpers.FromBtMsgPack[removeReactionToDirectChatMsg](actionData)
+
+	if err := acd.Validate(); err != nil {
+		return nil, err
+	}
+
+	return directChatService.RemoveReactionToMessage(ctx, clientUsername, acd.PartnerUsername, acd.MsgId)
+}
+
+func PinMessage(ctx context.Context, clientUsername string, actionData msgpack.RawMessage) (any, error) {
+
+	acd := helpers.FromBtMsgPack[pinDirectChatMsg](actionData)
+
+	if err := acd.Validate(); err != nil {
+		return nil, err
+	}
+
+	return directChatService.PinMessage(ctx, clientUsername, acd.PartnerUsername, acd.MsgId)
+}
+
+func UnpinMessage(ctx context.Context, clientUsername string, actionData msgpack.RawMessage) (any, error) {
+
+	acd := helpers.FromBtMsgPack[pinDirectChatMsg](actionData)
+
+	if err := acd.Validate(); err != nil {
+		return nil, err
+	}
+
+	return directChatService.UnpinMessage(ctx, clientUsername, acd.PartnerUsername, acd.MsgId)
+}
+
+func SendTypingState(ctx context.Context, clientUsername string, actionData msgpack.RawMessage) (any, error) {
+
+	acd := helpers.FromBtMsgPack[directChatTyping](actionData)
+
+	if err := acd.Validate(); err != nil {
+		return nil, err
+	}
+
+	return directChatService.SendTypingState(ctx, clientUsername, acd.PartnerUsername, acd.State)
+}
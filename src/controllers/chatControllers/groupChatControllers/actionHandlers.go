@@ -4,6 +4,7 @@ import (
 	"context"
 	"i9chat/src/helpers"
 	"i9chat/src/services/chatServices/groupChatService"
+	"strings"
 
 	"github.com/vmihailenco/msgpack/v5"
 )
@@ -39,6 +40,10 @@ func changeGroupPicture(ctx context.Context, clientUsername, groupId string, dat
 		return nil, err
 	}
 
+	if strings.HasPrefix(d.PictureCloudName, "original:") {
+		return groupChatService.ChangeGroupPictureFromOriginal(ctx, groupId, clientUsername, d.PictureCloudName)
+	}
+
 	return groupChatService.ChangeGroupPicture(ctx, groupId, clientUsername, d.PictureCloudName)
 }
 
@@ -72,6 +77,12 @@ func leaveGroup(ctx context.Context, clientUsername, groupId string, _ msgpack.R
 	return groupChatService.LeaveGroup(ctx, groupId, clientUsername)
 }
 
+func deleteGroup(ctx context.Context, clientUsername, groupId string, data msgpack.RawMessage) (any, error) {
+	d := helpers.FromBtMsgPack[deleteGroupAction](data)
+
+	return groupChatService.DeleteGroup(ctx, groupId, clientUsername, d.Archive)
+}
+
 func makeUserGroupAdmin(ctx context.Context, clientUsername, groupId string, data msgpack.RawMessage) (any, error) {
 
 	d := helpers.FromBtMsgPack[actOnSingleUserAction](data)
@@ -83,6 +94,17 @@ func makeUserGroupAdmin(ctx context.Context, clientUsername, groupId string, dat
 	return groupChatService.MakeUserGroupAdmin(ctx, groupId, clientUsername, d.User)
 }
 
+func transferGroupOwnership(ctx context.Context, clientUsername, groupId string, data msgpack.RawMessage) (any, error) {
+
+	d := helpers.FromBtMsgPack[actOnSingleUserAction](data)
+
+	if err := d.Validate(); err != nil {
+		return nil, err
+	}
+
+	return groupChatService.TransferGroupOwnership(ctx, groupId, clientUsername, d.User)
+}
+
 func removeUserFromGroupAdmins(ctx context.Context, clientUsername, groupId string, data msgpack.RawMessage) (any, error) {
 	d := helpers.FromBtMsgPack[actOnSingleUserAction](data)
 
@@ -92,3 +114,101 @@ func removeUserFromGroupAdmins(ctx context.Context, clientUsername, groupId stri
 
 	return groupChatService.RemoveUserFromGroupAdmins(ctx, groupId, clientUsername, d.User)
 }
+
+func pinMessage(ctx context.Context, clientUsername, groupId string, data msgpack.RawMessage) (any, error) {
+	d := helpers.FromBtMsgPack[actOnSingleMessageAction](data)
+
+	if err := d.Validate(); err != nil {
+		return nil, err
+	}
+
+	return groupChatService.PinMessage(ctx, groupId, clientUsername, d.MsgId)
+}
+
+func unpinMessage(ctx context.Context, clientUsername, groupId string, data msgpack.RawMessage) (any, error) {
+	d := helpers.FromBtMsgPack[actOnSingleMessageAction](data)
+
+	if err := d.Validate(); err != nil {
+		return nil, err
+	}
+
+	return groupChatService.UnpinMessage(ctx, groupId, clientUsername, d.MsgId)
+}
+
+func changePinPermission(ctx context.Context, clientUsername, groupId string, data msgpack.RawMessage) (any, error) {
+	d := helpers.FromBtMsgPack[changePinPermissionAction](data)
+
+	if err := d.Validate(); err != nil {
+		return nil, err
+	}
+
+	return groupChatService.ChangeGroupPinPermission(ctx, groupId, clientUsername, d.PinPermission)
+}
+
+func changeVisibility(ctx context.Context, clientUsername, groupId string, data msgpack.RawMessage) (any, error) {
+	d := helpers.FromBtMsgPack[changeVisibilityAction](data)
+
+	return groupChatService.ChangeGroupVisibility(ctx, groupId, clientUsername, d.IsPublic)
+}
+
+func createInviteLink(ctx context.Context, clientUsername, groupId string, data msgpack.RawMessage) (any, error) {
+	d := helpers.FromBtMsgPack[createInviteLinkAction](data)
+
+	if err := d.Validate(); err != nil {
+		return nil, err
+	}
+
+	return groupChatService.CreateInviteLink(ctx, groupId, clientUsername, d.ExpiresAt, d.MaxUses)
+}
+
+func revokeInviteLink(ctx context.Context, clientUsername, groupId string, data msgpack.RawMessage) (any, error) {
+	d := helpers.FromBtMsgPack[revokeInviteLinkAction](data)
+
+	if err := d.Validate(); err != nil {
+		return nil, err
+	}
+
+	return groupChatService.RevokeInviteLink(ctx, groupId, clientUsername, d.Code)
+}
+
+func changeJoinApproval(ctx context.Context, clientUsername, groupId string, data msgpack.RawMessage) (any, error) {
+	d := helpers.FromBtMsgPack[changeJoinApprovalAction](data)
+
+	return groupChatService.ChangeGroupJoinApproval(ctx, groupId, clientUsername, d.JoinApproval)
+}
+
+func listJoinRequests(ctx context.Context, clientUsername, groupId string, data msgpack.RawMessage) (any, error) {
+	d := helpers.FromBtMsgPack[listJoinRequestsAction](data)
+
+	return groupChatService.GetJoinRequests(ctx, groupId, clientUsername, helpers.CoalesceInt(d.Limit, 50), d.Cursor)
+}
+
+func approveJoinRequest(ctx context.Context, clientUsername, groupId string, data msgpack.RawMessage) (any, error) {
+	d := helpers.FromBtMsgPack[actOnSingleUserAction](data)
+
+	if err := d.Validate(); err != nil {
+		return nil, err
+	}
+
+	return groupChatService.ApproveJoinRequest(ctx, groupId, clientUsername, d.User)
+}
+
+func rejectJoinRequest(ctx context.Context, clientUsername, groupId string, data msgpack.RawMessage) (any, error) {
+	d := helpers.FromBtMsgPack[actOnSingleUserAction](data)
+
+	if err := d.Validate(); err != nil {
+		return nil, err
+	}
+
+	return groupChatService.RejectJoinRequest(ctx, groupId, clientUsername, d.User)
+}
+
+func changeSettings(ctx context.Context, clientUsername, groupId string, data msgpack.RawMessage) (any, error) {
+	d := helpers.FromBtMsgPack[changeSettingsAction](data)
+
+	if err := d.Validate(); err != nil {
+		return nil, err
+	}
+
+	return groupChatService.ChangeGroupSettings(ctx, groupId, clientUsername, d.Settings())
+}
@@ -8,6 +8,7 @@ import (
 	"i9chat/src/helpers"
 	"i9chat/src/services/cloudStorageService"
 	"regexp"
+	"strings"
 	"time"
 
 	validation "github.com/go-ozzo/ozzo-validation/v4"
@@ -18,6 +19,7 @@ type newGroupChatBody struct {
 	Name             string   `msgpack:"name"`
 	Description      string   `msgpack:"description"`
 	PictureCloudName string   `msgpack:"pictureCloudName"`
+	IsPublic         bool     `msgpack:"isPublic"`
 	InitUsers        []string `msgpack:"initUsers"`
 	CreatedAt        int64    `msgpack:"createdAt"`
 }
@@ -28,7 +30,7 @@ func (b newGroupChatBody) Validate() error {
 		validation.Field(&b.Description, validation.Required),
 		validation.Field(&b.PictureCloudName, validation.Required,
 			validation.Match(regexp.MustCompile(
-				`^small:uploads/group/group_pics/[\w-/]+\w medium:uploads/group/group_pics/[\w-/]+\w large:uploads/group/group_pics/[\w-/]+\w$`,
+				`^(small:uploads/group/group_pics/[\w-/]+\w medium:uploads/group/group_pics/[\w-/]+\w large:uploads/group/group_pics/[\w-/]+\w|original:uploads/group/group_pics/[\w-/]+\w)$`,
 			)).Error("invalid group picture cloud name"),
 		),
 		validation.Field(&b.InitUsers, validation.Required, validation.Length(1, 0).Error("at least 1 other user is required to start a group")),
@@ -39,6 +41,11 @@ func (b newGroupChatBody) Validate() error {
 		return helpers.ValidationError(err, "gccValidation.go", "newGroupChatBody")
 	}
 
+	// an original is verified before it's processed
+	if strings.HasPrefix(b.PictureCloudName, "original:") {
+		return nil
+	}
+
 	go func(gpicCn string) {
 		ctx := context.Background()
 
@@ -98,6 +105,26 @@ func (d changeGroupDescriptionAction) Validate() error {
 
 }
 
+type authorizeOriginalGroupPicUploadBody struct {
+	PicMIME string `msgpack:"pic_mime"`
+	PicSize int64  `msgpack:"pic_size"`
+}
+
+func (b authorizeOriginalGroupPicUploadBody) Validate() error {
+
+	err := validation.ValidateStruct(&b,
+		validation.Field(&b.PicMIME, validation.Required,
+			validation.In("image/jpeg", "image/png", "image/webp").Error(`unsupported pic_mime; use one of ["image/jpeg", "image/png", "image/webp"]`),
+		),
+		validation.Field(&b.PicSize, validation.Required,
+			validation.Min(int64(1*1024)).Error("pic_size out of range; min: 1KiB; max: 20MeB"),
+			validation.Max(int64(20*1024*1024)).Error("pic_size out of range; min: 1KiB; max: 20MeB"),
+		),
+	)
+
+	return helpers.ValidationError(err, "gccValidation.go", "authorizeOriginalGroupPicUploadBody")
+}
+
 type authorizeGroupPicUploadBody struct {
 	PicMIME string   `msgpack:"pic_mime"`
 	PicSize [3]int64 `msgpack:"pic_size"` // {small, medium, large}
@@ -149,7 +176,7 @@ type changeGroupPictureAction struct {
 func (d changeGroupPictureAction) Validate(ctx context.Context) error {
 	err := validation.ValidateStruct(&d,
 		validation.Field(&d.PictureCloudName, validation.Required, validation.Match(regexp.MustCompile(
-			`^small:uploads/group/group_pics/[\w-/]+\w medium:uploads/group/group_pics/[\w-/]+\w large:uploads/group/group_pics/[\w-/]+\w$`,
+			`^(small:uploads/group/group_pics/[\w-/]+\w medium:uploads/group/group_pics/[\w-/]+\w large:uploads/group/group_pics/[\w-/]+\w|original:uploads/group/group_pics/[\w-/]+\w)$`,
 		)).Error("invalid group picture cloud name")),
 	)
 
@@ -157,6 +184,11 @@ func (d changeGroupPictureAction) Validate(ctx context.Context) error {
 		return helpers.ValidationError(err, "gccValidation.go", "changeGroupPictureAction")
 	}
 
+	// an original is verified before it's processed
+	if strings.HasPrefix(d.PictureCloudName, "original:") {
+		return nil
+	}
+
 	go func(gpicCn string) {
 		ctx := context.Background()
 
@@ -216,6 +248,117 @@ func (d actOnSingleUserAction) Validate() error {
 
 }
 
+type actOnSingleMessageAction struct {
+	MsgId string `msgpack:"msgId"`
+}
+
+func (d actOnSingleMessageAction) Validate() error {
+	err := validation.ValidateStruct(&d,
+		validation.Field(&d.MsgId, validation.Required, is.UUID),
+	)
+
+	return helpers.ValidationError(err, "gccValidation.go", "actOnSingleMessageAction")
+
+}
+
+type changePinPermissionAction struct {
+	PinPermission string `msgpack:"pinPermission"`
+}
+
+func (d changePinPermissionAction) Validate() error {
+	err := validation.ValidateStruct(&d,
+		validation.Field(&d.PinPermission, validation.Required,
+			validation.In("admins", "all").Error(`invalid pinPermission; use one of ["admins", "all"]`),
+		),
+	)
+
+	return helpers.ValidationError(err, "gccValidation.go", "changePinPermissionAction")
+
+}
+
+type changeSettingsAction struct {
+	OnlyAdminsCanSendMessages *bool `msgpack:"onlyAdminsCanSendMessages"`
+	MembersCanEditInfo        *bool `msgpack:"membersCanEditInfo"`
+	MembersCanAddMembers      *bool `msgpack:"membersCanAddMembers"`
+}
+
+func (d changeSettingsAction) Validate() error {
+	var err error
+
+	if d.OnlyAdminsCanSendMessages == nil && d.MembersCanEditInfo == nil && d.MembersCanAddMembers == nil {
+		err = errors.New("at least one setting is required")
+	}
+
+	return helpers.ValidationError(err, "gccValidation.go", "changeSettingsAction")
+
+}
+
+// Settings returns the settings set, keyed by their names on the group
+func (d changeSettingsAction) Settings() map[string]bool {
+	settings := make(map[string]bool, 3)
+
+	if d.OnlyAdminsCanSendMessages != nil {
+		settings["only_admins_can_send_messages"] = *d.OnlyAdminsCanSendMessages
+	}
+
+	if d.MembersCanEditInfo != nil {
+		settings["members_can_edit_info"] = *d.MembersCanEditInfo
+	}
+
+	if d.MembersCanAddMembers != nil {
+		settings["members_can_add_members"] = *d.MembersCanAddMembers
+	}
+
+	return settings
+}
+
+type changeVisibilityAction struct {
+	IsPublic bool `msgpack:"isPublic"`
+}
+
+type changeJoinApprovalAction struct {
+	JoinApproval bool `msgpack:"joinApproval"`
+}
+
+type deleteGroupAction struct {
+	Archive bool `msgpack:"archive"` // members' chats are archived, read-only, rather than removed
+}
+
+type listJoinRequestsAction struct {
+	Limit  int64   `msgpack:"limit"`
+	Cursor float64 `msgpack:"cursor"`
+}
+
+type createInviteLinkAction struct {
+	ExpiresAt int64 `msgpack:"expiresAt"`
+	MaxUses   int64 `msgpack:"maxUses"`
+}
+
+func (d createInviteLinkAction) Validate() error {
+	err := validation.ValidateStruct(&d,
+		validation.Field(&d.ExpiresAt,
+			validation.When(d.ExpiresAt != 0, validation.Min(time.Now().UTC().UnixMilli()).Error("invalid past time")),
+		),
+		validation.Field(&d.MaxUses, validation.Min(int64(0))),
+	)
+
+	return helpers.ValidationError(err, "gccValidation.go", "createInviteLinkAction")
+
+}
+
+type revokeInviteLinkAction struct {
+	Code string `msgpack:"code"`
+}
+
+func (d revokeInviteLinkAction) Validate() error {
+	err := validation.ValidateStruct(&d,
+		validation.Field(&d.Code, validation.Required),
+	)
+
+	return helpers.ValidationError(err, "gccValidation.go", "revokeInviteLinkAction")
+
+}
+
 type sendGroupChatMsg struct {
 	GroupId          string               `msgpack:"groupId"`
 	IsReply          bool                 `msgpack:"isReply"`
@@ -262,3 +405,97 @@ func (d groupInfo) Validate() error {
 
 	return helpers.ValidationError(err, "gccValidation.go", "groupInfo")
 }
+
+type editGroupChatMsg struct {
+	GroupId string `msgpack:"groupId"`
+	MsgId   string `msgpack:"msgId"`
+	NewText string `msgpack:"newText"`
+	At      int64  `msgpack:"at"`
+}
+
+func (d editGroupChatMsg) Validate() error {
+	err := validation.ValidateStruct(&d,
+		validation.Field(&d.GroupId, validation.Required, is.UUID),
+		validation.Field(&d.MsgId, validation.Required, is.UUID),
+		validation.Field(&d.NewText, validation.Required),
+		validation.Field(&d.At, validation.Required, validation.Max(time.Now().UTC().UnixMilli()).Error("invalid future time")),
+	)
+
+	return helpers.ValidationError(err, "gccValidation.go", "editGroupChatMsg")
+}
+
+type deleteGroupChatMsgs struct {
+	GroupId string `msgpack:"groupId"`
+	MsgIds  []any  `msgpack:"msgIds"`
+}
+
+func (d deleteGroupChatMsgs) Validate() error {
+	err := validation.ValidateStruct(&d,
+		validation.Field(&d.GroupId, validation.Required, is.UUID),
+		validation.Field(&d.MsgIds, validation.Required, validation.Each(is.UUID)),
+	)
+
+	return helpers.ValidationError(err, "gccValidation.go", "deleteGroupChatMsgs")
+}
+
+type deleteGroupChatMsgForEveryone struct {
+	GroupId string `msgpack:"groupId"`
+	MsgId   string `msgpack:"msgId"`
+	At      int64  `msgpack:"at"`
+}
+
+func (d deleteGroupChatMsgForEveryone) Validate() error {
+	err := validation.ValidateStruct(&d,
+		validation.Field(&d.GroupId, validation.Required, is.UUID),
+		validation.Field(&d.MsgId, validation.Required, is.UUID),
+		validation.Field(&d.At, validation.Required, validation.Max(time.Now().UTC().UnixMilli()).Error("invalid future time")),
+	)
+
+	return helpers.ValidationError(err, "gccValidation.go", "deleteGroupChatMsgForEveryone")
+}
+
+type reactToGroupChatMsg struct {
+	GroupId string `msgpack:"groupId"`
+	MsgId   string `msgpack:"msgId"`
+	Emoji   string `msgpack:"emoji"`
+	At      int64  `msgpack:"at"`
+}
+
+func (d reactToGroupChatMsg) Validate() error {
+	err := validation.ValidateStruct(&d,
+		validation.Field(&d.GroupId, validation.Required, is.UUID),
+		validation.Field(&d.MsgId, validation.Required, is.UUID),
+		validation.Field(&d.Emoji, validation.Required, validation.RuneLength(1, 10)),
+		validation.Field(&d.At, validation.Required, validation.Max(time.Now().UTC().UnixMilli()).Error("invalid future time")),
+	)
+
+	return helpers.ValidationError(err, "gccValidation.go", "reactToGroupChatMsg")
+}
+
+type removeReactionToGroupChatMsg struct {
+	GroupId string `msgpack:"groupId"`
+	MsgId   string `msgpack:"msgId"`
+}
+
+func (d removeReactionToGroupChatMsg) Validate() error {
+	err := validation.ValidateStruct(&d,
+		validation.Field(&d.GroupId, validation.Required, is.UUID),
+		validation.Field(&d.MsgId, validation.Required, is.UUID),
+	)
+
+	return helpers.ValidationError(err, "gccValidation.go", "removeReactionToGroupChatMsg")
+}
+
+type groupChatTyping struct {
+	GroupId string `msgpack:"groupId"`
+	State   string `msgpack:"state"`
+}
+
+func (d groupChatTyping) Validate() error {
+	err := validation.ValidateStruct(&d,
+		validation.Field(&d.GroupId, validation.Required, is.UUID),
+		validation.Field(&d.State, validation.Required, validation.In("typing", "recording", "stopped")),
+	)
+
+	return helpers.ValidationError(err, "gccValidation.go", "groupChatTyping")
+}
@@ -4,8 +4,11 @@ import (
 	"context"
 	"fmt"
 	"i9chat/src/appTypes"
+	"i9chat/src/controllers/chatControllers/chatTypes"
 	"i9chat/src/helpers"
+	"i9chat/src/services/chatServices/chatUploadService"
 	"i9chat/src/services/chatServices/groupChatService"
+	"i9chat/src/services/cloudStorageService"
 
 	"github.com/gofiber/fiber/v3"
 	"github.com/vmihailenco/msgpack/v5"
@@ -33,6 +36,30 @@ func AuthorizeGroupPicUpload(c fiber.Ctx) error {
 	return c.MsgPack(respData)
 }
 
+func AuthorizeOriginalGroupPicUpload(c fiber.Ctx) error {
+	ctx := c.Context()
+
+	clientUser := c.Locals("user").(appTypes.ClientUser)
+
+	var body authorizeOriginalGroupPicUploadBody
+
+	err := c.Bind().MsgPack(&body)
+	if err != nil {
+		return err
+	}
+
+	if err = body.Validate(); err != nil {
+		return err
+	}
+
+	respData, err := groupChatService.AuthorizeOriginalGroupPicUpload(ctx, clientUser.Username, body.PicMIME)
+	if err != nil {
+		return err
+	}
+
+	return c.MsgPack(respData)
+}
+
 func CreateNewGroup(c fiber.Ctx) error {
 	ctx := c.Context()
 
@@ -54,6 +81,7 @@ func CreateNewGroup(c fiber.Ctx) error {
 		body.Name,
 		body.Description,
 		body.PictureCloudName,
+		body.IsPublic,
 		body.InitUsers,
 		body.CreatedAt,
 	)
@@ -86,21 +114,72 @@ func GetGroupMembers(c fiber.Ctx) error {
 	return c.MsgPack(respData)
 }
 
-func GetGroupChatHistory(c fiber.Ctx) error {
+func GetPinnedMessages(c fiber.Ctx) error {
 	ctx := c.Context()
 
 	clientUser := c.Locals("user").(appTypes.ClientUser)
 
-	var query struct {
-		Limit  int64
-		Cursor float64
+	respData, err := groupChatService.GetPinnedMessages(ctx, clientUser.Username, c.Params("group_id"))
+	if err != nil {
+		return err
 	}
 
+	return c.MsgPack(respData)
+}
+
+func GetInviteLinks(c fiber.Ctx) error {
+	ctx := c.Context()
+
+	clientUser := c.Locals("user").(appTypes.ClientUser)
+
+	respData, err := groupChatService.GetInviteLinks(ctx, c.Params("group_id"), clientUser.Username)
+	if err != nil {
+		return err
+	}
+
+	return c.MsgPack(respData)
+}
+
+func PreviewGroupByInviteLink(c fiber.Ctx) error {
+	ctx := c.Context()
+
+	respData, err := groupChatService.PreviewGroupByInviteLink(ctx, c.Params("code"))
+	if err != nil {
+		return err
+	}
+
+	return c.MsgPack(respData)
+}
+
+func JoinGroupViaInviteLink(c fiber.Ctx) error {
+	ctx := c.Context()
+
+	clientUser := c.Locals("user").(appTypes.ClientUser)
+
+	respData, err := groupChatService.JoinGroupViaInviteLink(ctx, c.Params("code"), clientUser.Username)
+	if err != nil {
+		return err
+	}
+
+	return c.MsgPack(respData)
+}
+
+func GetGroupChatHistory(c fiber.Ctx) error {
+	ctx := c.Context()
+
+	clientUser := c.Locals("user").(appTypes.ClientUser)
+
+	var query chatTypes.HistoryQuery
+
 	if err := c.Bind().Query(&query); err != nil {
 		return err
 	}
 
-	respData, err := groupChatService.GetChatHistory(ctx, clientUser.Username, c.Params("group_id"), helpers.CoalesceInt(query.Limit, 50), query.Cursor)
+	if err := query.Validate(); err != nil {
+		return err
+	}
+
+	respData, err := groupChatService.GetChatHistory(ctx, clientUser.Username, c.Params("group_id"), query.Mode, query.MsgId, helpers.CoalesceInt(query.Limit, 50), query.Cursor)
 	if err != nil {
 		return err
 	}
@@ -123,8 +202,21 @@ func ExecuteAction(c fiber.Ctx) error {
 		"change-description":      changeGroupDescription,
 		"change-picture":          changeGroupPicture,
 		"remove-user-from-admins": removeUserFromGroupAdmins,
+		"transfer-ownership":      transferGroupOwnership,
 		"remove-user":             removeUserFromGroup,
 		"leave":                   leaveGroup,
+		"delete-group":            deleteGroup,
+		"pin-message":             pinMessage,
+		"unpin-message":           unpinMessage,
+		"change-pin-permission":   changePinPermission,
+		"change-settings":         changeSettings,
+		"change-visibility":       changeVisibility,
+		"create-invite-link":      createInviteLink,
+		"revoke-invite-link":      revokeInviteLink,
+		"change-join-approval":    changeJoinApproval,
+		"list-join-requests":      listJoinRequests,
+		"approve-join-request":    approveJoinRequest,
+		"reject-join-request":     rejectJoinRequest,
 	}
 
 	var actionData msgpack.RawMessage
@@ -155,6 +247,10 @@ func SendMessage(ctx context.Context, clientUsername string, actionData msgpack.
 		return nil, err
 	}
 
+	if err := chatUploadService.FinalizeMessageMedia(ctx, clientUsername, cloudStorageService.GroupMediaChat(acd.GroupId), acd.Msg.Type, acd.Msg.MediaCloudName); err != nil {
+		return nil, err
+	}
+
 	return groupChatService.SendMessage(ctx, clientUsername, acd.GroupId, acd.ReplyTargetMsgId, acd.IsReply, helpers.ToJson(acd.Msg), acd.At)
 }
 
@@ -180,6 +276,72 @@ func AckMessagesRead(ctx context.Context, clientUsername string, actionData msgp
 	return groupChatService.AckMessagesRead(ctx, clientUsername, acd.GroupId, acd.MsgIds, acd.At)
 }
 
+func EditMessage(ctx context.Context, clientUsername string, actionData msgpack.RawMessage) (any, error) {
+
+	acd := helpers.FromBtMsgPack[editGroupChatMsg](actionData)
+
+	if err := acd.Validate(); err != nil {
+		return nil, err
+	}
+
+	return groupChatService.EditMessage(ctx, clientUsername, acd.GroupId, acd.MsgId, acd.NewText, acd.At)
+}
+
+func DeleteMessagesForMe(ctx context.Context, clientUsername string, actionData msgpack.RawMessage) (any, error) {
+
+	acd := helpers.FromBtMsgPack[deleteGroupChatMsgs](actionData)
+
+	if err := acd.Validate(); err != nil {
+		return nil, err
+	}
+
+	return groupChatService.DeleteMessagesForMe(ctx, clientUsername, acd.GroupId, acd.MsgIds)
+}
+
+func DeleteMessageForEveryone(ctx context.Context, clientUsername string, actionData msgpack.RawMessage) (any, error) {
+
+	acd := helpers.FromBtMsgPack[deleteGroupChatMsgForEveryone](actionData)
+
+	if err := acd.Validate(); err != nil {
+		return nil, err
+	}
+
+	return groupChatService.DeleteMessageForEveryone(ctx, clientUsername, acd.GroupId, acd.MsgId, acd.At)
+}
+
+func ReactToMessage(ctx context.Context, clientUsername string, actionData msgpack.RawMessage) (any, error) {
+
+	acd := helpers.FromBtMsgPack[reactToGroupChatMsg](actionData)
+
+	if err := acd.Validate(); err != nil {
+		return nil, err
+	}
+
+	return groupChatService.ReactToMessage(ctx, clientUsername, acd.GroupId, acd.MsgId, acd.Emoji, acd.At)
+}
+
+func RemoveReactionToMessage(ctx context.Context, clientUsername string, actionData msgpack.RawMessage) (any, error) {
+
+	acd := helpers.FromBtMsgPack[removeReactionToGroupChatMsg](actionData)
+
+	if err := acd.Validate(); err != nil {
+		return nil, err
+	}
+
+	return groupChatService.RemoveReactionToMessage(ctx, clientUsername, acd.GroupId, acd.MsgId)
+}
+
+func SendTypingState(ctx context.Context, clientUsername string, actionData msgpack.RawMessage) (any, error) {
+
+	acd := helpers.FromBtMsgPack[groupChatTyping](actionData)
+
+	if err := acd.Validate(); err != nil {
+		return nil, err
+	}
+
+	return groupChatService.SendTypingState(ctx, clientUsername, acd.GroupId, acd.State)
+}
+
 func GetGroupInfo(ctx context.Context, actionData msgpack.RawMessage) (any, error) {
 
 	acd := helpers.FromBtMsgPack[groupInfo](actionData)
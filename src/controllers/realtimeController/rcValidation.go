@@ -2,21 +2,39 @@ package realtimeController
 
 import (
 	"i9chat/src/helpers"
+	"regexp"
 
 	validation "github.com/go-ozzo/ozzo-validation/v4"
 	"github.com/vmihailenco/msgpack/v5"
 )
 
+// WSStream handshake query params
+type wsHandshakeParams struct {
+	DeviceId   string
+	ResumeFrom string
+}
+
+func (p wsHandshakeParams) Validate() error {
+	err := validation.ValidateStruct(&p,
+		validation.Field(&p.DeviceId, validation.Required, validation.Length(1, 64)),
+		validation.Field(&p.ResumeFrom, validation.Match(regexp.MustCompile(`^\d+-\d+$`)).Error("expected an event id")),
+	)
+
+	return helpers.ValidationError(err, "rcValidation.go", "wsHandshakeParams")
+}
+
 // Realtime Action Body
 type rtActionBody struct {
 	Action string             `msgpack:"action"`
 	Data   msgpack.RawMessage `msgpack:"data"`
+	ReqId  string             `msgpack:"reqId"` // optional, echoed back in the action's reply
 }
 
 func (b rtActionBody) Validate() error {
 	err := validation.ValidateStruct(&b,
 		validation.Field(&b.Action, validation.Required),
 		validation.Field(&b.Data, validation.Required),
+		validation.Field(&b.ReqId, validation.Length(0, 64)),
 	)
 
 	return helpers.ValidationError(err, "rcValidation.go", "rtActionBody")
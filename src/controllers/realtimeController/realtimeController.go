@@ -2,7 +2,6 @@ package realtimeController
 
 import (
 	"context"
-	"fmt"
 	"i9chat/src/appTypes"
 	"i9chat/src/controllers/chatControllers/directChatControllers"
 	"i9chat/src/controllers/chatControllers/groupChatControllers"
@@ -13,20 +12,62 @@ import (
 
 	"github.com/gofiber/contrib/v3/websocket"
 	"github.com/gofiber/fiber/v3"
+	"github.com/gofiber/utils/v2"
 )
 
+// WSStream is the client device's realtime connection.
+//
+// Reply guarantee: every action received gets exactly one reply,
+// either a "server reply" or a "server error", carrying the action's name as "toAction"
+// and the action's "reqId", if the client supplied one.
+// Replies are sent in the order the actions were received, as actions are handled one at a time.
+// A "server error" reply means the action had no effect, and may be retried.
+// If the connection drops before an action's reply arrives, the action may or may not have taken effect;
+// a message send that took effect is then found in the chat history
 var WSStream = websocket.New(func(c *websocket.Conn) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
 	clientUser := c.Locals("user").(appTypes.ClientUser)
 
-	go userService.GoOnline(context.Background(), clientUser.Username)
+	// device_id identifies the client's device among the user's connected devices.
+	// resume_from is the id of the last server event the device received,
+	// the server events after which are replayed before live delivery starts
+	handshake := wsHandshakeParams{
+		DeviceId:   c.Query("device_id", utils.UUIDv4()),
+		ResumeFrom: c.Query("resume_from"),
+	}
+
+	if err := handshake.Validate(); err != nil {
+		if err := c.WriteMessage(websocket.BinaryMessage, helpers.ToBtMsgPack(helpers.WSErrReply(err, "handshake", ""))); err != nil {
+			log.Println(err)
+		}
+
+		return
+	}
+
+	deviceId := handshake.DeviceId
 
-	realtimeService.AddPipe(ctx, clientUser.Username, c)
+	pipe, firstDevice := realtimeService.AddPipe(ctx, clientUser, deviceId, c)
+
+	if firstDevice {
+		go userService.GoOnline(context.Background(), clientUser.Username)
+	}
 
 	var w_err error
 
+	if err := realtimeService.SubscribeToSessionRevocation(ctx, pipe); err != nil {
+		w_err = err
+	}
+
+	if w_err == nil {
+		if err := realtimeService.SubscribeToServerEvents(ctx, pipe, handshake.ResumeFrom, cancel); err != nil {
+			w_err = err
+		}
+	}
+
+	cancelUserPresenceSub := make(map[string]context.CancelFunc)
+
 	for {
 
 		if w_err != nil {
@@ -41,42 +82,50 @@ var WSStream = websocket.New(func(c *websocket.Conn) {
 		}
 
 		if MSG_TYPE != websocket.BinaryMessage {
-			w_err = c.WriteMessage(websocket.BinaryMessage, helpers.ToBtMsgPack(fmt.Errorf("unexpected message type")))
+			w_err = pipe.WriteMessage(websocket.BinaryMessage, helpers.ToBtMsgPack(helpers.WSErrReply(fiber.NewError(fiber.StatusBadRequest, "unexpected message type"), "", "")))
 			continue
 		}
 
 		body := helpers.FromBtMsgPack[rtActionBody](msgPackBt)
 
 		if err := body.Validate(); err != nil {
-			w_err = c.WriteMessage(MSG_TYPE, helpers.ToBtMsgPack(helpers.WSErrReply(err, body.Action)))
+			w_err = pipe.WriteMessage(MSG_TYPE, helpers.ToBtMsgPack(helpers.WSErrReply(err, body.Action, body.ReqId)))
 			continue
 		}
 
-		cancelUserPresenceSub := make(map[string]context.CancelFunc)
-
 		switch body.Action {
 		case "subscribe to user presence change":
 
 			data := helpers.FromBtMsgPack[subToUserPresenceAcd](body.Data)
 
 			if err := data.Validate(); err != nil {
-				w_err = c.WriteMessage(MSG_TYPE, helpers.ToBtMsgPack(helpers.WSErrReply(err, body.Action)))
+				w_err = pipe.WriteMessage(MSG_TYPE, helpers.ToBtMsgPack(helpers.WSErrReply(err, body.Action, body.ReqId)))
 				continue
 			}
 
 			for _, tu := range data.Usernames {
+				if cancel, ok := cancelUserPresenceSub[tu]; ok {
+					cancel()
+				}
+
 				ctx, cancel := context.WithCancel(ctx)
 
-				realtimeService.SubscribeToUserPresence(ctx, clientUser.Username, tu, cancel)
+				if !realtimeService.SubscribeToUserPresence(ctx, pipe, tu, cancel) {
+					cancel()
+					delete(cancelUserPresenceSub, tu)
+					continue
+				}
 
 				cancelUserPresenceSub[tu] = cancel
 			}
+
+			w_err = pipe.WriteMessage(MSG_TYPE, helpers.ToBtMsgPack(helpers.WSReply(true, body.Action, body.ReqId)))
 		case "unsubscribe from user presence change":
 
 			data := helpers.FromBtMsgPack[unsubFromUserPresenceAcd](body.Data)
 
 			if err := data.Validate(); err != nil {
-				w_err = c.WriteMessage(MSG_TYPE, helpers.ToBtMsgPack(helpers.WSErrReply(err, body.Action)))
+				w_err = pipe.WriteMessage(MSG_TYPE, helpers.ToBtMsgPack(helpers.WSErrReply(err, body.Action, body.ReqId)))
 				continue
 			}
 
@@ -87,76 +136,204 @@ var WSStream = websocket.New(func(c *websocket.Conn) {
 
 				delete(cancelUserPresenceSub, tu)
 			}
+
+			w_err = pipe.WriteMessage(MSG_TYPE, helpers.ToBtMsgPack(helpers.WSReply(true, body.Action, body.ReqId)))
 		case "direct chat: send message":
 			respData, err := directChatControllers.SendMessage(ctx, clientUser.Username, body.Data)
 			if err != nil {
-				w_err = c.WriteMessage(MSG_TYPE, helpers.ToBtMsgPack(helpers.WSErrReply(err, body.Action)))
+				w_err = pipe.WriteMessage(MSG_TYPE, helpers.ToBtMsgPack(helpers.WSErrReply(err, body.Action, body.ReqId)))
 				continue
 			}
 
-			w_err = c.WriteMessage(MSG_TYPE, helpers.ToBtMsgPack(helpers.WSReply(respData, body.Action)))
+			w_err = pipe.WriteMessage(MSG_TYPE, helpers.ToBtMsgPack(helpers.WSReply(respData, body.Action, body.ReqId)))
 		case "direct chat: ack messages delivered":
 
 			respData, err := directChatControllers.AckMessagesDelivered(ctx, clientUser.Username, body.Data)
 			if err != nil {
-				w_err = c.WriteMessage(MSG_TYPE, helpers.ToBtMsgPack(helpers.WSErrReply(err, body.Action)))
+				w_err = pipe.WriteMessage(MSG_TYPE, helpers.ToBtMsgPack(helpers.WSErrReply(err, body.Action, body.ReqId)))
 				continue
 			}
 
-			w_err = c.WriteMessage(MSG_TYPE, helpers.ToBtMsgPack(helpers.WSReply(respData, body.Action)))
+			w_err = pipe.WriteMessage(MSG_TYPE, helpers.ToBtMsgPack(helpers.WSReply(respData, body.Action, body.ReqId)))
 		case "direct chat: ack messages read":
 
 			respData, err := directChatControllers.AckMessagesRead(ctx, clientUser.Username, body.Data)
 			if err != nil {
-				w_err = c.WriteMessage(MSG_TYPE, helpers.ToBtMsgPack(helpers.WSErrReply(err, body.Action)))
+				w_err = pipe.WriteMessage(MSG_TYPE, helpers.ToBtMsgPack(helpers.WSErrReply(err, body.Action, body.ReqId)))
+				continue
+			}
+
+			w_err = pipe.WriteMessage(MSG_TYPE, helpers.ToBtMsgPack(helpers.WSReply(respData, body.Action, body.ReqId)))
+		case "direct chat: edit message":
+
+			respData, err := directChatControllers.EditMessage(ctx, clientUser.Username, body.Data)
+			if err != nil {
+				w_err = pipe.WriteMessage(MSG_TYPE, helpers.ToBtMsgPack(helpers.WSErrReply(err, body.Action, body.ReqId)))
+				continue
+			}
+
+			w_err = pipe.WriteMessage(MSG_TYPE, helpers.ToBtMsgPack(helpers.WSReply(respData, body.Action, body.ReqId)))
+		case "direct chat: delete messages for me":
+
+			respData, err := directChatControllers.DeleteMessagesForMe(ctx, clientUser.Username, body.Data)
+			if err != nil {
+				w_err = pipe.WriteMessage(MSG_TYPE, helpers.ToBtMsgPack(helpers.WSErrReply(err, body.Action, body.ReqId)))
+				continue
+			}
+
+			w_err = pipe.WriteMessage(MSG_TYPE, helpers.ToBtMsgPack(helpers.WSReply(respData, body.Action, body.ReqId)))
+		case "direct chat: delete message for everyone":
+
+			respData, err := directChatControllers.DeleteMessageForEveryone(ctx, clientUser.Username, body.Data)
+			if err != nil {
+				w_err = pipe.WriteMessage(MSG_TYPE, helpers.ToBtMsgPack(helpers.WSErrReply(err, body.Action, body.ReqId)))
+				continue
+			}
+
+			w_err = pipe.WriteMessage(MSG_TYPE, helpers.ToBtMsgPack(helpers.WSReply(respData, body.Action, body.ReqId)))
+		case "direct chat: react to message":
+
+			respData, err := directChatControllers.ReactToMessage(ctx, clientUser.Username, body.Data)
+			if err != nil {
+				w_err = pipe.WriteMessage(MSG_TYPE, helpers.ToBtMsgPack(helpers.WSErrReply(err, body.Action, body.ReqId)))
+				continue
+			}
+
+			w_err = pipe.WriteMessage(MSG_TYPE, helpers.ToBtMsgPack(helpers.WSReply(respData, body.Action, body.ReqId)))
+		case "direct chat: remove reaction to message":
+
+			respData, err := directChatControllers.RemoveReactionToMessage(ctx, clientUser.Username, body.Data)
+			if err != nil {
+				w_err = pipe.WriteMessage(MSG_TYPE, helpers.ToBtMsgPack(helpers.WSErrReply(err, body.Action, body.ReqId)))
 				continue
 			}
 
-			w_err = c.WriteMessage(MSG_TYPE, helpers.ToBtMsgPack(helpers.WSReply(respData, body.Action)))
+			w_err = pipe.WriteMessage(MSG_TYPE, helpers.ToBtMsgPack(helpers.WSReply(respData, body.Action, body.ReqId)))
+		case "direct chat: pin message":
+
+			respData, err := directChatControllers.PinMessage(ctx, clientUser.Username, body.Data)
+			if err != nil {
+				w_err = pipe.WriteMessage(MSG_TYPE, helpers.ToBtMsgPack(helpers.WSErrReply(err, body.Action, body.ReqId)))
+				continue
+			}
+
+			w_err = pipe.WriteMessage(MSG_TYPE, helpers.ToBtMsgPack(helpers.WSReply(respData, body.Action, body.ReqId)))
+		case "direct chat: unpin message":
+
+			respData, err := directChatControllers.UnpinMessage(ctx, clientUser.Username, body.Data)
+			if err != nil {
+				w_err = pipe.WriteMessage(MSG_TYPE, helpers.ToBtMsgPack(helpers.WSErrReply(err, body.Action, body.ReqId)))
+				continue
+			}
+
+			w_err = pipe.WriteMessage(MSG_TYPE, helpers.ToBtMsgPack(helpers.WSReply(respData, body.Action, body.ReqId)))
+		case "direct chat: typing":
+
+			respData, err := directChatControllers.SendTypingState(ctx, clientUser.Username, body.Data)
+			if err != nil {
+				w_err = pipe.WriteMessage(MSG_TYPE, helpers.ToBtMsgPack(helpers.WSErrReply(err, body.Action, body.ReqId)))
+				continue
+			}
+
+			w_err = pipe.WriteMessage(MSG_TYPE, helpers.ToBtMsgPack(helpers.WSReply(respData, body.Action, body.ReqId)))
 		case "group chat: send message":
 
 			respData, err := groupChatControllers.SendMessage(ctx, clientUser.Username, body.Data)
 			if err != nil {
-				w_err = c.WriteMessage(MSG_TYPE, helpers.ToBtMsgPack(helpers.WSErrReply(err, body.Action)))
+				w_err = pipe.WriteMessage(MSG_TYPE, helpers.ToBtMsgPack(helpers.WSErrReply(err, body.Action, body.ReqId)))
 				continue
 			}
 
-			w_err = c.WriteMessage(MSG_TYPE, helpers.ToBtMsgPack(helpers.WSReply(respData, body.Action)))
+			w_err = pipe.WriteMessage(MSG_TYPE, helpers.ToBtMsgPack(helpers.WSReply(respData, body.Action, body.ReqId)))
 		case "group chat: ack messages delivered":
 
 			respData, err := groupChatControllers.AckMessagesDelivered(ctx, clientUser.Username, body.Data)
 			if err != nil {
-				w_err = c.WriteMessage(MSG_TYPE, helpers.ToBtMsgPack(helpers.WSErrReply(err, body.Action)))
+				w_err = pipe.WriteMessage(MSG_TYPE, helpers.ToBtMsgPack(helpers.WSErrReply(err, body.Action, body.ReqId)))
 				continue
 			}
 
-			w_err = c.WriteMessage(MSG_TYPE, helpers.ToBtMsgPack(helpers.WSReply(respData, body.Action)))
+			w_err = pipe.WriteMessage(MSG_TYPE, helpers.ToBtMsgPack(helpers.WSReply(respData, body.Action, body.ReqId)))
 		case "group chat: ack messages read":
 
 			respData, err := groupChatControllers.AckMessagesRead(ctx, clientUser.Username, body.Data)
 			if err != nil {
-				w_err = c.WriteMessage(MSG_TYPE, helpers.ToBtMsgPack(helpers.WSErrReply(err, body.Action)))
+				w_err = pipe.WriteMessage(MSG_TYPE, helpers.ToBtMsgPack(helpers.WSErrReply(err, body.Action, body.ReqId)))
+				continue
+			}
+
+			w_err = pipe.WriteMessage(MSG_TYPE, helpers.ToBtMsgPack(helpers.WSReply(respData, body.Action, body.ReqId)))
+		case "group chat: edit message":
+
+			respData, err := groupChatControllers.EditMessage(ctx, clientUser.Username, body.Data)
+			if err != nil {
+				w_err = pipe.WriteMessage(MSG_TYPE, helpers.ToBtMsgPack(helpers.WSErrReply(err, body.Action, body.ReqId)))
 				continue
 			}
 
-			w_err = c.WriteMessage(MSG_TYPE, helpers.ToBtMsgPack(helpers.WSReply(respData, body.Action)))
+			w_err = pipe.WriteMessage(MSG_TYPE, helpers.ToBtMsgPack(helpers.WSReply(respData, body.Action, body.ReqId)))
+		case "group chat: delete messages for me":
+
+			respData, err := groupChatControllers.DeleteMessagesForMe(ctx, clientUser.Username, body.Data)
+			if err != nil {
+				w_err = pipe.WriteMessage(MSG_TYPE, helpers.ToBtMsgPack(helpers.WSErrReply(err, body.Action, body.ReqId)))
+				continue
+			}
+
+			w_err = pipe.WriteMessage(MSG_TYPE, helpers.ToBtMsgPack(helpers.WSReply(respData, body.Action, body.ReqId)))
+		case "group chat: delete message for everyone":
+
+			respData, err := groupChatControllers.DeleteMessageForEveryone(ctx, clientUser.Username, body.Data)
+			if err != nil {
+				w_err = pipe.WriteMessage(MSG_TYPE, helpers.ToBtMsgPack(helpers.WSErrReply(err, body.Action, body.ReqId)))
+				continue
+			}
+
+			w_err = pipe.WriteMessage(MSG_TYPE, helpers.ToBtMsgPack(helpers.WSReply(respData, body.Action, body.ReqId)))
+		case "group chat: react to message":
+
+			respData, err := groupChatControllers.ReactToMessage(ctx, clientUser.Username, body.Data)
+			if err != nil {
+				w_err = pipe.WriteMessage(MSG_TYPE, helpers.ToBtMsgPack(helpers.WSErrReply(err, body.Action, body.ReqId)))
+				continue
+			}
+
+			w_err = pipe.WriteMessage(MSG_TYPE, helpers.ToBtMsgPack(helpers.WSReply(respData, body.Action, body.ReqId)))
+		case "group chat: remove reaction to message":
+
+			respData, err := groupChatControllers.RemoveReactionToMessage(ctx, clientUser.Username, body.Data)
+			if err != nil {
+				w_err = pipe.WriteMessage(MSG_TYPE, helpers.ToBtMsgPack(helpers.WSErrReply(err, body.Action, body.ReqId)))
+				continue
+			}
+
+			w_err = pipe.WriteMessage(MSG_TYPE, helpers.ToBtMsgPack(helpers.WSReply(respData, body.Action, body.ReqId)))
+		case "group chat: typing":
+
+			respData, err := groupChatControllers.SendTypingState(ctx, clientUser.Username, body.Data)
+			if err != nil {
+				w_err = pipe.WriteMessage(MSG_TYPE, helpers.ToBtMsgPack(helpers.WSErrReply(err, body.Action, body.ReqId)))
+				continue
+			}
+
+			w_err = pipe.WriteMessage(MSG_TYPE, helpers.ToBtMsgPack(helpers.WSReply(respData, body.Action, body.ReqId)))
 		case "group: get info":
 
 			respData, err := groupChatControllers.GetGroupInfo(ctx, body.Data)
 			if err != nil {
-				w_err = c.WriteMessage(MSG_TYPE, helpers.ToBtMsgPack(helpers.WSErrReply(err, body.Action)))
+				w_err = pipe.WriteMessage(MSG_TYPE, helpers.ToBtMsgPack(helpers.WSErrReply(err, body.Action, body.ReqId)))
 				continue
 			}
 
-			w_err = c.WriteMessage(MSG_TYPE, helpers.ToBtMsgPack(helpers.WSReply(respData, body.Action)))
+			w_err = pipe.WriteMessage(MSG_TYPE, helpers.ToBtMsgPack(helpers.WSReply(respData, body.Action, body.ReqId)))
 
 		default:
-			w_err = c.WriteMessage(MSG_TYPE, helpers.ToBtMsgPack(helpers.WSErrReply(fiber.NewErrorf(fiber.StatusInternalServerError, "invalid event: %s", body.Action), body.Action)))
+			w_err = pipe.WriteMessage(MSG_TYPE, helpers.ToBtMsgPack(helpers.WSErrReply(fiber.NewErrorf(fiber.StatusInternalServerError, "invalid event: %s", body.Action), body.Action, body.ReqId)))
 			continue
 		}
 	}
 
-	go userService.GoOffline(context.Background(), clientUser.Username)
-
-	realtimeService.RemovePipe(clientUser.Username)
+	if lastDevice := realtimeService.RemovePipe(context.Background(), clientUser.Username, pipe.ConnId); lastDevice {
+		go userService.GoOffline(context.Background(), clientUser.Username)
+	}
 })
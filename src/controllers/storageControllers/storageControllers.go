@@ -0,0 +1,134 @@
+package storageControllers
+
+import (
+	"errors"
+	"fmt"
+	"i9chat/src/appTypes"
+	"i9chat/src/helpers"
+	"i9chat/src/services/cloudStorageService"
+	"i9chat/src/services/userService"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// signedMcn returns the media cloud name of the request's signed url, if its signature is valid
+func signedMcn(c fiber.Ctx) (string, error) {
+	var query struct {
+		Expires   int64
+		Signature string
+	}
+
+	if err := c.Bind().Query(&query); err != nil {
+		return "", err
+	}
+
+	mcn := c.Params("*")
+
+	if !cloudStorageService.SignedUrlValid(c.Method(), mcn, query.Expires, query.Signature) {
+		return "", fiber.NewError(fiber.StatusForbidden, "invalid or expired signed url")
+	}
+
+	return mcn, nil
+}
+
+func StartUpload(c fiber.Ctx) error {
+	mcn, err := signedMcn(c)
+	if err != nil {
+		return err
+	}
+
+	if c.Get("x-goog-resumable") != "start" {
+		return fiber.NewError(fiber.StatusBadRequest, "expected header x-goog-resumable: start")
+	}
+
+	sessionUrl, err := cloudStorageService.StartUpload(c.Context(), mcn, c.Get("Content-Type"), time.Now().Add(24*time.Hour))
+	if err != nil {
+		helpers.LogError(err)
+		return fiber.ErrInternalServerError
+	}
+
+	c.Set("Location", sessionUrl)
+
+	return c.SendStatus(fiber.StatusCreated)
+}
+
+func UploadChunk(c fiber.Ctx) error {
+	mcn, err := signedMcn(c)
+	if err != nil {
+		return err
+	}
+
+	var start, end, total int64
+
+	if _, err := fmt.Sscanf(c.Get("Content-Range"), "bytes %d-%d/%d", &start, &end, &total); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid Content-Range header")
+	}
+
+	if start < 0 || end < start || end >= total || end-start+1 != int64(len(c.Body())) {
+		return fiber.NewError(fiber.StatusRequestedRangeNotSatisfiable, "Content-Range doesn't match the chunk")
+	}
+
+	if err := cloudStorageService.WriteUploadChunk(c.Context(), mcn, start, total, c.Body()); err != nil {
+		if errors.Is(err, cloudStorageService.ErrUploadRange) {
+			return fiber.NewError(fiber.StatusRequestedRangeNotSatisfiable, err.Error())
+		}
+
+		helpers.LogError(err)
+		return fiber.ErrInternalServerError
+	}
+
+	if end+1 < total {
+		c.Set("Range", fmt.Sprintf("bytes=0-%d", end))
+
+		return c.SendStatus(fiber.StatusPermanentRedirect)
+	}
+
+	return c.SendStatus(fiber.StatusOK)
+}
+
+func GetMedia(c fiber.Ctx) error {
+	mcn, err := signedMcn(c)
+	if err != nil {
+		return err
+	}
+
+	file, contentType, err := cloudStorageService.OpenLocalMedia(mcn)
+	if err != nil {
+		return fiber.ErrNotFound
+	}
+
+	if contentType != "" {
+		c.Set("Content-Type", contentType)
+	}
+
+	return c.SendStream(file)
+}
+
+// GetProxiedMedia serves a media, in the "proxy" media url mode, to the authenticated user,
+// if they're allowed access, by redirecting them to a short-lived signed url of it
+func GetProxiedMedia(c fiber.Ctx) error {
+	ctx := c.Context()
+
+	clientUser := c.Locals("user").(appTypes.ClientUser)
+
+	mcn := c.Params("*")
+
+	allowed, err := userService.CanAccessMedia(ctx, clientUser.Username, mcn)
+	if err != nil {
+		return fiber.ErrInternalServerError
+	}
+
+	if !allowed {
+		return fiber.ErrNotFound
+	}
+
+	url, err := cloudStorageService.GetProxiedMediaRedirect(mcn)
+	if err != nil {
+		return fiber.ErrInternalServerError
+	}
+
+	c.Set("Cache-Control", "private, max-age=240")
+
+	return c.Redirect().Status(fiber.StatusFound).To(url)
+}
@@ -8,8 +8,10 @@ import (
 	"i9chat/src/helpers"
 	"i9chat/src/services/cloudStorageService"
 	"regexp"
+	"strings"
 
 	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/go-ozzo/ozzo-validation/v4/is"
 )
 
 type authorizePPicUploadBody struct {
@@ -56,6 +58,26 @@ func (b authorizePPicUploadBody) Validate() error {
 	return helpers.ValidationError(err, "ucValidation.go", "authorizePPicUploadBody")
 }
 
+type authorizeOriginalPPicUploadBody struct {
+	PicMIME string `msgpack:"pic_mime"`
+	PicSize int64  `msgpack:"pic_size"`
+}
+
+func (b authorizeOriginalPPicUploadBody) Validate() error {
+
+	err := validation.ValidateStruct(&b,
+		validation.Field(&b.PicMIME, validation.Required,
+			validation.In("image/jpeg", "image/png", "image/webp").Error(`unsupported pic_mime; use one of ["image/jpeg", "image/png", "image/webp"]`),
+		),
+		validation.Field(&b.PicSize, validation.Required,
+			validation.Min(int64(1*1024)).Error("pic_size out of range; min: 1KiB; max: 20MeB"),
+			validation.Max(int64(20*1024*1024)).Error("pic_size out of range; min: 1KiB; max: 20MeB"),
+		),
+	)
+
+	return helpers.ValidationError(err, "ucValidation.go", "authorizeOriginalPPicUploadBody")
+}
+
 type changeProfilePictureBody struct {
 	ProfilePicCloudName string `msgpack:"profile_pic_cloud_name"`
 }
@@ -63,7 +85,7 @@ type changeProfilePictureBody struct {
 func (b changeProfilePictureBody) Validate(ctx context.Context) error {
 	err := validation.ValidateStruct(&b,
 		validation.Field(&b.ProfilePicCloudName, validation.Required, validation.Match(regexp.MustCompile(
-			`^small:uploads/user/profile_pics/[\w-/]+\w medium:uploads/user/profile_pics/[\w-/]+\w large:uploads/user/profile_pics/[\w-/]+\w$`,
+			`^(small:uploads/user/profile_pics/[\w-/]+\w medium:uploads/user/profile_pics/[\w-/]+\w large:uploads/user/profile_pics/[\w-/]+\w|original:uploads/user/profile_pics/[\w-/]+\w)$`,
 		)).Error("invalid profile pic cloud name")),
 	)
 
@@ -71,6 +93,11 @@ func (b changeProfilePictureBody) Validate(ctx context.Context) error {
 		return helpers.ValidationError(err, "ucValidation.go", "changeProfilePictureBody")
 	}
 
+	// an original is verified before it's processed
+	if strings.HasPrefix(b.ProfilePicCloudName, "original:") {
+		return nil
+	}
+
 	go func(ppicCn string) {
 		ctx := context.Background()
 
@@ -131,3 +158,77 @@ func (b updateMyGeolocationBody) Validate() error {
 	return helpers.ValidationError(err, "ucValidation.go", "updateMyGeolocationBody")
 
 }
+
+type searchMessagesQuery struct {
+	Q         string `query:"q"`
+	ChatType  string `query:"chat_type"`
+	ChatIdent string `query:"chat_ident"`
+	Limit     int64  `query:"limit"`
+	Offset    int64  `query:"offset"`
+}
+
+func (q searchMessagesQuery) Validate() error {
+	err := validation.ValidateStruct(&q,
+		validation.Field(&q.Q,
+			validation.Required,
+			validation.RuneLength(1, 100).Error("maximum search text length is 100 characters"),
+		),
+		validation.Field(&q.ChatType, validation.In("direct", "group").Error(`invalid chat_type; use one of ["direct", "group"]`)),
+		validation.Field(&q.ChatIdent, validation.When(q.ChatType != "", validation.Required).Else(validation.Empty)),
+		validation.Field(&q.Limit, validation.Min(int64(0)), validation.Max(int64(50))),
+		validation.Field(&q.Offset, validation.Min(int64(0))),
+	)
+
+	return helpers.ValidationError(err, "ucValidation.go", "searchMessagesQuery")
+}
+
+type starMessageBody struct {
+	MsgId string `msgpack:"msg_id"`
+}
+
+func (b starMessageBody) Validate() error {
+	err := validation.ValidateStruct(&b,
+		validation.Field(&b.MsgId, validation.Required, is.UUID),
+	)
+
+	return helpers.ValidationError(err, "ucValidation.go", "starMessageBody")
+}
+
+type blockUserBody struct {
+	Username string `msgpack:"username"`
+}
+
+func (b blockUserBody) Validate(clientUsername string) error {
+	err := validation.ValidateStruct(&b,
+		validation.Field(&b.Username,
+			validation.Required,
+			validation.NotIn(clientUsername).Error("you can't block yourself"),
+		),
+	)
+
+	return helpers.ValidationError(err, "ucValidation.go", "blockUserBody")
+}
+
+type unblockUserBody struct {
+	Username string `msgpack:"username"`
+}
+
+func (b unblockUserBody) Validate() error {
+	err := validation.ValidateStruct(&b,
+		validation.Field(&b.Username, validation.Required),
+	)
+
+	return helpers.ValidationError(err, "ucValidation.go", "unblockUserBody")
+}
+
+type revokeSessionBody struct {
+	SessionId string `msgpack:"session_id"`
+}
+
+func (b revokeSessionBody) Validate() error {
+	err := validation.ValidateStruct(&b,
+		validation.Field(&b.SessionId, validation.Required, is.UUIDv4),
+	)
+
+	return helpers.ValidationError(err, "ucValidation.go", "revokeSessionBody")
+}
@@ -4,7 +4,9 @@ import (
 	"i9chat/src/appTypes"
 	"i9chat/src/appTypes/UITypes"
 	"i9chat/src/helpers"
+	"i9chat/src/services/auth/sessionService"
 	"i9chat/src/services/userService"
+	"strings"
 
 	"github.com/gofiber/fiber/v3"
 )
@@ -46,6 +48,30 @@ func GetSessionUser(c fiber.Ctx) error {
 	return c.MsgPack(UITypes.ClientUser{Username: user.Username, ProfilePicUrl: user.ProfilePicUrl, Presence: user.Presence})
 }
 
+func AuthorizeOriginalPPicUpload(c fiber.Ctx) error {
+	ctx := c.Context()
+
+	clientUser := c.Locals("user").(appTypes.ClientUser)
+
+	var body authorizeOriginalPPicUploadBody
+
+	err := c.Bind().MsgPack(&body)
+	if err != nil {
+		return err
+	}
+
+	if err = body.Validate(); err != nil {
+		return err
+	}
+
+	respData, err := userService.AuthorizeOriginalPPicUpload(ctx, clientUser.Username, body.PicMIME)
+	if err != nil {
+		return err
+	}
+
+	return c.MsgPack(respData)
+}
+
 func ChangeProfilePicture(c fiber.Ctx) error {
 	ctx := c.Context()
 
@@ -62,6 +88,15 @@ func ChangeProfilePicture(c fiber.Ctx) error {
 		return err
 	}
 
+	if strings.HasPrefix(body.ProfilePicCloudName, "original:") {
+		respData, err := userService.ChangeProfilePictureFromOriginal(ctx, clientUser.Username, body.ProfilePicCloudName)
+		if err != nil {
+			return err
+		}
+
+		return c.MsgPack(respData)
+	}
+
 	respData, err := userService.ChangeProfilePicture(ctx, clientUser.Username, body.ProfilePicCloudName)
 	if err != nil {
 		return err
@@ -122,7 +157,9 @@ func SetMyLocation(c fiber.Ctx) error {
 func FindUser(c fiber.Ctx) error {
 	ctx := c.Context()
 
-	respData, err := userService.FindUser(ctx, c.Query("username"))
+	clientUser := c.Locals("user").(appTypes.ClientUser)
+
+	respData, err := userService.FindUser(ctx, clientUser.Username, c.Query("username"))
 
 	if err != nil {
 		return err
@@ -176,6 +213,169 @@ func GetMyChats(c fiber.Ctx) error {
 	return c.MsgPack(respData)
 }
 
+func SearchMessages(c fiber.Ctx) error {
+	ctx := c.Context()
+
+	clientUser := c.Locals("user").(appTypes.ClientUser)
+
+	var query searchMessagesQuery
+
+	if err := c.Bind().Query(&query); err != nil {
+		return err
+	}
+
+	if err := query.Validate(); err != nil {
+		return err
+	}
+
+	respData, err := userService.SearchMessages(ctx, clientUser.Username, query.Q, query.ChatType, query.ChatIdent, helpers.CoalesceInt(query.Limit, 20), query.Offset)
+	if err != nil {
+		return err
+	}
+
+	return c.MsgPack(respData)
+}
+
+func StarMessage(c fiber.Ctx) error {
+	ctx := c.Context()
+
+	clientUser := c.Locals("user").(appTypes.ClientUser)
+
+	var body starMessageBody
+
+	err := c.Bind().MsgPack(&body)
+	if err != nil {
+		return err
+	}
+
+	if err = body.Validate(); err != nil {
+		return err
+	}
+
+	respData, err := userService.StarMessage(ctx, clientUser.Username, body.MsgId)
+	if err != nil {
+		return err
+	}
+
+	return c.MsgPack(respData)
+}
+
+func UnstarMessage(c fiber.Ctx) error {
+	ctx := c.Context()
+
+	clientUser := c.Locals("user").(appTypes.ClientUser)
+
+	var body starMessageBody
+
+	err := c.Bind().MsgPack(&body)
+	if err != nil {
+		return err
+	}
+
+	if err = body.Validate(); err != nil {
+		return err
+	}
+
+	respData, err := userService.UnstarMessage(ctx, clientUser.Username, body.MsgId)
+	if err != nil {
+		return err
+	}
+
+	return c.MsgPack(respData)
+}
+
+func GetStarredMessages(c fiber.Ctx) error {
+	ctx := c.Context()
+
+	clientUser := c.Locals("user").(appTypes.ClientUser)
+
+	var query struct {
+		Limit  int64
+		Cursor float64
+	}
+
+	if err := c.Bind().Query(&query); err != nil {
+		return err
+	}
+
+	respData, err := userService.GetStarredMessages(ctx, clientUser.Username, helpers.CoalesceInt(query.Limit, 20), query.Cursor)
+	if err != nil {
+		return err
+	}
+
+	return c.MsgPack(respData)
+}
+
+func BlockUser(c fiber.Ctx) error {
+	ctx := c.Context()
+
+	clientUser := c.Locals("user").(appTypes.ClientUser)
+
+	var body blockUserBody
+
+	err := c.Bind().MsgPack(&body)
+	if err != nil {
+		return err
+	}
+
+	if err = body.Validate(clientUser.Username); err != nil {
+		return err
+	}
+
+	respData, err := userService.BlockUser(ctx, clientUser.Username, body.Username)
+	if err != nil {
+		return err
+	}
+
+	return c.MsgPack(respData)
+}
+
+func UnblockUser(c fiber.Ctx) error {
+	ctx := c.Context()
+
+	clientUser := c.Locals("user").(appTypes.ClientUser)
+
+	var body unblockUserBody
+
+	err := c.Bind().MsgPack(&body)
+	if err != nil {
+		return err
+	}
+
+	if err = body.Validate(); err != nil {
+		return err
+	}
+
+	respData, err := userService.UnblockUser(ctx, clientUser.Username, body.Username)
+	if err != nil {
+		return err
+	}
+
+	return c.MsgPack(respData)
+}
+
+func GetBlockedUsers(c fiber.Ctx) error {
+	ctx := c.Context()
+
+	clientUser := c.Locals("user").(appTypes.ClientUser)
+
+	var query struct {
+		Limit  int64
+		Cursor float64
+	}
+
+	if err := c.Bind().Query(&query); err != nil {
+		return err
+	}
+
+	respData, err := userService.GetBlockedUsers(ctx, clientUser.Username, helpers.CoalesceInt(query.Limit, 20), query.Cursor)
+	if err != nil {
+		return err
+	}
+
+	return c.MsgPack(respData)
+}
+
 func GetMyProfile(c fiber.Ctx) error {
 	ctx := c.Context()
 
@@ -189,7 +389,71 @@ func GetMyProfile(c fiber.Ctx) error {
 	return c.MsgPack(respData)
 }
 
+func GetMySessions(c fiber.Ctx) error {
+	ctx := c.Context()
+
+	clientUser := c.Locals("user").(appTypes.ClientUser)
+
+	respData, err := sessionService.GetSessions(ctx, clientUser.Username, clientUser.SessionId)
+	if err != nil {
+		return err
+	}
+
+	return c.MsgPack(respData)
+}
+
+func RevokeSession(c fiber.Ctx) error {
+	ctx := c.Context()
+
+	clientUser := c.Locals("user").(appTypes.ClientUser)
+
+	var body revokeSessionBody
+
+	err := c.Bind().MsgPack(&body)
+	if err != nil {
+		return err
+	}
+
+	if err = body.Validate(); err != nil {
+		return err
+	}
+
+	respData, err := sessionService.RevokeSessions(ctx, clientUser.Username, []string{body.SessionId})
+	if err != nil {
+		return err
+	}
+
+	if body.SessionId == clientUser.SessionId {
+		c.ClearCookie()
+	}
+
+	return c.MsgPack(respData)
+}
+
+func RevokeAllSessions(c fiber.Ctx) error {
+	ctx := c.Context()
+
+	clientUser := c.Locals("user").(appTypes.ClientUser)
+
+	respData, err := sessionService.RevokeAllSessions(ctx, clientUser.Username)
+	if err != nil {
+		return err
+	}
+
+	c.ClearCookie()
+
+	return c.MsgPack(respData)
+}
+
 func SignOut(c fiber.Ctx) error {
+	ctx := c.Context()
+
+	clientUser := c.Locals("user").(appTypes.ClientUser)
+
+	if _, err := sessionService.RevokeSessions(ctx, clientUser.Username, []string{clientUser.SessionId}); err != nil {
+		return err
+	}
+
 	c.ClearCookie()
 
 	return c.MsgPack("You've been logged out!")
@@ -7,6 +7,7 @@ import (
 	"os"
 	"runtime"
 	"strings"
+	"time"
 
 	"github.com/goccy/go-json"
 	"github.com/gofiber/fiber/v3"
@@ -30,7 +31,9 @@ func JoinWithCommaAnd(items ...string) string {
 	return strings.Join(items[:n-1], ", ") + ", and " + items[n-1]
 }
 
-func WSErrReply(err error, toAction string) map[string]any {
+// WSErrReply builds the error reply to a client's WebSocket action.
+// reqId, the client-supplied request id of the action, if any, is echoed back
+func WSErrReply(err error, toAction, reqId string) map[string]any {
 
 	errCode := fiber.StatusInternalServerError
 
@@ -47,10 +50,16 @@ func WSErrReply(err error, toAction string) map[string]any {
 		},
 	}
 
+	if reqId != "" {
+		errResp["reqId"] = reqId
+	}
+
 	return errResp
 }
 
-func WSReply(data any, toAction string) map[string]any {
+// WSReply builds the success reply to a client's WebSocket action.
+// reqId, the client-supplied request id of the action, if any, is echoed back
+func WSReply(data any, toAction, reqId string) map[string]any {
 
 	reply := map[string]any{
 		"event":    "server reply",
@@ -58,6 +67,10 @@ func WSReply(data any, toAction string) map[string]any {
 		"data":     data,
 	}
 
+	if reqId != "" {
+		reply["reqId"] = reqId
+	}
+
 	return reply
 }
 
@@ -176,3 +189,31 @@ func CoalesceInt(input int64, def int64) int64 {
 
 	return input
 }
+
+// MsgEditWindow is how long after sending a message its sender can still edit it.
+// It is set by the MSG_EDIT_WINDOW env var, as a duration (e.g. "15m"), and defaults to 15 minutes
+func MsgEditWindow() time.Duration {
+	return envDuration("MSG_EDIT_WINDOW", 15*time.Minute)
+}
+
+// MsgDeleteWindow is how long after sending a message its sender can still delete it for everyone.
+// It is set by the MSG_DELETE_WINDOW env var, as a duration (e.g. "1h"), and defaults to 1 hour
+func MsgDeleteWindow() time.Duration {
+	return envDuration("MSG_DELETE_WINDOW", time.Hour)
+}
+
+// MediaGCGracePeriod is how long an uploaded media is kept, unreferenced, before it's deleted,
+// and how long a superseded profile or group picture is kept, after it's replaced.
+// It is set by the MEDIA_GC_GRACE_PERIOD env var, as a duration (e.g. "48h"), and defaults to 48 hours
+func MediaGCGracePeriod() time.Duration {
+	return envDuration("MEDIA_GC_GRACE_PERIOD", 48*time.Hour)
+}
+
+func envDuration(key string, def time.Duration) time.Duration {
+	d, err := time.ParseDuration(os.Getenv(key))
+	if err != nil || d <= 0 {
+		return def
+	}
+
+	return d
+}
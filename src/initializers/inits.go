@@ -2,13 +2,18 @@ package initializers
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"i9chat/src/appGlobals"
 	"i9chat/src/backgroundWorkers"
 	"i9chat/src/helpers"
+	"i9chat/src/services/cloudStorageService"
 	"os"
 
 	"cloud.google.com/go/storage"
 	"github.com/joho/godotenv"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
 	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
 	"github.com/redis/go-redis/v9"
 	"github.com/redis/go-redis/v9/maintnotifications"
@@ -25,6 +30,62 @@ func initGCSClient() error {
 	return nil
 }
 
+// initStorageBackend sets up the media storage backend chosen by STORAGE_BACKEND:
+// "gcs" (the default), "s3", for S3-compatible storages, or "local", which needs no cloud credentials
+func initStorageBackend() error {
+	switch os.Getenv("STORAGE_BACKEND") {
+	case "local":
+		dir := os.Getenv("LOCAL_STORAGE_DIR")
+		if dir == "" {
+			dir = "local_storage"
+		}
+
+		baseUrl := os.Getenv("LOCAL_STORAGE_BASE_URL")
+		if baseUrl == "" {
+			baseUrl = "http://localhost:8000"
+		}
+
+		secret := os.Getenv("LOCAL_STORAGE_SECRET")
+		if secret == "" {
+			return errors.New("LOCAL_STORAGE_SECRET is required by the local storage backend")
+		}
+
+		cloudStorageService.UseBackend(cloudStorageService.NewLocalBackend(dir, baseUrl, secret))
+	case "s3":
+		s3Client, err := minio.New(os.Getenv("S3_ENDPOINT"), &minio.Options{
+			Creds:  credentials.NewStaticV4(os.Getenv("S3_ACCESS_KEY"), os.Getenv("S3_SECRET_KEY"), ""),
+			Secure: os.Getenv("S3_USE_SSL") == "true",
+			Region: os.Getenv("S3_REGION"),
+		})
+		if err != nil {
+			return err
+		}
+
+		// the app serves the s3 backend's uploads, as it does the local backend's signed urls
+		baseUrl := os.Getenv("S3_UPLOAD_BASE_URL")
+		if baseUrl == "" {
+			baseUrl = "http://localhost:8000"
+		}
+
+		secret := os.Getenv("S3_UPLOAD_SECRET")
+		if secret == "" {
+			return errors.New("S3_UPLOAD_SECRET is required by the s3 storage backend")
+		}
+
+		cloudStorageService.UseBackend(cloudStorageService.NewS3Backend(s3Client, os.Getenv("S3_BUCKET_NAME"), baseUrl, secret))
+	case "", "gcs":
+		if err := initGCSClient(); err != nil {
+			return err
+		}
+
+		cloudStorageService.UseBackend(cloudStorageService.NewGCSBackend(appGlobals.GCSClient, os.Getenv("GCS_BUCKET_NAME")))
+	default:
+		return fmt.Errorf("unknown STORAGE_BACKEND: %q", os.Getenv("STORAGE_BACKEND"))
+	}
+
+	return nil
+}
+
 func initNeo4jDriver() error {
 	driver, err := neo4j.NewDriverWithContext(os.Getenv("NEO4J_URL"), neo4j.BasicAuth(os.Getenv("NEO4J_USER"), os.Getenv("NEO4J_PASSWORD"), ""))
 	if err != nil {
@@ -96,6 +157,16 @@ func initNeo4jDriver() error {
 			return nil, err
 		}
 
+		_, err = tx.Run(ctx, `/* cypher */ CREATE CONSTRAINT unique_migration IF NOT EXISTS FOR (mig:Migration) REQUIRE mig.name IS UNIQUE`, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = tx.Run(ctx, `/* cypher */ CREATE FULLTEXT INDEX message_search IF NOT EXISTS FOR (m:DirectMessage|GroupMessage) ON EACH [m.search_text]`, nil)
+		if err != nil {
+			return nil, err
+		}
+
 		return nil, nil
 	})
 
@@ -103,11 +174,115 @@ func initNeo4jDriver() error {
 		return err2
 	}
 
+	// messages sent before message search are given their search text
+	err = runMigration(ctx, sess, "message_search_text", `/* cypher */
+		CYPHER 25
+
+		MATCH (m:DirectMessage|GroupMessage)
+		WHERE m.search_text IS NULL AND m.deleted_at IS NULL
+
+		CALL (m) {
+			WITH m, apoc.convert.fromJsonMap(m.content).props AS props
+			SET m.search_text = coalesce(props.text_content, props.caption, props.name, "")
+		} IN TRANSACTIONS OF 10000 ROWS
+		`)
+	if err != nil {
+		return err
+	}
+
+	// groups created before public groups stay joinable by id, as they were
+	err = runMigration(ctx, sess, "group_is_public", `/* cypher */
+		CYPHER 25
+
+		MATCH (g:Group)
+		WHERE g.is_public IS NULL
+
+		CALL (g) {
+			SET g.is_public = true
+		} IN TRANSACTIONS OF 10000 ROWS
+		`)
+	if err != nil {
+		return err
+	}
+
+	// groups created before group owners are given one: the admin who created the group,
+	// else the admin whose chat of the group began the earliest
+	err = runMigration(ctx, sess, "group_owner", `/* cypher */
+		CYPHER 25
+
+		MATCH (g:Group)
+		WHERE g.deleted_at IS NULL
+			AND NOT EXISTS { (:User)-[:IS_MEMBER_OF { role: "owner" }]->(g) }
+
+		CALL (g) {
+			MATCH (admin:User)-[adminMem:IS_MEMBER_OF { role: "admin" }]->(g),
+				(admin)-[:HAS_CHAT]->(adminChat:GroupChat)-[:WITH_GROUP]->(g)
+
+			LET isCreator = EXISTS {
+				(gact:GroupChatEntry{ che_type: "group activity" })-[:IN_GROUP_CHAT]->(adminChat)
+				WHERE gact.info STARTS WITH "You created "
+			}
+
+			CALL (adminChat) {
+				MATCH (che:GroupChatEntry)-[:IN_GROUP_CHAT]->(adminChat)
+
+				RETURN min(che.cursor) AS chatStart
+			}
+
+			WITH adminMem, isCreator, chatStart
+			ORDER BY isCreator DESC, chatStart ASC
+			LIMIT 1
+
+			SET adminMem.role = "owner"
+		} IN TRANSACTIONS OF 1000 ROWS
+		`)
+	if err != nil {
+		return err
+	}
+
 	appGlobals.Neo4jDriver = driver
 
 	return nil
 }
 
+// runMigration runs the data migration, cypher, unless the migration, name, has already run,
+// then records that it has, with a (:Migration) node.
+// Server instances starting together may both run a migration, so it must be idempotent.
+// CALL { ... } IN TRANSACTIONS only runs in an auto-commit transaction
+func runMigration(ctx context.Context, sess neo4j.SessionWithContext, name, cypher string) error {
+	res, err := sess.Run(ctx, `/* cypher */ RETURN EXISTS { (:Migration{ name: $name }) } AS ran`, map[string]any{"name": name})
+	if err != nil {
+		return err
+	}
+
+	rec, err := res.Single(ctx)
+	if err != nil {
+		return err
+	}
+
+	if ran, _ := rec.Get("ran"); ran.(bool) {
+		return nil
+	}
+
+	res, err = sess.Run(ctx, cypher, nil)
+	if err != nil {
+		return err
+	}
+
+	if _, err := res.Consume(ctx); err != nil {
+		return err
+	}
+
+	res, err = sess.Run(ctx, `/* cypher */ MERGE (mig:Migration{ name: $name }) ON CREATE SET mig.ran_at = datetime()`, map[string]any{"name": name})
+	if err != nil {
+		return err
+	}
+
+	_, err = res.Consume(ctx)
+
+	return err
+}
+
 func initRedisClient() error {
 	client := redis.NewClient(&redis.Options{
 		Addr:     os.Getenv("REDIS_ADDR"),
@@ -153,7 +328,7 @@ func InitApp() error {
 		return err
 	}
 
-	if err := initGCSClient(); err != nil {
+	if err := initStorageBackend(); err != nil {
 		return err
 	}
 
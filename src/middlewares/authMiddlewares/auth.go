@@ -4,6 +4,7 @@ import (
 	"encoding/base64"
 	"i9chat/src/appTypes"
 	"i9chat/src/helpers"
+	"i9chat/src/services/auth/sessionService"
 	"i9chat/src/services/securityServices"
 	"os"
 
@@ -34,6 +35,19 @@ func UserAuth(c fiber.Ctx) error {
 		return err
 	}
 
+	if clientUser.SessionId == "" {
+		return c.Status(fiber.StatusUnauthorized).SendString("authentication required")
+	}
+
+	active, err := sessionService.SessionActive(c.Context(), clientUser.Username, clientUser.SessionId)
+	if err != nil {
+		return err
+	}
+
+	if !active {
+		return c.Status(fiber.StatusUnauthorized).SendString("session revoked or expired; sign in again")
+	}
+
 	c.Locals("user", clientUser)
 
 	return c.Next()
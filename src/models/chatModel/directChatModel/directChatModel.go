@@ -4,10 +4,12 @@ import (
 	"context"
 	"fmt"
 	"i9chat/src/appGlobals"
+	"i9chat/src/appTypes"
 	"i9chat/src/appTypes/UITypes"
 	"i9chat/src/helpers"
 	"i9chat/src/models/db"
 	"i9chat/src/models/modelHelpers"
+	"time"
 
 	"github.com/gofiber/fiber/v3"
 	"github.com/redis/go-redis/v9"
@@ -53,7 +55,7 @@ func SendMessage(ctx context.Context, clientUsername, partnerUsername, msgConten
 		MERGE (partnerUser)-[:HAS_CHAT]->(partnerChat:DirectChat{ owner_username: $partner_username, partner_username: $client_username })-[:WITH_USER]->(clientUser)
 
 		WITH clientUser, clientChat, partnerUser, partnerChat, cheNextVal, ffu, ftu
-		CREATE (message:DirectMessage:DirectChatEntry{ id: randomUUID(), che_type: "message", content: $message_content, delivery_status: "sent", created_at: $at, cursor: cheNextVal }),
+		CREATE (message:DirectMessage:DirectChatEntry{ id: randomUUID(), che_type: "message", content: $message_content, search_text: $search_text, delivery_status: "sent", created_at: $at, cursor: cheNextVal }),
 			(clientUser)-[:SENDS_MESSAGE]->(message)-[:IN_DIRECT_CHAT]->(clientChat),
 			(message)-[:IN_DIRECT_CHAT { receipt: "received" }]->(partnerChat)
 		
@@ -65,6 +67,7 @@ func SendMessage(ctx context.Context, clientUsername, partnerUsername, msgConten
 			"client_username":           clientUsername,
 			"partner_username":          partnerUsername,
 			"message_content":           msgContent,
+			"search_text":               modelHelpers.MsgSearchText(msgContent),
 			"at":                        at,
 			"direct_che_serial_counter": "$directCHESC$",
 		},
@@ -171,7 +174,7 @@ func ReplyToMessage(ctx context.Context, clientUsername, partnerUsername, target
 		MERGE (partnerUser)-[:HAS_CHAT]->(partnerChat:DirectChat{ owner_username: $partner_username, partner_username: $client_username })-[:WITH_USER]->(clientUser)
 
 		WITH clientUser, clientChat, partnerUser, partnerChat, targetMsg, targetMsgSender, cheNextVal, ffu, ftu
-		CREATE (replyMsg:DirectMessage:DirectChatEntry{ id: randomUUID(), che_type: "message", content: $message_content, delivery_status: "sent", created_at: $at, cursor: cheNextVal }),
+		CREATE (replyMsg:DirectMessage:DirectChatEntry{ id: randomUUID(), che_type: "message", content: $message_content, search_text: $search_text, delivery_status: "sent", created_at: $at, cursor: cheNextVal }),
 			(clientUser)-[:SENDS_MESSAGE]->(replyMsg)-[:IN_DIRECT_CHAT]->(clientChat),
 			(replyMsg)-[:IN_DIRECT_CHAT { receipt: "received" }]->(partnerChat),
 			(replyMsg)-[:REPLIES_TO]->(targetMsg)
@@ -187,6 +190,7 @@ func ReplyToMessage(ctx context.Context, clientUsername, partnerUsername, target
 			"client_username":           clientUsername,
 			"partner_username":          partnerUsername,
 			"message_content":           msgContent,
+			"search_text":               modelHelpers.MsgSearchText(msgContent),
 			"target_msg_id":             targetMsgId,
 			"at":                        at,
 			"direct_che_serial_counter": "$directCHESC$",
@@ -211,13 +215,215 @@ type RxnToMessage struct {
 	ToMsgId string `msgpack:"to_msg_id" db:"to_msg_id"`
 }
 
+type EditedMessage struct {
+	Content  map[string]any `msgpack:"content" db:"content"`
+	EditedAt int64          `msgpack:"edited_at" db:"edited_at"`
+}
+
+// EditMessage replaces the text of the client's text message, or the caption of their photo or video message.
+// The replaced content is kept in the message's edit history
+func EditMessage(ctx context.Context, clientUsername, partnerUsername, msgId, newText string, at int64) (EditedMessage, error) {
+	res, err := db.Query(
+		ctx,
+		`/*cypher*/
+		CYPHER 25
+
+		MATCH (clientUser:User{ username: $client_username })-[:SENDS_MESSAGE]->(message:DirectMessage{ id: $message_id })-[:IN_DIRECT_CHAT]->(:DirectChat{ owner_username: $client_username, partner_username: $partner_username })
+		WHERE message.created_at >= $edit_window_start AND message.deleted_at IS NULL
+
+		WITH message, apoc.convert.fromJsonMap(message.content) AS content
+		WITH message, content,
+			CASE content.type WHEN "text" THEN "text_content" WHEN "photo" THEN "caption" WHEN "video" THEN "caption" END AS editedProp
+		WHERE editedProp IS NOT NULL
+
+		WITH message, content, apoc.map.setKey(content, "props", apoc.map.setKey(content.props, editedProp, $new_text)) AS newContent
+
+		SET message.edit_history = coalesce(message.edit_history, []) + [apoc.convert.toJson({ content: content, replaced_at: $at })],
+			message.content = apoc.convert.toJson(newContent),
+			message.search_text = $new_text,
+			message.edited_at = $at
+
+		RETURN { content: newContent, edited_at: message.edited_at } AS edited_msg
+		`,
+		map[string]any{
+			"client_username":   clientUsername,
+			"partner_username":  partnerUsername,
+			"message_id":        msgId,
+			"new_text":          newText,
+			"at":                at,
+			"edit_window_start": time.Now().UTC().Add(-helpers.MsgEditWindow()).UnixMilli(),
+		},
+	)
+	if err != nil {
+		helpers.LogError(err)
+		return EditedMessage{}, fiber.ErrInternalServerError
+	}
+
+	editedMessage := modelHelpers.RKeyGet[EditedMessage](res.Records, "edited_msg")
+
+	return editedMessage, nil
+}
+
+// SetProcessedMessageMedia replaces the original image of the client's photo message, originalCloudName,
+// with the images processed from it, mediaCloudName. It returns the message's new content,
+// or nil, if the message no longer exists, or no longer has the original
+func SetProcessedMessageMedia(ctx context.Context, clientUsername, msgId, originalCloudName, mediaCloudName string) (map[string]any, error) {
+	res, err := db.Query(
+		ctx,
+		`/*cypher*/
+		CYPHER 25
+
+		MATCH (:User{ username: $client_username })-[:SENDS_MESSAGE]->(message:DirectMessage{ id: $message_id })
+		WHERE message.deleted_at IS NULL
+
+		WITH message, apoc.convert.fromJsonMap(message.content) AS content
+		WHERE content.props.media_cloud_name = $original_cloud_name
+
+		WITH message, apoc.map.setKey(content, "props", apoc.map.setKey(content.props, "media_cloud_name", $media_cloud_name)) AS newContent
+
+		SET message.content = apoc.convert.toJson(newContent)
+
+		RETURN newContent AS content
+		`,
+		map[string]any{
+			"client_username":     clientUsername,
+			"message_id":          msgId,
+			"original_cloud_name": originalCloudName,
+			"media_cloud_name":    mediaCloudName,
+		},
+	)
+	if err != nil {
+		helpers.LogError(err)
+		return nil, fiber.ErrInternalServerError
+	}
+
+	content := modelHelpers.RKeyGet[map[string]any](res.Records, "content")
+
+	return content, nil
+}
+
+// MarkMessageMediaFailed marks the client's photo message, whose original image, originalCloudName,
+// failed to be processed. It returns the message's new content,
+// or nil, if the message no longer exists, or no longer has the original
+func MarkMessageMediaFailed(ctx context.Context, clientUsername, msgId, originalCloudName string) (map[string]any, error) {
+	res, err := db.Query(
+		ctx,
+		`/*cypher*/
+		CYPHER 25
+
+		MATCH (:User{ username: $client_username })-[:SENDS_MESSAGE]->(message:DirectMessage{ id: $message_id })
+		WHERE message.deleted_at IS NULL
+
+		WITH message, apoc.convert.fromJsonMap(message.content) AS content
+		WHERE content.props.media_cloud_name = $original_cloud_name
+
+		WITH message, apoc.map.setKey(content, "props", apoc.map.setKey(content.props, "media_processing_failed", true)) AS newContent
+
+		SET message.content = apoc.convert.toJson(newContent)
+
+		RETURN newContent AS content
+		`,
+		map[string]any{
+			"client_username":     clientUsername,
+			"message_id":          msgId,
+			"original_cloud_name": originalCloudName,
+		},
+	)
+	if err != nil {
+		helpers.LogError(err)
+		return nil, fiber.ErrInternalServerError
+	}
+
+	content := modelHelpers.RKeyGet[map[string]any](res.Records, "content")
+
+	return content, nil
+}
+
+// DeleteMessagesForMe removes the messages from the client's chat only
+func DeleteMessagesForMe(ctx context.Context, clientUsername, partnerUsername string, msgIds []any) (bool, error) {
+	res, err := db.Query(
+		ctx,
+		`/*cypher*/
+		CYPHER 25
+
+		MATCH (:DirectChat{ owner_username: $client_username, partner_username: $partner_username })<-[rel:IN_DIRECT_CHAT]-(message:DirectMessage WHERE message.id IN $message_ids)
+
+		DELETE rel
+
+		RETURN count(rel) > 0 AS done
+		`,
+		map[string]any{
+			"client_username":  clientUsername,
+			"partner_username": partnerUsername,
+			"message_ids":      msgIds,
+		},
+	)
+	if err != nil {
+		helpers.LogError(err)
+		return false, fiber.ErrInternalServerError
+	}
+
+	done := modelHelpers.RKeyGet[bool](res.Records, "done")
+
+	return done, nil
+}
+
+type DeletedMessage struct {
+	Content   map[string]any `msgpack:"-" db:"content"`
+	DeletedAt int64          `msgpack:"deleted_at" db:"deleted_at"`
+}
+
+// DeleteMessageForEveryone replaces the content of the client's message with a tombstone,
+// in both chats, discards its edit history, and unpins it.
+//
+// Content is the content the message had, whose media is to be deleted
+func DeleteMessageForEveryone(ctx context.Context, clientUsername, partnerUsername, msgId string, at int64) (DeletedMessage, error) {
+	res, err := db.Query(
+		ctx,
+		`/*cypher*/
+		CYPHER 25
+
+		MATCH (clientUser:User{ username: $client_username })-[:SENDS_MESSAGE]->(message:DirectMessage{ id: $message_id })-[:IN_DIRECT_CHAT]->(:DirectChat{ owner_username: $client_username, partner_username: $partner_username })
+		WHERE message.created_at >= $delete_window_start AND message.deleted_at IS NULL
+
+		WITH message, apoc.convert.fromJsonMap(message.content) AS content
+
+		SET message.content = $deleted_msg_content, message.deleted_at = $at
+		REMOVE message.edit_history, message.edited_at, message.search_text
+
+		CALL (message) {
+			MATCH (message)<-[pin:HAS_PINNED]-()
+			DELETE pin
+		}
+
+		RETURN { content: content, deleted_at: message.deleted_at } AS deleted_msg
+		`,
+		map[string]any{
+			"client_username":     clientUsername,
+			"partner_username":    partnerUsername,
+			"message_id":          msgId,
+			"deleted_msg_content": appTypes.DeletedMsgContentJson,
+			"at":                  at,
+			"delete_window_start": time.Now().UTC().Add(-helpers.MsgDeleteWindow()).UnixMilli(),
+		},
+	)
+	if err != nil {
+		helpers.LogError(err)
+		return DeletedMessage{}, fiber.ErrInternalServerError
+	}
+
+	deletedMessage := modelHelpers.RKeyGet[DeletedMessage](res.Records, "deleted_msg")
+
+	return deletedMessage, nil
+}
+
 func ReactToMessage(ctx context.Context, clientUsername, partnerUsername, msgId, emoji string, at int64) (RxnToMessage, error) {
 	res, err := db.Query(
 		ctx,
 		`/*cypher*/
 		CYPHER 25
 
-		MATCH (clientUser)-[:HAS_CHAT]->(clientChat:Chat{ owner_username: $client_username, partner_username: $partner_username })-[:WITH_USER]->(partnerUser),
+		MATCH (clientUser)-[:HAS_CHAT]->(clientChat:DirectChat{ owner_username: $client_username, partner_username: $partner_username })-[:WITH_USER]->(partnerUser),
 			(clientChat)<-[:IN_DIRECT_CHAT]-(message:DirectMessage{ id: $message_id }),
 			(partnerUser)-[:HAS_CHAT]->(partnerChat)-[:WITH_USER]->(clientUser)
 
@@ -298,18 +504,86 @@ func RemoveReactionToMessage(ctx context.Context, clientUsername, partnerUsernam
 	return CHEId, nil
 }
 
-func ChatHistory(ctx context.Context, clientUsername, partnerUsername string, limit int64, cursor float64) ([]UITypes.ChatHistoryEntry, error) {
-	cheMembers, err := redisDB().ZRevRangeByScoreWithScores(ctx, fmt.Sprintf("direct_chat:owner:%s:partner:%s:history", clientUsername, partnerUsername), &redis.ZRangeBy{
-		Max:   helpers.MaxCursor(cursor),
-		Min:   "-inf",
-		Count: limit,
-	}).Result()
+// PinMessage pins a message of the chat, for both partners, unless maxPinned messages are already pinned.
+// The chats are locked while their pinned messages are counted, so concurrent pins can't exceed the limit
+func PinMessage(ctx context.Context, clientUsername, partnerUsername, msgId string, at int64, maxPinned int) (bool, error) {
+	res, err := db.Query(
+		ctx,
+		`/*cypher*/
+		CYPHER 25
+
+		MATCH (clientChat:DirectChat{ owner_username: $client_username, partner_username: $partner_username })<-[:IN_DIRECT_CHAT]-(message:DirectMessage{ id: $message_id }),
+			(partnerChat:DirectChat{ owner_username: $partner_username, partner_username: $client_username })
+		WHERE message.deleted_at IS NULL
+
+		CALL apoc.lock.nodes([clientChat, partnerChat])
+
+		WITH clientChat, partnerChat, message
+		WHERE NOT EXISTS { (clientChat)-[:HAS_PINNED]->(message) }
+			AND COUNT { (clientChat)-[:HAS_PINNED]->() } < $max_pinned
+
+		CREATE (clientChat)-[:HAS_PINNED { at: $at, pinned_by: $client_username }]->(message),
+			(partnerChat)-[:HAS_PINNED { at: $at, pinned_by: $client_username }]->(message)
+
+		RETURN true AS done
+		`,
+		map[string]any{
+			"client_username":  clientUsername,
+			"partner_username": partnerUsername,
+			"message_id":       msgId,
+			"at":               at,
+			"max_pinned":       maxPinned,
+		},
+	)
 	if err != nil {
 		helpers.LogError(err)
-		return nil, fiber.ErrInternalServerError
+		return false, fiber.ErrInternalServerError
+	}
+
+	done := modelHelpers.RKeyGet[bool](res.Records, "done")
+
+	return done, nil
+}
+
+// UnpinMessage unpins a pinned message of the chat, for both partners
+func UnpinMessage(ctx context.Context, clientUsername, partnerUsername, msgId string) (bool, error) {
+	res, err := db.Query(
+		ctx,
+		`/*cypher*/
+		CYPHER 25
+
+		MATCH (:DirectChat{ owner_username: $client_username, partner_username: $partner_username })-[clientPin:HAS_PINNED]->(message:DirectMessage{ id: $message_id }),
+			(:DirectChat{ owner_username: $partner_username, partner_username: $client_username })-[partnerPin:HAS_PINNED]->(message)
+
+		DELETE clientPin, partnerPin
+
+		RETURN true AS done
+		`,
+		map[string]any{
+			"client_username":  clientUsername,
+			"partner_username": partnerUsername,
+			"message_id":       msgId,
+		},
+	)
+	if err != nil {
+		helpers.LogError(err)
+		return false, fiber.ErrInternalServerError
 	}
 
-	history, err := modelHelpers.CHEMembersForUICHEs(ctx, cheMembers, "direct")
+	done := modelHelpers.RKeyGet[bool](res.Records, "done")
+
+	return done, nil
+}
+
+// ChatHistory reads the client's chat history in a window of the mode, relative to the cursor, or the entry, anchorCHEId.
+// See modelHelpers.HistoryWindow
+func ChatHistory(ctx context.Context, clientUsername, partnerUsername, mode, anchorCHEId string, limit int64, cursor float64) ([]UITypes.ChatHistoryEntry, error) {
+	cheMembers, err := modelHelpers.HistoryWindow(ctx, redisDB(), fmt.Sprintf("direct_chat:owner:%s:partner:%s:history", clientUsername, partnerUsername), mode, anchorCHEId, limit, cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	history, err := modelHelpers.CHEMembersForUICHEs(ctx, cheMembers, "direct", clientUsername)
 	if err != nil {
 		helpers.LogError(err)
 		return nil, fiber.ErrInternalServerError
@@ -317,3 +591,20 @@ func ChatHistory(ctx context.Context, clientUsername, partnerUsername string, li
 
 	return history, nil
 }
+
+// PinnedMessages returns the pinned messages of the chat, the latest pinned first
+func PinnedMessages(ctx context.Context, clientUsername, partnerUsername string) ([]UITypes.PinnedMessage, error) {
+	pinnedMsgMembers, err := redisDB().ZRevRangeWithScores(ctx, fmt.Sprintf("direct_chat:owner:%s:partner:%s:pinned_messages", clientUsername, partnerUsername), 0, -1).Result()
+	if err != nil {
+		helpers.LogError(err)
+		return nil, fiber.ErrInternalServerError
+	}
+
+	pinnedMessages, err := modelHelpers.PinnedMsgMembersForUIPinnedMsgs(ctx, pinnedMsgMembers, "direct", clientUsername)
+	if err != nil {
+		helpers.LogError(err)
+		return nil, fiber.ErrInternalServerError
+	}
+
+	return pinnedMessages, nil
+}
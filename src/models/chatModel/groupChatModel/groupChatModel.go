@@ -4,10 +4,12 @@ import (
 	"context"
 	"fmt"
 	"i9chat/src/appGlobals"
+	"i9chat/src/appTypes"
 	"i9chat/src/appTypes/UITypes"
 	"i9chat/src/helpers"
 	"i9chat/src/models/db"
 	"i9chat/src/models/modelHelpers"
+	"time"
 
 	"github.com/gofiber/fiber/v3"
 	"github.com/redis/go-redis/v9"
@@ -22,6 +24,7 @@ type NewGroup struct {
 	Name           string         `msgpack:"name" db:"name"`
 	Description    string         `msgpack:"description" db:"description"`
 	PictureUrl     string         `msgpack:"picture_url" db:"picture_url"`
+	IsPublic       bool           `msgpack:"is_public" db:"is_public"`
 	CreatedAt      int64          `msgpack:"created_at" db:"created_at"`
 	ChatCursor     int64          `msgpack:"-" db:"chat_cursor"`
 	InitUsers      []any          `msgpack:"-" db:"init_users"`
@@ -29,7 +32,7 @@ type NewGroup struct {
 	InitUsersCHEs  map[string]any `msgpack:"-" db:"init_users_ches"`
 }
 
-func New(ctx context.Context, clientUsername, name, description, pictureCloudName string, initUsers []string, createdAt int64) (NewGroup, error) {
+func New(ctx context.Context, clientUsername, name, description, pictureCloudName string, isPublic bool, initUsers []string, createdAt int64) (NewGroup, error) {
 	res, err := db.Query(
 		ctx,
 		`/*cypher*/
@@ -46,9 +49,9 @@ func New(ctx context.Context, clientUsername, name, description, pictureCloudNam
 
 		CALL apoc.atomic.add(serialCounter, 'value', 2) YIELD newValue AS cheNextVal
 
-		CREATE (group:Group{ id: randomUUID(), name: $name, description: $description, picture_url: $picture_url, created_at: $created_at })
+		CREATE (group:Group{ id: randomUUID(), name: $name, description: $description, picture_url: $picture_url, is_public: $is_public, created_at: $created_at })
 
-		CREATE (clientUser)-[:IS_MEMBER_OF { role: "admin" }]->(group),
+		CREATE (clientUser)-[:IS_MEMBER_OF { role: "owner", since: $created_at }]->(group),
 			(clientUser)-[:HAS_CHAT]->(clientChat:GroupChat{ owner_username: $client_username, group_id: group.id, cursor: cheNextVal })-[:WITH_GROUP]->(group),
 			(cligact1:GroupChatEntry{ che_id: randomUUID(), che_type: "group activity", info: "You created " + $name, cursor: cheNextVal - 1 })-[:IN_GROUP_CHAT]->(clientChat),
 			(cligact2:GroupChatEntry{ che_id: randomUUID(), che_type: "group activity", info: "You added " + $init_users_str , cursor: cheNextVal })-[:IN_GROUP_CHAT]->(clientChat)
@@ -57,7 +60,7 @@ func New(ctx context.Context, clientUsername, name, description, pictureCloudNam
 		UNWIND initUserRows AS initUser
 
 		WITH group, initUser, clientUserCHEs, cheNextVal
-		CREATE (initUser)-[:IS_MEMBER_OF { role: "member" }]->(group),
+		CREATE (initUser)-[:IS_MEMBER_OF { role: "member", since: $created_at }]->(group),
 			(initUser)-[:HAS_CHAT]->(initUserChat:GroupChat{ owner_username: initUser.username, group_id: group.id, cursor: cheNextVal })-[:WITH_GROUP]->(group),
 			(initusergact1:GroupChatEntry{ che_id: randomUUID(), che_type: "group activity", info: $client_username + " created " + $name, cursor: cheNextVal - 1 })-[:IN_GROUP_CHAT]->(initUserChat),
 			(initusergact2:GroupChatEntry{ che_id: randomUUID(), che_type: "group activity", info: "You were added", cursor: cheNextVal })-[:IN_GROUP_CHAT]->(initUserChat)
@@ -66,13 +69,14 @@ func New(ctx context.Context, clientUsername, name, description, pictureCloudNam
 			reduce(accm = {}, x IN collect({ inituser: initUser.username, gact1: initusergact1, gact2: initusergact2}) | apoc.map.setKey(accm, x.inituser, [{che_id: x.gact1.che_id, che_type: x.gact1.che_type, info: x.gact1.info, cursor: x.gact1.cursor}, {che_id: x.gact2.che_id, che_type: x.gact2.che_type, info: x.gact2.info, cursor: x.gact2.cursor }])) AS initUsersCHEs
 
 		WITH DISTINCT group, clientUserCHEs, initUsersCHEs, cheNextVal
-		RETURN group { .id, .name, .description, .picture_url, .created_at, chat_cursor: cheNextVal, init_users: $init_users, client_user_ches: clientUserCHEs, init_users_ches: initUsersCHEs } AS new_group
+		RETURN group { .id, .name, .description, .picture_url, .is_public, .created_at, chat_cursor: cheNextVal, init_users: $init_users, client_user_ches: clientUserCHEs, init_users_ches: initUsersCHEs } AS new_group
 		`,
 		map[string]any{
 			"client_username":          clientUsername,
 			"name":                     name,
 			"description":              description,
 			"picture_url":              pictureCloudName,
+			"is_public":                isPublic,
 			"init_users":               initUsers,
 			"init_users_str":           helpers.JoinWithCommaAnd(initUsers...),
 			"created_at":               createdAt,
@@ -93,6 +97,7 @@ type EditActivity struct {
 	ClientUserCHE map[string]any `msgpack:"-" db:"client_user_che"`
 	MemInfo       string         `msgpack:"-" db:"mem_info"`
 	MemberUserCHE map[string]any `msgpack:"-" db:"member_user_che"`
+	OldPictureUrl string         `msgpack:"-" db:"old_picture_url"`
 }
 
 func ChangeName(ctx context.Context, groupId, clientUsername, newName string) (EditActivity, error) {
@@ -102,8 +107,9 @@ func ChangeName(ctx context.Context, groupId, clientUsername, newName string) (E
 		CYPHER 25
 
 		MATCH (group)<-[:WITH_GROUP]-(clientChat:GroupChat{ owner_username: $client_username, group_id: $group_id })<-[:HAS_CHAT]-(clientUser),
-			(clientUser)-[:IS_MEMBER_OF { role: "admin" }]->(group)
-		
+			(clientUser)-[mem:IS_MEMBER_OF]->(group)
+		WHERE mem.role IN ["owner", "admin"] OR coalesce(group.members_can_edit_info, false)
+
 		MERGE (serialCounter:GroupCHESerialCounter{ name: $group_che_serial_counter })
 		ON CREATE SET serialCounter.value = 0
 
@@ -147,8 +153,9 @@ func ChangeDescription(ctx context.Context, groupId, clientUsername, newDescript
 		CYPHER 25
 
 		MATCH (group)<-[:WITH_GROUP]-(clientChat:GroupChat{ owner_username: $client_username, group_id: $group_id })<-[:HAS_CHAT]-(clientUser),
-			(clientUser)-[:IS_MEMBER_OF { role: "admin" }]->(group)
-		
+			(clientUser)-[mem:IS_MEMBER_OF]->(group)
+		WHERE mem.role IN ["owner", "admin"] OR coalesce(group.members_can_edit_info, false)
+
 		MERGE (serialCounter:GroupCHESerialCounter{ name: $group_che_serial_counter })
 		ON CREATE SET serialCounter.value = 0
 
@@ -192,8 +199,9 @@ func ChangePicture(ctx context.Context, groupId, clientUsername, pictureCloudNam
 		CYPHER 25
 
 		MATCH (group)<-[:WITH_GROUP]-(clientChat:GroupChat{ owner_username: $client_username, group_id: $group_id })<-[:HAS_CHAT]-(clientUser),
-			(clientUser)-[:IS_MEMBER_OF { role: "admin" }]->(group)
-		
+			(clientUser)-[mem:IS_MEMBER_OF]->(group)
+		WHERE mem.role IN ["owner", "admin"] OR coalesce(group.members_can_edit_info, false)
+
 		MERGE (serialCounter:GroupCHESerialCounter{ name: $group_che_serial_counter })
 		ON CREATE SET serialCounter.value = 0
 
@@ -203,13 +211,13 @@ func ChangePicture(ctx context.Context, groupId, clientUsername, pictureCloudNam
 
 		CREATE (cligact:GroupChatEntry{ che_id: randomUUID(), che_type: "group activity", info: "You changed group picture", cursor: cheNextVal })-[:IN_GROUP_CHAT]->(clientChat)
 
-		WITH cligact { .* } AS clientUserCHE, group, cheNextVal
+		WITH cligact { .* } AS clientUserCHE, group, cheNextVal, group.picture_url AS oldPicUrl
 
 		SET group.picture_url = $pic_url
 
 		LET memInfo = $client_username + " changed group picture"
 
-		RETURN { client_user_che: clientUserCHE, mem_info: memInfo, member_user_che: { che_type:"group activity", info: memInfo, cursor: cheNextVal } } AS new_group_activity
+		RETURN { client_user_che: clientUserCHE, mem_info: memInfo, member_user_che: { che_type:"group activity", info: memInfo, cursor: cheNextVal }, old_picture_url: oldPicUrl } AS new_group_activity
 		`,
 		map[string]any{
 			"client_username":          clientUsername,
@@ -228,31 +236,43 @@ func ChangePicture(ctx context.Context, groupId, clientUsername, pictureCloudNam
 	return newGact, nil
 }
 
-type AddUsersActivity struct {
-	GroupInfo     map[string]any `msgpack:"-" db:"group_info"`
-	ClientUserCHE map[string]any `msgpack:"-" db:"client_user_che"`
-	ChatCursor    int64          `msgpack:"-" db:"chat_cursor"`
-	NewUsersCHE   map[string]any `msgpack:"-" db:"new_users_che"`
-	NewUsernames  []any          `msgpack:"-" db:"new_usernames"`
-	MemInfo       string         `msgpack:"-" db:"mem_info"`
-	MemberUserCHE map[string]any `msgpack:"-" db:"member_user_che"`
+// CanEditInfo reports whether the client can edit the group's info:
+// as an admin, or as a member, if members can edit info
+func CanEditInfo(ctx context.Context, groupId, clientUsername string) (bool, error) {
+	res, err := db.Query(
+		ctx,
+		`/*cypher*/
+		MATCH (:User{ username: $client_username })-[mem:IS_MEMBER_OF]->(group:Group{ id: $group_id })
+		WHERE mem.role IN ["owner", "admin"] OR coalesce(group.members_can_edit_info, false)
+
+		RETURN true AS can_edit
+		`,
+		map[string]any{
+			"client_username": clientUsername,
+			"group_id":        groupId,
+		},
+	)
+	if err != nil {
+		helpers.LogError(err)
+		return false, fiber.ErrInternalServerError
+	}
+
+	return len(res.Records) != 0, nil
 }
 
-func AddUsers(ctx context.Context, groupId, clientUsername string, newUsers []string) (AddUsersActivity, error) {
+// ChangeSettings sets the group's permission settings, settings, to their new values.
+// settingsInfo describes, for the group activity, each setting's change
+func ChangeSettings(ctx context.Context, groupId, clientUsername string, settings map[string]any, settingsInfo map[string]any) (EditActivity, error) {
 	res, err := db.Query(
 		ctx,
 		`/*cypher*/
 		CYPHER 25
 
 		MATCH (group)<-[:WITH_GROUP]-(clientChat:GroupChat{ owner_username: $client_username, group_id: $group_id })<-[:HAS_CHAT]-(clientUser),
-			(clientUser)-[:IS_MEMBER_OF { role: "admin" }]->(group),
-			(newUser:User WHERE newUser.username IN $new_users AND NOT EXISTS { (newUser)-[:LEFT_GROUP]->(group) }
-				AND NOT EXISTS { (newUser)-[:IS_MEMBER_OF]->(group) })
-			
-		WITH collect(newUser) AS nuRows,
-			head(collect(group)) AS group,
-			head(collect(clientUser)) AS clientUser,
-			head(collect(clientChat)) AS clientChat
+			(clientUser)-[clientMem:IS_MEMBER_OF WHERE clientMem.role IN ["owner", "admin"]]->(group)
+
+		WITH group, clientChat, [k IN keys($settings) WHERE coalesce(group[k], false) <> $settings[k]] AS changedSettings
+		WHERE size(changedSettings) > 0
 
 		MERGE (serialCounter:GroupCHESerialCounter{ name: $group_che_serial_counter })
 		ON CREATE SET serialCounter.value = 0
@@ -261,69 +281,92 @@ func AddUsers(ctx context.Context, groupId, clientUsername string, newUsers []st
 
 		CALL apoc.atomic.add(serialCounter, 'value', 1) YIELD newValue AS cheNextVal
 
-		CREATE (cligact:GroupChatEntry{ che_id: randomUUID(), che_type: "group activity", info: "You added " + $new_users_str, cursor: cheNextVal })-[:IN_GROUP_CHAT]->(clientChat)
+		LET changeInfo = apoc.text.join([k IN changedSettings | $settings_info[k]], ", ")
 
-		WITH group, nuRows, cligact { .* } AS clientUserCHE, cheNextVal
-		UNWIND nuRows AS newUser
+		CREATE (cligact:GroupChatEntry{ che_id: randomUUID(), che_type: "group activity", info: "You " + changeInfo, cursor: cheNextVal })-[:IN_GROUP_CHAT]->(clientChat)
 
-		LET canNullG = group, canNullNU = newUser
+		SET group += $settings
 
-		OPTIONAL MATCH(canNullG)-[rur:REMOVED_USER]->(canNullNU)
+		WITH cligact { .* } AS clientUserCHE, changeInfo, cheNextVal
 
-		DELETE rur
+		LET memInfo = $client_username + " " + changeInfo
 
-		WITH group, newUser, nuRows, clientUserCHE, cheNextVal
-		CREATE (newUser)-[:IS_MEMBER_OF { role: "member" }]->(group)
-		MERGE (newUser)-[:HAS_CHAT]->(newUserChat:GroupChat{ owner_username: newUser.username, group_id: $group_id })-[:WITH_GROUP]->(group)
+		RETURN { client_user_che: clientUserCHE, mem_info: memInfo, member_user_che: { che_type:"group activity", info: memInfo, cursor: cheNextVal } } AS new_group_activity
+		`,
+		map[string]any{
+			"client_username":          clientUsername,
+			"group_id":                 groupId,
+			"settings":                 settings,
+			"settings_info":            settingsInfo,
+			"group_che_serial_counter": "$groupCHESC$",
+		},
+	)
+	if err != nil {
+		helpers.LogError(err)
+		return EditActivity{}, fiber.ErrInternalServerError
+	}
 
-		SET newUserChat.cursor = cheNextVal
+	newGact := modelHelpers.RKeyGet[EditActivity](res.Records, "new_group_activity")
 
-		CREATE (nugact:GroupChatEntry{ che_id: randomUUID(), che_type: "group activity",  info: "You were added", cursor: cheNextVal })-[:IN_GROUP_CHAT]->(newUserChat)
+	return newGact, nil
+}
 
-		WITH group, clientUserCHE, cheNextVal,
-			[nu IN nuRows | nu.username] AS newUsernames,
-		  reduce(accm = {}, x IN collect({ newuser: newUser.username, gact: nugact}) | apoc.map.setKey(accm, x.newuser, {che_id: x.gact.che_id, che_type: x.gact.che_type, info: x.gact.info, cursor: x.gact.cursor })) AS newUsersCHE
+// ChangePinPermission sets who can pin messages in the group: "admins" (the default), or "all" members
+func ChangePinPermission(ctx context.Context, groupId, clientUsername, pinPermission, permissionInfo string) (EditActivity, error) {
+	res, err := db.Query(
+		ctx,
+		`/*cypher*/
+		CYPHER 25
 
-		WITH DISTINCT group { .id, .name, .description, .picture_url, .created_at } AS groupInfo, clientUserCHE, newUsersCHE, newUsernames, cheNextVal
+		MATCH (group)<-[:WITH_GROUP]-(clientChat:GroupChat{ owner_username: $client_username, group_id: $group_id })<-[:HAS_CHAT]-(clientUser),
+			(clientUser)-[clientMem:IS_MEMBER_OF WHERE clientMem.role IN ["owner", "admin"]]->(group)
+		WHERE coalesce(group.pin_permission, "admins") <> $pin_permission
 
-		LET memInfo = $client_username + " added " + $new_users_str
+		MERGE (serialCounter:GroupCHESerialCounter{ name: $group_che_serial_counter })
+		ON CREATE SET serialCounter.value = 0
 
-		RETURN { group_info: groupInfo, chat_cursor: cheNextVal, client_user_che: clientUserCHE, new_users_che: newUsersCHE, new_usernames: newUsernames, mem_info: memInfo, member_user_che: { che_type:"group activity", info: memInfo, cursor: cheNextVal } } AS new_group_activity
+		LET dummy = 0
+
+		CALL apoc.atomic.add(serialCounter, 'value', 1) YIELD newValue AS cheNextVal
+
+		CREATE (cligact:GroupChatEntry{ che_id: randomUUID(), che_type: "group activity", info: "You " + $permission_info, cursor: cheNextVal })-[:IN_GROUP_CHAT]->(clientChat)
+
+		SET group.pin_permission = $pin_permission
+
+		WITH cligact { .* } AS clientUserCHE, cheNextVal
+
+		LET memInfo = $client_username + " " + $permission_info
+
+		RETURN { client_user_che: clientUserCHE, mem_info: memInfo, member_user_che: { che_type:"group activity", info: memInfo, cursor: cheNextVal } } AS new_group_activity
 		`,
 		map[string]any{
 			"client_username":          clientUsername,
 			"group_id":                 groupId,
-			"new_users":                newUsers,
-			"new_users_str":            helpers.JoinWithCommaAnd(newUsers...),
+			"pin_permission":           pinPermission,
+			"permission_info":          permissionInfo,
 			"group_che_serial_counter": "$groupCHESC$",
 		},
 	)
 	if err != nil {
 		helpers.LogError(err)
-		return AddUsersActivity{}, fiber.ErrInternalServerError
+		return EditActivity{}, fiber.ErrInternalServerError
 	}
 
-	newGact := modelHelpers.RKeyGet[AddUsersActivity](res.Records, "new_group_activity")
+	newGact := modelHelpers.RKeyGet[EditActivity](res.Records, "new_group_activity")
 
 	return newGact, nil
 }
 
-type RemoveUserActivity struct {
-	ClientUserCHE map[string]any `msgpack:"-" db:"client_user_che"`
-	TargetUserCHE map[string]any `msgpack:"-" db:"target_user_che"`
-	MemInfo       string         `msgpack:"-" db:"mem_info"`
-	MemberUserCHE map[string]any `msgpack:"-" db:"member_user_che"`
-}
-
-func RemoveUser(ctx context.Context, groupId, clientUsername, targetUser string) (RemoveUserActivity, error) {
+// ChangeVisibility makes the group public, so anyone can join it by its id, or private, so users join it only via an invite link
+func ChangeVisibility(ctx context.Context, groupId, clientUsername string, isPublic bool, visibilityInfo string) (EditActivity, error) {
 	res, err := db.Query(
 		ctx,
 		`/*cypher*/
 		CYPHER 25
 
 		MATCH (group)<-[:WITH_GROUP]-(clientChat:GroupChat{ owner_username: $client_username, group_id: $group_id })<-[:HAS_CHAT]-(clientUser),
-			(clientUser)-[:IS_MEMBER_OF { role: "admin" }]->(group),
-			(group)<-[mem:IS_MEMBER_OF]-(targetUser:User{ username: $target_user })
+			(clientUser)-[clientMem:IS_MEMBER_OF WHERE clientMem.role IN ["owner", "admin"]]->(group)
+		WHERE coalesce(group.is_public, false) <> $is_public
 
 		MERGE (serialCounter:GroupCHESerialCounter{ name: $group_che_serial_counter })
 		ON CREATE SET serialCounter.value = 0
@@ -332,40 +375,44 @@ func RemoveUser(ctx context.Context, groupId, clientUsername, targetUser string)
 
 		CALL apoc.atomic.add(serialCounter, 'value', 1) YIELD newValue AS cheNextVal
 
-		DELETE mem
-
-		CREATE (cligact:GroupChatEntry{ che_id: randomUUID(), che_type: "group activity", info: "You removed " + $target_user, cursor: cheNextVal })-[:IN_GROUP_CHAT]->(clientChat)
-
-		WITH group, targetUser, cligact { .* } AS clientUserCHE, cheNextVal
-		MATCH (targetUserChat:GroupChat{ owner_username: targetUser.username, group_id: $group_id })
+		CREATE (cligact:GroupChatEntry{ che_id: randomUUID(), che_type: "group activity", info: "You " + $visibility_info, cursor: cheNextVal })-[:IN_GROUP_CHAT]->(clientChat)
 
-		CREATE (group)-[:REMOVED_USER]->(targetUser),
-			(tugact:GroupChatEntry{ che_id: randomUUID(), che_type: "group activity", info: $client_username + " removed you", cursor: cheNextVal })-[:IN_GROUP_CHAT]->(targetUserChat)
+		SET group.is_public = $is_public
 
-		WITH clientUserCHE, tugact { .* } AS targetUserCHE, cheNextVal
+		WITH cligact { .* } AS clientUserCHE, cheNextVal
 
-		LET memInfo = $client_username + " removed " + $target_user
+		LET memInfo = $client_username + " " + $visibility_info
 
-		RETURN { client_user_che: clientUserCHE, target_user_che: targetUserCHE, mem_info: memInfo, member_user_che: { che_type:"group activity", info: memInfo, cursor: cheNextVal } } AS new_group_activity
+		RETURN { client_user_che: clientUserCHE, mem_info: memInfo, member_user_che: { che_type:"group activity", info: memInfo, cursor: cheNextVal } } AS new_group_activity
 		`,
 		map[string]any{
 			"client_username":          clientUsername,
 			"group_id":                 groupId,
-			"target_user":              targetUser,
+			"is_public":                isPublic,
+			"visibility_info":          visibilityInfo,
 			"group_che_serial_counter": "$groupCHESC$",
 		},
 	)
 	if err != nil {
 		helpers.LogError(err)
-		return RemoveUserActivity{}, fiber.ErrInternalServerError
+		return EditActivity{}, fiber.ErrInternalServerError
 	}
 
-	newGact := modelHelpers.RKeyGet[RemoveUserActivity](res.Records, "new_group_activity")
+	newGact := modelHelpers.RKeyGet[EditActivity](res.Records, "new_group_activity")
 
 	return newGact, nil
 }
 
-type UserJoinedActivity struct {
+type JoinApprovalActivity struct {
+	ClientUserCHE map[string]any `msgpack:"-" db:"client_user_che"`
+	MemInfo       string         `msgpack:"-" db:"mem_info"`
+	MemberUserCHE map[string]any `msgpack:"-" db:"member_user_che"`
+	ApprovedJoins []ApprovedJoin `msgpack:"-" db:"-"`
+}
+
+// ApprovedJoin is a requester's join, approved as join approval is turned off
+type ApprovedJoin struct {
+	Requester     string         `msgpack:"-" db:"requester"`
 	GroupInfo     map[string]any `msgpack:"-" db:"group_info"`
 	ChatCursor    int64          `msgpack:"-" db:"chat_cursor"`
 	ClientUserCHE map[string]any `msgpack:"-" db:"client_user_che"`
@@ -373,15 +420,20 @@ type UserJoinedActivity struct {
 	MemberUserCHE map[string]any `msgpack:"-" db:"member_user_che"`
 }
 
-func Join(ctx context.Context, groupId, clientUsername string) (UserJoinedActivity, error) {
+// ChangeJoinApproval turns on, or off, the group's moderated mode,
+// in which users joining the group, by its id, request to join, for an admin to approve.
+//
+// Turning it off in a public group approves its pending requests, as their requesters could now join it by its id;
+// a private group's are kept pending, for admins to approve or reject
+func ChangeJoinApproval(ctx context.Context, groupId, clientUsername string, joinApproval bool, approvalInfo string, at int64) (JoinApprovalActivity, error) {
 	res, err := db.Query(
 		ctx,
 		`/*cypher*/
 		CYPHER 25
-		
-		MATCH (clientUser:User{ username: $client_username }), (group:Group{ id: $group_id })
-		WHERE NOT EXISTS { (clientUser)-[:IS_MEMBER_OF]->(group) }
-			AND NOT EXISTS { (group)-[:REMOVED_USER]->(clientUser) }
+
+		MATCH (group)<-[:WITH_GROUP]-(clientChat:GroupChat{ owner_username: $client_username, group_id: $group_id })<-[:HAS_CHAT]-(clientUser),
+			(clientUser)-[clientMem:IS_MEMBER_OF WHERE clientMem.role IN ["owner", "admin"]]->(group)
+		WHERE coalesce(group.join_approval, false) <> $join_approval
 
 		MERGE (serialCounter:GroupCHESerialCounter{ name: $group_che_serial_counter })
 		ON CREATE SET serialCounter.value = 0
@@ -390,59 +442,194 @@ func Join(ctx context.Context, groupId, clientUsername string) (UserJoinedActivi
 
 		CALL apoc.atomic.add(serialCounter, 'value', 1) YIELD newValue AS cheNextVal
 
-		CREATE (cligact:GroupChatEntry{ che_id: randomUUID(), che_type: "group activity", info: "You joined" })-[:IN_GROUP_CHAT]->(clientChat)
+		CREATE (cligact:GroupChatEntry{ che_id: randomUUID(), che_type: "group activity", info: "You " + $approval_info, cursor: cheNextVal })-[:IN_GROUP_CHAT]->(clientChat)
 
-		SET cligact.cursor = cheNextVal
+		SET group.join_approval = $join_approval
 
-		WITH group, clientUser, group AS canNullG, clientUser AS canNullCU, cligact { .* } AS clientUserCHE, cheNextVal
-		
-		OPTIONAL MATCH (canNullCU)-[lgr:LEFT_GROUP]->(canNullG)
+		WITH group, serialCounter, cligact { .* } AS clientUserCHE, cheNextVal
 
-		DELETE lgr
+		CALL (group, serialCounter) {
+			MATCH (requester:User)-[req:REQUESTED_TO_JOIN]->(group)
+			WHERE NOT $join_approval AND group.is_public
+				AND NOT EXISTS { (requester)-[:IS_MEMBER_OF]->(group) }
 
-		WITH group, clientUser, clientUserCHE, cheNextVal
-		CREATE (clientUser)-[:IS_MEMBER_OF { role: "member" }]->(group)
-		MERGE (clientUser)-[:HAS_CHAT]->(clientChat:GroupChat{ owner_username: clientUser.username, group_id: $group_id })-[:WITH_GROUP]->(group)
+			CALL apoc.atomic.add(serialCounter, 'value', 1) YIELD newValue AS reqCHENextVal
 
-		SET clientChat.cursor = cheNextVal
+			DELETE req
 
-		WITH DISTINCT group { .id, .name, .description, .picture_url, .created_at } AS groupInfo,
-			clientUserCHE, cheNextVal
+			WITH group, requester, reqCHENextVal
 
-		LET memInfo = $client_username + " joined"
+			OPTIONAL MATCH (requester)-[lgr:LEFT_GROUP]->(group)
 
-		RETURN { group_info: groupInfo, chat_cursor: cheNextVal, client_user_che: clientUserCHE, mem_info: memInfo, member_user_che: { che_type:"group activity", info: memInfo, cursor: cheNextVal } } AS new_group_activity
+			DELETE lgr
+
+			WITH DISTINCT group, requester, reqCHENextVal
+			CREATE (requester)-[:IS_MEMBER_OF { role: "member", since: $at }]->(group)
+			MERGE (requester)-[:HAS_CHAT]->(reqChat:GroupChat{ owner_username: requester.username, group_id: group.id })-[:WITH_GROUP]->(group)
+
+			SET reqChat.cursor = reqCHENextVal
+
+			CREATE (reqgact:GroupChatEntry{ che_id: randomUUID(), che_type: "group activity", info: $client_username + " approved your request to join", cursor: reqCHENextVal })-[:IN_GROUP_CHAT]->(reqChat)
+
+			LET reqMemInfo = $client_username + " approved " + requester.username + "'s request to join"
+
+			RETURN collect({ requester: requester.username, group_info: group { .id, .name, .description, .picture_url, .is_public, .created_at }, chat_cursor: reqCHENextVal, client_user_che: reqgact { .* }, mem_info: reqMemInfo, member_user_che: { che_type:"group activity", info: reqMemInfo, cursor: reqCHENextVal } }) AS approvedJoins
+		}
+
+		LET memInfo = $client_username + " " + $approval_info
+
+		RETURN { client_user_che: clientUserCHE, mem_info: memInfo, member_user_che: { che_type:"group activity", info: memInfo, cursor: cheNextVal } } AS new_group_activity,
+			approvedJoins AS approved_joins
 		`,
 		map[string]any{
 			"client_username":          clientUsername,
 			"group_id":                 groupId,
+			"join_approval":            joinApproval,
+			"approval_info":            approvalInfo,
+			"at":                       at,
 			"group_che_serial_counter": "$groupCHESC$",
 		},
 	)
 	if err != nil {
 		helpers.LogError(err)
-		return UserJoinedActivity{}, fiber.ErrInternalServerError
+		return JoinApprovalActivity{}, fiber.ErrInternalServerError
 	}
 
-	newGact := modelHelpers.RKeyGet[UserJoinedActivity](res.Records, "new_group_activity")
+	newGact := modelHelpers.RKeyGet[JoinApprovalActivity](res.Records, "new_group_activity")
+	newGact.ApprovedJoins = modelHelpers.RKeyGetMany[ApprovedJoin](res.Records, "approved_joins")
 
 	return newGact, nil
 }
 
-type UserLeftActivity struct {
+// PinMessage pins a message of the group, for all members, unless maxPinned messages are already pinned.
+// Admins can pin messages, and, if the group's pin permission is "all", every member.
+// The group is locked while its pinned messages are counted, so concurrent pins can't exceed the limit
+func PinMessage(ctx context.Context, groupId, clientUsername, msgId string, at int64, maxPinned int) (EditActivity, error) {
+	res, err := db.Query(
+		ctx,
+		`/*cypher*/
+		CYPHER 25
+
+		MATCH (group)<-[:WITH_GROUP]-(clientChat:GroupChat{ owner_username: $client_username, group_id: $group_id })<-[:HAS_CHAT]-(clientUser),
+			(clientUser)-[membership:IS_MEMBER_OF]->(group),
+			(clientChat)<-[:IN_GROUP_CHAT]-(message:GroupMessage{ id: $message_id })
+		WHERE (membership.role IN ["owner", "admin"] OR group.pin_permission = "all")
+			AND message.deleted_at IS NULL
+
+		CALL apoc.lock.nodes([group])
+
+		WITH group, clientChat, message
+		WHERE NOT EXISTS { (group)-[:HAS_PINNED]->(message) }
+			AND COUNT { (group)-[:HAS_PINNED]->() } < $max_pinned
+
+		MERGE (serialCounter:GroupCHESerialCounter{ name: $group_che_serial_counter })
+		ON CREATE SET serialCounter.value = 0
+
+		LET dummy = 0
+
+		CALL apoc.atomic.add(serialCounter, 'value', 1) YIELD newValue AS cheNextVal
+
+		CREATE (group)-[:HAS_PINNED { at: $at, pinned_by: $client_username }]->(message),
+			(cligact:GroupChatEntry{ che_id: randomUUID(), che_type: "group activity", info: "You pinned a message", cursor: cheNextVal })-[:IN_GROUP_CHAT]->(clientChat)
+
+		WITH cligact { .* } AS clientUserCHE, cheNextVal
+
+		LET memInfo = $client_username + " pinned a message"
+
+		RETURN { client_user_che: clientUserCHE, mem_info: memInfo, member_user_che: { che_type:"group activity", info: memInfo, cursor: cheNextVal } } AS new_group_activity
+		`,
+		map[string]any{
+			"client_username":          clientUsername,
+			"group_id":                 groupId,
+			"message_id":               msgId,
+			"at":                       at,
+			"max_pinned":               maxPinned,
+			"group_che_serial_counter": "$groupCHESC$",
+		},
+	)
+	if err != nil {
+		helpers.LogError(err)
+		return EditActivity{}, fiber.ErrInternalServerError
+	}
+
+	newGact := modelHelpers.RKeyGet[EditActivity](res.Records, "new_group_activity")
+
+	return newGact, nil
+}
+
+// UnpinMessage unpins a pinned message of the group, for all members.
+// Those who can pin messages can unpin them
+func UnpinMessage(ctx context.Context, groupId, clientUsername, msgId string) (EditActivity, error) {
+	res, err := db.Query(
+		ctx,
+		`/*cypher*/
+		CYPHER 25
+
+		MATCH (group)<-[:WITH_GROUP]-(clientChat:GroupChat{ owner_username: $client_username, group_id: $group_id })<-[:HAS_CHAT]-(clientUser),
+			(clientUser)-[membership:IS_MEMBER_OF]->(group),
+			(group)-[pin:HAS_PINNED]->(:GroupMessage{ id: $message_id })
+		WHERE membership.role IN ["owner", "admin"] OR group.pin_permission = "all"
+
+		MERGE (serialCounter:GroupCHESerialCounter{ name: $group_che_serial_counter })
+		ON CREATE SET serialCounter.value = 0
+
+		LET dummy = 0
+
+		CALL apoc.atomic.add(serialCounter, 'value', 1) YIELD newValue AS cheNextVal
+
+		DELETE pin
+
+		CREATE (cligact:GroupChatEntry{ che_id: randomUUID(), che_type: "group activity", info: "You unpinned a message", cursor: cheNextVal })-[:IN_GROUP_CHAT]->(clientChat)
+
+		WITH cligact { .* } AS clientUserCHE, cheNextVal
+
+		LET memInfo = $client_username + " unpinned a message"
+
+		RETURN { client_user_che: clientUserCHE, mem_info: memInfo, member_user_che: { che_type:"group activity", info: memInfo, cursor: cheNextVal } } AS new_group_activity
+		`,
+		map[string]any{
+			"client_username":          clientUsername,
+			"group_id":                 groupId,
+			"message_id":               msgId,
+			"group_che_serial_counter": "$groupCHESC$",
+		},
+	)
+	if err != nil {
+		helpers.LogError(err)
+		return EditActivity{}, fiber.ErrInternalServerError
+	}
+
+	newGact := modelHelpers.RKeyGet[EditActivity](res.Records, "new_group_activity")
+
+	return newGact, nil
+}
+
+type AddUsersActivity struct {
+	GroupInfo     map[string]any `msgpack:"-" db:"group_info"`
 	ClientUserCHE map[string]any `msgpack:"-" db:"client_user_che"`
+	ChatCursor    int64          `msgpack:"-" db:"chat_cursor"`
+	NewUsersCHE   map[string]any `msgpack:"-" db:"new_users_che"`
+	NewUsernames  []any          `msgpack:"-" db:"new_usernames"`
 	MemInfo       string         `msgpack:"-" db:"mem_info"`
 	MemberUserCHE map[string]any `msgpack:"-" db:"member_user_che"`
 }
 
-func Leave(ctx context.Context, groupId, clientUsername string) (UserLeftActivity, error) {
+func AddUsers(ctx context.Context, groupId, clientUsername string, newUsers []string, at int64) (AddUsersActivity, error) {
 	res, err := db.Query(
 		ctx,
 		`/*cypher*/
 		CYPHER 25
-		
-		MATCH (group:Group{ id: $group_id })<-[mem:IS_MEMBER_OF]-(clientUser:User{ username: $client_username }),
-			(clientUser)-[:HAS_CHAT]->(clientChat)-[:WITH_GROUP]->(group)
+
+		MATCH (group)<-[:WITH_GROUP]-(clientChat:GroupChat{ owner_username: $client_username, group_id: $group_id })<-[:HAS_CHAT]-(clientUser),
+			(clientUser)-[mem:IS_MEMBER_OF]->(group),
+			(newUser:User WHERE newUser.username IN $new_users AND NOT EXISTS { (newUser)-[:LEFT_GROUP]->(group) }
+				AND NOT EXISTS { (newUser)-[:IS_MEMBER_OF]->(group) })
+		WHERE mem.role IN ["owner", "admin"] OR coalesce(group.members_can_add_members, false)
+			
+		WITH collect(newUser) AS nuRows,
+			head(collect(group)) AS group,
+			head(collect(clientUser)) AS clientUser,
+			head(collect(clientChat)) AS clientChat
 
 		MERGE (serialCounter:GroupCHESerialCounter{ name: $group_che_serial_counter })
 		ON CREATE SET serialCounter.value = 0
@@ -451,52 +638,693 @@ func Leave(ctx context.Context, groupId, clientUsername string) (UserLeftActivit
 
 		CALL apoc.atomic.add(serialCounter, 'value', 1) YIELD newValue AS cheNextVal
 
-		DELETE mem
+		CREATE (cligact:GroupChatEntry{ che_id: randomUUID(), che_type: "group activity", info: "You added " + $new_users_str, cursor: cheNextVal })-[:IN_GROUP_CHAT]->(clientChat)
+
+		WITH group, nuRows, cligact { .* } AS clientUserCHE, cheNextVal
+		UNWIND nuRows AS newUser
+
+		LET canNullG = group, canNullNU = newUser
+
+		OPTIONAL MATCH(canNullG)-[rur:REMOVED_USER]->(canNullNU)
+
+		DELETE rur
+
+		WITH group, newUser, nuRows, clientUserCHE, cheNextVal
+		CREATE (newUser)-[:IS_MEMBER_OF { role: "member", since: $at }]->(group)
+		MERGE (newUser)-[:HAS_CHAT]->(newUserChat:GroupChat{ owner_username: newUser.username, group_id: $group_id })-[:WITH_GROUP]->(group)
+
+		SET newUserChat.cursor = cheNextVal
+
+		CREATE (nugact:GroupChatEntry{ che_id: randomUUID(), che_type: "group activity",  info: "You were added", cursor: cheNextVal })-[:IN_GROUP_CHAT]->(newUserChat)
+
+		WITH group, clientUserCHE, cheNextVal,
+			[nu IN nuRows | nu.username] AS newUsernames,
+		  reduce(accm = {}, x IN collect({ newuser: newUser.username, gact: nugact}) | apoc.map.setKey(accm, x.newuser, {che_id: x.gact.che_id, che_type: x.gact.che_type, info: x.gact.info, cursor: x.gact.cursor })) AS newUsersCHE
+
+		WITH DISTINCT group { .id, .name, .description, .picture_url, .created_at } AS groupInfo, clientUserCHE, newUsersCHE, newUsernames, cheNextVal
+
+		LET memInfo = $client_username + " added " + $new_users_str
+
+		RETURN { group_info: groupInfo, chat_cursor: cheNextVal, client_user_che: clientUserCHE, new_users_che: newUsersCHE, new_usernames: newUsernames, mem_info: memInfo, member_user_che: { che_type:"group activity", info: memInfo, cursor: cheNextVal } } AS new_group_activity
+		`,
+		map[string]any{
+			"client_username":          clientUsername,
+			"group_id":                 groupId,
+			"new_users":                newUsers,
+			"at":                       at,
+			"new_users_str":            helpers.JoinWithCommaAnd(newUsers...),
+			"group_che_serial_counter": "$groupCHESC$",
+		},
+	)
+	if err != nil {
+		helpers.LogError(err)
+		return AddUsersActivity{}, fiber.ErrInternalServerError
+	}
+
+	newGact := modelHelpers.RKeyGet[AddUsersActivity](res.Records, "new_group_activity")
+
+	return newGact, nil
+}
+
+type RemoveUserActivity struct {
+	ClientUserCHE map[string]any `msgpack:"-" db:"client_user_che"`
+	TargetUserCHE map[string]any `msgpack:"-" db:"target_user_che"`
+	MemInfo       string         `msgpack:"-" db:"mem_info"`
+	MemberUserCHE map[string]any `msgpack:"-" db:"member_user_che"`
+}
+
+func RemoveUser(ctx context.Context, groupId, clientUsername, targetUser string) (RemoveUserActivity, error) {
+	res, err := db.Query(
+		ctx,
+		`/*cypher*/
+		CYPHER 25
+
+		MATCH (group)<-[:WITH_GROUP]-(clientChat:GroupChat{ owner_username: $client_username, group_id: $group_id })<-[:HAS_CHAT]-(clientUser),
+			(clientUser)-[clientMem:IS_MEMBER_OF WHERE clientMem.role IN ["owner", "admin"]]->(group),
+			(group)<-[mem:IS_MEMBER_OF]-(targetUser:User{ username: $target_user })
+		WHERE mem.role <> "owner"
+
+		MERGE (serialCounter:GroupCHESerialCounter{ name: $group_che_serial_counter })
+		ON CREATE SET serialCounter.value = 0
+
+		LET dummy = 0
+
+		CALL apoc.atomic.add(serialCounter, 'value', 1) YIELD newValue AS cheNextVal
+
+		DELETE mem
+
+		CREATE (cligact:GroupChatEntry{ che_id: randomUUID(), che_type: "group activity", info: "You removed " + $target_user, cursor: cheNextVal })-[:IN_GROUP_CHAT]->(clientChat)
+
+		WITH group, targetUser, cligact { .* } AS clientUserCHE, cheNextVal
+		MATCH (targetUserChat:GroupChat{ owner_username: targetUser.username, group_id: $group_id })
+
+		CREATE (group)-[:REMOVED_USER]->(targetUser),
+			(tugact:GroupChatEntry{ che_id: randomUUID(), che_type: "group activity", info: $client_username + " removed you", cursor: cheNextVal })-[:IN_GROUP_CHAT]->(targetUserChat)
+
+		WITH clientUserCHE, tugact { .* } AS targetUserCHE, cheNextVal
+
+		LET memInfo = $client_username + " removed " + $target_user
+
+		RETURN { client_user_che: clientUserCHE, target_user_che: targetUserCHE, mem_info: memInfo, member_user_che: { che_type:"group activity", info: memInfo, cursor: cheNextVal } } AS new_group_activity
+		`,
+		map[string]any{
+			"client_username":          clientUsername,
+			"group_id":                 groupId,
+			"target_user":              targetUser,
+			"group_che_serial_counter": "$groupCHESC$",
+		},
+	)
+	if err != nil {
+		helpers.LogError(err)
+		return RemoveUserActivity{}, fiber.ErrInternalServerError
+	}
+
+	newGact := modelHelpers.RKeyGet[RemoveUserActivity](res.Records, "new_group_activity")
+
+	return newGact, nil
+}
+
+type UserJoinedActivity struct {
+	GroupInfo     map[string]any `msgpack:"-" db:"group_info"`
+	ChatCursor    int64          `msgpack:"-" db:"chat_cursor"`
+	ClientUserCHE map[string]any `msgpack:"-" db:"client_user_che"`
+	MemInfo       string         `msgpack:"-" db:"mem_info"`
+	MemberUserCHE map[string]any `msgpack:"-" db:"member_user_che"`
+}
+
+func Join(ctx context.Context, groupId, clientUsername string, at int64) (UserJoinedActivity, error) {
+	res, err := db.Query(
+		ctx,
+		`/*cypher*/
+		CYPHER 25
+		
+		MATCH (clientUser:User{ username: $client_username }), (group:Group{ id: $group_id, is_public: true })
+		WHERE group.deleted_at IS NULL
+			AND NOT coalesce(group.join_approval, false)
+			AND NOT EXISTS { (clientUser)-[:IS_MEMBER_OF]->(group) }
+			AND NOT EXISTS { (group)-[:REMOVED_USER]->(clientUser) }
+
+		MERGE (serialCounter:GroupCHESerialCounter{ name: $group_che_serial_counter })
+		ON CREATE SET serialCounter.value = 0
+
+		LET dummy = 0
+
+		CALL apoc.atomic.add(serialCounter, 'value', 1) YIELD newValue AS cheNextVal
+
+		CREATE (cligact:GroupChatEntry{ che_id: randomUUID(), che_type: "group activity", info: "You joined" })-[:IN_GROUP_CHAT]->(clientChat)
+
+		SET cligact.cursor = cheNextVal
+
+		WITH group, clientUser, group AS canNullG, clientUser AS canNullCU, cligact { .* } AS clientUserCHE, cheNextVal
+		
+		OPTIONAL MATCH (canNullCU)-[lgr:LEFT_GROUP]->(canNullG)
+
+		DELETE lgr
+
+		WITH group, clientUser, clientUserCHE, cheNextVal
+		CREATE (clientUser)-[:IS_MEMBER_OF { role: "member", since: $at }]->(group)
+		MERGE (clientUser)-[:HAS_CHAT]->(clientChat:GroupChat{ owner_username: clientUser.username, group_id: $group_id })-[:WITH_GROUP]->(group)
+
+		SET clientChat.cursor = cheNextVal
+
+		WITH DISTINCT group { .id, .name, .description, .picture_url, .is_public, .created_at } AS groupInfo,
+			clientUserCHE, cheNextVal
+
+		LET memInfo = $client_username + " joined"
+
+		RETURN { group_info: groupInfo, chat_cursor: cheNextVal, client_user_che: clientUserCHE, mem_info: memInfo, member_user_che: { che_type:"group activity", info: memInfo, cursor: cheNextVal } } AS new_group_activity
+		`,
+		map[string]any{
+			"client_username":          clientUsername,
+			"group_id":                 groupId,
+			"at":                       at,
+			"group_che_serial_counter": "$groupCHESC$",
+		},
+	)
+	if err != nil {
+		helpers.LogError(err)
+		return UserJoinedActivity{}, fiber.ErrInternalServerError
+	}
+
+	newGact := modelHelpers.RKeyGet[UserJoinedActivity](res.Records, "new_group_activity")
+
+	return newGact, nil
+}
+
+type JoinRequestT struct {
+	Admins  []any `msgpack:"-" db:"admins"`
+	Created bool  `msgpack:"-" db:"created"`
+}
+
+// RequestToJoin makes a pending request for the client to join the group, if the group requires join approval.
+// It returns the group's admins, to be notified of the request, if it wasn't already made
+func RequestToJoin(ctx context.Context, groupId, clientUsername string, at int64) (JoinRequestT, error) {
+	res, err := db.Query(
+		ctx,
+		`/*cypher*/
+		CYPHER 25
+
+		MATCH (clientUser:User{ username: $client_username }), (group:Group{ id: $group_id, join_approval: true })
+		WHERE group.deleted_at IS NULL
+			AND NOT EXISTS { (clientUser)-[:IS_MEMBER_OF]->(group) }
+			AND NOT EXISTS { (group)-[:REMOVED_USER]->(clientUser) }
+
+		LET created = NOT EXISTS { (clientUser)-[:REQUESTED_TO_JOIN]->(group) }
+
+		MERGE (clientUser)-[req:REQUESTED_TO_JOIN]->(group)
+		ON CREATE
+			SET req.at = $at
+
+		RETURN { admins: COLLECT { MATCH (admin:User)-[adminMem:IS_MEMBER_OF WHERE adminMem.role IN ["owner", "admin"]]->(group) RETURN admin.username }, created: created } AS join_request
+		`,
+		map[string]any{
+			"client_username": clientUsername,
+			"group_id":        groupId,
+			"at":              at,
+		},
+	)
+	if err != nil {
+		helpers.LogError(err)
+		return JoinRequestT{}, fiber.ErrInternalServerError
+	}
+
+	joinRequest := modelHelpers.RKeyGet[JoinRequestT](res.Records, "join_request")
+
+	return joinRequest, nil
+}
+
+type joinRequestMember struct {
+	Username string `db:"username"`
+	At       int64  `db:"at"`
+}
+
+// JoinRequests returns the group's pending join requests, the latest first, to an admin.
+// Each request is a snippet of the requester, whose cursor is when the request was made
+func JoinRequests(ctx context.Context, groupId, clientUsername string, limit int64, cursor float64) ([]UITypes.GroupMemberSnippet, error) {
+	res, err := db.Query(
+		ctx,
+		`/*cypher*/
+		MATCH (:User{ username: $client_username })-[clientMem:IS_MEMBER_OF WHERE clientMem.role IN ["owner", "admin"]]->(group:Group{ id: $group_id })
+
+		RETURN COLLECT {
+			MATCH (requester:User)-[req:REQUESTED_TO_JOIN]->(group)
+			WHERE $cursor = 0 OR req.at < $cursor
+
+			RETURN { username: requester.username, at: req.at } AS join_request
+			ORDER BY req.at DESC
+			LIMIT $limit
+		} AS join_requests
+		`,
+		map[string]any{
+			"client_username": clientUsername,
+			"group_id":        groupId,
+			"limit":           limit,
+			"cursor":          cursor,
+		},
+	)
+	if err != nil {
+		helpers.LogError(err)
+		return nil, fiber.ErrInternalServerError
+	}
+
+	if len(res.Records) == 0 {
+		return nil, fiber.NewError(fiber.StatusForbidden, "only group admins can view join requests")
+	}
+
+	joinRequests := modelHelpers.RKeyGetMany[joinRequestMember](res.Records, "join_requests")
+
+	requesterMembers := make([]redis.Z, len(joinRequests))
+
+	for i, jr := range joinRequests {
+		requesterMembers[i] = redis.Z{Member: jr.Username, Score: float64(jr.At)}
+	}
+
+	requesters, err := modelHelpers.GroupMembersForUIGroupMemSnippets(ctx, requesterMembers)
+	if err != nil {
+		helpers.LogError(err)
+		return nil, fiber.ErrInternalServerError
+	}
+
+	return requesters, nil
+}
+
+// ApproveJoinRequest adds the requester, targetUser, to the group, as a user who joins it
+func ApproveJoinRequest(ctx context.Context, groupId, clientUsername, targetUser string, at int64) (UserJoinedActivity, error) {
+	res, err := db.Query(
+		ctx,
+		`/*cypher*/
+		CYPHER 25
+
+		MATCH (:User{ username: $client_username })-[clientMem:IS_MEMBER_OF WHERE clientMem.role IN ["owner", "admin"]]->(group:Group{ id: $group_id }),
+			(targetUser:User{ username: $target_user })-[req:REQUESTED_TO_JOIN]->(group)
+		WHERE NOT EXISTS { (targetUser)-[:IS_MEMBER_OF]->(group) }
+
+		MERGE (serialCounter:GroupCHESerialCounter{ name: $group_che_serial_counter })
+		ON CREATE SET serialCounter.value = 0
+
+		LET dummy = 0
+
+		CALL apoc.atomic.add(serialCounter, 'value', 1) YIELD newValue AS cheNextVal
+
+		DELETE req
+
+		WITH group, targetUser, cheNextVal
+
+		OPTIONAL MATCH (targetUser)-[lgr:LEFT_GROUP]->(group)
+
+		DELETE lgr
+
+		WITH group, targetUser, cheNextVal
+		CREATE (targetUser)-[:IS_MEMBER_OF { role: "member", since: $at }]->(group)
+		MERGE (targetUser)-[:HAS_CHAT]->(targetChat:GroupChat{ owner_username: targetUser.username, group_id: group.id })-[:WITH_GROUP]->(group)
+
+		SET targetChat.cursor = cheNextVal
+
+		CREATE (targact:GroupChatEntry{ che_id: randomUUID(), che_type: "group activity", info: $client_username + " approved your request to join", cursor: cheNextVal })-[:IN_GROUP_CHAT]->(targetChat)
+
+		WITH DISTINCT group { .id, .name, .description, .picture_url, .is_public, .created_at } AS groupInfo,
+			targact { .* } AS targetUserCHE, cheNextVal
+
+		LET memInfo = $client_username + " approved " + $target_user + "'s request to join"
+
+		RETURN { group_info: groupInfo, chat_cursor: cheNextVal, client_user_che: targetUserCHE, mem_info: memInfo, member_user_che: { che_type:"group activity", info: memInfo, cursor: cheNextVal } } AS new_group_activity
+		`,
+		map[string]any{
+			"client_username":          clientUsername,
+			"group_id":                 groupId,
+			"target_user":              targetUser,
+			"at":                       at,
+			"group_che_serial_counter": "$groupCHESC$",
+		},
+	)
+	if err != nil {
+		helpers.LogError(err)
+		return UserJoinedActivity{}, fiber.ErrInternalServerError
+	}
+
+	newGact := modelHelpers.RKeyGet[UserJoinedActivity](res.Records, "new_group_activity")
+
+	return newGact, nil
+}
+
+func RejectJoinRequest(ctx context.Context, groupId, clientUsername, targetUser string) (bool, error) {
+	res, err := db.Query(
+		ctx,
+		`/*cypher*/
+		MATCH (:User{ username: $client_username })-[clientMem:IS_MEMBER_OF WHERE clientMem.role IN ["owner", "admin"]]->(group:Group{ id: $group_id }),
+			(:User{ username: $target_user })-[req:REQUESTED_TO_JOIN]->(group)
+
+		DELETE req
+
+		RETURN true AS done
+		`,
+		map[string]any{
+			"client_username": clientUsername,
+			"group_id":        groupId,
+			"target_user":     targetUser,
+		},
+	)
+	if err != nil {
+		helpers.LogError(err)
+		return false, fiber.ErrInternalServerError
+	}
+
+	if len(res.Records) == 0 {
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// CreateInviteLink creates an invite link, code, to the group, for users to join it with.
+// The link is valid till expiresAt, and for maxUses joins; zero, for either, means no limit
+func CreateInviteLink(ctx context.Context, groupId, clientUsername, code string, expiresAt, maxUses, createdAt int64) (UITypes.GroupInviteLink, error) {
+	res, err := db.Query(
+		ctx,
+		`/*cypher*/
+		MATCH (group:Group{ id: $group_id })<-[clientMem:IS_MEMBER_OF WHERE clientMem.role IN ["owner", "admin"]]-(:User{ username: $client_username })
+
+		CREATE (group)-[:HAS_INVITE_LINK]->(link:GroupInviteLink{ code: $code, created_by: $client_username, created_at: $created_at, expires_at: $expires_at, max_uses: $max_uses, uses: 0 })
+
+		RETURN link { .* } AS invite_link
+		`,
+		map[string]any{
+			"client_username": clientUsername,
+			"group_id":        groupId,
+			"code":            code,
+			"expires_at":      expiresAt,
+			"max_uses":        maxUses,
+			"created_at":      createdAt,
+		},
+	)
+	if err != nil {
+		helpers.LogError(err)
+		return UITypes.GroupInviteLink{}, fiber.ErrInternalServerError
+	}
+
+	inviteLink := modelHelpers.RKeyGet[UITypes.GroupInviteLink](res.Records, "invite_link")
+
+	return inviteLink, nil
+}
+
+func RevokeInviteLink(ctx context.Context, groupId, clientUsername, code string) (bool, error) {
+	res, err := db.Query(
+		ctx,
+		`/*cypher*/
+		MATCH (:User{ username: $client_username })-[clientMem:IS_MEMBER_OF WHERE clientMem.role IN ["owner", "admin"]]->(group:Group{ id: $group_id }),
+			(group)-[:HAS_INVITE_LINK]->(link:GroupInviteLink{ code: $code })
+
+		DETACH DELETE link
+
+		RETURN true AS done
+		`,
+		map[string]any{
+			"client_username": clientUsername,
+			"group_id":        groupId,
+			"code":            code,
+		},
+	)
+	if err != nil {
+		helpers.LogError(err)
+		return false, fiber.ErrInternalServerError
+	}
+
+	if len(res.Records) == 0 {
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// InviteLinks returns the group's invite links, the latest first, to an admin
+func InviteLinks(ctx context.Context, groupId, clientUsername string) ([]UITypes.GroupInviteLink, error) {
+	res, err := db.Query(
+		ctx,
+		`/*cypher*/
+		MATCH (:User{ username: $client_username })-[clientMem:IS_MEMBER_OF WHERE clientMem.role IN ["owner", "admin"]]->(group:Group{ id: $group_id })
+
+		OPTIONAL MATCH (group)-[:HAS_INVITE_LINK]->(link:GroupInviteLink)
+
+		WITH link
+		ORDER BY link.created_at DESC
+
+		RETURN collect(link { .* }) AS invite_links
+		`,
+		map[string]any{
+			"client_username": clientUsername,
+			"group_id":        groupId,
+		},
+	)
+	if err != nil {
+		helpers.LogError(err)
+		return nil, fiber.ErrInternalServerError
+	}
+
+	if len(res.Records) == 0 {
+		return nil, fiber.NewError(fiber.StatusForbidden, "only group admins can view invite links")
+	}
+
+	inviteLinks := modelHelpers.RKeyGetMany[UITypes.GroupInviteLink](res.Records, "invite_links")
+
+	return inviteLinks, nil
+}
+
+// InviteLinkGroupId returns the id of the group the invite link, code, is to; which is empty if the link isn't valid, at now
+func InviteLinkGroupId(ctx context.Context, code string, now int64) (string, error) {
+	res, err := db.Query(
+		ctx,
+		`/*cypher*/
+		MATCH (group:Group)-[:HAS_INVITE_LINK]->(link:GroupInviteLink{ code: $code })
+		WHERE (link.expires_at = 0 OR link.expires_at > $now)
+			AND (link.max_uses = 0 OR link.uses < link.max_uses)
+
+		RETURN group.id AS group_id
+		`,
+		map[string]any{
+			"code": code,
+			"now":  now,
+		},
+	)
+	if err != nil {
+		helpers.LogError(err)
+		return "", fiber.ErrInternalServerError
+	}
+
+	groupId := modelHelpers.RKeyGet[string](res.Records, "group_id")
+
+	return groupId, nil
+}
+
+// JoinViaInviteLink adds the client to the group the invite link, code, is to, if the link is valid, at now.
+// The group's join approval doesn't apply, as the link, created by an admin, is itself an admin's approval
+func JoinViaInviteLink(ctx context.Context, code, clientUsername string, now int64) (UserJoinedActivity, error) {
+	res, err := db.Query(
+		ctx,
+		`/*cypher*/
+		CYPHER 25
+
+		MATCH (clientUser:User{ username: $client_username }), (group:Group)-[:HAS_INVITE_LINK]->(link:GroupInviteLink{ code: $code })
+
+		CALL apoc.lock.nodes([link])
+
+		WITH clientUser, group, link
+		WHERE (link.expires_at = 0 OR link.expires_at > $now)
+			AND (link.max_uses = 0 OR link.uses < link.max_uses)
+			AND NOT EXISTS { (clientUser)-[:IS_MEMBER_OF]->(group) }
+			AND NOT EXISTS { (group)-[:REMOVED_USER]->(clientUser) }
+
+		MERGE (serialCounter:GroupCHESerialCounter{ name: $group_che_serial_counter })
+		ON CREATE SET serialCounter.value = 0
+
+		LET dummy = 0
+
+		CALL apoc.atomic.add(serialCounter, 'value', 1) YIELD newValue AS cheNextVal
+
+		SET link.uses = link.uses + 1
+
+		WITH group, clientUser, link.created_by AS inviter, cheNextVal
+
+		OPTIONAL MATCH (clientUser)-[lgr:LEFT_GROUP]->(group)
+
+		DELETE lgr
+
+		WITH group, clientUser, inviter, cheNextVal
+		CREATE (clientUser)-[:IS_MEMBER_OF { role: "member", since: $now }]->(group)
+		MERGE (clientUser)-[:HAS_CHAT]->(clientChat:GroupChat{ owner_username: clientUser.username, group_id: group.id })-[:WITH_GROUP]->(group)
+
+		SET clientChat.cursor = cheNextVal
+
+		CREATE (cligact:GroupChatEntry{ che_id: randomUUID(), che_type: "group activity", info: "You joined via invite link from " + inviter, cursor: cheNextVal })-[:IN_GROUP_CHAT]->(clientChat)
+
+		WITH DISTINCT group { .id, .name, .description, .picture_url, .is_public, .created_at } AS groupInfo,
+			cligact { .* } AS clientUserCHE, inviter, cheNextVal
+
+		LET memInfo = $client_username + " joined via invite link from " + inviter
+
+		RETURN { group_info: groupInfo, chat_cursor: cheNextVal, client_user_che: clientUserCHE, mem_info: memInfo, member_user_che: { che_type:"group activity", info: memInfo, cursor: cheNextVal } } AS new_group_activity
+		`,
+		map[string]any{
+			"client_username":          clientUsername,
+			"code":                     code,
+			"now":                      now,
+			"group_che_serial_counter": "$groupCHESC$",
+		},
+	)
+	if err != nil {
+		helpers.LogError(err)
+		return UserJoinedActivity{}, fiber.ErrInternalServerError
+	}
+
+	newGact := modelHelpers.RKeyGet[UserJoinedActivity](res.Records, "new_group_activity")
+
+	return newGact, nil
+}
+
+type UserLeftActivity struct {
+	ClientUserCHE map[string]any `msgpack:"-" db:"client_user_che"`
+	NewOwner      string         `msgpack:"-" db:"new_owner"`
+	MemInfo       string         `msgpack:"-" db:"mem_info"`
+	MemberUserCHE map[string]any `msgpack:"-" db:"member_user_che"`
+}
+
+func Leave(ctx context.Context, groupId, clientUsername string) (UserLeftActivity, error) {
+	res, err := db.Query(
+		ctx,
+		`/*cypher*/
+		CYPHER 25
+		
+		MATCH (group:Group{ id: $group_id })<-[mem:IS_MEMBER_OF]-(clientUser:User{ username: $client_username }),
+			(clientUser)-[:HAS_CHAT]->(clientChat)-[:WITH_GROUP]->(group)
+
+		MERGE (serialCounter:GroupCHESerialCounter{ name: $group_che_serial_counter })
+		ON CREATE SET serialCounter.value = 0
+
+		LET dummy = 0
+
+		CALL apoc.atomic.add(serialCounter, 'value', 1) YIELD newValue AS cheNextVal
+
+		LET wasOwner = mem.role = "owner"
+
+		DELETE mem
+
+		WITH group, clientUser, clientChat, wasOwner, cheNextVal
+		CREATE (clientUser)-[:LEFT_GROUP]->(group),
+			(cligact:GroupChatEntry{ che_id: randomUUID(), che_type: "group activity", info: "You left", cursor: cheNextVal })-[:IN_GROUP_CHAT]->(clientChat)
+
+		WITH group, wasOwner, cligact, cheNextVal
+
+		// the owner is succeeded by the longest-standing admin, or, if there's none, member
+		OPTIONAL CALL (group, wasOwner) {
+			MATCH (successor:User)-[succMem:IS_MEMBER_OF]->(group)
+			WHERE wasOwner
+
+			WITH successor, succMem
+			ORDER BY succMem.role = "admin" DESC, coalesce(succMem.since, 0)
+			LIMIT 1
+
+			SET succMem.role = "owner"
+
+			RETURN successor.username AS newOwner
+		}
+
+		WITH cligact { .* } AS clientUserCHE, newOwner, cheNextVal
+
+		LET memInfo = $client_username + " left" + CASE WHEN newOwner IS NULL THEN "" ELSE ", and " + newOwner + " is now the group owner" END
+
+		RETURN { client_user_che: clientUserCHE, new_owner: coalesce(newOwner, ""), mem_info: memInfo, member_user_che: { che_type: "group activity", info: memInfo, cursor: cheNextVal } } AS new_group_activity
+		`,
+		map[string]any{
+			"client_username":          clientUsername,
+			"group_id":                 groupId,
+			"group_che_serial_counter": "$groupCHESC$",
+		},
+	)
+	if err != nil {
+		helpers.LogError(err)
+		return UserLeftActivity{}, fiber.ErrInternalServerError
+	}
+
+	newGact := modelHelpers.RKeyGet[UserLeftActivity](res.Records, "new_group_activity")
+
+	return newGact, nil
+}
+
+type MakeUserAdminActivity struct {
+	ClientUserCHE map[string]any `msgpack:"-" db:"client_user_che"`
+	TargetUserCHE map[string]any `msgpack:"-" db:"target_user_che"`
+	MemInfo       string         `msgpack:"-" db:"mem_info"`
+	MemberUserCHE map[string]any `msgpack:"-" db:"member_user_che"`
+}
+
+func MakeUserAdmin(ctx context.Context, groupId, clientUsername, targetUser string) (MakeUserAdminActivity, error) {
+	res, err := db.Query(
+		ctx,
+		`/*cypher*/
+		CYPHER 25
+		
+		MATCH (group)<-[:WITH_GROUP]-(clientChat:GroupChat{ owner_username: $client_username, group_id: $group_id })<-[:HAS_CHAT]-(clientUser),
+			(clientUser)-[clientMem:IS_MEMBER_OF WHERE clientMem.role IN ["owner", "admin"]]->(group),
+			(group)<-[mem:IS_MEMBER_OF { role: "member" }]-(targetUser:User{ username: $target_user })
+
+		SET mem.role = "admin"
+
+		MERGE (serialCounter:GroupCHESerialCounter{ name: $group_che_serial_counter })
+		ON CREATE SET serialCounter.value = 0
+
+		LET dummy = 0
+
+		CALL apoc.atomic.add(serialCounter, 'value', 1) YIELD newValue AS cheNextVal
+
+		CREATE (cligact:GroupChatEntry{ che_id: randomUUID(), che_type: "group activity", info: "You made " + $target_user + " group admin", cursor: cheNextVal })-[:IN_GROUP_CHAT]->(clientChat)
+
+		WITH group, targetUser, cligact { .* } AS clientUserCHE, cheNextVal
+		MATCH (targetUser)-[:HAS_CHAT]->(targetUserChat)-[:WITH_GROUP]->(group)
 
-		WITH group, clientUser, clientChat, cheNextVal
-		CREATE (clientUser)-[:LEFT_GROUP]->(group),
-			(cligact:GroupChatEntry{ che_id: randomUUID(), che_type: "group activity", info: "You left", cursor: cheNextVal })-[:IN_GROUP_CHAT]->(clientChat)
+		CREATE (tugact:GroupChatEntry{ che_id: randomUUID(), che_type: "group activity", info: $client_username + " made you group admin", cursor: cheNextVal })-[:IN_GROUP_CHAT]->(targetUserChat)
 
-		WITH cligact { .* } AS clientUserCHE, cheNextVal
+		WITH DISTINCT clientUserCHE, tugact { .* } AS targetUserCHE, cheNextVal
 
-		LET memInfo = $client_username + " left"
+		LET memInfo = $client_username + " made " + $target_user + " group admin"
 
-		RETURN { client_user_che: clientUserCHE, mem_info: memInfo, member_user_che: { che_type: "group activity", info: memInfo, cursor: cheNextVal } } AS new_group_activity
+		RETURN { client_user_che: clientUserCHE, target_user_che: targetUserCHE, mem_info: memInfo, member_user_che: { che_type: "group activity", info: memInfo, cursor: cheNextVal } } AS new_group_activity
 		`,
 		map[string]any{
 			"client_username":          clientUsername,
 			"group_id":                 groupId,
+			"target_user":              targetUser,
 			"group_che_serial_counter": "$groupCHESC$",
 		},
 	)
 	if err != nil {
 		helpers.LogError(err)
-		return UserLeftActivity{}, fiber.ErrInternalServerError
+		return MakeUserAdminActivity{}, fiber.ErrInternalServerError
 	}
 
-	newGact := modelHelpers.RKeyGet[UserLeftActivity](res.Records, "new_group_activity")
+	newGact := modelHelpers.RKeyGet[MakeUserAdminActivity](res.Records, "new_group_activity")
 
 	return newGact, nil
 }
 
-type MakeUserAdminActivity struct {
+type TransferOwnershipActivity struct {
 	ClientUserCHE map[string]any `msgpack:"-" db:"client_user_che"`
 	TargetUserCHE map[string]any `msgpack:"-" db:"target_user_che"`
 	MemInfo       string         `msgpack:"-" db:"mem_info"`
 	MemberUserCHE map[string]any `msgpack:"-" db:"member_user_che"`
 }
 
-func MakeUserAdmin(ctx context.Context, groupId, clientUsername, targetUser string) (MakeUserAdminActivity, error) {
+// TransferOwnership makes a member, targetUser, the group's owner, and the client, its previous owner, an admin
+func TransferOwnership(ctx context.Context, groupId, clientUsername, targetUser string) (TransferOwnershipActivity, error) {
 	res, err := db.Query(
 		ctx,
 		`/*cypher*/
 		CYPHER 25
-		
+
 		MATCH (group)<-[:WITH_GROUP]-(clientChat:GroupChat{ owner_username: $client_username, group_id: $group_id })<-[:HAS_CHAT]-(clientUser),
-			(clientUser)-[:IS_MEMBER_OF { role: "admin" }]->(group),
-			(group)<-[mem:IS_MEMBER_OF { role: "member" }]-(targetUser:User{ username: $target_user })
+			(clientUser)-[clientMem:IS_MEMBER_OF { role: "owner" }]->(group),
+			(group)<-[mem:IS_MEMBER_OF]-(targetUser:User{ username: $target_user })
+		WHERE targetUser <> clientUser
 
-		SET mem.role = "admin"
+		SET clientMem.role = "admin", mem.role = "owner"
 
 		MERGE (serialCounter:GroupCHESerialCounter{ name: $group_che_serial_counter })
 		ON CREATE SET serialCounter.value = 0
@@ -505,16 +1333,16 @@ func MakeUserAdmin(ctx context.Context, groupId, clientUsername, targetUser stri
 
 		CALL apoc.atomic.add(serialCounter, 'value', 1) YIELD newValue AS cheNextVal
 
-		CREATE (cligact:GroupChatEntry{ che_id: randomUUID(), che_type: "group activity", info: "You made " + $target_user + " group admin", cursor: cheNextVal })-[:IN_GROUP_CHAT]->(clientChat)
+		CREATE (cligact:GroupChatEntry{ che_id: randomUUID(), che_type: "group activity", info: "You transferred group ownership to " + $target_user, cursor: cheNextVal })-[:IN_GROUP_CHAT]->(clientChat)
 
 		WITH group, targetUser, cligact { .* } AS clientUserCHE, cheNextVal
 		MATCH (targetUser)-[:HAS_CHAT]->(targetUserChat)-[:WITH_GROUP]->(group)
 
-		CREATE (tugact:GroupChatEntry{ che_id: randomUUID(), che_type: "group activity", info: $client_username + " made you group admin", cursor: cheNextVal })-[:IN_GROUP_CHAT]->(targetUserChat)
+		CREATE (tugact:GroupChatEntry{ che_id: randomUUID(), che_type: "group activity", info: $client_username + " transferred group ownership to you", cursor: cheNextVal })-[:IN_GROUP_CHAT]->(targetUserChat)
 
 		WITH DISTINCT clientUserCHE, tugact { .* } AS targetUserCHE, cheNextVal
 
-		LET memInfo = $client_username + " made " + $target_user + " group admin"
+		LET memInfo = $client_username + " transferred group ownership to " + $target_user
 
 		RETURN { client_user_che: clientUserCHE, target_user_che: targetUserCHE, mem_info: memInfo, member_user_che: { che_type: "group activity", info: memInfo, cursor: cheNextVal } } AS new_group_activity
 		`,
@@ -527,10 +1355,10 @@ func MakeUserAdmin(ctx context.Context, groupId, clientUsername, targetUser stri
 	)
 	if err != nil {
 		helpers.LogError(err)
-		return MakeUserAdminActivity{}, fiber.ErrInternalServerError
+		return TransferOwnershipActivity{}, fiber.ErrInternalServerError
 	}
 
-	newGact := modelHelpers.RKeyGet[MakeUserAdminActivity](res.Records, "new_group_activity")
+	newGact := modelHelpers.RKeyGet[TransferOwnershipActivity](res.Records, "new_group_activity")
 
 	return newGact, nil
 }
@@ -549,7 +1377,7 @@ func RemoveUserFromAdmins(ctx context.Context, groupId, clientUsername, targetUs
 		CYPHER 25
 		
 		MATCH (group)<-[:WITH_GROUP]-(clientChat:GroupChat{ owner_username: $client_username, group_id: $group_id })<-[:HAS_CHAT]-(clientUser),
-			(clientUser)-[:IS_MEMBER_OF { role: "admin" }]->(group),
+			(clientUser)-[clientMem:IS_MEMBER_OF WHERE clientMem.role IN ["owner", "admin"]]->(group),
 			(group)<-[mem:IS_MEMBER_OF { role: "admin" }]-(targetUser:User{ username: $target_user })
 
 		SET mem.role = "member"
@@ -591,6 +1419,181 @@ func RemoveUserFromAdmins(ctx context.Context, groupId, clientUsername, targetUs
 	return newGact, nil
 }
 
+type DeletedGroupT struct {
+	ClientUserCHE   map[string]any `msgpack:"-" db:"client_user_che"`
+	MemInfo         string         `msgpack:"-" db:"mem_info"`
+	MemberUserCHE   map[string]any `msgpack:"-" db:"member_user_che"`
+	MemberUsernames []any          `msgpack:"-" db:"member_usernames"`
+	PictureUrl      string         `msgpack:"-" db:"picture_url"`
+}
+
+// Delete dissolves the group: its memberships, invite links and join requests are removed,
+// leaving its members' chats read-only, each with an activity of the deletion.
+// The client must be the group's owner or an admin.
+//
+// If archive is false, the chats are, afterwards, removed, with their entries, by DeleteEntriesBgDBOper and DeleteBgDBOper
+func Delete(ctx context.Context, groupId, clientUsername string, archive bool, at int64) (DeletedGroupT, error) {
+	res, err := db.Query(
+		ctx,
+		`/*cypher*/
+		CYPHER 25
+
+		MATCH (group)<-[:WITH_GROUP]-(clientChat:GroupChat{ owner_username: $client_username, group_id: $group_id })<-[:HAS_CHAT]-(clientUser),
+			(clientUser)-[clientMem:IS_MEMBER_OF WHERE clientMem.role IN ["owner", "admin"]]->(group)
+		WHERE group.deleted_at IS NULL
+
+		SET group.deleted_at = $at, group.archived = $archive
+
+		MERGE (serialCounter:GroupCHESerialCounter{ name: $group_che_serial_counter })
+		ON CREATE SET serialCounter.value = 0
+
+		LET dummy = 0
+
+		CALL apoc.atomic.add(serialCounter, 'value', 1) YIELD newValue AS cheNextVal
+
+		CREATE (cligact:GroupChatEntry{ che_id: randomUUID(), che_type: "group activity", info: "You deleted " + group.name, cursor: cheNextVal })-[:IN_GROUP_CHAT]->(clientChat)
+
+		WITH group, clientUser, cligact { .* } AS clientUserCHE, randomUUID() AS memgactCHEId, cheNextVal
+
+		LET memInfo = $client_username + " deleted " + group.name
+
+		CALL (group, clientUser, memgactCHEId, memInfo, cheNextVal) {
+			MATCH (memberUser:User)-[mem:IS_MEMBER_OF]->(group)
+
+			DELETE mem
+
+			WITH group, memberUser
+			WHERE memberUser <> clientUser
+
+			MATCH (memberUser)-[:HAS_CHAT]->(memberChat)-[:WITH_GROUP]->(group)
+
+			CREATE (:GroupChatEntry{ che_id: memgactCHEId, che_type: "group activity", info: memInfo, cursor: cheNextVal })-[:IN_GROUP_CHAT]->(memberChat)
+
+			RETURN collect(memberUser.username) AS memberUsernames
+		}
+
+		CALL (group) {
+			MATCH (group)-[:HAS_INVITE_LINK]->(link:GroupInviteLink)
+
+			DETACH DELETE link
+		}
+
+		CALL (group) {
+			MATCH (:User)-[req:REQUESTED_TO_JOIN]->(group)
+
+			DELETE req
+		}
+
+		RETURN { client_user_che: clientUserCHE, mem_info: memInfo, member_user_che: { che_id: memgactCHEId, che_type: "group activity", info: memInfo, cursor: cheNextVal }, member_usernames: memberUsernames, picture_url: coalesce(group.picture_url, "") } AS deleted_group
+		`,
+		map[string]any{
+			"client_username":          clientUsername,
+			"group_id":                 groupId,
+			"archive":                  archive,
+			"at":                       at,
+			"group_che_serial_counter": "$groupCHESC$",
+		},
+	)
+	if err != nil {
+		helpers.LogError(err)
+		return DeletedGroupT{}, fiber.ErrInternalServerError
+	}
+
+	deletedGroup := modelHelpers.RKeyGet[DeletedGroupT](res.Records, "deleted_group")
+
+	return deletedGroup, nil
+}
+
+type DeletedEntries struct {
+	CHEIds      []any `msgpack:"-" db:"che_ids"`
+	MsgIds      []any `msgpack:"-" db:"msg_ids"`
+	MsgContents []any `msgpack:"-" db:"msg_contents"`
+	Stars       []any `msgpack:"-" db:"stars"`
+}
+
+// DeleteEntriesBgDBOper deletes, at most, limit entries from the chats of a deleted group, with their reactions.
+// It's repeated until no entries are returned; MsgContents are the contents of the deleted messages, whose media is to be deleted,
+// and Stars, for each starred deleted message, its msg_id and starrers, whose starred messages are to be updated
+func DeleteEntriesBgDBOper(ctx context.Context, groupId string, limit int64) (DeletedEntries, error) {
+	res, err := db.Query(
+		ctx,
+		`/*cypher*/
+		CYPHER 25
+
+		MATCH (group:Group{ id: $group_id })
+		WHERE group.deleted_at IS NOT NULL
+
+		CALL (group) {
+			MATCH (entry:GroupChatEntry)-[:IN_GROUP_CHAT]->(:GroupChat)-[:WITH_GROUP]->(group)
+
+			WITH DISTINCT entry
+			LIMIT $limit
+
+			WITH entry, coalesce(entry.id, entry.che_id) AS cheId,
+				CASE WHEN entry:GroupMessage THEN entry.id END AS msgId,
+				CASE WHEN entry:GroupMessage THEN entry.content END AS msgContent,
+				COLLECT { MATCH (starrer:User)-[:STARRED]->(entry) RETURN starrer.username } AS starrers
+
+			DETACH DELETE entry
+
+			RETURN collect(cheId) AS cheIds, collect(msgId) AS msgIds, collect(msgContent) AS msgContents,
+				collect(CASE WHEN size(starrers) > 0 THEN { msg_id: msgId, starrers: starrers } END) AS stars
+		}
+
+		RETURN { che_ids: cheIds, msg_ids: msgIds, msg_contents: msgContents, stars: stars } AS deleted_entries
+		`,
+		map[string]any{
+			"group_id": groupId,
+			"limit":    limit,
+		},
+	)
+	if err != nil {
+		helpers.LogError(err)
+		return DeletedEntries{}, fiber.ErrInternalServerError
+	}
+
+	deletedEntries := modelHelpers.RKeyGet[DeletedEntries](res.Records, "deleted_entries")
+
+	return deletedEntries, nil
+}
+
+// DeleteBgDBOper deletes a deleted group, whose chats' entries are deleted, and its chats.
+// It returns the owners of the chats
+func DeleteBgDBOper(ctx context.Context, groupId string) ([]any, error) {
+	res, err := db.Query(
+		ctx,
+		`/*cypher*/
+		CYPHER 25
+
+		MATCH (group:Group{ id: $group_id })
+		WHERE group.deleted_at IS NOT NULL
+
+		LET chatOwners = COLLECT { MATCH (chat:GroupChat)-[:WITH_GROUP]->(group) RETURN chat.owner_username }
+
+		CALL (group) {
+			MATCH (chat:GroupChat)-[:WITH_GROUP]->(group)
+
+			DETACH DELETE chat
+		}
+
+		DETACH DELETE group
+
+		RETURN chatOwners
+		`,
+		map[string]any{
+			"group_id": groupId,
+		},
+	)
+	if err != nil {
+		helpers.LogError(err)
+		return nil, fiber.ErrInternalServerError
+	}
+
+	chatOwners := modelHelpers.RKeyGet[[]any](res.Records, "chatOwners")
+
+	return chatOwners, nil
+}
+
 type PostGroupActivity struct {
 	MemberUsersCHE  map[string]any `msgpack:"-" db:"member_users_che"`
 	MemberUsernames []any          `msgpack:"-" db:"member_usernames"`
@@ -650,7 +1653,10 @@ func SendMessage(ctx context.Context, clientUsername, groupId, msgContent string
 		CYPHER 25
 
 		MATCH (group)<-[:WITH_GROUP]-(clientChat:GroupChat{ owner_username: $client_username, group_id: $group_id })<-[:HAS_CHAT]-(clientUser)
-		WHERE EXISTS { (clientUser)-[:IS_MEMBER_OF]->(group) }
+		WHERE EXISTS {
+			MATCH (clientUser)-[mem:IS_MEMBER_OF]->(group)
+			WHERE mem.role IN ["owner", "admin"] OR NOT coalesce(group.only_admins_can_send_messages, false)
+		}
 
 		MERGE (serialCounter:GroupCHESerialCounter{ name: $group_che_serial_counter })
 		ON CREATE SET serialCounter.value = 0
@@ -659,7 +1665,7 @@ func SendMessage(ctx context.Context, clientUsername, groupId, msgContent string
 
 		CALL apoc.atomic.add(serialCounter, 'value', 1) YIELD newValue AS cheNextVal
 
-		CREATE (message:GroupMessage:GroupChatEntry{ id: randomUUID(), che_type: "message", content: $message_content, delivery_status: "sent", created_at: $at, cursor: cheNextVal }),
+		CREATE (message:GroupMessage:GroupChatEntry{ id: randomUUID(), che_type: "message", content: $message_content, search_text: $search_text, delivery_status: "sent", created_at: $at, cursor: cheNextVal }),
 			(clientUser)-[:SENDS_MESSAGE]->(message)-[:IN_GROUP_CHAT]->(clientChat)
 		
 		SET clientChat.cursor = cheNextVal
@@ -671,6 +1677,7 @@ func SendMessage(ctx context.Context, clientUsername, groupId, msgContent string
 			"client_username":          clientUsername,
 			"group_id":                 groupId,
 			"message_content":          msgContent,
+			"search_text":              modelHelpers.MsgSearchText(msgContent),
 			"at":                       at,
 			"group_che_serial_counter": "$groupCHESC$",
 		},
@@ -811,8 +1818,9 @@ func ReplyToMessage(ctx context.Context, clientUsername, groupId, targetMsgId, m
 		CYPHER 25
 
 		MATCH (group)<-[:WITH_GROUP]-(clientChat:GroupChat{ owner_username: $client_username, group_id: $group_id })<-[:HAS_CHAT]-(clientUser),
-			(clientUser)-[:IS_MEMBER_OF]->(group),
+			(clientUser)-[mem:IS_MEMBER_OF]->(group),
 			(clientChat)<-[:IN_GROUP_CHAT]-(targetMsg:GroupMessage { id: $target_msg_id })
+		WHERE mem.role IN ["owner", "admin"] OR NOT coalesce(group.only_admins_can_send_messages, false)
 
 		MATCH (targetMsg)<-[:SENDS_MESSAGE]-(targetMsgSender)
 
@@ -823,7 +1831,7 @@ func ReplyToMessage(ctx context.Context, clientUsername, groupId, targetMsgId, m
 
 		CALL apoc.atomic.add(serialCounter, 'value', 1) YIELD newValue AS cheNextVal
 
-		CREATE (replyMsg:GroupMessage:GroupChatEntry{ id: randomUUID(), che_type: "message", content: $message_content, delivery_status: "sent", created_at: $at, cursor: cheNextVal }),
+		CREATE (replyMsg:GroupMessage:GroupChatEntry{ id: randomUUID(), che_type: "message", content: $message_content, search_text: $search_text, delivery_status: "sent", created_at: $at, cursor: cheNextVal }),
 			(clientUser)-[:SENDS_MESSAGE]->(replyMsg)-[:IN_GROUP_CHAT]->(clientChat),
 			(replyMsg)-[:REPLIES_TO]->(targetMsg)
 
@@ -838,6 +1846,7 @@ func ReplyToMessage(ctx context.Context, clientUsername, groupId, targetMsgId, m
 			"client_username":          clientUsername,
 			"group_id":                 groupId,
 			"message_content":          msgContent,
+			"search_text":              modelHelpers.MsgSearchText(msgContent),
 			"target_msg_id":            targetMsgId,
 			"at":                       at,
 			"group_che_serial_counter": "$groupCHESC$",
@@ -862,6 +1871,213 @@ type RxnToMessage struct {
 	ToMsgId string `msgpack:"to_msg_id" db:"to_msg_id"`
 }
 
+type EditedMessage struct {
+	Content  map[string]any `msgpack:"content" db:"content"`
+	EditedAt int64          `msgpack:"edited_at" db:"edited_at"`
+}
+
+// EditMessage replaces the text of the client's text message, or the caption of their photo or video message.
+// The replaced content is kept in the message's edit history
+func EditMessage(ctx context.Context, clientUsername, groupId, msgId, newText string, at int64) (EditedMessage, error) {
+	res, err := db.Query(
+		ctx,
+		`/*cypher*/
+		CYPHER 25
+
+		MATCH (clientUser:User{ username: $client_username })-[:SENDS_MESSAGE]->(message:GroupMessage{ id: $message_id })-[:IN_GROUP_CHAT]->(:GroupChat{ owner_username: $client_username, group_id: $group_id })-[:WITH_GROUP]->(group)
+		WHERE message.created_at >= $edit_window_start AND message.deleted_at IS NULL
+			AND EXISTS { (clientUser)-[:IS_MEMBER_OF]->(group) }
+
+		WITH message, apoc.convert.fromJsonMap(message.content) AS content
+		WITH message, content,
+			CASE content.type WHEN "text" THEN "text_content" WHEN "photo" THEN "caption" WHEN "video" THEN "caption" END AS editedProp
+		WHERE editedProp IS NOT NULL
+
+		WITH message, content, apoc.map.setKey(content, "props", apoc.map.setKey(content.props, editedProp, $new_text)) AS newContent
+
+		SET message.edit_history = coalesce(message.edit_history, []) + [apoc.convert.toJson({ content: content, replaced_at: $at })],
+			message.content = apoc.convert.toJson(newContent),
+			message.search_text = $new_text,
+			message.edited_at = $at
+
+		RETURN { content: newContent, edited_at: message.edited_at } AS edited_msg
+		`,
+		map[string]any{
+			"client_username":   clientUsername,
+			"group_id":          groupId,
+			"message_id":        msgId,
+			"new_text":          newText,
+			"at":                at,
+			"edit_window_start": time.Now().UTC().Add(-helpers.MsgEditWindow()).UnixMilli(),
+		},
+	)
+	if err != nil {
+		helpers.LogError(err)
+		return EditedMessage{}, fiber.ErrInternalServerError
+	}
+
+	editedMessage := modelHelpers.RKeyGet[EditedMessage](res.Records, "edited_msg")
+
+	return editedMessage, nil
+}
+
+// SetProcessedMessageMedia replaces the original image of the client's photo message, originalCloudName,
+// with the images processed from it, mediaCloudName. It returns the message's new content,
+// or nil, if the message no longer exists, or no longer has the original
+func SetProcessedMessageMedia(ctx context.Context, clientUsername, msgId, originalCloudName, mediaCloudName string) (map[string]any, error) {
+	res, err := db.Query(
+		ctx,
+		`/*cypher*/
+		CYPHER 25
+
+		MATCH (:User{ username: $client_username })-[:SENDS_MESSAGE]->(message:GroupMessage{ id: $message_id })
+		WHERE message.deleted_at IS NULL
+
+		WITH message, apoc.convert.fromJsonMap(message.content) AS content
+		WHERE content.props.media_cloud_name = $original_cloud_name
+
+		WITH message, apoc.map.setKey(content, "props", apoc.map.setKey(content.props, "media_cloud_name", $media_cloud_name)) AS newContent
+
+		SET message.content = apoc.convert.toJson(newContent)
+
+		RETURN newContent AS content
+		`,
+		map[string]any{
+			"client_username":     clientUsername,
+			"message_id":          msgId,
+			"original_cloud_name": originalCloudName,
+			"media_cloud_name":    mediaCloudName,
+		},
+	)
+	if err != nil {
+		helpers.LogError(err)
+		return nil, fiber.ErrInternalServerError
+	}
+
+	content := modelHelpers.RKeyGet[map[string]any](res.Records, "content")
+
+	return content, nil
+}
+
+// MarkMessageMediaFailed marks the client's photo message, whose original image, originalCloudName,
+// failed to be processed. It returns the message's new content,
+// or nil, if the message no longer exists, or no longer has the original
+func MarkMessageMediaFailed(ctx context.Context, clientUsername, msgId, originalCloudName string) (map[string]any, error) {
+	res, err := db.Query(
+		ctx,
+		`/*cypher*/
+		CYPHER 25
+
+		MATCH (:User{ username: $client_username })-[:SENDS_MESSAGE]->(message:GroupMessage{ id: $message_id })
+		WHERE message.deleted_at IS NULL
+
+		WITH message, apoc.convert.fromJsonMap(message.content) AS content
+		WHERE content.props.media_cloud_name = $original_cloud_name
+
+		WITH message, apoc.map.setKey(content, "props", apoc.map.setKey(content.props, "media_processing_failed", true)) AS newContent
+
+		SET message.content = apoc.convert.toJson(newContent)
+
+		RETURN newContent AS content
+		`,
+		map[string]any{
+			"client_username":     clientUsername,
+			"message_id":          msgId,
+			"original_cloud_name": originalCloudName,
+		},
+	)
+	if err != nil {
+		helpers.LogError(err)
+		return nil, fiber.ErrInternalServerError
+	}
+
+	content := modelHelpers.RKeyGet[map[string]any](res.Records, "content")
+
+	return content, nil
+}
+
+// DeleteMessagesForMe removes the messages from the client's group chat only
+func DeleteMessagesForMe(ctx context.Context, clientUsername, groupId string, msgIds []any) (bool, error) {
+	res, err := db.Query(
+		ctx,
+		`/*cypher*/
+		CYPHER 25
+
+		MATCH (:GroupChat{ owner_username: $client_username, group_id: $group_id })<-[rel:IN_GROUP_CHAT]-(message:GroupMessage WHERE message.id IN $message_ids)
+
+		DELETE rel
+
+		RETURN count(rel) > 0 AS done
+		`,
+		map[string]any{
+			"client_username": clientUsername,
+			"group_id":        groupId,
+			"message_ids":     msgIds,
+		},
+	)
+	if err != nil {
+		helpers.LogError(err)
+		return false, fiber.ErrInternalServerError
+	}
+
+	done := modelHelpers.RKeyGet[bool](res.Records, "done")
+
+	return done, nil
+}
+
+type DeletedMessage struct {
+	Content   map[string]any `msgpack:"-" db:"content"`
+	DeletedAt int64          `msgpack:"deleted_at" db:"deleted_at"`
+}
+
+// DeleteMessageForEveryone replaces the content of a group message with a tombstone,
+// in all members' chats, discards its edit history, and unpins it.
+// The client must either be the message's sender, within the delete window, or a group admin.
+//
+// Content is the content the message had, whose media is to be deleted
+func DeleteMessageForEveryone(ctx context.Context, clientUsername, groupId, msgId string, at int64) (DeletedMessage, error) {
+	res, err := db.Query(
+		ctx,
+		`/*cypher*/
+		CYPHER 25
+
+		MATCH (group)<-[:WITH_GROUP]-(clientChat:GroupChat{ owner_username: $client_username, group_id: $group_id })<-[:HAS_CHAT]-(clientUser),
+			(clientUser)-[clientMem:IS_MEMBER_OF]->(group),
+			(clientChat)<-[:IN_GROUP_CHAT]-(message:GroupMessage{ id: $message_id })<-[:SENDS_MESSAGE]-(senderUser)
+		WHERE message.deleted_at IS NULL
+			AND ((senderUser = clientUser AND message.created_at >= $delete_window_start) OR clientMem.role IN ["owner", "admin"])
+
+		WITH message, apoc.convert.fromJsonMap(message.content) AS content
+
+		SET message.content = $deleted_msg_content, message.deleted_at = $at
+		REMOVE message.edit_history, message.edited_at, message.search_text
+
+		CALL (message) {
+			MATCH (message)<-[pin:HAS_PINNED]-()
+			DELETE pin
+		}
+
+		RETURN { content: content, deleted_at: message.deleted_at } AS deleted_msg
+		`,
+		map[string]any{
+			"client_username":     clientUsername,
+			"group_id":            groupId,
+			"message_id":          msgId,
+			"deleted_msg_content": appTypes.DeletedMsgContentJson,
+			"at":                  at,
+			"delete_window_start": time.Now().UTC().Add(-helpers.MsgDeleteWindow()).UnixMilli(),
+		},
+	)
+	if err != nil {
+		helpers.LogError(err)
+		return DeletedMessage{}, fiber.ErrInternalServerError
+	}
+
+	deletedMessage := modelHelpers.RKeyGet[DeletedMessage](res.Records, "deleted_msg")
+
+	return deletedMessage, nil
+}
+
 func ReactToMessage(ctx context.Context, clientUsername, groupId, msgId, emoji string, at int64) (RxnToMessage, error) {
 	res, err := db.Query(
 		ctx,
@@ -872,7 +2088,7 @@ func ReactToMessage(ctx context.Context, clientUsername, groupId, msgId, emoji s
 			(clientUser)-[:IS_MEMBER_OF]->(group),
 			(clientChat)<-[:IN_GROUP_CHAT]-(message:GroupMessage{ id: $message_id })
 
-		MERGE (serialCounter:DirectCHESerialCounter{ name: $direct_che_serial_counter })
+		MERGE (serialCounter:GroupCHESerialCounter{ name: $group_che_serial_counter })
 		ON CREATE SET serialCounter.value = 0
 
 		LET dummy = 0
@@ -892,7 +2108,7 @@ func ReactToMessage(ctx context.Context, clientUsername, groupId, msgId, emoji s
 
 		MERGE (msgrxn)-[:IN_GROUP_CHAT]->(clientChat)
 
-		RETURN msgrxn { .che_id, .che_type, .emoji, to_msg_id: $message_id, reactor: $client_username } rxn_to_msg
+		RETURN msgrxn { .che_id, .che_type, .emoji, to_msg_id: $message_id, reactor: $client_username, .cursor } AS rxn_to_msg
 		`,
 		map[string]any{
 			"client_username":          clientUsername,
@@ -913,35 +2129,48 @@ func ReactToMessage(ctx context.Context, clientUsername, groupId, msgId, emoji s
 	return rxnToMessage, nil
 }
 
-func PostReactToMessage(ctx context.Context, clientUsername, groupId, msgId string) error {
-	_, err := db.Query(
+type PostRxnToMessage struct {
+	Reactor         string `msgpack:"-" db:"reactor"`
+	MsgId           string `msgpack:"-" db:"msg_id"`
+	MemberUsernames []any  `msgpack:"-" db:"member_usernames"`
+}
+
+// PostReactToMessages puts the reactions, rxns, each a map of its "reactor", "group_id" and "msg_id",
+// in the chats of the other members of their groups, at once.
+// It returns, for each reaction, the members it's put in the chats of
+func PostReactToMessages(ctx context.Context, rxns []any) ([]PostRxnToMessage, error) {
+	res, err := db.Query(
 		ctx,
 		`/* cypher */
 		CYPHER 25
 
-		MATCH (message:GroupMessage{ id: $msg_id }), (msgrxn:GroupMessageReaction{ reactor_username: $client_username, message_id: $msg_id })
+		UNWIND $rxns AS rxn
 
-		OPTIONAL MATCH (group:Group{ id: $group_id })<-[:IS_MEMBER_OF]-(memberUser WHERE memberUser.username <> $client_username)
-		OPTIONAL MATCH (memberUser)-[:HAS_CHAT]->(memberChat)-[:WITH_GROUP]->(group)
+		MATCH (:GroupMessage{ id: rxn.msg_id }), (msgrxn:GroupMessageReaction{ reactor_username: rxn.reactor, message_id: rxn.msg_id })
+
+		CALL (rxn, msgrxn) {
+			MATCH (group:Group{ id: rxn.group_id })<-[:IS_MEMBER_OF]-(memberUser WHERE memberUser.username <> rxn.reactor),
+				(memberUser)-[:HAS_CHAT]->(memberChat)-[:WITH_GROUP]->(group)
 
-		WITH msgrxn, collect(memberChat) AS memberChats
+			MERGE (msgrxn)-[:IN_GROUP_CHAT]->(memberChat)
 
-		FOREACH (mc IN memberChats | MERGE (msgrxn)-[:IN_GROUP_CHAT]->(mc))
+			RETURN collect(memberUser.username) AS memberUsernames
+		}
 
-		FINISH
+		RETURN collect({ reactor: rxn.reactor, msg_id: rxn.msg_id, member_usernames: memberUsernames }) AS post_rxns_to_msgs
 		`,
 		map[string]any{
-			"client_username": clientUsername,
-			"group_id":        groupId,
-			"msg_id":          msgId,
+			"rxns": rxns,
 		},
 	)
 	if err != nil {
 		helpers.LogError(err)
-		return err
+		return nil, fiber.ErrInternalServerError
 	}
 
-	return nil
+	prms := modelHelpers.RKeyGetMany[PostRxnToMessage](res.Records, "post_rxns_to_msgs")
+
+	return prms, nil
 }
 
 func RemoveReactionToMessage(ctx context.Context, clientUsername, groupId, msgId string) (string, error) {
@@ -952,7 +2181,7 @@ func RemoveReactionToMessage(ctx context.Context, clientUsername, groupId, msgId
 		
 		MATCH (group)<-[:WITH_GROUP]-(clientChat:GroupChat{ owner_username: $client_username, group_id: $group_id })<-[:HAS_CHAT]-(clientUser),
 			(clientUser)-[:IS_MEMBER_OF]->(group),
-			(clientChat)<-[IN_GROUP_CHAT]-(message:GroupMessage{ id: $message_id })
+			(clientChat)<-[:IN_GROUP_CHAT]-(message:GroupMessage{ id: $message_id })
 
 		MATCH (msgrxn:GroupMessageReaction:GroupChatEntry{ reactor_username: clientUser.username, message_id: message.id }),
 			(clientUser)-[crxn:REACTS_TO_MESSAGE]->(message)
@@ -979,24 +2208,38 @@ func RemoveReactionToMessage(ctx context.Context, clientUsername, groupId, msgId
 	return CHEId, nil
 }
 
-func ChatHistory(ctx context.Context, clientUsername, groupId string, limit int64, cursor float64) ([]UITypes.ChatHistoryEntry, error) {
-	cheMembers, err := redisDB().ZRevRangeByScoreWithScores(ctx, fmt.Sprintf("group_chat:owner:%s:group_id:%s:history", clientUsername, groupId), &redis.ZRangeBy{
-		Max:   helpers.MaxCursor(cursor),
-		Min:   "-inf",
-		Count: limit,
-	}).Result()
+// ChatHistory reads the client's chat history in a window of the mode, relative to the cursor, or the entry, anchorCHEId.
+// See modelHelpers.HistoryWindow
+func ChatHistory(ctx context.Context, clientUsername, groupId, mode, anchorCHEId string, limit int64, cursor float64) ([]UITypes.ChatHistoryEntry, error) {
+	cheMembers, err := modelHelpers.HistoryWindow(ctx, redisDB(), fmt.Sprintf("group_chat:owner:%s:group_id:%s:history", clientUsername, groupId), mode, anchorCHEId, limit, cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	history, err := modelHelpers.CHEMembersForUICHEs(ctx, cheMembers, "group", clientUsername)
+	if err != nil {
+		helpers.LogError(err)
+		return nil, fiber.ErrInternalServerError
+	}
+
+	return history, nil
+}
+
+// PinnedMessages returns the pinned messages of the group, the latest pinned first
+func PinnedMessages(ctx context.Context, clientUsername, groupId string) ([]UITypes.PinnedMessage, error) {
+	pinnedMsgMembers, err := redisDB().ZRevRangeWithScores(ctx, fmt.Sprintf("group:%s:pinned_messages", groupId), 0, -1).Result()
 	if err != nil {
 		helpers.LogError(err)
 		return nil, fiber.ErrInternalServerError
 	}
 
-	history, err := modelHelpers.CHEMembersForUICHEs(ctx, cheMembers, "group")
+	pinnedMessages, err := modelHelpers.PinnedMsgMembersForUIPinnedMsgs(ctx, pinnedMsgMembers, "group", clientUsername)
 	if err != nil {
 		helpers.LogError(err)
 		return nil, fiber.ErrInternalServerError
 	}
 
-	return history, nil
+	return pinnedMessages, nil
 }
 
 func GroupInfo(ctx context.Context, groupId string) (UITypes.GroupInfo, error) {
@@ -69,6 +69,19 @@ func buildGroupMemberSnippetUIFromCache(ctx context.Context, muser string) (gmem
 	return gmemSnippetUI, nil
 }
 
+func buildBlockedUserSnippetUIFromCache(ctx context.Context, buser string) (buserSnippetUI UITypes.BlockedUserSnippet, err error) {
+	nilVal := UITypes.BlockedUserSnippet{}
+
+	buserSnippetUI, err = cache.GetUser[UITypes.BlockedUserSnippet](ctx, buser)
+	if err != nil {
+		return nilVal, err
+	}
+
+	buserSnippetUI.ProfilePicUrl = cloudStorageService.ProfilePicCloudNameToUrl(buserSnippetUI.ProfilePicUrl)
+
+	return buserSnippetUI, nil
+}
+
 func buildChatSnippetUIFromCache(ctx context.Context, clientUsername, chatIdent string) (chatSnippetUI UITypes.ChatSnippet, err error) {
 	nilVal := UITypes.ChatSnippet{}
 
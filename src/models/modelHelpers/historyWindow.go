@@ -0,0 +1,94 @@
+package modelHelpers
+
+import (
+	"context"
+	"fmt"
+	"i9chat/src/helpers"
+	"slices"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/redis/go-redis/v9"
+)
+
+/*
+A chat's history is read in windows of, at most, limit entries, relative to a position in it:
+  - "before" (default): the entries older than the cursor; the latest entries, if there's no cursor.
+  - "after": the entries newer than the cursor, for scrolling forward, back to the present.
+  - "around": the entry at the cursor, with the entries on both sides of it.
+
+The position is the cursor, or the entry, anchorCHEId, whose cursor is looked up.
+Entries are returned latest first, whatever the mode; so a client pages backward
+from the last entry's cursor, and forward from the first entry's cursor.
+*/
+
+// HistoryWindow reads the members of the chat history sorted set, historyKey, in the window described above.
+func HistoryWindow(ctx context.Context, rdb *redis.Client, historyKey, mode, anchorCHEId string, limit int64, cursor float64) ([]redis.Z, error) {
+	if anchorCHEId != "" {
+		anchorCursor, err := rdb.ZScore(ctx, historyKey, anchorCHEId).Result()
+		if err == redis.Nil {
+			return nil, fiber.NewError(fiber.StatusNotFound, "entry not found in this chat's history")
+		}
+
+		if err != nil {
+			helpers.LogError(err)
+			return nil, fiber.ErrInternalServerError
+		}
+
+		cursor = anchorCursor
+	}
+
+	var olderCmd, newerCmd *redis.ZSliceCmd
+
+	_, err := rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		switch mode {
+		case "after":
+			newerCmd = pipe.ZRangeByScoreWithScores(ctx, historyKey, &redis.ZRangeBy{
+				Min:   fmt.Sprintf("(%f", cursor),
+				Max:   "+inf",
+				Count: limit,
+			})
+		case "around":
+			// the entry at the cursor is with the older half
+			olderCmd = pipe.ZRevRangeByScoreWithScores(ctx, historyKey, &redis.ZRangeBy{
+				Max:   fmt.Sprintf("%f", cursor),
+				Min:   "-inf",
+				Count: limit - limit/2,
+			})
+			// a zero count would read the sorted set to its end
+			if limit/2 > 0 {
+				newerCmd = pipe.ZRangeByScoreWithScores(ctx, historyKey, &redis.ZRangeBy{
+					Min:   fmt.Sprintf("(%f", cursor),
+					Max:   "+inf",
+					Count: limit / 2,
+				})
+			}
+		default:
+			olderCmd = pipe.ZRevRangeByScoreWithScores(ctx, historyKey, &redis.ZRangeBy{
+				Max:   helpers.MaxCursor(cursor),
+				Min:   "-inf",
+				Count: limit,
+			})
+		}
+
+		return nil
+	})
+	if err != nil {
+		helpers.LogError(err)
+		return nil, fiber.ErrInternalServerError
+	}
+
+	var older, newer []redis.Z
+
+	if olderCmd != nil {
+		older = olderCmd.Val()
+	}
+
+	if newerCmd != nil {
+		newer = newerCmd.Val()
+	}
+
+	// newer entries are read oldest first, from the cursor
+	slices.Reverse(newer)
+
+	return append(newer, older...), nil
+}
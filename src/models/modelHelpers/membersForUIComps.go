@@ -3,6 +3,7 @@ package modelHelpers
 import (
 	"context"
 	"i9chat/src/appTypes/UITypes"
+	"i9chat/src/cache"
 	"runtime"
 
 	"github.com/redis/go-redis/v9"
@@ -92,7 +93,7 @@ func ChatIdentMembersForUIChatSnippets(ctx context.Context, chatIdentMembers []r
 	return chatSnippetsAcc, nil
 }
 
-func CHEMembersForUICHEs(ctx context.Context, CHEMembers []redis.Z, chatType string) ([]UITypes.ChatHistoryEntry, error) {
+func CHEMembersForUICHEs(ctx context.Context, CHEMembers []redis.Z, chatType, clientUsername string) ([]UITypes.ChatHistoryEntry, error) {
 	chemsLen := len(CHEMembers)
 
 	CHEsAcc := make([]UITypes.ChatHistoryEntry, chemsLen)
@@ -125,6 +126,21 @@ func CHEMembersForUICHEs(ctx context.Context, CHEMembers []redis.Z, chatType str
 		return nil, err
 	}
 
+	// the client's starred messages are flagged
+	CHEIds := make([]string, chemsLen)
+	for i, chem := range CHEMembers {
+		CHEIds[i] = chem.Member.(string)
+	}
+
+	starred, err := cache.GetStarredAmong(ctx, clientUsername, CHEIds)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, isStarred := range starred {
+		CHEsAcc[i].Starred = isStarred
+	}
+
 	return CHEsAcc, nil
 }
 
@@ -166,3 +182,58 @@ func GroupMembersForUIGroupMemSnippets(ctx context.Context, groupMembers []redis
 
 	return memSnippetsAcc, nil
 }
+
+func BlockedUserMembersForUIBlockedUserSnippets(ctx context.Context, blockedUserMembers []redis.Z) ([]UITypes.BlockedUserSnippet, error) {
+	bumsLen := len(blockedUserMembers)
+
+	buserSnippetsAcc := make([]UITypes.BlockedUserSnippet, bumsLen)
+
+	threadNums := min(bumsLen, runtime.NumCPU())
+
+	eg, sharedCtx := errgroup.WithContext(ctx)
+
+	for i := range threadNums {
+		eg.Go(func() error {
+			j := i
+			start, end := (bumsLen*j)/threadNums, bumsLen*(j+1)/threadNums
+
+			for pIndx := start; pIndx < end; pIndx++ {
+				blockedUser := blockedUserMembers[pIndx].Member.(string)
+				cursor := blockedUserMembers[pIndx].Score
+
+				buserSnippet, err := buildBlockedUserSnippetUIFromCache(sharedCtx, blockedUser)
+				if err != nil {
+					return err
+				}
+
+				buserSnippet.Cursor = cursor
+
+				buserSnippetsAcc[pIndx] = buserSnippet
+			}
+
+			return nil
+		})
+	}
+
+	if err := eg.Wait(); err != nil {
+		return nil, err
+	}
+
+	return buserSnippetsAcc, nil
+}
+
+// PinnedMsgMembersForUIPinnedMsgs builds the pinned messages of a chat, whose members are scored by the time they're pinned
+func PinnedMsgMembersForUIPinnedMsgs(ctx context.Context, pinnedMsgMembers []redis.Z, chatType, clientUsername string) ([]UITypes.PinnedMessage, error) {
+	CHEs, err := CHEMembersForUICHEs(ctx, pinnedMsgMembers, chatType, clientUsername)
+	if err != nil {
+		return nil, err
+	}
+
+	pinnedMsgs := make([]UITypes.PinnedMessage, len(CHEs))
+
+	for i, CHE := range CHEs {
+		pinnedMsgs[i] = UITypes.PinnedMessage{PinnedAt: int64(pinnedMsgMembers[i].Score), Message: CHE}
+	}
+
+	return pinnedMsgs, nil
+}
@@ -1,6 +1,8 @@
 package modelHelpers
 
 import (
+	"cmp"
+	"encoding/json"
 	"fmt"
 	"reflect"
 	"strings"
@@ -104,3 +106,21 @@ func RKeyGetMany[T any](r []*neo4j.Record, key string) (res []T) {
 
 	return resAny.([]T)
 }
+
+// MsgSearchText returns the text a message is found by, in message search, given its content:
+// the text content of a text message, the caption of a photo or video, or the name of a file
+func MsgSearchText(msgContent string) string {
+	var content struct {
+		Props struct {
+			TextContent string `json:"text_content"`
+			Caption     string `json:"caption"`
+			Name        string `json:"name"`
+		} `json:"props"`
+	}
+
+	if err := json.Unmarshal([]byte(msgContent), &content); err != nil {
+		return ""
+	}
+
+	return cmp.Or(content.Props.TextContent, content.Props.Caption, content.Props.Name)
+}
@@ -6,9 +6,11 @@ import (
 	"i9chat/src/appGlobals"
 	"i9chat/src/appTypes"
 	"i9chat/src/appTypes/UITypes"
+	"i9chat/src/cache"
 	"i9chat/src/helpers"
 	"i9chat/src/models/db"
 	"i9chat/src/models/modelHelpers"
+	"strings"
 
 	"github.com/gofiber/fiber/v3"
 	"github.com/redis/go-redis/v9"
@@ -134,14 +136,17 @@ func ChangePassword(ctx context.Context, email, newPassword string) (string, err
 	return username, nil
 }
 
-func ChangeProfilePicture(ctx context.Context, clientUsername, profilePicCloudName string) (bool, error) {
+// ChangeProfilePicture returns the replaced profile picture's cloud name,
+// or an empty string, if the change wasn't made
+func ChangeProfilePicture(ctx context.Context, clientUsername, profilePicCloudName string) (string, error) {
 	res, err := db.Query(
 		ctx,
 		`/*cypher*/
 		MATCH (u:User{ username: $client_username })
+		WITH u, coalesce(u.profile_pic_url, "{notset}") AS old_profile_pic_url
 		SET u.profile_pic_url = $profile_pic_url
 
-		RETURN true AS done
+		RETURN old_profile_pic_url
 		`,
 		map[string]any{
 			"client_username": clientUsername,
@@ -150,14 +155,16 @@ func ChangeProfilePicture(ctx context.Context, clientUsername, profilePicCloudNa
 	)
 	if err != nil {
 		helpers.LogError(err)
-		return false, fiber.ErrInternalServerError
+		return "", fiber.ErrInternalServerError
 	}
 
 	if len(res.Records) == 0 {
-		return false, nil
+		return "", nil
 	}
 
-	return true, nil
+	oldProfilePicCloudName := modelHelpers.RKeyGet[string](res.Records, "old_profile_pic_url")
+
+	return oldProfilePicCloudName, nil
 }
 
 func ChangeBio(ctx context.Context, clientUsername, newBio string) (bool, error) {
@@ -259,6 +266,7 @@ func FindNearby(ctx context.Context, clientUsername string, x, y, radius float64
 		`/*cypher*/
 		MATCH (u:User)
 		WHERE u.username <> $client_username AND point.distance(point({ x: $live_long, y: $live_lat, crs: "WGS-84" }), u.geolocation) <= $radius
+			AND NOT EXISTS { (u)-[:BLOCKED]-(:User{ username: $client_username }) }
 
 		RETURN collect(u { .username, .profile_pic_url, .bio, .presence, .last_seen }) AS nearby_users
 	`,
@@ -293,6 +301,83 @@ func GetMyProfile(ctx context.Context, clientUsername string) (UITypes.UserProfi
 	return profile, nil
 }
 
+func BlockUser(ctx context.Context, clientUsername, targetUsername string, at int64) (bool, error) {
+	res, err := db.Query(
+		ctx,
+		`/*cypher*/
+		MATCH (clientUser:User{ username: $client_username }), (targetUser:User{ username: $target_username })
+		WHERE clientUser <> targetUser
+
+		MERGE (clientUser)-[blk:BLOCKED]->(targetUser)
+		ON CREATE
+			SET blk.at = $at
+
+		RETURN true AS done
+		`,
+		map[string]any{
+			"client_username": clientUsername,
+			"target_username": targetUsername,
+			"at":              at,
+		},
+	)
+	if err != nil {
+		helpers.LogError(err)
+		return false, fiber.ErrInternalServerError
+	}
+
+	if len(res.Records) == 0 {
+		return false, nil
+	}
+
+	return true, nil
+}
+
+func UnblockUser(ctx context.Context, clientUsername, targetUsername string) (bool, error) {
+	res, err := db.Query(
+		ctx,
+		`/*cypher*/
+		MATCH (:User{ username: $client_username })-[blk:BLOCKED]->(:User{ username: $target_username })
+		DELETE blk
+
+		RETURN true AS done
+		`,
+		map[string]any{
+			"client_username": clientUsername,
+			"target_username": targetUsername,
+		},
+	)
+	if err != nil {
+		helpers.LogError(err)
+		return false, fiber.ErrInternalServerError
+	}
+
+	if len(res.Records) == 0 {
+		return false, nil
+	}
+
+	return true, nil
+}
+
+func GetBlockedUsers(ctx context.Context, clientUsername string, limit int64, cursor float64) ([]UITypes.BlockedUserSnippet, error) {
+	blockedUserMembers, err := redisDB().ZRevRangeByScoreWithScores(ctx, fmt.Sprintf("user:%s:blocked_users", clientUsername), &redis.ZRangeBy{
+		Max:   helpers.MaxCursor(cursor),
+		Min:   "-inf",
+		Count: limit,
+	}).Result()
+	if err != nil {
+		helpers.LogError(err)
+		return nil, fiber.ErrInternalServerError
+	}
+
+	blockedUsers, err := modelHelpers.BlockedUserMembersForUIBlockedUserSnippets(ctx, blockedUserMembers)
+	if err != nil {
+		helpers.LogError(err)
+		return nil, fiber.ErrInternalServerError
+	}
+
+	return blockedUsers, nil
+}
+
 func GetMyChats(ctx context.Context, clientUsername string, limit int64, cursor float64) ([]UITypes.ChatSnippet, error) {
 	chatIdentMembers, err := redisDB().ZRevRangeByScoreWithScores(ctx, fmt.Sprintf("user:%s:chats_sorted", clientUsername), &redis.ZRangeBy{
 		Max:   helpers.MaxCursor(cursor),
@@ -312,3 +397,284 @@ func GetMyChats(ctx context.Context, clientUsername string, limit int64, cursor
 
 	return myChats, nil
 }
+
+type foundMsgMember struct {
+	CHEId     string `db:"che_id"`
+	Cursor    int64  `db:"cursor"`
+	ChatType  string `db:"chat_type"`
+	ChatIdent string `db:"chat_ident"`
+}
+
+// SearchMessages finds the messages, in the client's chats, whose text content, caption, or file name matches query,
+// most relevant first. The search is scoped to one chat, if chatType and chatIdent are set
+func SearchMessages(ctx context.Context, clientUsername, query, chatType, chatIdent string, limit, offset int64) ([]UITypes.FoundMessage, error) {
+	res, err := db.Query(
+		ctx,
+		`/*cypher*/
+		CYPHER 25
+
+		CALL db.index.fulltext.queryNodes("message_search", $query) YIELD node AS message, score
+		WHERE message.deleted_at IS NULL
+
+		MATCH (message)-[:IN_DIRECT_CHAT|IN_GROUP_CHAT]->(chat:DirectChat|GroupChat{ owner_username: $client_username })
+
+		WITH message, score,
+			CASE WHEN chat:DirectChat THEN "direct" ELSE "group" END AS chatType,
+			coalesce(chat.partner_username, chat.group_id) AS chatIdent
+		WHERE $chat_type = "" OR (chatType = $chat_type AND chatIdent = $chat_ident)
+
+		ORDER BY score DESC, message.created_at DESC
+		SKIP $offset
+		LIMIT $limit
+
+		RETURN collect({ che_id: message.id, cursor: message.cursor, chat_type: chatType, chat_ident: chatIdent }) AS found_msgs
+		`,
+		map[string]any{
+			"client_username": clientUsername,
+			"query":           messageSearchQuery(query),
+			"chat_type":       chatType,
+			"chat_ident":      chatIdent,
+			"limit":           limit,
+			"offset":          offset,
+		},
+	)
+	if err != nil {
+		helpers.LogError(err)
+		return nil, fiber.ErrInternalServerError
+	}
+
+	foundMsgMembers := modelHelpers.RKeyGetMany[foundMsgMember](res.Records, "found_msgs")
+
+	CHEMembers := make([]redis.Z, len(foundMsgMembers))
+	chatTypes := make([]string, len(foundMsgMembers))
+
+	for i, fmm := range foundMsgMembers {
+		CHEMembers[i] = redis.Z{Member: fmm.CHEId, Score: float64(fmm.Cursor)}
+		chatTypes[i] = fmm.ChatType
+	}
+
+	CHEs, err := chatMsgMembersForUICHEs(ctx, clientUsername, CHEMembers, chatTypes)
+	if err != nil {
+		return nil, fiber.ErrInternalServerError
+	}
+
+	foundMessages := make([]UITypes.FoundMessage, len(foundMsgMembers))
+
+	for i, fmm := range foundMsgMembers {
+		foundMessages[i] = UITypes.FoundMessage{ChatType: fmm.ChatType, ChatIdent: fmm.ChatIdent, Message: CHEs[i]}
+	}
+
+	return foundMessages, nil
+}
+
+// chatMsgMembersForUICHEs builds the history entries of messages from different chats, CHEMembers,
+// with chatTypes[i] the chat type of CHEMembers[i], and each member's score its cursor.
+// The entries are built per chat type, then put back in order
+func chatMsgMembersForUICHEs(ctx context.Context, clientUsername string, CHEMembers []redis.Z, chatTypes []string) ([]UITypes.ChatHistoryEntry, error) {
+	CHEs := make([]UITypes.ChatHistoryEntry, len(CHEMembers))
+
+	chatTypeCHEMembers := make(map[string][]redis.Z, 2)
+	chatTypeCHEIndexes := make(map[string][]int, 2)
+
+	for i, chem := range CHEMembers {
+		chatTypeCHEMembers[chatTypes[i]] = append(chatTypeCHEMembers[chatTypes[i]], chem)
+		chatTypeCHEIndexes[chatTypes[i]] = append(chatTypeCHEIndexes[chatTypes[i]], i)
+	}
+
+	for chatType, members := range chatTypeCHEMembers {
+		chatTypeCHEs, err := modelHelpers.CHEMembersForUICHEs(ctx, members, chatType, clientUsername)
+		if err != nil {
+			helpers.LogError(err)
+			return nil, err
+		}
+
+		for j, CHE := range chatTypeCHEs {
+			CHE.Cursor = members[j].Score
+
+			CHEs[chatTypeCHEIndexes[chatType][j]] = CHE
+		}
+	}
+
+	return CHEs, nil
+}
+
+type MediaChatT struct {
+	ChatType  string `db:"chat_type"`
+	ChatIdent string `db:"chat_ident"`
+}
+
+// FindMediaChats finds the client's chats with a message whose content references the media, mcn
+func FindMediaChats(ctx context.Context, clientUsername, mcn string) ([]MediaChatT, error) {
+	res, err := db.Query(
+		ctx,
+		`/*cypher*/
+		MATCH (:User{ username: $client_username })-[:HAS_CHAT]->(chat:DirectChat|GroupChat)<-[:IN_DIRECT_CHAT|IN_GROUP_CHAT]-(message:DirectMessage|GroupMessage)
+		WHERE message.deleted_at IS NULL AND message.content CONTAINS $mcn
+
+		WITH DISTINCT chat
+
+		RETURN collect({
+			chat_type: CASE WHEN chat:DirectChat THEN "direct" ELSE "group" END,
+			chat_ident: coalesce(chat.partner_username, chat.group_id)
+		}) AS media_chats
+		`,
+		map[string]any{
+			"client_username": clientUsername,
+			"mcn":             mcn,
+		},
+	)
+	if err != nil {
+		helpers.LogError(err)
+		return nil, fiber.ErrInternalServerError
+	}
+
+	return modelHelpers.RKeyGetMany[MediaChatT](res.Records, "media_chats"), nil
+}
+
+type StarredMsgChatT struct {
+	ChatType  string `msgpack:"chat_type" db:"chat_type"`
+	ChatIdent string `msgpack:"chat_ident" db:"chat_ident"`
+	MsgCursor int64  `msgpack:"msg_cursor" db:"msg_cursor"`
+	StarredAt int64  `msgpack:"-" db:"starred_at"`
+}
+
+// StarMessage stars, for the client, the message, msgId, in any of the client's chats.
+// It returns the message's chat, which is empty if there's no such message,
+// and when it was starred, which, for an already starred message, is when it was first starred
+func StarMessage(ctx context.Context, clientUsername, msgId string, at int64) (StarredMsgChatT, error) {
+	res, err := db.Query(
+		ctx,
+		`/*cypher*/
+		MATCH (clientUser:User{ username: $client_username })-[:HAS_CHAT]->(chat:DirectChat|GroupChat),
+			(message:DirectMessage|GroupMessage{ id: $msg_id })-[:IN_DIRECT_CHAT|IN_GROUP_CHAT]->(chat)
+		WHERE message.deleted_at IS NULL
+
+		MERGE (clientUser)-[star:STARRED]->(message)
+		ON CREATE
+			SET star.at = $at
+
+		RETURN {
+			chat_type: CASE WHEN chat:DirectChat THEN "direct" ELSE "group" END,
+			chat_ident: coalesce(chat.partner_username, chat.group_id),
+			msg_cursor: message.cursor,
+			starred_at: star.at
+		} AS msg_chat
+		`,
+		map[string]any{
+			"client_username": clientUsername,
+			"msg_id":          msgId,
+			"at":              at,
+		},
+	)
+	if err != nil {
+		helpers.LogError(err)
+		return StarredMsgChatT{}, fiber.ErrInternalServerError
+	}
+
+	if len(res.Records) == 0 {
+		return StarredMsgChatT{}, nil
+	}
+
+	return modelHelpers.RKeyGet[StarredMsgChatT](res.Records, "msg_chat"), nil
+}
+
+func UnstarMessage(ctx context.Context, clientUsername, msgId string) (bool, error) {
+	res, err := db.Query(
+		ctx,
+		`/*cypher*/
+		MATCH (:User{ username: $client_username })-[star:STARRED]->(:DirectMessage|GroupMessage{ id: $msg_id })
+		DELETE star
+
+		RETURN true AS done
+		`,
+		map[string]any{
+			"client_username": clientUsername,
+			"msg_id":          msgId,
+		},
+	)
+	if err != nil {
+		helpers.LogError(err)
+		return false, fiber.ErrInternalServerError
+	}
+
+	if len(res.Records) == 0 {
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// GetStarredMessages returns the client's starred messages, the latest starred first
+func GetStarredMessages(ctx context.Context, clientUsername string, limit int64, cursor float64) ([]UITypes.StarredMessage, error) {
+	starredMsgMembers, err := redisDB().ZRevRangeByScoreWithScores(ctx, fmt.Sprintf("user:%s:starred_messages", clientUsername), &redis.ZRangeBy{
+		Max:   helpers.MaxCursor(cursor),
+		Min:   "-inf",
+		Count: limit,
+	}).Result()
+	if err != nil {
+		helpers.LogError(err)
+		return nil, fiber.ErrInternalServerError
+	}
+
+	msgIds := make([]string, len(starredMsgMembers))
+	for i, smm := range starredMsgMembers {
+		msgIds[i] = smm.Member.(string)
+	}
+
+	msgChats, err := cache.GetStarredMessagesChats[StarredMsgChatT](ctx, clientUsername, msgIds)
+	if err != nil {
+		return nil, fiber.ErrInternalServerError
+	}
+
+	CHEMembers := make([]redis.Z, len(msgIds))
+	chatTypes := make([]string, len(msgIds))
+
+	for i, mc := range msgChats {
+		CHEMembers[i] = redis.Z{Member: msgIds[i], Score: float64(mc.MsgCursor)}
+		chatTypes[i] = mc.ChatType
+	}
+
+	CHEs, err := chatMsgMembersForUICHEs(ctx, clientUsername, CHEMembers, chatTypes)
+	if err != nil {
+		return nil, fiber.ErrInternalServerError
+	}
+
+	starredMessages := make([]UITypes.StarredMessage, len(msgIds))
+
+	for i, mc := range msgChats {
+		starredMessages[i] = UITypes.StarredMessage{
+			ChatType:  mc.ChatType,
+			ChatIdent: mc.ChatIdent,
+			Message:   CHEs[i],
+			Cursor:    starredMsgMembers[i].Score,
+		}
+	}
+
+	return starredMessages, nil
+}
+
+// messageSearchQuery turns the user's search text into a full-text query,
+// matching messages containing every word, the last one as a prefix, as it may be incomplete
+func messageSearchQuery(text string) string {
+	words := strings.Fields(strings.ToLower(text))
+
+	for i, word := range words {
+		var escaped strings.Builder
+
+		for _, r := range word {
+			if strings.ContainsRune(`+-&|!(){}[]^"~*?:\/`, r) {
+				escaped.WriteRune('\\')
+			}
+
+			escaped.WriteRune(r)
+		}
+
+		words[i] = escaped.String()
+	}
+
+	if len(words) > 0 {
+		words[len(words)-1] += "*"
+	}
+
+	return strings.Join(words, " AND ")
+}
@@ -2,6 +2,7 @@ package appRoutes
 
 import (
 	CUC "i9chat/src/controllers/chatControllers/chatUploadControllers"
+	SC "i9chat/src/controllers/storageControllers"
 	"i9chat/src/middlewares/authMiddlewares"
 	"i9chat/src/routes/appRoutes/directChatRoutes"
 	"i9chat/src/routes/appRoutes/groupChatRoutes"
@@ -24,4 +25,7 @@ func Route(router fiber.Router) {
 
 	router.Post("/chat_upload/authorize", CUC.AuthorizeUpload)
 	router.Post("/chat_upload/authorize/visual", CUC.AuthorizeVisualUpload)
+	router.Post("/chat_upload/authorize/original", CUC.AuthorizeOriginalUpload)
+
+	router.Get("/media/*", SC.GetProxiedMedia)
 }
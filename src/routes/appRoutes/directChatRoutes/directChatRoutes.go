@@ -8,4 +8,5 @@ import (
 
 func Route(router fiber.Router) {
 	router.Get("/:partner_username/history", directChatControllers.GetDirectChatHistory)
+	router.Get("/:partner_username/pinned_messages", directChatControllers.GetPinnedMessages)
 }
@@ -8,9 +8,15 @@ import (
 
 func Route(router fiber.Router) {
 	router.Post("/group_pic_upload/authorize", GCC.AuthorizeGroupPicUpload)
+	router.Post("/group_pic_upload/authorize/original", GCC.AuthorizeOriginalGroupPicUpload)
 
 	router.Post("/new", GCC.CreateNewGroup)
 	router.Get("/:group_id/members", GCC.GetGroupMembers)
 	router.Get("/:group_id/history", GCC.GetGroupChatHistory)
+	router.Get("/:group_id/pinned_messages", GCC.GetPinnedMessages)
+	router.Get("/:group_id/invite_links", GCC.GetInviteLinks)
 	router.Post("/:group_id/execute_action/:action", GCC.ExecuteAction)
+
+	router.Get("/invite_links/:code/preview", GCC.PreviewGroupByInviteLink)
+	router.Post("/invite_links/:code/join", GCC.JoinGroupViaInviteLink)
 }
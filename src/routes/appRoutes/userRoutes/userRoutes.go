@@ -9,6 +9,7 @@ import (
 func Route(router fiber.Router) {
 
 	router.Post("/profile_pic_upload/authorize", UC.AuthorizePPicUpload)
+	router.Post("/profile_pic_upload/authorize/original", UC.AuthorizeOriginalPPicUpload)
 
 	router.Get("/session_user", UC.GetSessionUser)
 	router.Get("/me", UC.GetMyProfile)
@@ -21,6 +22,19 @@ func Route(router fiber.Router) {
 	router.Get("/find_nearby_users", UC.FindNearbyUsers)
 
 	router.Get("/my_chats", UC.GetMyChats)
+	router.Get("/search_messages", UC.SearchMessages)
+
+	router.Post("/star_message", UC.StarMessage)
+	router.Post("/unstar_message", UC.UnstarMessage)
+	router.Get("/starred_messages", UC.GetStarredMessages)
+
+	router.Post("/block_user", UC.BlockUser)
+	router.Post("/unblock_user", UC.UnblockUser)
+	router.Get("/blocked_users", UC.GetBlockedUsers)
+
+	router.Get("/sessions", UC.GetMySessions)
+	router.Post("/revoke_session", UC.RevokeSession)
+	router.Post("/revoke_all_sessions", UC.RevokeAllSessions)
 
 	router.Get("/signout", UC.SignOut)
 }
@@ -0,0 +1,15 @@
+package storageRoutes
+
+import (
+	SC "i9chat/src/controllers/storageControllers"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// Route serves the signed urls of the local storage backend, and the upload urls of the S3 storage backend.
+// Requests are authorized by the url's signature, not the user's session
+func Route(router fiber.Router) {
+	router.Post("/*", SC.StartUpload)
+	router.Put("/*", SC.UploadChunk)
+	router.Get("/*", SC.GetMedia)
+}
@@ -0,0 +1,192 @@
+package sessionService
+
+import (
+	"context"
+	"fmt"
+	"i9chat/src/appGlobals"
+	"i9chat/src/helpers"
+	"i9chat/src/services/realtimeService"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/gofiber/utils/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func rdb() *redis.Client {
+	return appGlobals.RedisClient
+}
+
+// SessionTTL is how long a session, and the auth jwt carrying its id, lasts
+const SessionTTL = 10 * 24 * time.Hour
+
+/*
+A user's sessions are kept in Redis as:
+  - user:{username}:sessions, a sorted set of the user's session ids, scored by their last use.
+    A session is active as long as its id is in this set.
+  - session:{sessionId}, a hash of the session's details, expiring with the session
+*/
+
+type Session struct {
+	Id         string `msgpack:"id" redis:"-"`
+	Device     string `msgpack:"device" redis:"device"`
+	Ip         string `msgpack:"ip" redis:"ip"`
+	CreatedAt  int64  `msgpack:"created_at" redis:"created_at"`
+	LastUsedAt int64  `msgpack:"last_used_at" redis:"-"`
+	Current    bool   `msgpack:"current" redis:"-"`
+}
+
+func NewSession(ctx context.Context, clientUsername, device, ip string) (string, error) {
+	sessionId := utils.UUIDv4()
+	now := time.Now().UTC().UnixMilli()
+
+	_, err := rdb().TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, fmt.Sprintf("session:%s", sessionId), Session{Device: device, Ip: ip, CreatedAt: now})
+		pipe.Expire(ctx, fmt.Sprintf("session:%s", sessionId), SessionTTL)
+		pipe.ZAdd(ctx, fmt.Sprintf("user:%s:sessions", clientUsername), redis.Z{Score: float64(now), Member: sessionId})
+
+		return nil
+	})
+	if err != nil {
+		helpers.LogError(err)
+		return "", fiber.ErrInternalServerError
+	}
+
+	return sessionId, nil
+}
+
+// SessionActive reports whether clientUser's session, sessionId, hasn't been revoked,
+// recording its use if it hasn't
+func SessionActive(ctx context.Context, clientUsername, sessionId string) (bool, error) {
+	var lastUsedAt *redis.FloatCmd
+
+	_, err := rdb().Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		lastUsedAt = pipe.ZScore(ctx, fmt.Sprintf("user:%s:sessions", clientUsername), sessionId)
+		pipe.ZAddXX(ctx, fmt.Sprintf("user:%s:sessions", clientUsername), redis.Z{Score: float64(time.Now().UTC().UnixMilli()), Member: sessionId})
+
+		return nil
+	})
+	if err != nil && err != redis.Nil {
+		helpers.LogError(err)
+		return false, fiber.ErrInternalServerError
+	}
+
+	return lastUsedAt.Err() == nil, nil
+}
+
+// GetSessions returns clientUser's active sessions, the most recently used first.
+// Sessions that have expired are dropped along the way
+func GetSessions(ctx context.Context, clientUsername, currentSessionId string) ([]Session, error) {
+	sessionMembers, err := rdb().ZRevRangeWithScores(ctx, fmt.Sprintf("user:%s:sessions", clientUsername), 0, -1).Result()
+	if err != nil {
+		helpers.LogError(err)
+		return nil, fiber.ErrInternalServerError
+	}
+
+	cmds, err := rdb().Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, sm := range sessionMembers {
+			pipe.HGetAll(ctx, fmt.Sprintf("session:%s", sm.Member.(string)))
+		}
+
+		return nil
+	})
+	if err != nil {
+		helpers.LogError(err)
+		return nil, fiber.ErrInternalServerError
+	}
+
+	sessions := []Session{}
+	expiredSessionIds := []any{}
+
+	for i, sm := range sessionMembers {
+		sessionId := sm.Member.(string)
+
+		sessionCmd := cmds[i].(*redis.MapStringStringCmd)
+
+		if len(sessionCmd.Val()) == 0 {
+			expiredSessionIds = append(expiredSessionIds, sessionId)
+			continue
+		}
+
+		var session Session
+
+		if err := sessionCmd.Scan(&session); err != nil {
+			helpers.LogError(err)
+			return nil, fiber.ErrInternalServerError
+		}
+
+		session.Id = sessionId
+		session.LastUsedAt = int64(sm.Score)
+		session.Current = sessionId == currentSessionId
+
+		sessions = append(sessions, session)
+	}
+
+	if len(expiredSessionIds) > 0 {
+		if err := rdb().ZRem(ctx, fmt.Sprintf("user:%s:sessions", clientUsername), expiredSessionIds...).Err(); err != nil {
+			helpers.LogError(err)
+		}
+	}
+
+	return sessions, nil
+}
+
+// RevokeSessions ends clientUser's sessions, sessionIds, at once,
+// closing the sockets opened with them.
+// Session ids not belonging to clientUser are ignored
+func RevokeSessions(ctx context.Context, clientUsername string, sessionIds []string) (bool, error) {
+	scores, err := rdb().ZMScore(ctx, fmt.Sprintf("user:%s:sessions", clientUsername), sessionIds...).Result()
+	if err != nil {
+		helpers.LogError(err)
+		return false, fiber.ErrInternalServerError
+	}
+
+	ownSessionIds := []string{}
+	members := []any{}
+
+	for i, sessionId := range sessionIds {
+		// a member not in the set has a score of 0
+		if scores[i] == 0 {
+			continue
+		}
+
+		ownSessionIds = append(ownSessionIds, sessionId)
+		members = append(members, sessionId)
+	}
+
+	if len(ownSessionIds) == 0 {
+		return false, nil
+	}
+
+	_, err = rdb().TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRem(ctx, fmt.Sprintf("user:%s:sessions", clientUsername), members...)
+
+		for _, sessionId := range ownSessionIds {
+			pipe.Del(ctx, fmt.Sprintf("session:%s", sessionId))
+		}
+
+		return nil
+	})
+	if err != nil {
+		helpers.LogError(err)
+		return false, fiber.ErrInternalServerError
+	}
+
+	realtimeService.PublishSessionsRevoked(ctx, ownSessionIds)
+
+	return true, nil
+}
+
+func RevokeAllSessions(ctx context.Context, clientUsername string) (bool, error) {
+	sessionIds, err := rdb().ZRange(ctx, fmt.Sprintf("user:%s:sessions", clientUsername), 0, -1).Result()
+	if err != nil {
+		helpers.LogError(err)
+		return false, fiber.ErrInternalServerError
+	}
+
+	if len(sessionIds) == 0 {
+		return false, nil
+	}
+
+	return RevokeSessions(ctx, clientUsername, sessionIds)
+}
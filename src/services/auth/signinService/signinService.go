@@ -5,6 +5,7 @@ import (
 	"i9chat/src/appErrors/userErrors"
 	"i9chat/src/appTypes"
 	"i9chat/src/appTypes/UITypes"
+	"i9chat/src/services/auth/sessionService"
 	"i9chat/src/services/securityServices"
 	"i9chat/src/services/userService"
 	"os"
@@ -18,7 +19,7 @@ type signinRespT struct {
 	User UITypes.ClientUser `msgpack:"user"`
 }
 
-func Signin(ctx context.Context, emailOrUsername, password string) (signinRespT, string, error) {
+func Signin(ctx context.Context, emailOrUsername, password, device, ip string) (signinRespT, string, error) {
 	var resp signinRespT
 
 	theUser, err := userService.SigninUserFind(ctx, emailOrUsername)
@@ -41,9 +42,15 @@ func Signin(ctx context.Context, emailOrUsername, password string) (signinRespT,
 		return resp, "", fiber.NewError(fiber.StatusNotFound, userErrors.IncorrectCredentials)
 	}
 
+	sessionId, err := sessionService.NewSession(ctx, theUser.Username, device, ip)
+	if err != nil {
+		return resp, "", err
+	}
+
 	authJwt, err := securityServices.JwtSign(appTypes.ClientUser{
-		Username: theUser.Username,
-	}, os.Getenv("AUTH_JWT_SECRET"), time.Now().UTC().Add(10*24*time.Hour))
+		Username:  theUser.Username,
+		SessionId: sessionId,
+	}, os.Getenv("AUTH_JWT_SECRET"), time.Now().UTC().Add(sessionService.SessionTTL))
 
 	if err != nil {
 		return resp, "", err
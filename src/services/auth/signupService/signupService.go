@@ -7,6 +7,7 @@ import (
 	"i9chat/src/appTypes"
 	"i9chat/src/appTypes/UITypes"
 	"i9chat/src/helpers"
+	"i9chat/src/services/auth/sessionService"
 	"i9chat/src/services/mailService"
 	"i9chat/src/services/securityServices"
 	"i9chat/src/services/userService"
@@ -83,7 +84,7 @@ type signup3RespT struct {
 	User UITypes.ClientUser `msgpack:"user"`
 }
 
-func RegisterUser(ctx context.Context, sessionData msgpack.RawMessage, username, password, bio string) (signup3RespT, string, error) {
+func RegisterUser(ctx context.Context, sessionData msgpack.RawMessage, username, password, bio, device, ip string) (signup3RespT, string, error) {
 	var resp signup3RespT
 
 	email := helpers.FromBtMsgPack[struct {
@@ -113,9 +114,15 @@ func RegisterUser(ctx context.Context, sessionData msgpack.RawMessage, username,
 		return resp, "", err
 	}
 
+	sessionId, err := sessionService.NewSession(ctx, newUser.Username, device, ip)
+	if err != nil {
+		return resp, "", err
+	}
+
 	authJwt, err := securityServices.JwtSign(appTypes.ClientUser{
-		Username: newUser.Username,
-	}, os.Getenv("AUTH_JWT_SECRET"), time.Now().UTC().Add(10*24*time.Hour)) // 10 days
+		Username:  newUser.Username,
+		SessionId: sessionId,
+	}, os.Getenv("AUTH_JWT_SECRET"), time.Now().UTC().Add(sessionService.SessionTTL)) // 10 days
 	if err != nil {
 		return resp, "", err
 	}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"i9chat/src/services/cloudStorageService"
+	"i9chat/src/services/imageProcessingService"
 	"time"
 
 	"github.com/gofiber/fiber/v3"
@@ -15,7 +16,7 @@ type AuthDataT struct {
 	MediaCloudName string `msgpack:"mediaCloudName"`
 }
 
-func Authorize(ctx context.Context, msgType, mediaMIME string) (AuthDataT, error) {
+func Authorize(ctx context.Context, clientUsername, msgType, mediaMIME string) (AuthDataT, error) {
 	var res AuthDataT
 
 	mediaCloudName := fmt.Sprintf("uploads/chat/%s/%d%d/%s", msgType, time.Now().Year(), time.Now().Month(), utils.UUIDv4())
@@ -25,13 +26,19 @@ func Authorize(ctx context.Context, msgType, mediaMIME string) (AuthDataT, error
 		return res, fiber.ErrInternalServerError
 	}
 
+	if err := recordAuthorizedUpload(ctx, mediaCloudName, authorizedUpload{Uploader: clientUsername, MediaKind: msgType, MIME: mediaMIME}); err != nil {
+		return res, fiber.ErrInternalServerError
+	}
+
+	cloudStorageService.ScheduleMediaDeletion(ctx, mediaCloudName)
+
 	res.UploadUrl = url
 	res.MediaCloudName = mediaCloudName
 
 	return res, nil
 }
 
-func AuthorizeVisual(ctx context.Context, msgType string, mediaMIME [2]string) (AuthDataT, error) {
+func AuthorizeVisual(ctx context.Context, clientUsername, msgType string, mediaMIME [2]string) (AuthDataT, error) {
 	var res AuthDataT
 
 	for blurPlch0_actual1, mime := range mediaMIME {
@@ -45,6 +52,17 @@ func AuthorizeVisual(ctx context.Context, msgType string, mediaMIME [2]string) (
 			return res, fiber.ErrInternalServerError
 		}
 
+		mediaKind := msgType
+		if blurPlch0_actual1 == 0 {
+			mediaKind = "blur_placeholder"
+		}
+
+		if err := recordAuthorizedUpload(ctx, mediaCloudName, authorizedUpload{Uploader: clientUsername, MediaKind: mediaKind, MIME: mime}); err != nil {
+			return res, fiber.ErrInternalServerError
+		}
+
+		cloudStorageService.ScheduleMediaDeletion(ctx, mediaCloudName)
+
 		if blurPlch0_actual1 == 0 {
 			res.UploadUrl += "blur_placeholder:"
 			res.MediaCloudName += "blur_placeholder:"
@@ -64,3 +82,21 @@ func AuthorizeVisual(ctx context.Context, msgType string, mediaMIME [2]string) (
 
 	return res, nil
 }
+
+// AuthorizeOriginal authorizes the upload of a photo message's original image,
+// from which its blur placeholder, and actual photo, are produced by the server
+func AuthorizeOriginal(ctx context.Context, clientUsername, mediaMIME string) (AuthDataT, error) {
+	var res AuthDataT
+
+	mediaCloudName := fmt.Sprintf("uploads/chat/photo/%d%d/%s-original", time.Now().Year(), time.Now().Month(), utils.UUIDv4())
+
+	url, originalCloudName, err := imageProcessingService.AuthorizeOriginalUpload(ctx, clientUsername, "photo", mediaCloudName, mediaMIME)
+	if err != nil {
+		return res, err
+	}
+
+	res.UploadUrl = url
+	res.MediaCloudName = originalCloudName
+
+	return res, nil
+}
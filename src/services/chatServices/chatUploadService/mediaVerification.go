@@ -0,0 +1,226 @@
+package chatUploadService
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"i9chat/src/appGlobals"
+	"i9chat/src/helpers"
+	"i9chat/src/services/cloudStorageService"
+	"i9chat/src/services/imageProcessingService"
+	"net/http"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/redis/go-redis/v9"
+)
+
+func rdb() *redis.Client {
+	return appGlobals.RedisClient
+}
+
+// how long an authorized upload can be finalized, after it's authorized
+const uploadAuthTTL = 24 * time.Hour
+
+// authorizedUpload is recorded for each media cloud name an upload is authorized for,
+// so the media can be verified against it, when it's finalized
+type authorizedUpload struct {
+	Uploader  string `redis:"uploader"`
+	MediaKind string `redis:"media_kind"` // the message type, or "blur_placeholder"
+	MIME      string `redis:"mime"`
+}
+
+// the [min, max] size, in bytes, of each kind of media
+var mediaSizeRange = map[string][2]int64{
+	"blur_placeholder": {1 * 1024, 100 * 1024},
+	"photo":            {1 * 1024, 10 * 1024 * 1024},
+	"video":            {1 * 1024, 40 * 1024 * 1024},
+	"voice":            {500, 10 * 1024 * 1024},
+	"audio":            {500, 20 * 1024 * 1024},
+	"file":             {500, 50 * 1024 * 1024},
+}
+
+func recordAuthorizedUpload(ctx context.Context, mediaCloudName string, upload authorizedUpload) error {
+	_, err := rdb().TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, fmt.Sprintf("chat_upload:%s", mediaCloudName), upload)
+		pipe.Expire(ctx, fmt.Sprintf("chat_upload:%s", mediaCloudName), uploadAuthTTL)
+
+		return nil
+	})
+	if err != nil {
+		helpers.LogError(err)
+		return err
+	}
+
+	return nil
+}
+
+// FinalizeMessageMedia verifies the media of a message, about to be sent by clientUser in mediaChat,
+// before the message is accepted.
+//
+// The media must have been uploaded by clientUser, with an authorized upload for the message type,
+// be of the authorized MIME type, judged by its content, and be within the size range of its kind.
+// Invalid media is deleted, and the send rejected.
+//
+// Valid media is moved to its final name, which mediaCloudName is set to,
+// so the upload's still valid signed url can't replace the verified content
+func FinalizeMessageMedia(ctx context.Context, clientUsername, mediaChat, msgType string, mediaCloudName *string) error {
+	if mediaCloudName == nil {
+		return nil
+	}
+
+	// verified here, and recorded in mediaChat once processed
+	if imageProcessingService.IsOriginal(*mediaCloudName) {
+		return imageProcessingService.VerifyOriginalUpload(ctx, clientUsername, "photo", *mediaCloudName)
+	}
+
+	if msgType == "photo" || msgType == "video" {
+		var (
+			blurPlchMcn string
+			actualMcn   string
+		)
+
+		if _, err := fmt.Sscanf(*mediaCloudName, "blur_placeholder:%s actual:%s", &blurPlchMcn, &actualMcn); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid media cloud name")
+		}
+
+		finalBlurPlchMcn, err := finalizeUpload(ctx, clientUsername, "blur_placeholder", blurPlchMcn)
+		if err != nil {
+			return err
+		}
+
+		finalActualMcn, err := finalizeUpload(ctx, clientUsername, msgType, actualMcn)
+		if err != nil {
+			return err
+		}
+
+		if err := cloudStorageService.RecordMediaChat(ctx, mediaChat, finalBlurPlchMcn, finalActualMcn); err != nil {
+			return fiber.ErrInternalServerError
+		}
+
+		*mediaCloudName = fmt.Sprintf("blur_placeholder:%s actual:%s", finalBlurPlchMcn, finalActualMcn)
+
+		return nil
+	}
+
+	finalMcn, err := finalizeUpload(ctx, clientUsername, msgType, *mediaCloudName)
+	if err != nil {
+		return err
+	}
+
+	if err := cloudStorageService.RecordMediaChat(ctx, mediaChat, finalMcn); err != nil {
+		return fiber.ErrInternalServerError
+	}
+
+	*mediaCloudName = finalMcn
+
+	return nil
+}
+
+// finalizeUpload verifies the uploaded media, mcn, returning the final name it's moved to.
+//
+// The authorized upload is consumed, so the media is finalized once, and its final name used by one message only.
+// The media is copied to its final name before it's verified, and the upload deleted,
+// while it's left scheduled for deletion, in case it's uploaded again.
+// The final media is scheduled for deletion too, until the message referencing it is stored
+func finalizeUpload(ctx context.Context, clientUsername, mediaKind, mcn string) (string, error) {
+	var (
+		upload    authorizedUpload
+		uploadCmd *redis.MapStringStringCmd
+	)
+
+	_, err := rdb().TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		uploadCmd = pipe.HGetAll(ctx, fmt.Sprintf("chat_upload:%s", mcn))
+		pipe.Del(ctx, fmt.Sprintf("chat_upload:%s", mcn))
+
+		return nil
+	})
+	if err != nil {
+		helpers.LogError(err)
+		return "", fiber.ErrInternalServerError
+	}
+
+	if err := uploadCmd.Scan(&upload); err != nil {
+		helpers.LogError(err)
+		return "", fiber.ErrInternalServerError
+	}
+
+	if upload.Uploader != clientUsername || upload.MediaKind != mediaKind {
+		return "", fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("%s media: no authorized upload found for this media", mediaKind))
+	}
+
+	if cloudStorageService.GetMediaInfo(ctx, mcn) == nil {
+		return "", fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("%s media: upload not found or incomplete", mediaKind))
+	}
+
+	finalMcn := mcn + "-final"
+
+	if err := cloudStorageService.CopyMedia(ctx, mcn, finalMcn); err != nil {
+		return "", fiber.ErrInternalServerError
+	}
+
+	cloudStorageService.ScheduleMediaDeletion(ctx, finalMcn)
+
+	go cloudStorageService.DeleteCloudMedia(context.Background(), mcn)
+
+	mInfo := cloudStorageService.GetMediaInfo(ctx, finalMcn)
+	if mInfo == nil {
+		return "", fiber.ErrInternalServerError
+	}
+
+	sizeRange := mediaSizeRange[mediaKind]
+
+	if mInfo.Size < sizeRange[0] || mInfo.Size > sizeRange[1] {
+		go cloudStorageService.DeleteCloudMedia(context.Background(), finalMcn)
+
+		return "", fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("%s media: size out of range; min: %dB; max: %dB", mediaKind, sizeRange[0], sizeRange[1]))
+	}
+
+	head, err := cloudStorageService.GetMediaHead(ctx, finalMcn, 512)
+	if err != nil {
+		return "", fiber.ErrInternalServerError
+	}
+
+	if !contentMatchesMIME(head, upload.MIME) {
+		go cloudStorageService.DeleteCloudMedia(context.Background(), finalMcn)
+
+		return "", fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("%s media: content isn't of the authorized type, %s", mediaKind, upload.MIME))
+	}
+
+	return finalMcn, nil
+}
+
+// contentMatchesMIME reports whether head, the first bytes of a media, is the content of a mimeType media.
+// Arbitrary file types, which can't all be recognized, are let through
+func contentMatchesMIME(head []byte, mimeType string) bool {
+	sniffed := http.DetectContentType(head)
+
+	// the major brand of an ISO base media file (mp4, m4a, avif)
+	isoBrand := ""
+	if len(head) >= 12 && bytes.Equal(head[4:8], []byte("ftyp")) {
+		isoBrand = string(head[8:12])
+	}
+
+	switch mimeType {
+	case "image/jpeg", "image/png", "image/webp", "video/webm":
+		return sniffed == mimeType
+	case "image/avif":
+		return isoBrand == "avif" || isoBrand == "avis"
+	case "video/mp4":
+		return isoBrand != "" && isoBrand != "avif" && isoBrand != "avis"
+	case "audio/mp4":
+		return isoBrand != ""
+	case "audio/webm":
+		return sniffed == "video/webm"
+	case "audio/ogg":
+		return sniffed == "application/ogg"
+	case "audio/mpeg":
+		// ID3 tagged, or starting with an MPEG frame sync
+		return sniffed == "audio/mpeg" || len(head) >= 2 && head[0] == 0xFF && head[1]&0xE0 == 0xE0
+	case "audio/aac":
+		// starting with an ADTS frame sync
+		return len(head) >= 2 && head[0] == 0xFF && head[1]&0xF6 == 0xF0
+	default:
+		return true
+	}
+}
@@ -2,6 +2,8 @@ package directChatService
 
 import (
 	"context"
+	"fmt"
+	"i9chat/src/appErrors/userErrors"
 	"i9chat/src/appTypes"
 	"i9chat/src/appTypes/UITypes"
 	"i9chat/src/cache"
@@ -10,15 +12,38 @@ import (
 	"i9chat/src/services/cloudStorageService"
 	"i9chat/src/services/eventStreamService"
 	"i9chat/src/services/eventStreamService/eventTypes"
+	"i9chat/src/services/imageProcessingService"
 	"i9chat/src/services/realtimeService"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
 )
 
+// ensureNotBlocked fails when either of the chat partners has blocked the other.
+// The check is served from the cached block sets, not the graph
+func ensureNotBlocked(ctx context.Context, clientUsername, partnerUsername string) error {
+	blocked, err := cache.IsBlockedBetween(ctx, clientUsername, partnerUsername)
+	if err != nil {
+		return fiber.ErrInternalServerError
+	}
+
+	if blocked {
+		return fiber.NewError(fiber.StatusForbidden, userErrors.BlockedUser)
+	}
+
+	return nil
+}
+
 func SendMessage(ctx context.Context, clientUsername, partnerUsername, replyTargetMsgId string, isReply bool, msgContentJson string, at int64) (map[string]any, error) {
 	var (
 		newMessage directChat.NewMessage
 		err        error
 	)
 
+	if err = ensureNotBlocked(ctx, clientUsername, partnerUsername); err != nil {
+		return nil, err
+	}
+
 	if !isReply {
 		newMessage, err = directChat.SendMessage(ctx, clientUsername, partnerUsername, msgContentJson, at)
 		if err != nil {
@@ -35,6 +60,19 @@ func SendMessage(ctx context.Context, clientUsername, partnerUsername, replyTarg
 		return nil, nil
 	}
 
+	if originalCloudName, ok := imageProcessingService.MessageOriginal(newMessage.Content); ok {
+		go eventStreamService.QueueImageProcessingJob(eventTypes.ImageProcessingJob{
+			Target:      "direct_chat_photo",
+			OwnerUser:   clientUsername,
+			PartnerUser: partnerUsername,
+			MsgId:       newMessage.Id,
+			OriginalMcn: imageProcessingService.OriginalMcn(originalCloudName),
+		})
+	} else {
+		// the message now references its media; an original is unscheduled once it's processed
+		cloudStorageService.UnscheduleMediaDeletion(ctx, cloudStorageService.MessageMediaCloudNames(newMessage.Content)...)
+	}
+
 	go func(msg directChat.NewMessage, clientUsername, partnerUsername string) {
 		uisender, _ := cache.GetUser[UITypes.ClientUser](context.Background(), clientUsername)
 
@@ -142,10 +180,158 @@ func AckMessagesRead(ctx context.Context, clientUsername, partnerUsername string
 	return done, nil
 }
 
+func EditMessage(ctx context.Context, clientUsername, partnerUsername, msgId, newText string, at int64) (map[string]any, error) {
+	if err := ensureNotBlocked(ctx, clientUsername, partnerUsername); err != nil {
+		return nil, err
+	}
+
+	editedMessage, err := directChat.EditMessage(ctx, clientUsername, partnerUsername, msgId, newText, at)
+	if err != nil {
+		return nil, err
+	}
+
+	if editedMessage.EditedAt == 0 {
+		return nil, nil
+	}
+
+	// encoded before the media cloud name in the content is converted to url, in place, below
+	contentJson := helpers.ToJson(editedMessage.Content)
+
+	go realtimeService.SendEventMsg(partnerUsername, appTypes.ServerEventMsg{
+		Event: "direct chat: message edited",
+		Data: map[string]any{
+			"chat_partner": clientUsername,
+			"msg_id":       msgId,
+			"content":      cloudStorageService.MessageMediaCloudNameToUrl(editedMessage.Content),
+			"edited_at":    editedMessage.EditedAt,
+		},
+	})
+
+	// queue msg edit event
+	go eventStreamService.QueueDirectMsgEditEvent(eventTypes.DirectMsgEditEvent{
+		FromUser: clientUsername,
+		ToUser:   partnerUsername,
+		CHEId:    msgId,
+		Content:  contentJson,
+		EditedAt: editedMessage.EditedAt,
+	})
+
+	return map[string]any{"edited_at": editedMessage.EditedAt}, nil
+}
+
+// ApplyProcessedPhoto replaces the original image of the photo message with the images processed from it,
+// and lets both chat partners know
+func ApplyProcessedPhoto(ctx context.Context, ownerUsername, partnerUsername, msgId, originalCloudName, mediaCloudName string) {
+	content, err := directChat.SetProcessedMessageMedia(ctx, ownerUsername, msgId, originalCloudName, mediaCloudName)
+	if err != nil || content == nil {
+		imageProcessingService.DeleteProcessed(ctx, mediaCloudName)
+		return
+	}
+
+	cache.UpdateDirectChatHistoryEntry(ctx, msgId, map[string]any{"content": content})
+
+	UIContent := cloudStorageService.MessageMediaCloudNameToUrl(content)
+
+	for user, partner := range map[string]string{ownerUsername: partnerUsername, partnerUsername: ownerUsername} {
+		go realtimeService.SendEventMsg(user, appTypes.ServerEventMsg{
+			Event: "direct chat: message media processed",
+			Data: map[string]any{
+				"chat_partner": partner,
+				"msg_id":       msgId,
+				"content":      UIContent,
+			},
+		})
+	}
+}
+
+// FailProcessedPhoto marks the photo message whose original image couldn't be processed,
+// and lets both users know
+func FailProcessedPhoto(ctx context.Context, ownerUsername, partnerUsername, msgId, originalCloudName string) {
+	content, err := directChat.MarkMessageMediaFailed(ctx, ownerUsername, msgId, originalCloudName)
+	if err != nil || content == nil {
+		return
+	}
+
+	cache.UpdateDirectChatHistoryEntry(ctx, msgId, map[string]any{"content": content})
+
+	UIContent := cloudStorageService.MessageMediaCloudNameToUrl(content)
+
+	for user, partner := range map[string]string{ownerUsername: partnerUsername, partnerUsername: ownerUsername} {
+		go realtimeService.SendEventMsg(user, appTypes.ServerEventMsg{
+			Event: "direct chat: message media processing failed",
+			Data: map[string]any{
+				"chat_partner": partner,
+				"msg_id":       msgId,
+				"content":      UIContent,
+			},
+		})
+	}
+}
+
+func DeleteMessagesForMe(ctx context.Context, clientUsername, partnerUsername string, msgIds []any) (bool, error) {
+	done, err := directChat.DeleteMessagesForMe(ctx, clientUsername, partnerUsername, msgIds)
+	if err != nil {
+		return false, err
+	}
+
+	if done {
+		// queue msg deletion event
+		go eventStreamService.QueueDirectMsgDeletionEvent(eventTypes.DirectMsgDeletionEvent{
+			FromUser: clientUsername,
+			ToUser:   partnerUsername,
+			CHEIds:   msgIds,
+			For:      "me",
+		})
+	}
+
+	return done, nil
+}
+
+func DeleteMessageForEveryone(ctx context.Context, clientUsername, partnerUsername, msgId string, at int64) (map[string]any, error) {
+	deletedMessage, err := directChat.DeleteMessageForEveryone(ctx, clientUsername, partnerUsername, msgId, at)
+	if err != nil {
+		return nil, err
+	}
+
+	if deletedMessage.DeletedAt == 0 {
+		return nil, nil
+	}
+
+	go cloudStorageService.DeleteMessageMedia(context.Background(), deletedMessage.Content)
+
+	if err := cache.RemoveDirectChatPinnedMessage(ctx, clientUsername, partnerUsername, msgId); err != nil {
+		return nil, fiber.ErrInternalServerError
+	}
+
+	go realtimeService.SendEventMsg(partnerUsername, appTypes.ServerEventMsg{
+		Event: "direct chat: message deleted",
+		Data: map[string]any{
+			"chat_partner": clientUsername,
+			"msg_id":       msgId,
+			"deleted_at":   deletedMessage.DeletedAt,
+		},
+	})
+
+	// queue msg deletion event
+	go eventStreamService.QueueDirectMsgDeletionEvent(eventTypes.DirectMsgDeletionEvent{
+		FromUser:  clientUsername,
+		ToUser:    partnerUsername,
+		CHEIds:    []any{msgId},
+		For:       "everyone",
+		DeletedAt: deletedMessage.DeletedAt,
+	})
+
+	return map[string]any{"deleted_at": deletedMessage.DeletedAt}, nil
+}
+
 // Fix business logic: There's the possiblitity of the message not existing in the partner's user's chat
 // Do the client's first, then return the condition whether the message exists with the partner
 // If true, then do for the partner, else skip for them
 func ReactToMessage(ctx context.Context, clientUsername, partnerUsername, msgId, emoji string, at int64) (map[string]any, error) {
+	if err := ensureNotBlocked(ctx, clientUsername, partnerUsername); err != nil {
+		return nil, err
+	}
+
 	rxnToMessage, err := directChat.ReactToMessage(ctx, clientUsername, partnerUsername, msgId, emoji, at)
 	if err != nil {
 		return nil, err
@@ -221,6 +407,120 @@ func RemoveReactionToMessage(ctx context.Context, clientUsername, partnerUsernam
 	return done, nil
 }
 
-func GetChatHistory(ctx context.Context, clientUsername, partnerUsername string, limit int64, cursor float64) (any, error) {
-	return directChat.ChatHistory(ctx, clientUsername, partnerUsername, limit, cursor)
+// maxPinnedMessages is how many messages can be pinned in a direct chat, at once
+const maxPinnedMessages = 5
+
+// PinMessage pins a message of the chat, for both partners, and lets the partner know
+func PinMessage(ctx context.Context, clientUsername, partnerUsername, msgId string) (bool, error) {
+	if err := ensureNotBlocked(ctx, clientUsername, partnerUsername); err != nil {
+		return false, err
+	}
+
+	at := time.Now().UTC().UnixMilli()
+
+	done, err := directChat.PinMessage(ctx, clientUsername, partnerUsername, msgId, at, maxPinnedMessages)
+	if err != nil {
+		return false, err
+	}
+
+	if !done {
+		pinnedCount, err := cache.GetDirectChatPinnedMessagesCount(ctx, clientUsername, partnerUsername)
+		if err != nil {
+			return false, fiber.ErrInternalServerError
+		}
+
+		if pinnedCount >= maxPinnedMessages {
+			return false, fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("you can only pin up to %d messages in a chat; unpin one first", maxPinnedMessages))
+		}
+
+		return false, nil
+	}
+
+	if err := cache.StoreDirectChatPinnedMessage(ctx, clientUsername, partnerUsername, msgId, at); err != nil {
+		return false, fiber.ErrInternalServerError
+	}
+
+	go realtimeService.SendEventMsg(partnerUsername, appTypes.ServerEventMsg{
+		Event: "direct chat: message pinned",
+		Data: map[string]any{
+			"chat_partner": clientUsername,
+			"msg_id":       msgId,
+			"pinned_at":    at,
+		},
+	})
+
+	return true, nil
+}
+
+// UnpinMessage unpins a pinned message of the chat, for both partners, and lets the partner know
+func UnpinMessage(ctx context.Context, clientUsername, partnerUsername, msgId string) (bool, error) {
+	done, err := directChat.UnpinMessage(ctx, clientUsername, partnerUsername, msgId)
+	if err != nil {
+		return false, err
+	}
+
+	if done {
+		if err := cache.RemoveDirectChatPinnedMessage(ctx, clientUsername, partnerUsername, msgId); err != nil {
+			return false, fiber.ErrInternalServerError
+		}
+
+		go realtimeService.SendEventMsg(partnerUsername, appTypes.ServerEventMsg{
+			Event: "direct chat: message unpinned",
+			Data: map[string]any{
+				"chat_partner": clientUsername,
+				"msg_id":       msgId,
+			},
+		})
+	}
+
+	return done, nil
+}
+
+// SendTypingState relays the client's typing state (typing, recording, stopped) to the partner.
+// It isn't persisted, and a typing or recording state not renewed in time is relayed as stopped
+func SendTypingState(ctx context.Context, clientUsername, partnerUsername, state string) (bool, error) {
+	if err := ensureNotBlocked(ctx, clientUsername, partnerUsername); err != nil {
+		return false, err
+	}
+
+	chatExists, err := cache.ChatExists(ctx, clientUsername, partnerUsername)
+	if err != nil {
+		return false, err
+	}
+
+	if !chatExists {
+		return false, nil
+	}
+
+	sendState := func(state string) {
+		realtimeService.SendEphemeralEventMsg(partnerUsername, appTypes.ServerEventMsg{
+			Event: "direct chat: typing",
+			Data: map[string]any{
+				"chat_partner": clientUsername,
+				"state":        state,
+			},
+		})
+	}
+
+	stateKey := fmt.Sprintf("direct_chat:%s:%s:typing", clientUsername, partnerUsername)
+
+	if state == "stopped" {
+		realtimeService.EndEphemeralState(stateKey)
+	} else {
+		realtimeService.RenewEphemeralState(stateKey, func() {
+			sendState("stopped")
+		})
+	}
+
+	go sendState(state)
+
+	return true, nil
+}
+
+func GetChatHistory(ctx context.Context, clientUsername, partnerUsername, mode, anchorMsgId string, limit int64, cursor float64) (any, error) {
+	return directChat.ChatHistory(ctx, clientUsername, partnerUsername, mode, anchorMsgId, limit, cursor)
+}
+
+func GetPinnedMessages(ctx context.Context, clientUsername, partnerUsername string) ([]UITypes.PinnedMessage, error) {
+	return directChat.PinnedMessages(ctx, clientUsername, partnerUsername)
 }
@@ -6,6 +6,7 @@ import (
 	"i9chat/src/appGlobals"
 	"i9chat/src/appTypes"
 	"i9chat/src/appTypes/UITypes"
+	"i9chat/src/cache"
 	"i9chat/src/helpers"
 	"i9chat/src/services/realtimeService"
 
@@ -168,3 +169,210 @@ func broadcastMsgReactionRemoved(groupId, clientUsername string, data any) {
 		cursor = nextCursor
 	}
 }
+
+func broadcastMsgEdited(groupId, clientUsername string, data any) {
+	ctx := context.Background()
+
+	var cursor uint64 = 0
+
+	for {
+		musers, nextCursor, err := appGlobals.RedisClient.SScan(ctx, fmt.Sprintf("group:%s:members", groupId), cursor, "*", 100).Result()
+		if err != nil && err != redis.Nil {
+			helpers.LogError(err)
+			return
+		}
+
+		for _, mu := range musers {
+			if mu == clientUsername {
+				continue
+			}
+
+			go realtimeService.SendEventMsg(mu, appTypes.ServerEventMsg{
+				Event: "group chat: message edited",
+				Data:  data,
+			})
+		}
+
+		if nextCursor == 0 {
+			break
+		}
+
+		cursor = nextCursor
+	}
+}
+
+func broadcastMsgDeleted(groupId, clientUsername string, data any) {
+	ctx := context.Background()
+
+	var cursor uint64 = 0
+
+	for {
+		musers, nextCursor, err := appGlobals.RedisClient.SScan(ctx, fmt.Sprintf("group:%s:members", groupId), cursor, "*", 100).Result()
+		if err != nil && err != redis.Nil {
+			helpers.LogError(err)
+			return
+		}
+
+		for _, mu := range musers {
+			if mu == clientUsername {
+				continue
+			}
+
+			go realtimeService.SendEventMsg(mu, appTypes.ServerEventMsg{
+				Event: "group chat: message deleted",
+				Data:  data,
+			})
+		}
+
+		if nextCursor == 0 {
+			break
+		}
+
+		cursor = nextCursor
+	}
+}
+
+func broadcastTypingState(groupId, clientUsername, state string) {
+	onMems, err := cache.GetGroupOnlineMembers(context.Background(), groupId)
+	if err != nil {
+		return
+	}
+
+	for _, mu := range onMems {
+		if mu == clientUsername {
+			continue
+		}
+
+		go realtimeService.SendEphemeralEventMsg(mu, appTypes.ServerEventMsg{
+			Event: "group chat: typing",
+			Data: map[string]any{
+				"group_id": groupId,
+				"member":   clientUsername,
+				"state":    state,
+			},
+		})
+	}
+}
+
+func broadcastMsgMediaEvent(groupId, event string, data any) {
+	ctx := context.Background()
+
+	var cursor uint64 = 0
+
+	for {
+		musers, nextCursor, err := appGlobals.RedisClient.SScan(ctx, fmt.Sprintf("group:%s:members", groupId), cursor, "*", 100).Result()
+		if err != nil && err != redis.Nil {
+			helpers.LogError(err)
+			return
+		}
+
+		for _, mu := range musers {
+			go realtimeService.SendEventMsg(mu, appTypes.ServerEventMsg{
+				Event: event,
+				Data:  data,
+			})
+		}
+
+		if nextCursor == 0 {
+			break
+		}
+
+		cursor = nextCursor
+	}
+}
+
+func broadcastMsgPinned(groupId, clientUsername string, data any) {
+	ctx := context.Background()
+
+	var cursor uint64 = 0
+
+	for {
+		musers, nextCursor, err := appGlobals.RedisClient.SScan(ctx, fmt.Sprintf("group:%s:members", groupId), cursor, "*", 100).Result()
+		if err != nil && err != redis.Nil {
+			helpers.LogError(err)
+			return
+		}
+
+		for _, mu := range musers {
+			if mu == clientUsername {
+				continue
+			}
+
+			go realtimeService.SendEventMsg(mu, appTypes.ServerEventMsg{
+				Event: "group chat: message pinned",
+				Data:  data,
+			})
+		}
+
+		if nextCursor == 0 {
+			break
+		}
+
+		cursor = nextCursor
+	}
+}
+
+func broadcastMsgUnpinned(groupId, clientUsername string, data any) {
+	ctx := context.Background()
+
+	var cursor uint64 = 0
+
+	for {
+		musers, nextCursor, err := appGlobals.RedisClient.SScan(ctx, fmt.Sprintf("group:%s:members", groupId), cursor, "*", 100).Result()
+		if err != nil && err != redis.Nil {
+			helpers.LogError(err)
+			return
+		}
+
+		for _, mu := range musers {
+			if mu == clientUsername {
+				continue
+			}
+
+			go realtimeService.SendEventMsg(mu, appTypes.ServerEventMsg{
+				Event: "group chat: message unpinned",
+				Data:  data,
+			})
+		}
+
+		if nextCursor == 0 {
+			break
+		}
+
+		cursor = nextCursor
+	}
+}
+
+func broadcastNewJoinRequest(admins []any, data any) {
+	for _, admin := range admins {
+
+		realtimeService.SendEventMsg(admin.(string), appTypes.ServerEventMsg{
+			Event: "group chat: new join request",
+			Data:  data,
+		})
+	}
+}
+
+func sendJoinRequestApproved(requester string, data any) {
+	realtimeService.SendEventMsg(requester, appTypes.ServerEventMsg{
+		Event: "group chat: join request approved",
+		Data:  data,
+	})
+}
+
+func sendJoinRequestRejected(requester string, data any) {
+	realtimeService.SendEventMsg(requester, appTypes.ServerEventMsg{
+		Event: "group chat: join request rejected",
+		Data:  data,
+	})
+}
+
+func broadcastGroupDeleted(formerMembers []any, data any) {
+	for _, fm := range formerMembers {
+
+		realtimeService.SendEventMsg(fm.(string), appTypes.ServerEventMsg{
+			Event: "group chat: group deleted",
+			Data:  data,
+		})
+	}
+}
@@ -2,6 +2,7 @@ package groupChatService
 
 import (
 	"context"
+	"crypto/rand"
 	"fmt"
 	"i9chat/src/appTypes/UITypes"
 	"i9chat/src/cache"
@@ -10,6 +11,9 @@ import (
 	"i9chat/src/services/cloudStorageService"
 	"i9chat/src/services/eventStreamService"
 	"i9chat/src/services/eventStreamService/eventTypes"
+	"i9chat/src/services/imageProcessingService"
+	"i9chat/src/services/realtimeService"
+	"slices"
 	"time"
 
 	"github.com/gofiber/fiber/v3"
@@ -35,6 +39,8 @@ func AuthorizeGroupPicUpload(ctx context.Context, picMIME string) (AuthGroupPicD
 			return res, fiber.ErrInternalServerError
 		}
 
+		cloudStorageService.ScheduleMediaDeletion(ctx, groupPicCloudName)
+
 		switch small0_medium1_large2 {
 		case 0:
 			res.UploadUrl += "small:"
@@ -59,8 +65,37 @@ func AuthorizeGroupPicUpload(ctx context.Context, picMIME string) (AuthGroupPicD
 	return res, nil
 }
 
-func NewGroup(ctx context.Context, clientUsername, name, description, pictureCloudName string, initUsers []string, createdAt int64) (map[string]any, error) {
-	newGroup, err := groupChat.New(ctx, clientUsername, name, description, pictureCloudName, initUsers, createdAt)
+// AuthorizeOriginalGroupPicUpload authorizes the upload of an original image,
+// from which the group picture's variants are produced by the server
+func AuthorizeOriginalGroupPicUpload(ctx context.Context, clientUsername, picMIME string) (AuthGroupPicDataT, error) {
+	var res AuthGroupPicDataT
+
+	groupPicCloudName := fmt.Sprintf("uploads/group/group_pics/%d%d/%s-original", time.Now().Year(), time.Now().Month(), utils.UUIDv4())
+
+	url, originalCloudName, err := imageProcessingService.AuthorizeOriginalUpload(ctx, clientUsername, "group_pic", groupPicCloudName, picMIME)
+	if err != nil {
+		return res, err
+	}
+
+	res.UploadUrl = url
+	res.GroupPicCloudName = originalCloudName
+
+	return res, nil
+}
+
+func NewGroup(ctx context.Context, clientUsername, name, description, pictureCloudName string, isPublic bool, initUsers []string, createdAt int64) (map[string]any, error) {
+	// the group is created without a picture, and its picture changed, once processed from the original
+	originalCloudName := ""
+
+	if imageProcessingService.IsOriginal(pictureCloudName) {
+		if err := imageProcessingService.VerifyOriginalUpload(ctx, clientUsername, "group_pic", pictureCloudName); err != nil {
+			return nil, err
+		}
+
+		originalCloudName, pictureCloudName = pictureCloudName, "{notset}"
+	}
+
+	newGroup, err := groupChat.New(ctx, clientUsername, name, description, pictureCloudName, isPublic, initUsers, createdAt)
 	if err != nil {
 		return nil, err
 	}
@@ -69,6 +104,17 @@ func NewGroup(ctx context.Context, clientUsername, name, description, pictureClo
 		return nil, nil
 	}
 
+	if originalCloudName != "" {
+		go eventStreamService.QueueImageProcessingJob(eventTypes.ImageProcessingJob{
+			Target:      "group_pic",
+			OwnerUser:   clientUsername,
+			GroupId:     newGroup.Id,
+			OriginalMcn: imageProcessingService.OriginalMcn(originalCloudName),
+		})
+	}
+
+	cloudStorageService.UnscheduleMediaDeletion(ctx, cloudStorageService.PicCloudNames(pictureCloudName)...)
+
 	go func(newGroup groupChat.NewGroup) {
 		// history is the same for all init users, we only need separation for the cache
 		// so we make use of one user's history
@@ -200,6 +246,9 @@ func ChangeGroupPicture(ctx context.Context, groupId, clientUsername, picCloudNa
 		return UITypes.ChatHistoryEntry{}, nil
 	}
 
+	cloudStorageService.UnscheduleMediaDeletion(ctx, cloudStorageService.PicCloudNames(picCloudName)...)
+	cloudStorageService.ScheduleMediaDeletion(ctx, cloudStorageService.PicCloudNames(newActivity.OldPictureUrl)...)
+
 	go eventStreamService.QueueGroupEditEvent(eventTypes.GroupEditEvent{
 		GroupId:       groupId,
 		UpdateKVMap:   map[string]any{"picture_url": picCloudName},
@@ -220,8 +269,347 @@ func ChangeGroupPicture(ctx context.Context, groupId, clientUsername, picCloudNa
 	}, nil
 }
 
+// ChangeGroupPictureFromOriginal queues the original image for processing, if the client can edit the group's info.
+// The group picture is changed once its variants are produced
+func ChangeGroupPictureFromOriginal(ctx context.Context, groupId, clientUsername, originalCloudName string) (map[string]any, error) {
+	canEdit, err := groupChat.CanEditInfo(ctx, groupId, clientUsername)
+	if err != nil {
+		return nil, err
+	}
+
+	if !canEdit {
+		return nil, fiber.NewError(fiber.StatusForbidden, "you can't edit this group's info")
+	}
+
+	if err := imageProcessingService.VerifyOriginalUpload(ctx, clientUsername, "group_pic", originalCloudName); err != nil {
+		return nil, err
+	}
+
+	go eventStreamService.QueueImageProcessingJob(eventTypes.ImageProcessingJob{
+		Target:      "group_pic",
+		OwnerUser:   clientUsername,
+		GroupId:     groupId,
+		OriginalMcn: imageProcessingService.OriginalMcn(originalCloudName),
+	})
+
+	return map[string]any{"processing": true}, nil
+}
+
+// ApplyProcessedGroupPicture changes the group picture, on behalf of the admin who uploaded its original,
+// to the variants produced from it
+func ApplyProcessedGroupPicture(ctx context.Context, groupId, ownerUsername, picCloudName string) {
+	ownerCHE, err := ChangeGroupPicture(ctx, groupId, ownerUsername, picCloudName)
+	if err != nil || ownerCHE.CHEType == "" {
+		imageProcessingService.DeleteProcessed(ctx, picCloudName)
+		return
+	}
+
+	broadcastActivityToOne(groupId, ownerCHE, ownerUsername)
+}
+
+// groupSettingsInfo describes each group permission setting's change, to off and to on
+var groupSettingsInfo = map[string][2]string{
+	"only_admins_can_send_messages": {"allowed all members to send messages", "allowed only admins to send messages"},
+	"members_can_edit_info":         {"allowed only admins to edit group info", "allowed all members to edit group info"},
+	"members_can_add_members":       {"allowed only admins to add members", "allowed all members to add members"},
+}
+
+// ChangeGroupSettings sets the group's permission settings: announcement mode ("only_admins_can_send_messages"),
+// "members_can_edit_info", and "members_can_add_members"; which are all off by default
+func ChangeGroupSettings(ctx context.Context, groupId, clientUsername string, settings map[string]bool) (UITypes.ChatHistoryEntry, error) {
+	newSettings := make(map[string]any, len(settings))
+	settingsInfo := make(map[string]any, len(settings))
+
+	for setting, on := range settings {
+		newSettings[setting] = on
+
+		if on {
+			settingsInfo[setting] = groupSettingsInfo[setting][1]
+		} else {
+			settingsInfo[setting] = groupSettingsInfo[setting][0]
+		}
+	}
+
+	newActivity, err := groupChat.ChangeSettings(ctx, groupId, clientUsername, newSettings, settingsInfo)
+	if err != nil {
+		return UITypes.ChatHistoryEntry{}, err
+	}
+
+	done := newActivity.ClientUserCHE != nil
+
+	if !done {
+		return UITypes.ChatHistoryEntry{}, nil
+	}
+
+	go broadcastActivityToAll(groupId, UITypes.ChatHistoryEntry{
+		CHEType: newActivity.MemberUserCHE["che_type"].(string),
+		Info:    newActivity.MemberUserCHE["info"].(string),
+		Cursor:  float64(newActivity.MemberUserCHE["cursor"].(int64)),
+	}, []any{clientUsername})
+
+	go eventStreamService.QueueGroupEditEvent(eventTypes.GroupEditEvent{
+		GroupId:       groupId,
+		EditorUser:    clientUsername,
+		UpdateKVMap:   newSettings,
+		EditorUserCHE: newActivity.ClientUserCHE,
+		MemInfo:       newActivity.MemInfo,
+	})
+
+	return UITypes.ChatHistoryEntry{
+		CHEType: newActivity.ClientUserCHE["che_type"].(string),
+		Info:    newActivity.ClientUserCHE["info"].(string),
+		Cursor:  float64(newActivity.ClientUserCHE["cursor"].(int64)),
+	}, nil
+}
+
+// ChangeGroupPinPermission sets who can pin messages in the group: "admins", or "all" members
+func ChangeGroupPinPermission(ctx context.Context, groupId, clientUsername, pinPermission string) (UITypes.ChatHistoryEntry, error) {
+	permissionInfo := "allowed only admins to pin messages"
+	if pinPermission == "all" {
+		permissionInfo = "allowed all members to pin messages"
+	}
+
+	newActivity, err := groupChat.ChangePinPermission(ctx, groupId, clientUsername, pinPermission, permissionInfo)
+	if err != nil {
+		return UITypes.ChatHistoryEntry{}, err
+	}
+
+	done := newActivity.ClientUserCHE != nil
+
+	if !done {
+		return UITypes.ChatHistoryEntry{}, nil
+	}
+
+	go broadcastActivityToAll(groupId, UITypes.ChatHistoryEntry{
+		CHEType: newActivity.MemberUserCHE["che_type"].(string),
+		Info:    newActivity.MemberUserCHE["info"].(string),
+		Cursor:  float64(newActivity.MemberUserCHE["cursor"].(int64)),
+	}, []any{clientUsername})
+
+	go eventStreamService.QueueGroupEditEvent(eventTypes.GroupEditEvent{
+		GroupId:       groupId,
+		EditorUser:    clientUsername,
+		UpdateKVMap:   map[string]any{"pin_permission": pinPermission},
+		EditorUserCHE: newActivity.ClientUserCHE,
+		MemInfo:       newActivity.MemInfo,
+	})
+
+	return UITypes.ChatHistoryEntry{
+		CHEType: newActivity.ClientUserCHE["che_type"].(string),
+		Info:    newActivity.ClientUserCHE["info"].(string),
+		Cursor:  float64(newActivity.ClientUserCHE["cursor"].(int64)),
+	}, nil
+}
+
+// ChangeGroupVisibility makes the group public, so anyone can join it by its id, or private
+func ChangeGroupVisibility(ctx context.Context, groupId, clientUsername string, isPublic bool) (UITypes.ChatHistoryEntry, error) {
+	visibilityInfo := "made the group private"
+	if isPublic {
+		visibilityInfo = "made the group public"
+	}
+
+	newActivity, err := groupChat.ChangeVisibility(ctx, groupId, clientUsername, isPublic, visibilityInfo)
+	if err != nil {
+		return UITypes.ChatHistoryEntry{}, err
+	}
+
+	done := newActivity.ClientUserCHE != nil
+
+	if !done {
+		return UITypes.ChatHistoryEntry{}, nil
+	}
+
+	go broadcastActivityToAll(groupId, UITypes.ChatHistoryEntry{
+		CHEType: newActivity.MemberUserCHE["che_type"].(string),
+		Info:    newActivity.MemberUserCHE["info"].(string),
+		Cursor:  float64(newActivity.MemberUserCHE["cursor"].(int64)),
+	}, []any{clientUsername})
+
+	go eventStreamService.QueueGroupEditEvent(eventTypes.GroupEditEvent{
+		GroupId:       groupId,
+		EditorUser:    clientUsername,
+		UpdateKVMap:   map[string]any{"is_public": isPublic},
+		EditorUserCHE: newActivity.ClientUserCHE,
+		MemInfo:       newActivity.MemInfo,
+	})
+
+	return UITypes.ChatHistoryEntry{
+		CHEType: newActivity.ClientUserCHE["che_type"].(string),
+		Info:    newActivity.ClientUserCHE["info"].(string),
+		Cursor:  float64(newActivity.ClientUserCHE["cursor"].(int64)),
+	}, nil
+}
+
+// ChangeGroupJoinApproval turns on, or off, the group's requirement of an admin's approval for users joining it
+func ChangeGroupJoinApproval(ctx context.Context, groupId, clientUsername string, joinApproval bool) (UITypes.ChatHistoryEntry, error) {
+	approvalInfo := "turned off join approval"
+	if joinApproval {
+		approvalInfo = "turned on join approval"
+	}
+
+	newActivity, err := groupChat.ChangeJoinApproval(ctx, groupId, clientUsername, joinApproval, approvalInfo, time.Now().UTC().UnixMilli())
+	if err != nil {
+		return UITypes.ChatHistoryEntry{}, err
+	}
+
+	done := newActivity.ClientUserCHE != nil
+
+	if !done {
+		return UITypes.ChatHistoryEntry{}, nil
+	}
+
+	go broadcastActivityToAll(groupId, UITypes.ChatHistoryEntry{
+		CHEType: newActivity.MemberUserCHE["che_type"].(string),
+		Info:    newActivity.MemberUserCHE["info"].(string),
+		Cursor:  float64(newActivity.MemberUserCHE["cursor"].(int64)),
+	}, []any{clientUsername})
+
+	go eventStreamService.QueueGroupEditEvent(eventTypes.GroupEditEvent{
+		GroupId:       groupId,
+		EditorUser:    clientUsername,
+		UpdateKVMap:   map[string]any{"join_approval": joinApproval},
+		EditorUserCHE: newActivity.ClientUserCHE,
+		MemInfo:       newActivity.MemInfo,
+	})
+
+	// requests pending in a public group, approved as join approval is turned off
+	for _, aj := range newActivity.ApprovedJoins {
+		newGroupChat := userJoined(groupId, aj.Requester, groupChat.UserJoinedActivity{
+			GroupInfo:     aj.GroupInfo,
+			ChatCursor:    aj.ChatCursor,
+			ClientUserCHE: aj.ClientUserCHE,
+			MemInfo:       aj.MemInfo,
+			MemberUserCHE: aj.MemberUserCHE,
+		})
+
+		go sendJoinRequestApproved(aj.Requester, newGroupChat)
+	}
+
+	return UITypes.ChatHistoryEntry{
+		CHEType: newActivity.ClientUserCHE["che_type"].(string),
+		Info:    newActivity.ClientUserCHE["info"].(string),
+		Cursor:  float64(newActivity.ClientUserCHE["cursor"].(int64)),
+	}, nil
+}
+
+// maxPinnedMessages is how many messages can be pinned in a group, at once
+const maxPinnedMessages = 10
+
+// PinMessage pins a message of the group, for all members, and updates their pinned messages
+func PinMessage(ctx context.Context, groupId, clientUsername, msgId string) (UITypes.ChatHistoryEntry, error) {
+	at := time.Now().UTC().UnixMilli()
+
+	newActivity, err := groupChat.PinMessage(ctx, groupId, clientUsername, msgId, at, maxPinnedMessages)
+	if err != nil {
+		return UITypes.ChatHistoryEntry{}, err
+	}
+
+	done := newActivity.ClientUserCHE != nil
+
+	if !done {
+		pinnedCount, err := cache.GetGroupPinnedMessagesCount(ctx, groupId)
+		if err != nil {
+			return UITypes.ChatHistoryEntry{}, fiber.ErrInternalServerError
+		}
+
+		if pinnedCount >= maxPinnedMessages {
+			return UITypes.ChatHistoryEntry{}, fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("you can only pin up to %d messages in a group; unpin one first", maxPinnedMessages))
+		}
+
+		return UITypes.ChatHistoryEntry{}, nil
+	}
+
+	if err := cache.StoreGroupPinnedMessage(ctx, groupId, msgId, at); err != nil {
+		return UITypes.ChatHistoryEntry{}, fiber.ErrInternalServerError
+	}
+
+	go broadcastMsgPinned(groupId, clientUsername, map[string]any{
+		"group_id":  groupId,
+		"msg_id":    msgId,
+		"pinned_by": clientUsername,
+		"pinned_at": at,
+	})
+
+	go broadcastActivityToAll(groupId, UITypes.ChatHistoryEntry{
+		CHEType: newActivity.MemberUserCHE["che_type"].(string),
+		Info:    newActivity.MemberUserCHE["info"].(string),
+		Cursor:  float64(newActivity.MemberUserCHE["cursor"].(int64)),
+	}, []any{clientUsername})
+
+	go eventStreamService.QueueGroupEditEvent(eventTypes.GroupEditEvent{
+		GroupId:       groupId,
+		EditorUser:    clientUsername,
+		UpdateKVMap:   map[string]any{},
+		EditorUserCHE: newActivity.ClientUserCHE,
+		MemInfo:       newActivity.MemInfo,
+	})
+
+	return UITypes.ChatHistoryEntry{
+		CHEType: newActivity.ClientUserCHE["che_type"].(string),
+		Info:    newActivity.ClientUserCHE["info"].(string),
+		Cursor:  float64(newActivity.ClientUserCHE["cursor"].(int64)),
+	}, nil
+}
+
+// UnpinMessage unpins a pinned message of the group, for all members, and updates their pinned messages
+func UnpinMessage(ctx context.Context, groupId, clientUsername, msgId string) (UITypes.ChatHistoryEntry, error) {
+	newActivity, err := groupChat.UnpinMessage(ctx, groupId, clientUsername, msgId)
+	if err != nil {
+		return UITypes.ChatHistoryEntry{}, err
+	}
+
+	done := newActivity.ClientUserCHE != nil
+
+	if !done {
+		return UITypes.ChatHistoryEntry{}, nil
+	}
+
+	if err := cache.RemoveGroupPinnedMessage(ctx, groupId, msgId); err != nil {
+		return UITypes.ChatHistoryEntry{}, fiber.ErrInternalServerError
+	}
+
+	go broadcastMsgUnpinned(groupId, clientUsername, map[string]any{
+		"group_id": groupId,
+		"msg_id":   msgId,
+	})
+
+	go broadcastActivityToAll(groupId, UITypes.ChatHistoryEntry{
+		CHEType: newActivity.MemberUserCHE["che_type"].(string),
+		Info:    newActivity.MemberUserCHE["info"].(string),
+		Cursor:  float64(newActivity.MemberUserCHE["cursor"].(int64)),
+	}, []any{clientUsername})
+
+	go eventStreamService.QueueGroupEditEvent(eventTypes.GroupEditEvent{
+		GroupId:       groupId,
+		EditorUser:    clientUsername,
+		UpdateKVMap:   map[string]any{},
+		EditorUserCHE: newActivity.ClientUserCHE,
+		MemInfo:       newActivity.MemInfo,
+	})
+
+	return UITypes.ChatHistoryEntry{
+		CHEType: newActivity.ClientUserCHE["che_type"].(string),
+		Info:    newActivity.ClientUserCHE["info"].(string),
+		Cursor:  float64(newActivity.ClientUserCHE["cursor"].(int64)),
+	}, nil
+}
+
 func AddUsersToGroup(ctx context.Context, groupId, clientUsername string, newUsers []string) (UITypes.ChatHistoryEntry, error) {
-	newActivity, err := groupChat.AddUsers(ctx, groupId, clientUsername, newUsers)
+	// users who have blocked, or been blocked by, the admin are left out
+	blocked, err := cache.GetBlockedBetween(ctx, clientUsername, newUsers)
+	if err != nil {
+		return UITypes.ChatHistoryEntry{}, fiber.ErrInternalServerError
+	}
+
+	newUsers = slices.DeleteFunc(newUsers, func(nu string) bool {
+		return blocked[nu]
+	})
+
+	if len(newUsers) == 0 {
+		return UITypes.ChatHistoryEntry{}, nil
+	}
+
+	newActivity, err := groupChat.AddUsers(ctx, groupId, clientUsername, newUsers, time.Now().UTC().UnixMilli())
 	if err != nil {
 		return UITypes.ChatHistoryEntry{}, err
 	}
@@ -314,8 +702,29 @@ func RemoveUserFromGroup(ctx context.Context, groupId, clientUsername, targetUse
 	}, nil
 }
 
+// JoinGroup adds the client to a public group.
+// If the group requires join approval, the client, instead, requests to join, for an admin to approve
 func JoinGroup(ctx context.Context, groupId, clientUsername string) (map[string]any, error) {
-	newActivity, err := groupChat.Join(ctx, groupId, clientUsername)
+	at := time.Now().UTC().UnixMilli()
+
+	joinRequest, err := groupChat.RequestToJoin(ctx, groupId, clientUsername, at)
+	if err != nil {
+		return nil, err
+	}
+
+	if joinRequest.Admins != nil {
+		if joinRequest.Created {
+			go broadcastNewJoinRequest(joinRequest.Admins, map[string]any{
+				"group_id":  groupId,
+				"requester": clientUsername,
+				"at":        at,
+			})
+		}
+
+		return map[string]any{"join_request": "pending"}, nil
+	}
+
+	newActivity, err := groupChat.Join(ctx, groupId, clientUsername, at)
 	if err != nil {
 		return nil, err
 	}
@@ -325,6 +734,101 @@ func JoinGroup(ctx context.Context, groupId, clientUsername string) (map[string]
 		return nil, nil
 	}
 
+	return userJoined(groupId, clientUsername, newActivity), nil
+}
+
+func GetJoinRequests(ctx context.Context, groupId, clientUsername string, limit int64, cursor float64) ([]UITypes.GroupMemberSnippet, error) {
+	return groupChat.JoinRequests(ctx, groupId, clientUsername, limit, cursor)
+}
+
+// ApproveJoinRequest adds the requester, targetUser, to the group, sending them their new group chat
+func ApproveJoinRequest(ctx context.Context, groupId, clientUsername, targetUser string) (bool, error) {
+	newActivity, err := groupChat.ApproveJoinRequest(ctx, groupId, clientUsername, targetUser, time.Now().UTC().UnixMilli())
+	if err != nil {
+		return false, err
+	}
+
+	done := newActivity.GroupInfo != nil
+	if !done {
+		return false, nil
+	}
+
+	newGroupChat := userJoined(groupId, targetUser, newActivity)
+
+	go sendJoinRequestApproved(targetUser, newGroupChat)
+
+	return true, nil
+}
+
+func RejectJoinRequest(ctx context.Context, groupId, clientUsername, targetUser string) (bool, error) {
+	done, err := groupChat.RejectJoinRequest(ctx, groupId, clientUsername, targetUser)
+	if err != nil {
+		return false, err
+	}
+
+	if done {
+		go sendJoinRequestRejected(targetUser, map[string]any{"group_id": groupId})
+	}
+
+	return done, nil
+}
+
+// CreateInviteLink creates an invite link to the group, valid till expiresAt, and for maxUses joins;
+// zero, for either, means no limit
+func CreateInviteLink(ctx context.Context, groupId, clientUsername string, expiresAt, maxUses int64) (UITypes.GroupInviteLink, error) {
+	inviteLink, err := groupChat.CreateInviteLink(ctx, groupId, clientUsername, rand.Text(), expiresAt, maxUses, time.Now().UTC().UnixMilli())
+	if err != nil {
+		return UITypes.GroupInviteLink{}, err
+	}
+
+	if inviteLink.Code == "" {
+		return UITypes.GroupInviteLink{}, fiber.NewError(fiber.StatusForbidden, "only group admins can create invite links")
+	}
+
+	return inviteLink, nil
+}
+
+func RevokeInviteLink(ctx context.Context, groupId, clientUsername, code string) (bool, error) {
+	return groupChat.RevokeInviteLink(ctx, groupId, clientUsername, code)
+}
+
+func GetInviteLinks(ctx context.Context, groupId, clientUsername string) ([]UITypes.GroupInviteLink, error) {
+	return groupChat.InviteLinks(ctx, groupId, clientUsername)
+}
+
+// PreviewGroupByInviteLink returns the info of the group the invite link, code, is to, for a user to decide on joining it
+func PreviewGroupByInviteLink(ctx context.Context, code string) (UITypes.GroupInfo, error) {
+	groupId, err := groupChat.InviteLinkGroupId(ctx, code, time.Now().UTC().UnixMilli())
+	if err != nil {
+		return UITypes.GroupInfo{}, err
+	}
+
+	if groupId == "" {
+		return UITypes.GroupInfo{}, fiber.NewError(fiber.StatusNotFound, "this invite link is invalid, expired, or used up")
+	}
+
+	return groupChat.GroupInfo(ctx, groupId)
+}
+
+// JoinGroupViaInviteLink adds the client to the group the invite link, code, is to.
+// Join approval is bypassed, as the link is created by an admin
+func JoinGroupViaInviteLink(ctx context.Context, code, clientUsername string) (map[string]any, error) {
+	newActivity, err := groupChat.JoinViaInviteLink(ctx, code, clientUsername, time.Now().UTC().UnixMilli())
+	if err != nil {
+		return nil, err
+	}
+
+	done := newActivity.GroupInfo != nil
+	if !done {
+		return nil, nil
+	}
+
+	return userJoined(newActivity.GroupInfo["id"].(string), clientUsername, newActivity), nil
+}
+
+// userJoined notifies the group's members of the user's join, queues the join's background work,
+// and returns the user's new group chat, with its history
+func userJoined(groupId, clientUsername string, newActivity groupChat.UserJoinedActivity) map[string]any {
 	go broadcastActivityToAll(groupId, UITypes.ChatHistoryEntry{
 		CHEType: newActivity.MemberUserCHE["che_type"].(string),
 		Info:    newActivity.MemberUserCHE["info"].(string),
@@ -348,7 +852,7 @@ func JoinGroup(ctx context.Context, groupId, clientUsername string) (map[string]
 	return map[string]any{
 		"chat":    UITypes.ChatSnippet{Type: "group", Group: groupInfo, UnreadMC: 1, Cursor: float64(newActivity.ChatCursor)},
 		"history": []UITypes.ChatHistoryEntry{{CHEType: che["che_type"].(string), Info: che["info"].(string), Cursor: float64(che["cursor"].(int64))}},
-	}, nil
+	}
 }
 
 func LeaveGroup(ctx context.Context, groupId, clientUsername string) (UITypes.ChatHistoryEntry, error) {
@@ -373,6 +877,7 @@ func LeaveGroup(ctx context.Context, groupId, clientUsername string) (UITypes.Ch
 		OldMember:    clientUsername,
 		OldMemberCHE: newActivity.ClientUserCHE,
 		MemInfo:      newActivity.MemInfo,
+		NewOwner:     newActivity.NewOwner,
 	})
 
 	return UITypes.ChatHistoryEntry{
@@ -421,6 +926,47 @@ func MakeUserGroupAdmin(ctx context.Context, groupId, clientUsername, targetUser
 	}, nil
 }
 
+// TransferGroupOwnership makes a member, targetUser, the group's owner, and the client, its owner, an admin
+func TransferGroupOwnership(ctx context.Context, groupId, clientUsername, targetUser string) (UITypes.ChatHistoryEntry, error) {
+	newActivity, err := groupChat.TransferOwnership(ctx, groupId, clientUsername, targetUser)
+	if err != nil {
+		return UITypes.ChatHistoryEntry{}, err
+	}
+
+	done := newActivity.ClientUserCHE != nil
+	if !done {
+		return UITypes.ChatHistoryEntry{}, nil
+	}
+
+	go broadcastActivityToOne(groupId, UITypes.ChatHistoryEntry{
+		CHEType: newActivity.TargetUserCHE["che_type"].(string),
+		Info:    newActivity.TargetUserCHE["info"].(string),
+		Cursor:  float64(newActivity.TargetUserCHE["cursor"].(int64)),
+	}, targetUser)
+
+	go broadcastActivityToAll(groupId, UITypes.ChatHistoryEntry{
+		CHEType: newActivity.MemberUserCHE["che_type"].(string),
+		Info:    newActivity.MemberUserCHE["info"].(string),
+		Cursor:  float64(newActivity.MemberUserCHE["cursor"].(int64)),
+	}, []any{clientUsername, targetUser})
+
+	// the new owner is, as well, an admin
+	go eventStreamService.QueueGroupMakeUserAdminEvent(eventTypes.GroupMakeUserAdminEvent{
+		GroupId:     groupId,
+		Admin:       clientUsername,
+		NewAdmin:    targetUser,
+		AdminCHE:    newActivity.ClientUserCHE,
+		NewAdminCHE: newActivity.TargetUserCHE,
+		MemInfo:     newActivity.MemInfo,
+	})
+
+	return UITypes.ChatHistoryEntry{
+		CHEType: newActivity.ClientUserCHE["che_type"].(string),
+		Info:    newActivity.ClientUserCHE["info"].(string),
+		Cursor:  float64(newActivity.ClientUserCHE["cursor"].(int64)),
+	}, nil
+}
+
 func RemoveUserFromGroupAdmins(ctx context.Context, groupId, clientUsername, targetUser string) (UITypes.ChatHistoryEntry, error) {
 	newActivity, err := groupChat.RemoveUserFromAdmins(ctx, groupId, clientUsername, targetUser)
 	if err != nil {
@@ -460,6 +1006,46 @@ func RemoveUserFromGroupAdmins(ctx context.Context, groupId, clientUsername, tar
 	}, nil
 }
 
+// DeleteGroup dissolves the group, notifying its members. Their chats are archived, left read-only,
+// or, if archive is false, removed, with all their messages and media, by the groups deleted worker
+func DeleteGroup(ctx context.Context, groupId, clientUsername string, archive bool) (UITypes.ChatHistoryEntry, error) {
+	deletedGroup, err := groupChat.Delete(ctx, groupId, clientUsername, archive, time.Now().UTC().UnixMilli())
+	if err != nil {
+		return UITypes.ChatHistoryEntry{}, err
+	}
+
+	done := deletedGroup.ClientUserCHE != nil
+	if !done {
+		return UITypes.ChatHistoryEntry{}, nil
+	}
+
+	go broadcastGroupDeleted(deletedGroup.MemberUsernames, map[string]any{
+		"group_id": groupId,
+		"archived": archive,
+		"che": UITypes.ChatHistoryEntry{
+			CHEType: deletedGroup.MemberUserCHE["che_type"].(string),
+			Info:    deletedGroup.MemberUserCHE["info"].(string),
+			Cursor:  float64(deletedGroup.MemberUserCHE["cursor"].(int64)),
+		},
+	})
+
+	go eventStreamService.QueueGroupDeletedEvent(eventTypes.GroupDeletedEvent{
+		GroupId:          groupId,
+		DeleterUser:      clientUsername,
+		DeleterUserCHE:   deletedGroup.ClientUserCHE,
+		MemberUserCHE:    deletedGroup.MemberUserCHE,
+		FormerMembers:    deletedGroup.MemberUsernames,
+		PictureCloudName: deletedGroup.PictureUrl,
+		Archive:          archive,
+	})
+
+	return UITypes.ChatHistoryEntry{
+		CHEType: deletedGroup.ClientUserCHE["che_type"].(string),
+		Info:    deletedGroup.ClientUserCHE["info"].(string),
+		Cursor:  float64(deletedGroup.ClientUserCHE["cursor"].(int64)),
+	}, nil
+}
+
 func SendMessage(ctx context.Context, clientUsername, groupId, replyTargetMsgId string, isReply bool, msgContentJson string, at int64) (map[string]any, error) {
 	var (
 		newMessage groupChat.NewMessage
@@ -482,6 +1068,19 @@ func SendMessage(ctx context.Context, clientUsername, groupId, replyTargetMsgId
 		return nil, nil
 	}
 
+	if originalCloudName, ok := imageProcessingService.MessageOriginal(newMessage.Content); ok {
+		go eventStreamService.QueueImageProcessingJob(eventTypes.ImageProcessingJob{
+			Target:      "group_chat_photo",
+			OwnerUser:   clientUsername,
+			GroupId:     groupId,
+			MsgId:       newMessage.Id,
+			OriginalMcn: imageProcessingService.OriginalMcn(originalCloudName),
+		})
+	} else {
+		// the message now references its media; an original is unscheduled once it's processed
+		cloudStorageService.UnscheduleMediaDeletion(ctx, cloudStorageService.MessageMediaCloudNames(newMessage.Content)...)
+	}
+
 	go func(msg groupChat.NewMessage, clientUsername string) {
 		uisender, _ := cache.GetUser[UITypes.ClientUser](context.Background(), clientUsername)
 
@@ -557,6 +1156,127 @@ func AckMessagesRead(ctx context.Context, clientUsername, groupId string, msgIds
 	return done, nil
 }
 
+func EditMessage(ctx context.Context, clientUsername, groupId, msgId, newText string, at int64) (map[string]any, error) {
+	editedMessage, err := groupChat.EditMessage(ctx, clientUsername, groupId, msgId, newText, at)
+	if err != nil {
+		return nil, err
+	}
+
+	if editedMessage.EditedAt == 0 {
+		return nil, nil
+	}
+
+	// encoded before the media cloud name in the content is converted to url, in place, below
+	contentJson := helpers.ToJson(editedMessage.Content)
+
+	go broadcastMsgEdited(groupId, clientUsername, map[string]any{
+		"group_id":  groupId,
+		"msg_id":    msgId,
+		"content":   cloudStorageService.MessageMediaCloudNameToUrl(editedMessage.Content),
+		"edited_at": editedMessage.EditedAt,
+	})
+
+	// queue msg edit event
+	go eventStreamService.QueueGroupMsgEditEvent(eventTypes.GroupMsgEditEvent{
+		FromUser: clientUsername,
+		ToGroup:  groupId,
+		CHEId:    msgId,
+		Content:  contentJson,
+		EditedAt: editedMessage.EditedAt,
+	})
+
+	return map[string]any{"edited_at": editedMessage.EditedAt}, nil
+}
+
+// ApplyProcessedPhoto replaces the original image of the photo message with the images processed from it,
+// and lets the group members know
+func ApplyProcessedPhoto(ctx context.Context, groupId, ownerUsername, msgId, originalCloudName, mediaCloudName string) {
+	content, err := groupChat.SetProcessedMessageMedia(ctx, ownerUsername, msgId, originalCloudName, mediaCloudName)
+	if err != nil || content == nil {
+		imageProcessingService.DeleteProcessed(ctx, mediaCloudName)
+		return
+	}
+
+	cache.UpdateGroupChatHistoryEntry(ctx, msgId, map[string]any{"content": content})
+
+	broadcastMsgMediaEvent(groupId, "group chat: message media processed", map[string]any{
+		"group_id": groupId,
+		"msg_id":   msgId,
+		"content":  cloudStorageService.MessageMediaCloudNameToUrl(content),
+	})
+}
+
+// FailProcessedPhoto marks the photo message whose original image couldn't be processed,
+// and lets the group members know
+func FailProcessedPhoto(ctx context.Context, groupId, ownerUsername, msgId, originalCloudName string) {
+	content, err := groupChat.MarkMessageMediaFailed(ctx, ownerUsername, msgId, originalCloudName)
+	if err != nil || content == nil {
+		return
+	}
+
+	cache.UpdateGroupChatHistoryEntry(ctx, msgId, map[string]any{"content": content})
+
+	broadcastMsgMediaEvent(groupId, "group chat: message media processing failed", map[string]any{
+		"group_id": groupId,
+		"msg_id":   msgId,
+		"content":  cloudStorageService.MessageMediaCloudNameToUrl(content),
+	})
+}
+
+func DeleteMessagesForMe(ctx context.Context, clientUsername, groupId string, msgIds []any) (bool, error) {
+	done, err := groupChat.DeleteMessagesForMe(ctx, clientUsername, groupId, msgIds)
+	if err != nil {
+		return false, err
+	}
+
+	if done {
+		// queue msg deletion event
+		go eventStreamService.QueueGroupMsgDeletionEvent(eventTypes.GroupMsgDeletionEvent{
+			FromUser: clientUsername,
+			ToGroup:  groupId,
+			CHEIds:   msgIds,
+			For:      "me",
+		})
+	}
+
+	return done, nil
+}
+
+func DeleteMessageForEveryone(ctx context.Context, clientUsername, groupId, msgId string, at int64) (map[string]any, error) {
+	deletedMessage, err := groupChat.DeleteMessageForEveryone(ctx, clientUsername, groupId, msgId, at)
+	if err != nil {
+		return nil, err
+	}
+
+	if deletedMessage.DeletedAt == 0 {
+		return nil, nil
+	}
+
+	go cloudStorageService.DeleteMessageMedia(context.Background(), deletedMessage.Content)
+
+	if err := cache.RemoveGroupPinnedMessage(ctx, groupId, msgId); err != nil {
+		return nil, fiber.ErrInternalServerError
+	}
+
+	go broadcastMsgDeleted(groupId, clientUsername, map[string]any{
+		"group_id":   groupId,
+		"msg_id":     msgId,
+		"deleted_by": clientUsername,
+		"deleted_at": deletedMessage.DeletedAt,
+	})
+
+	// queue msg deletion event
+	go eventStreamService.QueueGroupMsgDeletionEvent(eventTypes.GroupMsgDeletionEvent{
+		FromUser:  clientUsername,
+		ToGroup:   groupId,
+		CHEIds:    []any{msgId},
+		For:       "everyone",
+		DeletedAt: deletedMessage.DeletedAt,
+	})
+
+	return map[string]any{"deleted_at": deletedMessage.DeletedAt}, nil
+}
+
 func ReactToMessage(ctx context.Context, clientUsername, groupId, msgId, emoji string, at int64) (map[string]any, error) {
 	rxnToMessage, err := groupChat.ReactToMessage(ctx, clientUsername, groupId, msgId, emoji, at)
 	if err != nil {
@@ -600,7 +1320,7 @@ func ReactToMessage(ctx context.Context, clientUsername, groupId, msgId, emoji s
 	return map[string]any{"che_cursor": rxnToMessage.Cursor}, nil
 }
 
-func RemoveReactionToMessage(ctx context.Context, clientUsername, groupId, msgId string, at int64) (bool, error) {
+func RemoveReactionToMessage(ctx context.Context, clientUsername, groupId, msgId string) (bool, error) {
 	CHEId, err := groupChat.RemoveReactionToMessage(ctx, clientUsername, groupId, msgId)
 	if err != nil {
 		return false, err
@@ -627,8 +1347,49 @@ func RemoveReactionToMessage(ctx context.Context, clientUsername, groupId, msgId
 	return done, nil
 }
 
-func GetChatHistory(ctx context.Context, clientUsername, groupId string, limit int64, cursor float64) ([]UITypes.ChatHistoryEntry, error) {
-	return groupChat.ChatHistory(ctx, clientUsername, groupId, limit, cursor)
+// SendTypingState relays the client's typing state (typing, recording, stopped) to the group's online members.
+// It isn't persisted, and a typing or recording state not renewed in time is relayed as stopped
+func SendTypingState(ctx context.Context, clientUsername, groupId, state string) (bool, error) {
+	isMember, err := cache.IsGroupMember(ctx, groupId, clientUsername)
+	if err != nil {
+		return false, err
+	}
+
+	if !isMember {
+		return false, nil
+	}
+
+	stateKey := fmt.Sprintf("group_chat:%s:%s:typing", groupId, clientUsername)
+
+	if state == "stopped" {
+		realtimeService.EndEphemeralState(stateKey)
+	} else {
+		realtimeService.RenewEphemeralState(stateKey, func() {
+			broadcastTypingState(groupId, clientUsername, "stopped")
+		})
+	}
+
+	go broadcastTypingState(groupId, clientUsername, state)
+
+	return true, nil
+}
+
+func GetChatHistory(ctx context.Context, clientUsername, groupId, mode, anchorMsgId string, limit int64, cursor float64) ([]UITypes.ChatHistoryEntry, error) {
+	return groupChat.ChatHistory(ctx, clientUsername, groupId, mode, anchorMsgId, limit, cursor)
+}
+
+// GetPinnedMessages returns the pinned messages of the group, to its members
+func GetPinnedMessages(ctx context.Context, clientUsername, groupId string) ([]UITypes.PinnedMessage, error) {
+	isMember, err := cache.IsGroupMember(ctx, groupId, clientUsername)
+	if err != nil {
+		return nil, fiber.ErrInternalServerError
+	}
+
+	if !isMember {
+		return nil, fiber.NewError(fiber.StatusForbidden, "you're not a member of this group")
+	}
+
+	return groupChat.PinnedMessages(ctx, clientUsername, groupId)
 }
 
 func GetGroupInfo(ctx context.Context, groupId string) (UITypes.GroupInfo, error) {
@@ -0,0 +1,98 @@
+package cloudStorageService
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+/*
+The local and the S3 backends' uploads are served by the app itself, under /api/storage/{mcn},
+emulating the part of GCS's resumable upload protocol used by clients:
+  - POST, with the signed upload url, starts the upload,
+    and responds with the upload session url in the Location header.
+  - PUT, with the session url, writes each chunk, positioned by its Content-Range header,
+    within the total size declared by the upload's first chunk.
+
+A url's signature is an HMAC of its method, mcn and expiry time
+*/
+
+// the largest upload accepted, that of a file message
+const maxUploadSize = 50 * 1024 * 1024
+
+// ErrUploadRange is returned for an upload chunk falling outside the upload's declared size,
+// or, for the uploads that must be written in order, not following the upload's last chunk
+var ErrUploadRange = errors.New("upload chunk out of the declared range")
+
+// appServedBackend is implemented by the backends whose uploads are served by the app
+type appServedBackend interface {
+	signer() *urlSigner
+
+	// startUpload starts the upload of the object, mcn, of contentType
+	startUpload(ctx context.Context, mcn, contentType string) error
+
+	// writeUploadChunk writes chunk into the started upload of mcn, at offset, of the upload's total size
+	writeUploadChunk(ctx context.Context, mcn string, offset, total int64, chunk []byte) error
+}
+
+type urlSigner struct {
+	baseUrl string
+	secret  []byte
+}
+
+func (us *urlSigner) sign(method, mcn string, expires int64) string {
+	mac := hmac.New(sha256.New, us.secret)
+	fmt.Fprintf(mac, "%s\n%s\n%d", method, mcn, expires)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (us *urlSigner) signedUrl(method, mcn string, expires time.Time) string {
+	exp := expires.Unix()
+
+	return fmt.Sprintf("%s/api/storage/%s?expires=%d&signature=%s", us.baseUrl, mcn, exp, us.sign(method, mcn, exp))
+}
+
+// SignedUrlValid reports whether signature signs the url, of method, for mcn, and the url hasn't expired.
+// It is always false when the backend in use doesn't have its urls served by the app
+func SignedUrlValid(method, mcn string, expires int64, signature string) bool {
+	ab, ok := backend.(appServedBackend)
+	if !ok {
+		return false
+	}
+
+	if time.Now().Unix() > expires {
+		return false
+	}
+
+	return hmac.Equal([]byte(signature), []byte(ab.signer().sign(method, mcn, expires)))
+}
+
+// StartUpload starts the upload of the object, mcn, of contentType,
+// returning the url, valid until expires, to which its chunks are written
+func StartUpload(ctx context.Context, mcn, contentType string, expires time.Time) (string, error) {
+	ab := backend.(appServedBackend)
+
+	if err := ab.startUpload(ctx, mcn, contentType); err != nil {
+		return "", err
+	}
+
+	return ab.signer().signedUrl(http.MethodPut, mcn, expires), nil
+}
+
+// WriteUploadChunk writes chunk into the started upload of mcn, at offset.
+//
+// The upload's total size is declared by its first chunk, and every chunk must fall within it,
+// and declare the same total, else ErrUploadRange is returned
+func WriteUploadChunk(ctx context.Context, mcn string, offset, total int64, chunk []byte) error {
+	if total > maxUploadSize {
+		return ErrUploadRange
+	}
+
+	return backend.(appServedBackend).writeUploadChunk(ctx, mcn, offset, total, chunk)
+}
@@ -2,27 +2,15 @@ package cloudStorageService
 
 import (
 	"context"
-	"errors"
-	"i9chat/src/appGlobals"
+	"fmt"
 	"i9chat/src/helpers"
-	"net/http"
-	"os"
+	"io"
+	"strings"
 	"time"
-
-	"cloud.google.com/go/storage"
 )
 
 func GetUploadUrl(mediaCloudName, contentType string) (string, error) {
-	url, err := appGlobals.GCSClient.Bucket(os.Getenv("GCS_BUCKET_NAME")).SignedURL(
-		mediaCloudName,
-		&storage.SignedURLOptions{
-			Scheme:      storage.SigningSchemeV4,
-			Method:      http.MethodPost,
-			ContentType: contentType,
-			Expires:     time.Now().Add(15 * time.Minute),
-			Headers:     []string{"x-goog-resumable:start"},
-		},
-	)
+	url, err := backend.SignedUploadUrl(mediaCloudName, contentType, time.Now().Add(15*time.Minute))
 	if err != nil {
 		helpers.LogError(err)
 		return "", err
@@ -32,34 +20,122 @@ func GetUploadUrl(mediaCloudName, contentType string) (string, error) {
 }
 
 func GetMediaUrl(mcn string) string {
-	url, err := appGlobals.GCSClient.Bucket(os.Getenv("GCS_BUCKET_NAME")).SignedURL(mcn, &storage.SignedURLOptions{
-		Scheme:  storage.SigningSchemeV4,
-		Method:  "GET",
-		Expires: time.Now().Add((6 * 24) * time.Hour),
-	})
+	return GetMediaUrls(context.Background(), mcn)[0]
+}
+
+func GetMediaInfo(ctx context.Context, mcn string) *MediaInfo {
+	mInfo, err := backend.MediaInfo(ctx, mcn)
 	if err != nil {
 		helpers.LogError(err)
+		return nil
 	}
 
-	return url
+	return mInfo
 }
 
-func GetMediaInfo(ctx context.Context, mcn string) *storage.ObjectAttrs {
-	mInfo, err := appGlobals.GCSClient.Bucket(os.Getenv("GCS_BUCKET_NAME")).Object(mcn).Attrs(ctx)
+// GetMediaHead returns the first n bytes, at most, of the media, mcn
+func GetMediaHead(ctx context.Context, mcn string, n int64) ([]byte, error) {
+	head, err := backend.ReadHead(ctx, mcn, n)
 	if err != nil {
-		if !errors.Is(err, storage.ErrObjectNotExist) {
-			helpers.LogError(err)
-		}
+		helpers.LogError(err)
+		return nil, err
+	}
 
-		return nil
+	return head, nil
+}
+
+// ReadMedia returns the content of the media, mcn, failing if it's larger than maxSize
+func ReadMedia(ctx context.Context, mcn string, maxSize int64) ([]byte, error) {
+	reader, err := backend.Read(ctx, mcn)
+	if err != nil {
+		helpers.LogError(err)
+		return nil, err
 	}
 
-	return mInfo
+	defer reader.Close()
+
+	data, err := io.ReadAll(io.LimitReader(reader, maxSize+1))
+	if err != nil {
+		helpers.LogError(err)
+		return nil, err
+	}
+
+	if int64(len(data)) > maxSize {
+		return nil, fmt.Errorf("media %s is larger than %d bytes", mcn, maxSize)
+	}
+
+	return data, nil
+}
+
+// PutMedia stores data, produced by the app itself, as the media, mcn
+func PutMedia(ctx context.Context, mcn, contentType string, data []byte) error {
+	if err := backend.Write(ctx, mcn, contentType, data); err != nil {
+		helpers.LogError(err)
+		return err
+	}
+
+	return nil
+}
+
+// CopyMedia copies the media, srcMcn, to dstMcn
+func CopyMedia(ctx context.Context, srcMcn, dstMcn string) error {
+	if err := backend.Copy(ctx, srcMcn, dstMcn); err != nil {
+		helpers.LogError(err)
+		return err
+	}
+
+	return nil
 }
 
-func DeleteCloudMedia(ctx context.Context, mcn string) {
-	err := appGlobals.GCSClient.Bucket(os.Getenv("GCS_BUCKET_NAME")).Object(mcn).Delete(ctx)
+// DeleteCloudMedia deletes the media, mcn, along with its cached url and recorded chats
+func DeleteCloudMedia(ctx context.Context, mcn string) error {
+	err := backend.Delete(ctx, mcn)
 	if err != nil {
 		helpers.LogError(err)
+		return err
+	}
+
+	forgetMedia(ctx, mcn)
+
+	return nil
+}
+
+// DeleteMessageMedia deletes the media attached to a message, given the message's content
+func DeleteMessageMedia(ctx context.Context, msgContent map[string]any) {
+	for _, mcn := range MessageMediaCloudNames(msgContent) {
+		DeleteCloudMedia(ctx, mcn)
+	}
+}
+
+// MessageMediaCloudNames returns the media cloud names of the media attached to a message, given the message's content.
+// The media of a photo, yet to be processed, is its original
+func MessageMediaCloudNames(msgContent map[string]any) []string {
+	contentProps, _ := msgContent["props"].(map[string]any)
+
+	mediaCloudName, ok := contentProps["media_cloud_name"].(string)
+	if !ok {
+		return nil
+	}
+
+	if originalMcn, ok := strings.CutPrefix(mediaCloudName, "original:"); ok {
+		return []string{originalMcn}
+	}
+
+	switch msgContent["type"] {
+	case "photo", "video":
+		var (
+			blurPlchMcn string
+			actualMcn   string
+		)
+
+		_, err := fmt.Sscanf(mediaCloudName, "blur_placeholder:%s actual:%s", &blurPlchMcn, &actualMcn)
+		if err != nil {
+			helpers.LogError(err)
+			return nil
+		}
+
+		return []string{blurPlchMcn, actualMcn}
+	default:
+		return []string{mediaCloudName}
 	}
 }
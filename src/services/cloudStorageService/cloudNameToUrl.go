@@ -1,9 +1,11 @@
 package cloudStorageService
 
 import (
+	"context"
 	"fmt"
 	"i9chat/src/helpers"
 	"maps"
+	"strings"
 )
 
 func ProfilePicCloudNameToUrl(ppicCloudName string) string {
@@ -19,17 +21,20 @@ func ProfilePicCloudNameToUrl(ppicCloudName string) string {
 			helpers.LogError(err)
 		}
 
-		smallPicUrl := GetMediaUrl(smallPPicn)
-		mediumPicUrl := GetMediaUrl(mediumPPicn)
-		largePicUrl := GetMediaUrl(largePPicn)
+		picUrls := GetMediaUrls(context.Background(), smallPPicn, mediumPPicn, largePPicn)
 
-		return fmt.Sprintf("small:%s medium:%s large:%s", smallPicUrl, mediumPicUrl, largePicUrl)
+		return fmt.Sprintf("small:%s medium:%s large:%s", picUrls[0], picUrls[1], picUrls[2])
 	}
 
 	return ppicCloudName
 }
 
 func GroupPicCloudNameToUrl(picCloudName string) string {
+	// a new group's picture, while its original is processed
+	if picCloudName == "{notset}" {
+		return picCloudName
+	}
+
 	var (
 		smallPicn  string
 		mediumPicn string
@@ -41,11 +46,9 @@ func GroupPicCloudNameToUrl(picCloudName string) string {
 		helpers.LogError(err)
 	}
 
-	smallPicUrl := GetMediaUrl(smallPicn)
-	mediumPicUrl := GetMediaUrl(mediumPicn)
-	largePicUrl := GetMediaUrl(largePicn)
+	picUrls := GetMediaUrls(context.Background(), smallPicn, mediumPicn, largePicn)
 
-	return fmt.Sprintf("small:%s medium:%s large:%s", smallPicUrl, mediumPicUrl, largePicUrl)
+	return fmt.Sprintf("small:%s medium:%s large:%s", picUrls[0], picUrls[1], picUrls[2])
 }
 
 func MessageMediaCloudNameToUrl(msgContent map[string]any) map[string]any {
@@ -54,10 +57,13 @@ func MessageMediaCloudNameToUrl(msgContent map[string]any) map[string]any {
 
 	msgContentType := msgContent["type"].(string)
 
-	if msgContentType != "text" {
+	if msgContentType != "text" && msgContentType != "deleted" {
 		mediaCloudName := contentProps["media_cloud_name"].(string)
 
-		if msgContentType == "photo" || msgContentType == "video" {
+		if strings.HasPrefix(mediaCloudName, "original:") {
+			// the original, which may carry EXIF metadata, isn't served while it's processed
+			contentProps["media_url"] = "{processing}"
+		} else if msgContentType == "photo" || msgContentType == "video" {
 			var (
 				blurPlchMcn string
 				actualMcn   string
@@ -68,10 +74,9 @@ func MessageMediaCloudNameToUrl(msgContent map[string]any) map[string]any {
 				helpers.LogError(err)
 			}
 
-			blurPlchUrl := GetMediaUrl(blurPlchMcn)
-			actualUrl := GetMediaUrl(actualMcn)
+			mediaUrls := GetMediaUrls(context.Background(), blurPlchMcn, actualMcn)
 
-			contentProps["media_url"] = fmt.Sprintf("blur_placeholder:%s actual:%s", blurPlchUrl, actualUrl)
+			contentProps["media_url"] = fmt.Sprintf("blur_placeholder:%s actual:%s", mediaUrls[0], mediaUrls[1])
 		} else {
 			mediaUrl := GetMediaUrl(mediaCloudName)
 
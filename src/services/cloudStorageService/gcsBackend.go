@@ -0,0 +1,88 @@
+package cloudStorageService
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"cloud.google.com/go/storage"
+)
+
+// gcsBackend stores media in a Google Cloud Storage bucket
+type gcsBackend struct {
+	bucket *storage.BucketHandle
+}
+
+func NewGCSBackend(client *storage.Client, bucketName string) StorageBackend {
+	return &gcsBackend{bucket: client.Bucket(bucketName)}
+}
+
+func (gb *gcsBackend) SignedUploadUrl(mcn, contentType string, expires time.Time) (string, error) {
+	return gb.bucket.SignedURL(mcn, &storage.SignedURLOptions{
+		Scheme:      storage.SigningSchemeV4,
+		Method:      http.MethodPost,
+		ContentType: contentType,
+		Expires:     expires,
+		Headers:     []string{"x-goog-resumable:start"},
+	})
+}
+
+func (gb *gcsBackend) SignedDownloadUrl(mcn string, expires time.Time) (string, error) {
+	return gb.bucket.SignedURL(mcn, &storage.SignedURLOptions{
+		Scheme:  storage.SigningSchemeV4,
+		Method:  http.MethodGet,
+		Expires: expires,
+	})
+}
+
+func (gb *gcsBackend) MediaInfo(ctx context.Context, mcn string) (*MediaInfo, error) {
+	attrs, err := gb.bucket.Object(mcn).Attrs(ctx)
+	if err != nil {
+		if errors.Is(err, storage.ErrObjectNotExist) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return &MediaInfo{Size: attrs.Size, ContentType: attrs.ContentType}, nil
+}
+
+func (gb *gcsBackend) ReadHead(ctx context.Context, mcn string, n int64) ([]byte, error) {
+	reader, err := gb.bucket.Object(mcn).NewRangeReader(ctx, 0, n)
+	if err != nil {
+		return nil, err
+	}
+
+	defer reader.Close()
+
+	return io.ReadAll(reader)
+}
+
+func (gb *gcsBackend) Read(ctx context.Context, mcn string) (io.ReadCloser, error) {
+	return gb.bucket.Object(mcn).NewReader(ctx)
+}
+
+func (gb *gcsBackend) Write(ctx context.Context, mcn, contentType string, data []byte) error {
+	writer := gb.bucket.Object(mcn).NewWriter(ctx)
+	writer.ContentType = contentType
+
+	if _, err := writer.Write(data); err != nil {
+		writer.Close()
+		return err
+	}
+
+	return writer.Close()
+}
+
+func (gb *gcsBackend) Copy(ctx context.Context, srcMcn, dstMcn string) error {
+	_, err := gb.bucket.Object(dstMcn).CopierFrom(gb.bucket.Object(srcMcn)).Run(ctx)
+
+	return err
+}
+
+func (gb *gcsBackend) Delete(ctx context.Context, mcn string) error {
+	return gb.bucket.Object(mcn).Delete(ctx)
+}
@@ -0,0 +1,78 @@
+package cloudStorageService
+
+import (
+	"context"
+	"fmt"
+	"i9chat/src/appGlobals"
+	"i9chat/src/helpers"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+/*
+Media that may end up unreferenced is garbage collected:
+  - every authorized upload is scheduled for deletion, after a grace period,
+    and unscheduled once a message, profile or group references it.
+  - a superseded profile or group picture is scheduled for deletion, after the grace period.
+
+The schedule is kept in the media_gc_queue sorted set, of media cloud names scored by their due time,
+which the media gc background worker works through
+*/
+
+const MediaGCQueue = "media_gc_queue"
+
+func rdb() *redis.Client {
+	return appGlobals.RedisClient
+}
+
+// ScheduleMediaDeletion schedules the deletion of the media, mcns, once the grace period is over
+func ScheduleMediaDeletion(ctx context.Context, mcns ...string) {
+	if len(mcns) == 0 {
+		return
+	}
+
+	dueAt := float64(time.Now().Add(helpers.MediaGCGracePeriod()).UnixMilli())
+
+	members := make([]redis.Z, len(mcns))
+
+	for i, mcn := range mcns {
+		members[i] = redis.Z{Score: dueAt, Member: mcn}
+	}
+
+	if err := rdb().ZAdd(ctx, MediaGCQueue, members...).Err(); err != nil {
+		helpers.LogError(err)
+	}
+}
+
+// UnscheduleMediaDeletion keeps the media, mcns, now referenced, from being deleted
+func UnscheduleMediaDeletion(ctx context.Context, mcns ...string) {
+	if len(mcns) == 0 {
+		return
+	}
+
+	members := make([]any, len(mcns))
+
+	for i, mcn := range mcns {
+		members[i] = mcn
+	}
+
+	if err := rdb().ZRem(ctx, MediaGCQueue, members...).Err(); err != nil {
+		helpers.LogError(err)
+	}
+}
+
+// PicCloudNames splits a profile or group picture's cloud name into its small, medium and large media cloud names
+func PicCloudNames(picCloudName string) []string {
+	var (
+		smallPicn  string
+		mediumPicn string
+		largePicn  string
+	)
+
+	if _, err := fmt.Sscanf(picCloudName, "small:%s medium:%s large:%s", &smallPicn, &mediumPicn, &largePicn); err != nil {
+		return nil
+	}
+
+	return []string{smallPicn, mediumPicn, largePicn}
+}
@@ -0,0 +1,196 @@
+package cloudStorageService
+
+import (
+	"context"
+	"fmt"
+	"i9chat/src/cache"
+	"i9chat/src/helpers"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+/*
+Media urls are resolved in one of two modes, set by the MEDIA_URL_MODE env:
+  - "signed" (default): a signed url of the storage backend, valid for mediaUrlTTL.
+    Signed urls are cached in redis, by media cloud name, and re-signed mediaUrlRefreshMargin before they expire,
+    so a served url is always valid for, at least, the refresh margin.
+  - "proxy": a url of the app's media proxy endpoint, which only serves chat media
+    to the members of the chats it's sent in.
+*/
+
+const (
+	mediaUrlTTL           = 6 * 24 * time.Hour
+	mediaUrlRefreshMargin = 24 * time.Hour
+)
+
+func proxyMode() bool {
+	return os.Getenv("MEDIA_URL_MODE") == "proxy"
+}
+
+func mediaUrlKey(mcn string) string {
+	return fmt.Sprintf("media_url:%s", mcn)
+}
+
+func mediaChatsKey(mcn string) string {
+	return fmt.Sprintf("media:%s:chats", mcn)
+}
+
+// GetMediaUrls resolves the urls of the media, mcns, in order.
+// A media whose url can't be resolved gets an empty string
+func GetMediaUrls(ctx context.Context, mcns ...string) []string {
+	urls := make([]string, len(mcns))
+
+	if len(mcns) == 0 {
+		return urls
+	}
+
+	if proxyMode() {
+		for i, mcn := range mcns {
+			urls[i] = proxiedMediaUrl(mcn)
+		}
+
+		return urls
+	}
+
+	keys := make([]string, len(mcns))
+
+	for i, mcn := range mcns {
+		keys[i] = mediaUrlKey(mcn)
+	}
+
+	cached, err := rdb().MGet(ctx, keys...).Result()
+	if err != nil {
+		helpers.LogError(err)
+		cached = make([]any, len(mcns))
+	}
+
+	_, err = rdb().Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, mcn := range mcns {
+			if cachedUrl, ok := cached[i].(string); ok {
+				urls[i] = cachedUrl
+				continue
+			}
+
+			url, err := backend.SignedDownloadUrl(mcn, time.Now().Add(mediaUrlTTL))
+			if err != nil {
+				helpers.LogError(err)
+				continue
+			}
+
+			urls[i] = url
+
+			pipe.Set(ctx, keys[i], url, mediaUrlTTL-mediaUrlRefreshMargin)
+		}
+
+		return nil
+	})
+	if err != nil {
+		helpers.LogError(err)
+	}
+
+	return urls
+}
+
+func proxiedMediaUrl(mcn string) string {
+	return os.Getenv("MEDIA_PROXY_BASE_URL") + "/api/app/media/" + (&url.URL{Path: mcn}).EscapedPath()
+}
+
+// GetProxiedMediaRedirect returns a short-lived signed url of the media, mcn,
+// for the proxy endpoint to redirect an authorized requester to
+func GetProxiedMediaRedirect(mcn string) (string, error) {
+	url, err := backend.SignedDownloadUrl(mcn, time.Now().Add(5*time.Minute))
+	if err != nil {
+		helpers.LogError(err)
+		return "", err
+	}
+
+	return url, nil
+}
+
+// DirectMediaChat identifies the direct chat between userA and userB, as a chat a media is sent in
+func DirectMediaChat(userA, userB string) string {
+	return fmt.Sprintf("direct:%s:%s", userA, userB)
+}
+
+// GroupMediaChat identifies the group chat, groupId, as a chat a media is sent in
+func GroupMediaChat(groupId string) string {
+	return fmt.Sprintf("group:%s", groupId)
+}
+
+// RecordMediaChat records a chat the media, mcns, is sent in,
+// by which its access through the proxy endpoint is authorized
+func RecordMediaChat(ctx context.Context, mediaChat string, mcns ...string) error {
+	_, err := rdb().Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, mcn := range mcns {
+			pipe.SAdd(ctx, mediaChatsKey(mcn), mediaChat)
+		}
+
+		return nil
+	})
+	if err != nil {
+		helpers.LogError(err)
+		return err
+	}
+
+	return nil
+}
+
+// CanAccessMedia reports whether clientUser can access the media, mcn, through the proxy endpoint.
+// Chat media is accessible to the members of the chats it's sent in; profile and group pictures, to every user
+func CanAccessMedia(ctx context.Context, clientUsername, mcn string) (bool, error) {
+	if !strings.HasPrefix(mcn, "uploads/chat/") {
+		return true, nil
+	}
+
+	mediaChats, err := rdb().SMembers(ctx, mediaChatsKey(mcn)).Result()
+	if err != nil {
+		helpers.LogError(err)
+		return false, err
+	}
+
+	for _, mediaChat := range mediaChats {
+		chatType, chatIdent, _ := strings.Cut(mediaChat, ":")
+
+		switch chatType {
+		case "direct":
+			userA, userB, _ := strings.Cut(chatIdent, ":")
+
+			if clientUsername == userA || clientUsername == userB {
+				return true, nil
+			}
+		case "group":
+			isMember, err := cache.IsGroupMember(ctx, chatIdent, clientUsername)
+			if err != nil {
+				return false, err
+			}
+
+			if isMember {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
+
+// MediaChatsRecorded reports whether any chat the media, mcn, is sent in, is recorded
+func MediaChatsRecorded(ctx context.Context, mcn string) (bool, error) {
+	n, err := rdb().Exists(ctx, mediaChatsKey(mcn)).Result()
+	if err != nil {
+		helpers.LogError(err)
+		return false, err
+	}
+
+	return n == 1, nil
+}
+
+// forgetMedia removes the cached url and the recorded chats of the deleted media, mcn
+func forgetMedia(ctx context.Context, mcn string) {
+	if err := rdb().Del(ctx, mediaUrlKey(mcn), mediaChatsKey(mcn)).Err(); err != nil {
+		helpers.LogError(err)
+	}
+}
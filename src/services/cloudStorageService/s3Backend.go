@@ -0,0 +1,249 @@
+package cloudStorageService
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/redis/go-redis/v9"
+)
+
+/*
+s3Backend stores media in a bucket of an S3-compatible object storage (MinIO, Ceph, AWS S3).
+Clients download from the bucket directly, with presigned GET urls.
+
+As S3 has no equivalent of GCS's resumable uploads, the app serves its uploads itself (see appServedUploads.go),
+streaming each upload's chunks into a multipart upload of the object.
+An S3 part, but the last, must be at least s3MinPartSize, so chunks are buffered, in redis,
+until they make up a part; hence, chunks must be written in order.
+
+An upload's state is kept in the s3_upload:{mcn} hash (its multipart upload id, received and total sizes),
+along with its buffered chunks and its uploaded parts' ETags.
+The multipart uploads never completed are left to the bucket's AbortIncompleteMultipartUpload lifecycle rule
+*/
+type s3Backend struct {
+	client *minio.Client
+	bucket string
+	urlSigner
+}
+
+const s3MinPartSize = 5 * 1024 * 1024
+
+// how long an upload's state is kept, as long as its session url is valid
+const s3UploadTTL = 24 * time.Hour
+
+func NewS3Backend(client *minio.Client, bucketName, baseUrl, secret string) StorageBackend {
+	return &s3Backend{client: client, bucket: bucketName, urlSigner: urlSigner{baseUrl: strings.TrimSuffix(baseUrl, "/"), secret: []byte(secret)}}
+}
+
+func (sb *s3Backend) signer() *urlSigner {
+	return &sb.urlSigner
+}
+
+func (sb *s3Backend) core() minio.Core {
+	return minio.Core{Client: sb.client}
+}
+
+func s3UploadKeys(mcn string) (upload, buffer, parts string) {
+	upload = fmt.Sprintf("s3_upload:%s", mcn)
+
+	return upload, upload + ":buffer", upload + ":parts"
+}
+
+func (sb *s3Backend) SignedUploadUrl(mcn, contentType string, expires time.Time) (string, error) {
+	return sb.signedUrl(http.MethodPost, mcn, expires), nil
+}
+
+func (sb *s3Backend) SignedDownloadUrl(mcn string, expires time.Time) (string, error) {
+	url, err := sb.client.PresignedGetObject(context.Background(), sb.bucket, mcn, time.Until(expires), nil)
+	if err != nil {
+		return "", err
+	}
+
+	return url.String(), nil
+}
+
+func (sb *s3Backend) MediaInfo(ctx context.Context, mcn string) (*MediaInfo, error) {
+	info, err := sb.client.StatObject(ctx, sb.bucket, mcn, minio.StatObjectOptions{})
+	if err != nil {
+		if minio.ToErrorResponse(err).StatusCode == http.StatusNotFound {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return &MediaInfo{Size: info.Size, ContentType: info.ContentType}, nil
+}
+
+func (sb *s3Backend) ReadHead(ctx context.Context, mcn string, n int64) ([]byte, error) {
+	opts := minio.GetObjectOptions{}
+
+	if err := opts.SetRange(0, n-1); err != nil {
+		return nil, err
+	}
+
+	object, err := sb.client.GetObject(ctx, sb.bucket, mcn, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	defer object.Close()
+
+	return io.ReadAll(object)
+}
+
+func (sb *s3Backend) Read(ctx context.Context, mcn string) (io.ReadCloser, error) {
+	return sb.client.GetObject(ctx, sb.bucket, mcn, minio.GetObjectOptions{})
+}
+
+func (sb *s3Backend) Write(ctx context.Context, mcn, contentType string, data []byte) error {
+	_, err := sb.client.PutObject(ctx, sb.bucket, mcn, bytes.NewReader(data), int64(len(data)), minio.PutObjectOptions{ContentType: contentType})
+
+	return err
+}
+
+func (sb *s3Backend) Copy(ctx context.Context, srcMcn, dstMcn string) error {
+	_, err := sb.client.CopyObject(ctx, minio.CopyDestOptions{Bucket: sb.bucket, Object: dstMcn}, minio.CopySrcOptions{Bucket: sb.bucket, Object: srcMcn})
+
+	return err
+}
+
+func (sb *s3Backend) Delete(ctx context.Context, mcn string) error {
+	return sb.client.RemoveObject(ctx, sb.bucket, mcn, minio.RemoveObjectOptions{})
+}
+
+func (sb *s3Backend) startUpload(ctx context.Context, mcn, contentType string) error {
+	uploadId, err := sb.core().NewMultipartUpload(ctx, sb.bucket, mcn, minio.PutObjectOptions{ContentType: contentType})
+	if err != nil {
+		return err
+	}
+
+	uploadKey, bufferKey, partsKey := s3UploadKeys(mcn)
+
+	_, err = rdb().TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, uploadKey, bufferKey, partsKey)
+		pipe.HSet(ctx, uploadKey, "upload_id", uploadId, "received", 0)
+		pipe.Expire(ctx, uploadKey, s3UploadTTL)
+
+		return nil
+	})
+
+	return err
+}
+
+// KEYS: upload hash, upload's buffer, upload's parts.
+// ARGV: chunk's offset, chunk's size, upload's total size, chunk (if buffered), chunk's part ETag (if uploaded).
+//
+// The chunk is only committed if it follows the upload's last chunk, and declares the same total size
+var commitS3UploadChunkScript = redis.NewScript(`
+if redis.call("HGET", KEYS[1], "received") ~= ARGV[1] then
+	return 0
+end
+local total = redis.call("HGET", KEYS[1], "total")
+if total ~= false and total ~= ARGV[3] then
+	return 0
+end
+if ARGV[5] == "" then
+	redis.call("APPEND", KEYS[2], ARGV[4])
+else
+	redis.call("DEL", KEYS[2])
+	redis.call("RPUSH", KEYS[3], ARGV[5])
+end
+redis.call("HSET", KEYS[1], "total", ARGV[3])
+redis.call("HINCRBY", KEYS[1], "received", ARGV[2])
+local ttl = redis.call("TTL", KEYS[1])
+redis.call("EXPIRE", KEYS[2], ttl)
+redis.call("EXPIRE", KEYS[3], ttl)
+return 1
+`)
+
+// writeUploadChunk buffers chunk, uploading the buffered chunks as the next part,
+// once they make up a part, or chunk is the upload's last, completing the upload then.
+//
+// Nothing is committed until the part is uploaded, so that a failed chunk can be retried
+func (sb *s3Backend) writeUploadChunk(ctx context.Context, mcn string, offset, total int64, chunk []byte) error {
+	uploadKey, bufferKey, partsKey := s3UploadKeys(mcn)
+
+	upload, err := rdb().HMGet(ctx, uploadKey, "upload_id", "received", "total").Result()
+	if err != nil {
+		return err
+	}
+
+	uploadId, _ := upload[0].(string)
+	received, _ := upload[1].(string)
+	declared, _ := upload[2].(string)
+
+	if uploadId == "" || received != strconv.FormatInt(offset, 10) ||
+		(declared != "" && declared != strconv.FormatInt(total, 10)) || offset+int64(len(chunk)) > total {
+		return ErrUploadRange
+	}
+
+	buffered, err := rdb().Get(ctx, bufferKey).Bytes()
+	if err != nil && err != redis.Nil {
+		return err
+	}
+
+	last := offset+int64(len(chunk)) == total
+
+	if !last && len(buffered)+len(chunk) < s3MinPartSize {
+		return sb.commitUploadChunk(ctx, mcn, offset, total, chunk, "")
+	}
+
+	partsETags, err := rdb().LRange(ctx, partsKey, 0, -1).Result()
+	if err != nil {
+		return err
+	}
+
+	part := append(buffered, chunk...)
+
+	objectPart, err := sb.core().PutObjectPart(ctx, sb.bucket, mcn, uploadId, len(partsETags)+1, bytes.NewReader(part), int64(len(part)), minio.PutObjectPartOptions{})
+	if err != nil {
+		return err
+	}
+
+	if !last {
+		return sb.commitUploadChunk(ctx, mcn, offset, total, chunk, objectPart.ETag)
+	}
+
+	completeParts := make([]minio.CompletePart, 0, len(partsETags)+1)
+
+	for i, ETag := range append(partsETags, objectPart.ETag) {
+		completeParts = append(completeParts, minio.CompletePart{PartNumber: i + 1, ETag: ETag})
+	}
+
+	if _, err := sb.core().CompleteMultipartUpload(ctx, sb.bucket, mcn, uploadId, completeParts, minio.PutObjectOptions{}); err != nil {
+		return err
+	}
+
+	return rdb().Del(ctx, uploadKey, bufferKey, partsKey).Err()
+}
+
+// commitUploadChunk commits chunk, buffered, if partETag is empty, or uploaded as the part, partETag
+func (sb *s3Backend) commitUploadChunk(ctx context.Context, mcn string, offset, total int64, chunk []byte, partETag string) error {
+	uploadKey, bufferKey, partsKey := s3UploadKeys(mcn)
+
+	chunkSize := len(chunk)
+
+	// an uploaded chunk is no longer buffered
+	if partETag != "" {
+		chunk = nil
+	}
+
+	committed, err := commitS3UploadChunkScript.Run(ctx, rdb(), []string{uploadKey, bufferKey, partsKey}, offset, chunkSize, total, chunk, partETag).Int()
+	if err != nil {
+		return err
+	}
+
+	if committed == 0 {
+		return ErrUploadRange
+	}
+
+	return nil
+}
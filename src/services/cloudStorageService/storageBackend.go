@@ -0,0 +1,53 @@
+package cloudStorageService
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// StorageBackend is where media objects are stored,
+// addressed by their media cloud name (mcn).
+// Clients upload and download media directly, through the signed urls it issues
+type StorageBackend interface {
+	// SignedUploadUrl returns a url, valid until expires, with which the object, mcn, is uploaded,
+	// following the backend's own upload protocol
+	SignedUploadUrl(mcn, contentType string, expires time.Time) (string, error)
+
+	// SignedDownloadUrl returns a url, valid until expires, from which the object, mcn, can be read
+	SignedDownloadUrl(mcn string, expires time.Time) (string, error)
+
+	// MediaInfo returns the object's info, or nil if the object doesn't exist
+	MediaInfo(ctx context.Context, mcn string) (*MediaInfo, error)
+
+	// ReadHead returns the first n bytes, at most, of the object, mcn
+	ReadHead(ctx context.Context, mcn string, n int64) ([]byte, error)
+
+	// Read opens the object, mcn, for reading
+	Read(ctx context.Context, mcn string) (io.ReadCloser, error)
+
+	// Write creates, or replaces, the object, mcn, with data, for media produced by the app itself
+	Write(ctx context.Context, mcn, contentType string, data []byte) error
+
+	// Copy creates, or replaces, the object, dstMcn, with the content of the object, srcMcn
+	Copy(ctx context.Context, srcMcn, dstMcn string) error
+
+	Delete(ctx context.Context, mcn string) error
+}
+
+type MediaInfo struct {
+	Size        int64
+	ContentType string
+}
+
+var backend StorageBackend
+
+// UseBackend sets the storage backend used by the app
+func UseBackend(b StorageBackend) {
+	backend = b
+}
+
+// Backend returns the storage backend used by the app
+func Backend() StorageBackend {
+	return backend
+}
@@ -97,6 +97,30 @@ func QueueDirectMsgReactionRemovedEvent(mrre eventTypes.DirectMsgReactionRemoved
 	}
 }
 
+func QueueDirectMsgEditEvent(dmee eventTypes.DirectMsgEditEvent) {
+	ctx := context.Background()
+
+	err := rdb().XAdd(ctx, &redis.XAddArgs{
+		Stream: "direct_msg_edits",
+		Values: dmee,
+	}).Err()
+	if err != nil {
+		helpers.LogError(err)
+	}
+}
+
+func QueueDirectMsgDeletionEvent(dmde eventTypes.DirectMsgDeletionEvent) {
+	ctx := context.Background()
+
+	err := rdb().XAdd(ctx, &redis.XAddArgs{
+		Stream: "direct_msg_deletions",
+		Values: dmde,
+	}).Err()
+	if err != nil {
+		helpers.LogError(err)
+	}
+}
+
 func QueueNewGroupEvent(nue eventTypes.NewGroupEvent) {
 	ctx := context.Background()
 
@@ -193,6 +217,18 @@ func QueueGroupRemoveUserFromAdminsEvent(ege eventTypes.GroupRemoveUserFromAdmin
 	}
 }
 
+func QueueGroupDeletedEvent(gde eventTypes.GroupDeletedEvent) {
+	ctx := context.Background()
+
+	err := rdb().XAdd(ctx, &redis.XAddArgs{
+		Stream: "groups_deleted",
+		Values: gde,
+	}).Err()
+	if err != nil {
+		helpers.LogError(err)
+	}
+}
+
 func QueueNewGroupMessageEvent(ndme eventTypes.NewGroupMessageEvent) {
 	ctx := context.Background()
 
@@ -240,3 +276,39 @@ func QueueGroupMsgReactionRemovedEvent(mrre eventTypes.GroupMsgReactionRemovedEv
 		helpers.LogError(err)
 	}
 }
+
+func QueueGroupMsgEditEvent(gmee eventTypes.GroupMsgEditEvent) {
+	ctx := context.Background()
+
+	err := rdb().XAdd(ctx, &redis.XAddArgs{
+		Stream: "group_msg_edits",
+		Values: gmee,
+	}).Err()
+	if err != nil {
+		helpers.LogError(err)
+	}
+}
+
+func QueueGroupMsgDeletionEvent(gmde eventTypes.GroupMsgDeletionEvent) {
+	ctx := context.Background()
+
+	err := rdb().XAdd(ctx, &redis.XAddArgs{
+		Stream: "group_msg_deletions",
+		Values: gmde,
+	}).Err()
+	if err != nil {
+		helpers.LogError(err)
+	}
+}
+
+func QueueImageProcessingJob(ipj eventTypes.ImageProcessingJob) {
+	ctx := context.Background()
+
+	err := rdb().XAdd(ctx, &redis.XAddArgs{
+		Stream: "image_processing_jobs",
+		Values: ipj,
+	}).Err()
+	if err != nil {
+		helpers.LogError(err)
+	}
+}
@@ -68,6 +68,7 @@ type GroupUserLeftEvent struct {
 	OldMember    string              `redis:"oldMember"`
 	OldMemberCHE appTypes.BinableMap `redis:"oldMemberCHE"`
 	MemInfo      string              `redis:"memInfo"`
+	NewOwner     string              `redis:"newOwner"` // if the old member was the owner
 }
 
 type GroupMakeUserAdminEvent struct {
@@ -88,6 +89,18 @@ type GroupRemoveUserFromAdminsEvent struct {
 	MemInfo     string              `redis:"memInfo"`
 }
 
+// GroupDeletedEvent is a group deleted by DeleterUser; its cleanup is left to the worker.
+// Its members' chats are either archived, read-only, or removed, along with their entries and media
+type GroupDeletedEvent struct {
+	GroupId          string                `redis:"groupId"`
+	DeleterUser      string                `redis:"deleterUser"`
+	DeleterUserCHE   appTypes.BinableMap   `redis:"deleterUserCHE"`
+	MemberUserCHE    appTypes.BinableMap   `redis:"memberUserCHE"`
+	FormerMembers    appTypes.BinableSlice `redis:"formerMembers"`
+	PictureCloudName string                `redis:"pictureCloudName"`
+	Archive          bool                  `redis:"archive"`
+}
+
 type NewDirectMessageEvent struct {
 	FirstFromUser bool   `redis:"ffu"`
 	FirstToUser   bool   `redis:"ftu"`
@@ -159,7 +172,45 @@ type GroupMsgReactionRemovedEvent struct {
 	CHEId    string `redis:"CHEId"`
 }
 
-type MsgDeletionEvent struct {
-	CHEId string `redis:"CHEId"`
-	For   string `redis:"for"`
+type DirectMsgEditEvent struct {
+	FromUser string `redis:"fromUser"`
+	ToUser   string `redis:"toUser"`
+	CHEId    string `redis:"CHEId"`
+	Content  string `redis:"content"`
+	EditedAt int64  `redis:"editedAt"`
+}
+
+type GroupMsgEditEvent struct {
+	FromUser string `redis:"fromUser"`
+	ToGroup  string `redis:"toGroup"`
+	CHEId    string `redis:"CHEId"`
+	Content  string `redis:"content"`
+	EditedAt int64  `redis:"editedAt"`
+}
+
+type DirectMsgDeletionEvent struct {
+	FromUser  string                `redis:"fromUser"`
+	ToUser    string                `redis:"toUser"`
+	CHEIds    appTypes.BinableSlice `redis:"CHEIds"`
+	For       string                `redis:"for"`
+	DeletedAt int64                 `redis:"deletedAt"`
+}
+
+type GroupMsgDeletionEvent struct {
+	FromUser  string                `redis:"fromUser"`
+	ToGroup   string                `redis:"toGroup"`
+	CHEIds    appTypes.BinableSlice `redis:"CHEIds"`
+	For       string                `redis:"for"`
+	DeletedAt int64                 `redis:"deletedAt"`
+}
+
+// ImageProcessingJob is an original image, uploaded for the server to process,
+// into the variants of a profile or group picture, or the media of a chat photo message
+type ImageProcessingJob struct {
+	Target      string `redis:"target"` // "profile_pic", "group_pic", "direct_chat_photo", or "group_chat_photo"
+	OwnerUser   string `redis:"ownerUser"`
+	GroupId     string `redis:"groupId"`
+	PartnerUser string `redis:"partnerUser"`
+	MsgId       string `redis:"msgId"`
+	OriginalMcn string `redis:"originalMcn"`
 }
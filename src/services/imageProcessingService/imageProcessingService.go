@@ -0,0 +1,254 @@
+package imageProcessingService
+
+import (
+	"context"
+	"fmt"
+	"i9chat/src/appGlobals"
+	"i9chat/src/helpers"
+	"i9chat/src/services/cloudStorageService"
+	"i9chat/src/services/eventStreamService/eventTypes"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/redis/go-redis/v9"
+)
+
+/*
+In the optional original upload mode, clients upload one original image,
+instead of the resized variants of a picture, or the blur placeholder of a photo,
+and the image processing background worker produces them:
+  - profile and group pictures get small, medium and large variants.
+  - chat photos get a blur placeholder, and a resized actual photo.
+
+The produced images are re-encoded as JPEG, which drops the original's EXIF metadata (GPS included).
+Original cloud names are marked with the "original:" prefix, wherever a processed cloud name is expected
+*/
+
+const OriginalPrefix = "original:"
+
+const (
+	originalUploadTTL = 24 * time.Hour
+	maxOriginalSize   = 20 * 1024 * 1024
+	maxOriginalPixels = 50_000_000
+)
+
+var originalMIMEs = []string{"image/jpeg", "image/png", "image/webp"}
+
+var picVariantSides = map[string]int{"small": 128, "medium": 512, "large": 1080}
+
+const (
+	photoMaxSide           = 2048
+	blurPlaceholderMaxSide = 32
+)
+
+func rdb() *redis.Client {
+	return appGlobals.RedisClient
+}
+
+// originalUpload is recorded for each original image an upload is authorized for,
+// so the original can be verified against it, before it's processed
+type originalUpload struct {
+	Uploader string `msgpack:"uploader"`
+	Target   string `msgpack:"target"` // "profile_pic", "group_pic", or "photo"
+	MIME     string `msgpack:"mime"`
+}
+
+func originalUploadKey(mcn string) string {
+	return fmt.Sprintf("original_upload:%s", mcn)
+}
+
+// IsOriginal reports whether cloudName is the cloud name of an original image
+func IsOriginal(cloudName string) bool {
+	return strings.HasPrefix(cloudName, OriginalPrefix)
+}
+
+// OriginalMcn returns the media cloud name of the original image, cloudName
+func OriginalMcn(cloudName string) string {
+	return strings.TrimPrefix(cloudName, OriginalPrefix)
+}
+
+// MessageOriginal returns the original image cloud name of a photo message, given the message's content,
+// if its images are yet to be processed
+func MessageOriginal(msgContent map[string]any) (string, bool) {
+	contentProps, _ := msgContent["props"].(map[string]any)
+
+	mediaCloudName, _ := contentProps["media_cloud_name"].(string)
+
+	return mediaCloudName, IsOriginal(mediaCloudName)
+}
+
+// AuthorizeOriginalUpload authorizes clientUser to upload an original image, for target, as the media, mcn.
+// It returns the upload url, and the original's cloud name
+func AuthorizeOriginalUpload(ctx context.Context, clientUsername, target, mcn, mime string) (string, string, error) {
+	url, err := cloudStorageService.GetUploadUrl(mcn, mime)
+	if err != nil {
+		return "", "", fiber.ErrInternalServerError
+	}
+
+	err = rdb().Set(ctx, originalUploadKey(mcn), helpers.ToMsgPack(originalUpload{Uploader: clientUsername, Target: target, MIME: mime}), originalUploadTTL).Err()
+	if err != nil {
+		helpers.LogError(err)
+		return "", "", fiber.ErrInternalServerError
+	}
+
+	// deleted, if it's never processed
+	cloudStorageService.ScheduleMediaDeletion(ctx, mcn)
+
+	return url, OriginalPrefix + mcn, nil
+}
+
+// VerifyOriginalUpload verifies the original image, cloudName, before it's queued for processing, for target.
+//
+// It must have been uploaded by clientUser, with an authorized upload for target,
+// be within the size limit, and be an image of the authorized MIME type, judged by its content.
+// The authorized upload is consumed, so the original is queued once. An invalid original is deleted
+func VerifyOriginalUpload(ctx context.Context, clientUsername, target, cloudName string) error {
+	mcn := OriginalMcn(cloudName)
+
+	uploadMsgPack, err := rdb().GetDel(ctx, originalUploadKey(mcn)).Result()
+	if err != nil && err != redis.Nil {
+		helpers.LogError(err)
+		return fiber.ErrInternalServerError
+	}
+
+	upload := helpers.FromMsgPack[originalUpload](uploadMsgPack)
+
+	if upload.Uploader != clientUsername || upload.Target != target {
+		return fiber.NewError(fiber.StatusBadRequest, "no authorized upload found for this original image")
+	}
+
+	mInfo := cloudStorageService.GetMediaInfo(ctx, mcn)
+	if mInfo == nil {
+		return fiber.NewError(fiber.StatusBadRequest, "original image: upload not found or incomplete")
+	}
+
+	if mInfo.Size > maxOriginalSize {
+		go cloudStorageService.DeleteCloudMedia(context.Background(), mcn)
+
+		return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("original image: size out of range; max: %dB", maxOriginalSize))
+	}
+
+	head, err := cloudStorageService.GetMediaHead(ctx, mcn, 512)
+	if err != nil {
+		return fiber.ErrInternalServerError
+	}
+
+	if http.DetectContentType(head) != upload.MIME {
+		go cloudStorageService.DeleteCloudMedia(context.Background(), mcn)
+
+		return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("original image: content isn't of the authorized type, %s", upload.MIME))
+	}
+
+	return nil
+}
+
+// SupportedOriginalMIME reports whether an original image of mime can be processed
+func SupportedOriginalMIME(mime string) bool {
+	return slices.Contains(originalMIMEs, mime)
+}
+
+// Process produces the images of the job's target from its original,
+// stores them, and deletes the original.
+// It returns the cloud name of the produced images, in the form their target expects
+func Process(ctx context.Context, job eventTypes.ImageProcessingJob) (string, error) {
+	data, err := cloudStorageService.ReadMedia(ctx, job.OriginalMcn, maxOriginalSize)
+	if err != nil {
+		return "", err
+	}
+
+	img, err := decodeOriented(data)
+	if err != nil {
+		return "", err
+	}
+
+	// the original's name ends with "-original", which each produced image's name replaces
+	mcnBase := strings.TrimSuffix(job.OriginalMcn, "-original")
+
+	var (
+		cloudName string
+		produced  []string
+	)
+
+	putJPEG := func(mcn string, maxSide, quality int) error {
+		jpg, err := encodeJPEG(fit(img, maxSide), quality)
+		if err != nil {
+			return err
+		}
+
+		if err := cloudStorageService.PutMedia(ctx, mcn, "image/jpeg", jpg); err != nil {
+			return err
+		}
+
+		produced = append(produced, mcn)
+
+		return nil
+	}
+
+	switch job.Target {
+	case "profile_pic", "group_pic":
+		for _, which := range []string{"small", "medium", "large"} {
+			if err = putJPEG(mcnBase+"-"+which, picVariantSides[which], 85); err != nil {
+				break
+			}
+		}
+
+		if err == nil {
+			cloudName = fmt.Sprintf("small:%s medium:%s large:%s", produced[0], produced[1], produced[2])
+		}
+	case "direct_chat_photo", "group_chat_photo":
+		if err = putJPEG(mcnBase+"-blur_placeholder", blurPlaceholderMaxSide, 60); err == nil {
+			err = putJPEG(mcnBase+"-actual", photoMaxSide, 85)
+		}
+
+		if err == nil {
+			mediaChat := cloudStorageService.GroupMediaChat(job.GroupId)
+			if job.Target == "direct_chat_photo" {
+				mediaChat = cloudStorageService.DirectMediaChat(job.OwnerUser, job.PartnerUser)
+			}
+
+			err = cloudStorageService.RecordMediaChat(ctx, mediaChat, produced...)
+
+			cloudName = fmt.Sprintf("blur_placeholder:%s actual:%s", produced[0], produced[1])
+		}
+	default:
+		err = fmt.Errorf("unknown image processing target: %s", job.Target)
+	}
+
+	if err != nil {
+		for _, mcn := range produced {
+			cloudStorageService.DeleteCloudMedia(ctx, mcn)
+		}
+
+		return "", err
+	}
+
+	cloudStorageService.DeleteCloudMedia(ctx, job.OriginalMcn)
+	cloudStorageService.UnscheduleMediaDeletion(ctx, job.OriginalMcn)
+
+	return cloudName, nil
+}
+
+// DeleteProcessed deletes the images of cloudName, produced by Process, when its target no longer exists
+func DeleteProcessed(ctx context.Context, cloudName string) {
+	mcns := cloudStorageService.PicCloudNames(cloudName)
+
+	if mcns == nil {
+		var (
+			blurPlchMcn string
+			actualMcn   string
+		)
+
+		if _, err := fmt.Sscanf(cloudName, "blur_placeholder:%s actual:%s", &blurPlchMcn, &actualMcn); err != nil {
+			return
+		}
+
+		mcns = []string{blurPlchMcn, actualMcn}
+	}
+
+	for _, mcn := range mcns {
+		cloudStorageService.DeleteCloudMedia(ctx, mcn)
+	}
+}
@@ -0,0 +1,110 @@
+package realtimeService
+
+import (
+	"context"
+	"fmt"
+	"i9chat/src/appTypes"
+	"i9chat/src/helpers"
+	"sync"
+	"time"
+
+	"github.com/gofiber/utils/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// SendEphemeralEventMsg publishes msg on toUser's server events channel, without logging it,
+// so it only reaches toUser's currently connected sockets, and is never replayed
+func SendEphemeralEventMsg(toUser string, msg appTypes.ServerEventMsg) {
+	if err := rdb().Publish(context.Background(), fmt.Sprintf("user_%s_server_events", toUser), helpers.ToMsgPack(msg)).Err(); err != nil {
+		helpers.LogError(err)
+	}
+}
+
+// an ephemeral state (e.g. typing) not renewed within this duration expires
+const ephemeralStateTTL = 6 * time.Second
+
+/*
+An ephemeral state's latest renewal is recorded in Redis, by its token, whichever server instance renews it.
+Once ephemeralStateTTL is over, the renewing instance expires the state only if the record still holds its token;
+otherwise the state was renewed, or ended, meanwhile, possibly on another instance.
+The record outlives ephemeralStateTTL by a grace period, only to be cleaned up
+*/
+
+const ephemeralStateGracePeriod = 2 * time.Second
+
+var expireEphemeralStateScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+var (
+	ephemeralStateTimers   = make(map[string]*time.Timer)
+	ephemeralStateTimersMu sync.Mutex
+)
+
+func ephemeralStateKey(stateKey string) string {
+	return fmt.Sprintf("ephemeral_state:%s", stateKey)
+}
+
+// RenewEphemeralState (re)starts the expiry of the ephemeral state, stateKey.
+// onExpire is called if the state is neither renewed nor ended, on any server instance, within ephemeralStateTTL
+func RenewEphemeralState(stateKey string, onExpire func()) {
+	ctx := context.Background()
+
+	token := utils.UUIDv4()
+
+	if err := rdb().Set(ctx, ephemeralStateKey(stateKey), token, ephemeralStateTTL+ephemeralStateGracePeriod).Err(); err != nil {
+		helpers.LogError(err)
+		return
+	}
+
+	ephemeralStateTimersMu.Lock()
+	defer ephemeralStateTimersMu.Unlock()
+
+	if timer, ok := ephemeralStateTimers[stateKey]; ok {
+		timer.Stop()
+	}
+
+	var timer *time.Timer
+
+	timer = time.AfterFunc(ephemeralStateTTL, func() {
+		ephemeralStateTimersMu.Lock()
+		if ephemeralStateTimers[stateKey] == timer {
+			delete(ephemeralStateTimers, stateKey)
+		}
+		ephemeralStateTimersMu.Unlock()
+
+		expired, err := expireEphemeralStateScript.Run(ctx, rdb(), []string{ephemeralStateKey(stateKey)}, token).Int()
+		if err != nil {
+			helpers.LogError(err)
+			return
+		}
+
+		// renewed or ended meanwhile
+		if expired == 0 {
+			return
+		}
+
+		onExpire()
+	})
+
+	ephemeralStateTimers[stateKey] = timer
+}
+
+// EndEphemeralState cancels the expiry of the ephemeral state, stateKey, on every server instance
+func EndEphemeralState(stateKey string) {
+	if err := rdb().Del(context.Background(), ephemeralStateKey(stateKey)).Err(); err != nil {
+		helpers.LogError(err)
+	}
+
+	ephemeralStateTimersMu.Lock()
+	defer ephemeralStateTimersMu.Unlock()
+
+	if timer, ok := ephemeralStateTimers[stateKey]; ok {
+		timer.Stop()
+
+		delete(ephemeralStateTimers, stateKey)
+	}
+}
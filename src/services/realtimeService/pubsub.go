@@ -4,9 +4,11 @@ import (
 	"context"
 	"fmt"
 	"i9chat/src/appTypes"
+	"i9chat/src/cache"
 	"i9chat/src/helpers"
 
 	"github.com/gofiber/contrib/v3/websocket"
+	"github.com/redis/go-redis/v9"
 )
 
 func PublishUserPresenceChange(ctx context.Context, targetUsername string, data map[string]any) {
@@ -18,27 +20,210 @@ func PublishUserPresenceChange(ctx context.Context, targetUsername string, data
 	}
 }
 
-func SubscribeToUserPresence(ctx context.Context, clientUsername string, targetUsername string, ctxCancel context.CancelFunc) {
+// SubscribeToUserPresence relays targetUser's presence changes to clientPipe.
+// Nothing is subscribed, and false is returned, if either of the users has blocked the other
+func SubscribeToUserPresence(ctx context.Context, clientPipe *Pipe, targetUsername string, ctxCancel context.CancelFunc) bool {
+	blocked, err := cache.IsBlockedBetween(ctx, clientPipe.Username, targetUsername)
+	if err != nil || blocked {
+		return false
+	}
+
 	pubsub := rdb().Subscribe(ctx, fmt.Sprintf("user_%s_presence_change", targetUsername))
 
+	go pipePresenceSubscription(ctx, pubsub, clientPipe, targetUsername, ctxCancel)
+
+	return true
+}
+
+// pipePresenceSubscription writes targetUser's presence changes received on pubsub to clientPipe,
+// until ctx is done, or either of the users blocks the other, after which the subscription is closed
+func pipePresenceSubscription(ctx context.Context, pubsub *redis.PubSub, clientPipe *Pipe, targetUsername string, ctxCancel context.CancelFunc) {
 	defer func() {
 		if err := pubsub.Close(); err != nil {
 			helpers.LogError(err)
 		}
 	}()
 
-	go func(ctxCancel context.CancelFunc) {
-		ch := pubsub.Channel()
+	ch := pubsub.Channel()
 
-		for msg := range ch {
-			if userPipe, ok := AllClientSockets.Load(clientUsername); ok {
-				pipe := userPipe.(*websocket.Conn)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
 
-				if err := pipe.WriteMessage(websocket.BinaryMessage, []byte(msg.Payload)); err != nil {
+			// a block made since the subscription ends it
+			blocked, err := cache.IsBlockedBetween(ctx, clientPipe.Username, targetUsername)
+			if err != nil {
+				continue
+			}
+
+			if blocked {
+				ctxCancel()
+				return
+			}
+
+			if err := clientPipe.WriteMessage(websocket.BinaryMessage, []byte(msg.Payload)); err != nil {
+				helpers.LogError(err)
+				ctxCancel()
+			}
+		}
+	}
+}
+
+// SubscribeToServerEvents relays the server events published for clientUser,
+// by any server instance, to the socket of clientUser's device held by clientPipe.
+// Each connected device has its own subscription, so every event reaches all of them.
+//
+// The subscription is confirmed before returning, so that no event sent afterwards is missed.
+// If resumeFrom, an event id, is set, the events after it in clientUser's event log
+// are replayed first, and their live copies skipped.
+// If some of them are no longer in the event log, a "resync required" event is sent instead,
+// for the client to reload its chats' state
+func SubscribeToServerEvents(ctx context.Context, clientPipe *Pipe, resumeFrom string, ctxCancel context.CancelFunc) error {
+	pubsub := rdb().Subscribe(ctx, fmt.Sprintf("user_%s_server_events", clientPipe.Username))
+
+	if _, err := pubsub.Receive(ctx); err != nil {
+		helpers.LogError(err)
+
+		if err := pubsub.Close(); err != nil {
+			helpers.LogError(err)
+		}
+
+		return err
+	}
+
+	replayedUpTo := ""
+
+	if resumeFrom != "" {
+		events, trimmed, err := missedEvents(ctx, clientPipe.Username, resumeFrom)
+		if err != nil {
+			if err := pubsub.Close(); err != nil {
+				helpers.LogError(err)
+			}
+
+			return err
+		}
+
+		if trimmed {
+			events = []appTypes.ServerEventMsg{{Event: "resync required"}}
+		}
+
+		for _, event := range events {
+			if err := clientPipe.WriteMessage(websocket.BinaryMessage, helpers.ToBtMsgPack(event)); err != nil {
+				if err := pubsub.Close(); err != nil {
 					helpers.LogError(err)
-					ctxCancel()
 				}
+
+				return err
 			}
+
+			replayedUpTo = event.EventId
+		}
+	}
+
+	go pipeSubscription(ctx, pubsub, clientPipe, replayedUpTo, ctxCancel)
+
+	return nil
+}
+
+// PublishSessionsRevoked tells every server instance to close the sockets opened with sessionIds
+func PublishSessionsRevoked(ctx context.Context, sessionIds []string) {
+	for _, sessionId := range sessionIds {
+		if err := rdb().Publish(ctx, fmt.Sprintf("session_%s_revoked", sessionId), "").Err(); err != nil {
+			helpers.LogError(err)
 		}
-	}(ctxCancel)
+	}
+}
+
+// SubscribeToSessionRevocation closes clientPipe's socket as soon as the session it was opened with is revoked.
+// The client device is told so, before the socket is closed
+func SubscribeToSessionRevocation(ctx context.Context, clientPipe *Pipe) error {
+	pubsub := rdb().Subscribe(ctx, fmt.Sprintf("session_%s_revoked", clientPipe.SessionId))
+
+	if _, err := pubsub.Receive(ctx); err != nil {
+		helpers.LogError(err)
+
+		if err := pubsub.Close(); err != nil {
+			helpers.LogError(err)
+		}
+
+		return err
+	}
+
+	go func() {
+		defer func() {
+			if err := pubsub.Close(); err != nil {
+				helpers.LogError(err)
+			}
+		}()
+
+		select {
+		case <-ctx.Done():
+			return
+		case _, ok := <-pubsub.Channel():
+			if !ok {
+				return
+			}
+
+			if err := clientPipe.WriteMessage(websocket.BinaryMessage, helpers.ToBtMsgPack(appTypes.ServerEventMsg{
+				Event: "session revoked",
+			})); err != nil {
+				helpers.LogError(err)
+			}
+
+			if err := clientPipe.Close(); err != nil {
+				helpers.LogError(err)
+			}
+		}
+	}()
+
+	return nil
+}
+
+// pipeSubscription writes every message received on pubsub to clientPipe,
+// until ctx is done, after which the subscription is closed.
+//
+// Server events up to replayedUpTo, an event id, have already been replayed, and are skipped;
+// events with no event id were never logged, and are passed through
+func pipeSubscription(ctx context.Context, pubsub *redis.PubSub, clientPipe *Pipe, replayedUpTo string, ctxCancel context.CancelFunc) {
+	defer func() {
+		if err := pubsub.Close(); err != nil {
+			helpers.LogError(err)
+		}
+	}()
+
+	ch := pubsub.Channel()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+
+			if replayedUpTo != "" {
+				event := helpers.FromMsgPack[appTypes.ServerEventMsg](msg.Payload)
+
+				// ephemeral events, and events that failed to be logged, have no event id, and are never replayed
+				if event.EventId != "" {
+					if !eventIdAfter(event.EventId, replayedUpTo) {
+						continue
+					}
+
+					replayedUpTo = ""
+				}
+			}
+
+			if err := clientPipe.WriteMessage(websocket.BinaryMessage, []byte(msg.Payload)); err != nil {
+				helpers.LogError(err)
+				ctxCancel()
+			}
+		}
+	}
 }
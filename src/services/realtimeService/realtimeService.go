@@ -4,6 +4,7 @@ import (
 	"i9chat/src/appGlobals"
 	"sync"
 
+	"github.com/gofiber/contrib/v3/websocket"
 	"github.com/redis/go-redis/v9"
 )
 
@@ -11,4 +12,26 @@ func rdb() *redis.Client {
 	return appGlobals.RedisClient
 }
 
-var AllClientSockets = &sync.Map{}
+// Pipe is a client device's socket.
+// Writes are serialized, as the socket is written to by several goroutines
+type Pipe struct {
+	Username  string
+	SessionId string
+	DeviceId  string
+	ConnId    string // unique to the socket; a device may briefly have two, while reconnecting
+
+	conn *websocket.Conn
+	wmu  sync.Mutex
+}
+
+func (p *Pipe) WriteMessage(messageType int, data []byte) error {
+	p.wmu.Lock()
+	defer p.wmu.Unlock()
+
+	return p.conn.WriteMessage(messageType, data)
+}
+
+// Close closes the socket, ending the client device's connection
+func (p *Pipe) Close() error {
+	return p.conn.Close()
+}
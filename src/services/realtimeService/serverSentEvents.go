@@ -2,28 +2,248 @@ package realtimeService
 
 import (
 	"context"
+	"fmt"
 	"i9chat/src/appTypes"
 	"i9chat/src/helpers"
+	"strings"
+	"time"
 
 	"github.com/gofiber/contrib/v3/websocket"
+	"github.com/gofiber/utils/v2"
+	"github.com/redis/go-redis/v9"
 )
 
+// the number of most recent server events kept in a user's event log
+const eventLogMaxLen = 1000
+
+// SendEventMsg appends msg to toUser's event log, then publishes it,
+// with its event log id, on toUser's server events channel,
+// so it reaches every socket of toUser on whichever server instance holds it.
+//
+// A device that was disconnected replays the events it missed from the event log,
+// when it reconnects
 func SendEventMsg(toUser string, msg appTypes.ServerEventMsg) {
-	if userPipe, ok := AllClientSockets.Load(toUser); ok {
-		pipe := userPipe.(*websocket.Conn)
+	ctx := context.Background()
 
-		if err := pipe.WriteMessage(websocket.BinaryMessage, helpers.ToBtMsgPack(msg)); err != nil {
-			helpers.LogError(err)
-		}
+	eventId, err := rdb().XAdd(ctx, &redis.XAddArgs{
+		Stream: fmt.Sprintf("user:%s:event_log", toUser),
+		MaxLen: eventLogMaxLen,
+		Approx: true,
+		Values: map[string]any{"msg": helpers.ToMsgPack(msg)},
+	}).Result()
+	if err != nil {
+		helpers.LogError(err)
+	}
+
+	msg.EventId = eventId
+
+	if err := rdb().Publish(ctx, fmt.Sprintf("user_%s_server_events", toUser), helpers.ToMsgPack(msg)).Err(); err != nil {
+		helpers.LogError(err)
+	}
+}
+
+// missedEvents returns the events in clientUser's event log after the event, afterEventId.
+//
+// trimmed reports whether the event log no longer holds afterEventId, as it's been trimmed past it,
+// in which case events were missed that can't be replayed, and none are returned
+func missedEvents(ctx context.Context, clientUsername, afterEventId string) (events []appTypes.ServerEventMsg, trimmed bool, err error) {
+	eventLog := fmt.Sprintf("user:%s:event_log", clientUsername)
+
+	oldest, err := rdb().XRangeN(ctx, eventLog, "-", "+", 1).Result()
+	if err != nil {
+		helpers.LogError(err)
+		return nil, false, err
+	}
+
+	if len(oldest) == 0 || eventIdAfter(oldest[0].ID, afterEventId) {
+		return nil, true, nil
+	}
+
+	entries, err := rdb().XRange(ctx, eventLog, "("+afterEventId, "+").Result()
+	if err != nil {
+		helpers.LogError(err)
+		return nil, false, err
+	}
+
+	events = make([]appTypes.ServerEventMsg, len(entries))
+
+	for i, entry := range entries {
+		msg := helpers.FromMsgPack[appTypes.ServerEventMsg](entry.Values["msg"].(string))
+		msg.EventId = entry.ID
 
-		return
+		events[i] = msg
 	}
+
+	return events, false, nil
 }
 
-func AddPipe(ctx context.Context, clientUsername string, pipe *websocket.Conn) {
-	AllClientSockets.Store(clientUsername, pipe)
+// eventIdAfter reports whether the event log id, a, comes after b
+func eventIdAfter(a, b string) bool {
+	aMs, aSeq, _ := strings.Cut(a, "-")
+	bMs, bSeq, _ := strings.Cut(b, "-")
+
+	if aMs != bMs {
+		return helpers.ParseInt(aMs) > helpers.ParseInt(bMs)
+	}
+
+	return helpers.ParseInt(aSeq) > helpers.ParseInt(bSeq)
+}
+
+// a connected socket renews its connection record at this interval.
+// A record not renewed for ConnectionStaleAfter, as the server instance holding the socket is gone,
+// is swept by SweepStaleConnections
+const (
+	connectionHeartbeatInterval = 30 * time.Second
+	ConnectionStaleAfter        = 3 * connectionHeartbeatInterval
+)
+
+// each socket is recorded, by its connection id, in its user's connections, and in all users' connections,
+// scored by when it was last seen
+const allConnectionsKey = "user_connections"
+
+func userConnectionsKey(username string) string {
+	return fmt.Sprintf("user:%s:connections", username)
 }
 
-func RemovePipe(clientUsername string) {
-	AllClientSockets.Delete(clientUsername)
+func staleConnectionsMax() string {
+	return fmt.Sprint(time.Now().Add(-ConnectionStaleAfter).UnixMilli())
+}
+
+// AddPipe registers the socket of clientUser's device, deviceId, under a connection id of its own,
+// so that a device reconnecting, before its previous socket is closed, doesn't displace the new socket.
+// The socket's connection record is renewed until ctx is done.
+//
+// firstDevice reports whether clientUser had no other connected socket
+// on any server instance, which is when clientUser goes online
+func AddPipe(ctx context.Context, clientUser appTypes.ClientUser, deviceId string, conn *websocket.Conn) (pipe *Pipe, firstDevice bool) {
+	clientUsername := clientUser.Username
+
+	pipe = &Pipe{Username: clientUsername, SessionId: clientUser.SessionId, DeviceId: deviceId, ConnId: utils.UUIDv4(), conn: conn}
+
+	var connectionsCount *redis.IntCmd
+
+	_, err := rdb().TxPipelined(ctx, func(rpipe redis.Pipeliner) error {
+		now := float64(time.Now().UnixMilli())
+
+		rpipe.ZRemRangeByScore(ctx, userConnectionsKey(clientUsername), "-inf", staleConnectionsMax())
+		rpipe.ZAdd(ctx, userConnectionsKey(clientUsername), redis.Z{Score: now, Member: pipe.ConnId})
+		rpipe.ZAdd(ctx, allConnectionsKey, redis.Z{Score: now, Member: clientUsername + " " + pipe.ConnId})
+		connectionsCount = rpipe.ZCard(ctx, userConnectionsKey(clientUsername))
+
+		return nil
+	})
+	if err != nil {
+		helpers.LogError(err)
+
+		return pipe, false
+	}
+
+	go pipe.heartbeat(ctx)
+
+	return pipe, connectionsCount.Val() == 1
+}
+
+// heartbeat renews the pipe's connection record, until ctx is done
+func (p *Pipe) heartbeat(ctx context.Context) {
+	ticker := time.NewTicker(connectionHeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// a record already removed, is never renewed back
+			_, err := rdb().Pipelined(ctx, func(rpipe redis.Pipeliner) error {
+				now := float64(time.Now().UnixMilli())
+
+				rpipe.ZAddXX(ctx, userConnectionsKey(p.Username), redis.Z{Score: now, Member: p.ConnId})
+				rpipe.ZAddXX(ctx, allConnectionsKey, redis.Z{Score: now, Member: p.Username + " " + p.ConnId})
+
+				return nil
+			})
+			if err != nil && ctx.Err() == nil {
+				helpers.LogError(err)
+			}
+		}
+	}
+}
+
+// RemovePipe unregisters the socket of clientUser's connection, connId.
+//
+// lastDevice reports whether clientUser has no other connected socket
+// on any server instance, which is when clientUser goes offline
+func RemovePipe(ctx context.Context, clientUsername, connId string) (lastDevice bool) {
+	var connectionsCount *redis.IntCmd
+
+	_, err := rdb().TxPipelined(ctx, func(rpipe redis.Pipeliner) error {
+		rpipe.ZRem(ctx, userConnectionsKey(clientUsername), connId)
+		rpipe.ZRem(ctx, allConnectionsKey, clientUsername+" "+connId)
+		rpipe.ZRemRangeByScore(ctx, userConnectionsKey(clientUsername), "-inf", staleConnectionsMax())
+		connectionsCount = rpipe.ZCard(ctx, userConnectionsKey(clientUsername))
+
+		return nil
+	})
+	if err != nil {
+		helpers.LogError(err)
+
+		return false
+	}
+
+	return connectionsCount.Val() == 0
+}
+
+// SweepStaleConnections removes the connection records no longer renewed, left by server instances that are gone.
+// It returns the users left with no connected socket, who are to go offline
+func SweepStaleConnections(ctx context.Context) (offlineUsers []string) {
+	for {
+		staleConns, err := rdb().ZRangeByScore(ctx, allConnectionsKey, &redis.ZRangeBy{
+			Min:   "-inf",
+			Max:   staleConnectionsMax(),
+			Count: 500,
+		}).Result()
+		if err != nil {
+			helpers.LogError(err)
+			return offlineUsers
+		}
+
+		if len(staleConns) == 0 {
+			return offlineUsers
+		}
+
+		for _, userConn := range staleConns {
+			// whichever server instance removes the record, sweeps it
+			removed, err := rdb().ZRem(ctx, allConnectionsKey, userConn).Result()
+			if err != nil {
+				helpers.LogError(err)
+				continue
+			}
+
+			if removed == 0 {
+				continue
+			}
+
+			var username, connId string
+
+			fmt.Sscanf(userConn, "%s %s", &username, &connId)
+
+			var connectionsCount *redis.IntCmd
+
+			_, err = rdb().TxPipelined(ctx, func(rpipe redis.Pipeliner) error {
+				rpipe.ZRem(ctx, userConnectionsKey(username), connId)
+				rpipe.ZRemRangeByScore(ctx, userConnectionsKey(username), "-inf", staleConnectionsMax())
+				connectionsCount = rpipe.ZCard(ctx, userConnectionsKey(username))
+
+				return nil
+			})
+			if err != nil {
+				helpers.LogError(err)
+				continue
+			}
+
+			if connectionsCount.Val() == 0 {
+				offlineUsers = append(offlineUsers, username)
+			}
+		}
+	}
 }
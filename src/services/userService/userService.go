@@ -5,11 +5,13 @@ import (
 	"fmt"
 	"i9chat/src/appTypes"
 	"i9chat/src/appTypes/UITypes"
+	"i9chat/src/cache"
 	"i9chat/src/helpers"
 	user "i9chat/src/models/userModel"
 	"i9chat/src/services/cloudStorageService"
 	"i9chat/src/services/eventStreamService"
 	"i9chat/src/services/eventStreamService/eventTypes"
+	"i9chat/src/services/imageProcessingService"
 	"i9chat/src/services/realtimeService"
 	"time"
 
@@ -127,6 +129,8 @@ func AuthorizePPicUpload(ctx context.Context, picMIME string) (AuthPPicDataT, er
 			return res, fiber.ErrInternalServerError
 		}
 
+		cloudStorageService.ScheduleMediaDeletion(ctx, pPicCloudName)
+
 		switch small0_medium1_large2 {
 		case 0:
 			res.UploadUrl += "small:"
@@ -151,22 +155,78 @@ func AuthorizePPicUpload(ctx context.Context, picMIME string) (AuthPPicDataT, er
 	return res, nil
 }
 
+// AuthorizeOriginalPPicUpload authorizes the upload of an original image,
+// from which the profile picture's variants are produced by the server
+func AuthorizeOriginalPPicUpload(ctx context.Context, clientUsername, picMIME string) (AuthPPicDataT, error) {
+	var res AuthPPicDataT
+
+	pPicCloudName := fmt.Sprintf("uploads/user/profile_pics/%d%d/%s-original", time.Now().Year(), time.Now().Month(), utils.UUIDv4())
+
+	url, originalCloudName, err := imageProcessingService.AuthorizeOriginalUpload(ctx, clientUsername, "profile_pic", pPicCloudName, picMIME)
+	if err != nil {
+		return res, err
+	}
+
+	res.UploadUrl = url
+	res.PPicCloudName = originalCloudName
+
+	return res, nil
+}
+
 func ChangeProfilePicture(ctx context.Context, clientUsername, profilePicCloudName string) (bool, error) {
-	done, err := user.ChangeProfilePicture(ctx, clientUsername, profilePicCloudName)
+	oldProfilePicCloudName, err := user.ChangeProfilePicture(ctx, clientUsername, profilePicCloudName)
 	if err != nil {
 		return false, err
 	}
 
+	done := oldProfilePicCloudName != ""
+
 	if done {
 		go eventStreamService.QueueEditUserEvent(eventTypes.EditUserEvent{
 			Username:    clientUsername,
 			UpdateKVMap: map[string]any{"profile_pic_url": profilePicCloudName},
 		})
+
+		cloudStorageService.UnscheduleMediaDeletion(ctx, cloudStorageService.PicCloudNames(profilePicCloudName)...)
+		cloudStorageService.ScheduleMediaDeletion(ctx, cloudStorageService.PicCloudNames(oldProfilePicCloudName)...)
 	}
 
 	return done, nil
 }
 
+// ChangeProfilePictureFromOriginal queues the original image for processing.
+// The profile picture is changed once its variants are produced
+func ChangeProfilePictureFromOriginal(ctx context.Context, clientUsername, originalCloudName string) (bool, error) {
+	if err := imageProcessingService.VerifyOriginalUpload(ctx, clientUsername, "profile_pic", originalCloudName); err != nil {
+		return false, err
+	}
+
+	go eventStreamService.QueueImageProcessingJob(eventTypes.ImageProcessingJob{
+		Target:      "profile_pic",
+		OwnerUser:   clientUsername,
+		OriginalMcn: imageProcessingService.OriginalMcn(originalCloudName),
+	})
+
+	return true, nil
+}
+
+// ApplyProcessedProfilePicture changes the user's profile picture to the variants produced from their original,
+// and lets them know
+func ApplyProcessedProfilePicture(ctx context.Context, username, profilePicCloudName string) {
+	done, err := ChangeProfilePicture(ctx, username, profilePicCloudName)
+	if err != nil || !done {
+		imageProcessingService.DeleteProcessed(ctx, profilePicCloudName)
+		return
+	}
+
+	realtimeService.SendEventMsg(username, appTypes.ServerEventMsg{
+		Event: "profile picture processed",
+		Data: map[string]any{
+			"profile_pic_url": cloudStorageService.ProfilePicCloudNameToUrl(profilePicCloudName),
+		},
+	})
+}
+
 func ChangeBio(ctx context.Context, clientUsername, newBio string) (any, error) {
 	done, err := user.ChangeBio(ctx, clientUsername, newBio)
 	if err != nil {
@@ -201,7 +261,16 @@ func SetMyLocation(ctx context.Context, clientUsername string, newGeolocation ap
 	return done, err
 }
 
-func FindUser(ctx context.Context, username string) (UITypes.UserSnippet, error) {
+func FindUser(ctx context.Context, clientUsername, username string) (UITypes.UserSnippet, error) {
+	blocked, err := cache.IsBlockedBetween(ctx, clientUsername, username)
+	if err != nil {
+		return UITypes.UserSnippet{}, fiber.ErrInternalServerError
+	}
+
+	if blocked {
+		return UITypes.UserSnippet{}, nil
+	}
+
 	return user.Find(ctx, username)
 }
 
@@ -213,6 +282,117 @@ func GetMyChats(ctx context.Context, clientUsername string, limit int64, cursor
 	return user.GetMyChats(ctx, clientUsername, limit, cursor)
 }
 
+func SearchMessages(ctx context.Context, clientUsername, query, chatType, chatIdent string, limit, offset int64) ([]UITypes.FoundMessage, error) {
+	return user.SearchMessages(ctx, clientUsername, query, chatType, chatIdent, limit, offset)
+}
+
+// CanAccessMedia reports whether the client can access the media, mcn, through the media proxy.
+//
+// Media sent before the chats it's sent in were recorded, has them found among the client's chats, and recorded
+func CanAccessMedia(ctx context.Context, clientUsername, mcn string) (bool, error) {
+	allowed, err := cloudStorageService.CanAccessMedia(ctx, clientUsername, mcn)
+	if err != nil || allowed {
+		return allowed, err
+	}
+
+	recorded, err := cloudStorageService.MediaChatsRecorded(ctx, mcn)
+	if err != nil || recorded {
+		return false, err
+	}
+
+	mediaChats, err := user.FindMediaChats(ctx, clientUsername, mcn)
+	if err != nil || len(mediaChats) == 0 {
+		return false, err
+	}
+
+	for _, mc := range mediaChats {
+		mediaChat := cloudStorageService.GroupMediaChat(mc.ChatIdent)
+		if mc.ChatType == "direct" {
+			mediaChat = cloudStorageService.DirectMediaChat(clientUsername, mc.ChatIdent)
+		}
+
+		if err := cloudStorageService.RecordMediaChat(ctx, mediaChat, mcn); err != nil {
+			return false, err
+		}
+	}
+
+	return cloudStorageService.CanAccessMedia(ctx, clientUsername, mcn)
+}
+
+func StarMessage(ctx context.Context, clientUsername, msgId string) (bool, error) {
+	at := time.Now().UTC().UnixMilli()
+
+	msgChat, err := user.StarMessage(ctx, clientUsername, msgId, at)
+	if err != nil {
+		return false, err
+	}
+
+	if msgChat.ChatType == "" {
+		return false, nil
+	}
+
+	if err := cache.StoreUserStarredMessage(ctx, clientUsername, msgId, helpers.ToMsgPack(msgChat), msgChat.StarredAt); err != nil {
+		return false, fiber.ErrInternalServerError
+	}
+
+	return true, nil
+}
+
+func UnstarMessage(ctx context.Context, clientUsername, msgId string) (bool, error) {
+	done, err := user.UnstarMessage(ctx, clientUsername, msgId)
+	if err != nil {
+		return false, err
+	}
+
+	if done {
+		if err := cache.RemoveUserStarredMessage(ctx, clientUsername, msgId); err != nil {
+			return false, fiber.ErrInternalServerError
+		}
+	}
+
+	return done, nil
+}
+
+func GetStarredMessages(ctx context.Context, clientUsername string, limit int64, cursor float64) ([]UITypes.StarredMessage, error) {
+	return user.GetStarredMessages(ctx, clientUsername, limit, cursor)
+}
+
+func BlockUser(ctx context.Context, clientUsername, targetUsername string) (bool, error) {
+	at := time.Now().UTC().UnixMilli()
+
+	done, err := user.BlockUser(ctx, clientUsername, targetUsername, at)
+	if err != nil {
+		return false, err
+	}
+
+	if done {
+		if err := cache.StoreUserBlockedUser(ctx, clientUsername, targetUsername, at); err != nil {
+			return false, fiber.ErrInternalServerError
+		}
+	}
+
+	return done, nil
+}
+
+func UnblockUser(ctx context.Context, clientUsername, targetUsername string) (bool, error) {
+	done, err := user.UnblockUser(ctx, clientUsername, targetUsername)
+	if err != nil {
+		return false, err
+	}
+
+	if done {
+		if err := cache.RemoveUserBlockedUser(ctx, clientUsername, targetUsername); err != nil {
+			return false, fiber.ErrInternalServerError
+		}
+	}
+
+	return done, nil
+}
+
+func GetBlockedUsers(ctx context.Context, clientUsername string, limit int64, cursor float64) ([]UITypes.BlockedUserSnippet, error) {
+	return user.GetBlockedUsers(ctx, clientUsername, limit, cursor)
+}
+
 func GetMyProfile(ctx context.Context, clientUsername string) (UITypes.UserProfile, error) {
 	return user.GetMyProfile(ctx, clientUsername)
 }
@@ -146,6 +146,8 @@ func TestUserAuth(t *testing.T) {
 		user1.SessionCookie = res.Header.Get("Set-Cookie")
 	}
 
+	signedOutSessionCookie := user1.SessionCookie
+
 	{
 		t.Log("Action: user1 signs out")
 
@@ -168,6 +170,28 @@ func TestUserAuth(t *testing.T) {
 		require.Equal("You've been logged out!", rb)
 	}
 
+	{
+		t.Log("Action: user1 reuses the signed out session | it is rejected, as its auth jwt is revoked")
+
+		req := httptest.NewRequest("GET", userPath+"/session_user", nil)
+		req.Header.Set("Cookie", signedOutSessionCookie)
+
+		res, err := app.Test(req)
+		require.NoError(err)
+
+		if !assert.Equal(t, http.StatusUnauthorized, res.StatusCode) {
+			rb, err := errResBody(res.Body)
+			require.NoError(err)
+			t.Log("unexpected error:", rb)
+			return
+		}
+
+		rb, err := errResBody(res.Body)
+		require.NoError(err)
+
+		require.Equal("session revoked or expired; sign in again", rb)
+	}
+
 	{
 		t.Log("Action: user1 signs in with incorrect credentials")
 
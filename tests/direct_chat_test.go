@@ -1,11 +1,17 @@
 package tests
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
-	"i9chat/src/appGlobals"
+	"i9chat/src/appErrors/userErrors"
+	"i9chat/src/models/db"
+	"i9chat/src/services/cloudStorageService"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
+	"slices"
 	"testing"
 	"time"
 
@@ -406,7 +412,7 @@ func TestDirectChat(t *testing.T) {
 				require.NoError(err)
 
 				for _, baMcn := range varMediaCloudName {
-					err := appGlobals.GCSClient.Bucket(os.Getenv("GCS_BUCKET_NAME")).Object(baMcn).Delete(t.Context())
+					err := cloudStorageService.Backend().Delete(t.Context(), baMcn)
 					require.NoError(err)
 				}
 			}(mediaCloudName)
@@ -443,6 +449,33 @@ func TestDirectChat(t *testing.T) {
 		}, nil))
 
 		user2NewMsgId = user2ServerReply["data"].(map[string]any)["new_msg_id"].(string)
+
+		t.Log("Action: user2 sends another message with the same, already finalized, media | rejected")
+
+		err = wsWriteMsgPack(user2.WSConn, map[string]any{
+			"action": "direct chat: send message",
+			"data": map[string]any{
+				"partnerUsername": user1.Username,
+				"msg": map[string]any{
+					"type": "photo",
+					"props": map[string]any{
+						"media_cloud_name": mediaCloudName,
+						"caption":          "Again!",
+					},
+				},
+				"at": time.Now().UTC().UnixMilli(),
+			},
+		})
+		require.NoError(err)
+
+		td.Cmp(td.Require(t), <-user2.ServerEventMsg, td.Map(map[string]any{
+			"event":    "server error",
+			"toAction": "direct chat: send message",
+			"data": td.Map(map[string]any{
+				"statusCode": td.Lax(http.StatusBadRequest),
+				"errorMsg":   "blur_placeholder media: no authorized upload found for this media",
+			}, nil),
+		}, nil))
 	}
 
 	{
@@ -591,4 +624,908 @@ func TestDirectChat(t *testing.T) {
 			),
 		)
 	}
+
+	{
+		t.Log("Action: user1 edits his message to user2 | user2 is notified")
+
+		err := wsWriteMsgPack(user1.WSConn, map[string]any{
+			"action": "direct chat: edit message",
+			"data": map[string]any{
+				"partnerUsername": user2.Username,
+				"msgId":           user1NewMsgId,
+				"newText":         "Hi. How are you doing?",
+				"at":              time.Now().UTC().UnixMilli(),
+			},
+		})
+		require.NoError(err)
+
+		user1ServerReply := <-user1.ServerEventMsg
+
+		td.Cmp(td.Require(t), user1ServerReply, td.Map(map[string]any{
+			"event":    "server reply",
+			"toAction": "direct chat: edit message",
+			"data": td.Map(map[string]any{
+				"edited_at": td.NotZero(),
+			}, nil),
+		}, nil))
+
+		user2MsgEdited := <-user2.ServerEventMsg
+
+		td.Cmp(td.Require(t), user2MsgEdited, td.SuperMapOf(map[string]any{
+			"event": "direct chat: message edited",
+			"data": td.Map(map[string]any{
+				"chat_partner": user1.Username,
+				"msg_id":       user1NewMsgId,
+				"content": td.SuperMapOf(map[string]any{
+					"type": "text",
+					"props": td.SuperMapOf(map[string]any{
+						"text_content": "Hi. How are you doing?",
+					}, nil),
+				}, nil),
+				"edited_at": td.Lax(user1ServerReply["data"].(map[string]any)["edited_at"]),
+			}, nil),
+		}, nil))
+	}
+
+	{
+		t.Log("Action: user2 edits user1's message | the message isn't edited")
+
+		err := wsWriteMsgPack(user2.WSConn, map[string]any{
+			"action": "direct chat: edit message",
+			"data": map[string]any{
+				"partnerUsername": user1.Username,
+				"msgId":           user1NewMsgId,
+				"newText":         "Hi. How do you do?",
+				"at":              time.Now().UTC().UnixMilli(),
+			},
+		})
+		require.NoError(err)
+
+		user2ServerReply := <-user2.ServerEventMsg
+
+		td.Cmp(td.Require(t), user2ServerReply, td.Map(map[string]any{
+			"event":    "server reply",
+			"toAction": "direct chat: edit message",
+			"data":     nil,
+		}, nil))
+	}
+
+	{
+		t.Log("Action: user1 edits his message after the edit window | the message isn't edited")
+
+		t.Setenv("MSG_EDIT_WINDOW", "1ms")
+
+		<-(time.NewTimer(10 * time.Millisecond).C)
+
+		err := wsWriteMsgPack(user1.WSConn, map[string]any{
+			"action": "direct chat: edit message",
+			"data": map[string]any{
+				"partnerUsername": user2.Username,
+				"msgId":           user1NewMsgId,
+				"newText":         "Hi. How do you do?",
+				"at":              time.Now().UTC().UnixMilli(),
+			},
+		})
+		require.NoError(err)
+
+		user1ServerReply := <-user1.ServerEventMsg
+
+		td.Cmp(td.Require(t), user1ServerReply, td.Map(map[string]any{
+			"event":    "server reply",
+			"toAction": "direct chat: edit message",
+			"data":     nil,
+		}, nil))
+	}
+
+	{
+		t.Log("Check: the message's edit history keeps its replaced content")
+
+		res, err := db.Query(context.Background(),
+			`MATCH (message:DirectMessage{ id: $message_id }) RETURN message.edit_history AS edit_history`,
+			map[string]any{"message_id": user1NewMsgId},
+		)
+		require.NoError(err)
+		require.Len(res.Records, 1)
+
+		editHistory, _ := res.Records[0].Get("edit_history")
+
+		td.Cmp(td.Require(t), editHistory, td.Len(1))
+
+		var editRecord map[string]any
+
+		require.NoError(json.Unmarshal([]byte(editHistory.([]any)[0].(string)), &editRecord))
+
+		td.Cmp(td.Require(t), editRecord, td.Map(map[string]any{
+			"content": td.SuperMapOf(map[string]any{
+				"type": "text",
+				"props": td.SuperMapOf(map[string]any{
+					"text_content": "Hi. How're you doing?",
+				}, nil),
+			}, nil),
+			"replaced_at": td.NotZero(),
+		}, nil))
+	}
+
+	{
+		<-(time.NewTimer(100 * time.Millisecond).C)
+
+		t.Log("Action: user2 opens the chat history with user1 | the message is edited")
+
+		req := httptest.NewRequest("GET", directChatPath+"/"+user1.Username+"/history", nil)
+		req.Header.Add("Content-Type", "application/vnd.msgpack")
+		req.Header.Set("Cookie", user2.SessionCookie)
+
+		res, err := app.Test(req)
+		require.NoError(err)
+
+		if !assert.Equal(t, http.StatusOK, res.StatusCode) {
+			rb, err := errResBody(res.Body)
+			require.NoError(err)
+			t.Log("unexpected error:", rb)
+			return
+		}
+
+		rb, err := succResBody[[]map[string]any](res.Body)
+		require.NoError(err)
+
+		td.Cmp(td.Require(t), rb, td.Contains(td.SuperMapOf(map[string]any{
+			"id": user1NewMsgId,
+			"content": td.SuperMapOf(map[string]any{
+				"type": "text",
+				"props": td.SuperMapOf(map[string]any{
+					"text_content": "Hi. How are you doing?",
+				}, nil),
+			}, nil),
+			"delivery_status": "read",
+			"edited_at":       td.NotZero(),
+		}, nil)))
+	}
+
+	user1SecondMsgId := ""
+
+	{
+		t.Log("Action: user1 sends another message to user2 | user2 receives the message")
+
+		err := wsWriteMsgPack(user1.WSConn, map[string]any{
+			"action": "direct chat: send message",
+			"data": map[string]any{
+				"partnerUsername": user2.Username,
+				"msg": map[string]any{
+					"type": "text",
+					"props": map[string]any{
+						"text_content": "Are you there?",
+					},
+				},
+				"at": time.Now().UTC().UnixMilli(),
+			},
+		})
+		require.NoError(err)
+
+		user1ServerReply := <-user1.ServerEventMsg
+
+		td.Cmp(td.Require(t), user1ServerReply, td.Map(map[string]any{
+			"event":    "server reply",
+			"toAction": "direct chat: send message",
+			"data": td.Map(map[string]any{
+				"new_msg_id": td.Ignore(),
+				"che_cursor": td.Ignore(),
+			}, nil),
+		}, nil))
+
+		user1SecondMsgId = user1ServerReply["data"].(map[string]any)["new_msg_id"].(string)
+
+		user2NewMsgReceived := <-user2.ServerEventMsg
+
+		td.Cmp(td.Require(t), user2NewMsgReceived, td.Map(map[string]any{
+			"event": "direct chat: new che: message",
+			"data": td.SuperMapOf(map[string]any{
+				"id": user1SecondMsgId,
+			}, nil),
+		}, nil))
+	}
+
+	{
+		t.Log("Action: user1 deletes the message for everyone, right away | user2 is notified")
+
+		err := wsWriteMsgPack(user1.WSConn, map[string]any{
+			"action": "direct chat: delete message for everyone",
+			"data": map[string]any{
+				"partnerUsername": user2.Username,
+				"msgId":           user1SecondMsgId,
+				"at":              time.Now().UTC().UnixMilli(),
+			},
+		})
+		require.NoError(err)
+
+		user1ServerReply := <-user1.ServerEventMsg
+
+		td.Cmp(td.Require(t), user1ServerReply, td.Map(map[string]any{
+			"event":    "server reply",
+			"toAction": "direct chat: delete message for everyone",
+			"data": td.Map(map[string]any{
+				"deleted_at": td.NotZero(),
+			}, nil),
+		}, nil))
+
+		user2MsgDeleted := <-user2.ServerEventMsg
+
+		td.Cmp(td.Require(t), user2MsgDeleted, td.SuperMapOf(map[string]any{
+			"event": "direct chat: message deleted",
+			"data": td.Map(map[string]any{
+				"chat_partner": user1.Username,
+				"msg_id":       user1SecondMsgId,
+				"deleted_at":   td.Lax(user1ServerReply["data"].(map[string]any)["deleted_at"]),
+			}, nil),
+		}, nil))
+	}
+
+	{
+		t.Log("Action: user2 deletes user1's message for everyone | the message isn't deleted")
+
+		err := wsWriteMsgPack(user2.WSConn, map[string]any{
+			"action": "direct chat: delete message for everyone",
+			"data": map[string]any{
+				"partnerUsername": user1.Username,
+				"msgId":           user1NewMsgId,
+				"at":              time.Now().UTC().UnixMilli(),
+			},
+		})
+		require.NoError(err)
+
+		user2ServerReply := <-user2.ServerEventMsg
+
+		td.Cmp(td.Require(t), user2ServerReply, td.Map(map[string]any{
+			"event":    "server reply",
+			"toAction": "direct chat: delete message for everyone",
+			"data":     nil,
+		}, nil))
+	}
+
+	{
+		t.Log("Action: user1 deletes his message for everyone after the delete window | the message isn't deleted")
+
+		t.Setenv("MSG_DELETE_WINDOW", "1ms")
+
+		<-(time.NewTimer(10 * time.Millisecond).C)
+
+		err := wsWriteMsgPack(user1.WSConn, map[string]any{
+			"action": "direct chat: delete message for everyone",
+			"data": map[string]any{
+				"partnerUsername": user2.Username,
+				"msgId":           user1NewMsgId,
+				"at":              time.Now().UTC().UnixMilli(),
+			},
+		})
+		require.NoError(err)
+
+		user1ServerReply := <-user1.ServerEventMsg
+
+		td.Cmp(td.Require(t), user1ServerReply, td.Map(map[string]any{
+			"event":    "server reply",
+			"toAction": "direct chat: delete message for everyone",
+			"data":     nil,
+		}, nil))
+	}
+
+	{
+		t.Log("Action: user2 deletes user1's message for user2 only")
+
+		err := wsWriteMsgPack(user2.WSConn, map[string]any{
+			"action": "direct chat: delete messages for me",
+			"data": map[string]any{
+				"partnerUsername": user1.Username,
+				"msgIds":          []string{user1NewMsgId},
+			},
+		})
+		require.NoError(err)
+
+		user2ServerReply := <-user2.ServerEventMsg
+
+		td.Cmp(td.Require(t), user2ServerReply, td.Map(map[string]any{
+			"event":    "server reply",
+			"toAction": "direct chat: delete messages for me",
+			"data":     true,
+		}, nil))
+	}
+
+	{
+		<-(time.NewTimer(100 * time.Millisecond).C)
+
+		t.Log("Action: user2 opens the chat history with user1 | the message deleted for everyone is a tombstone, the one deleted for user2 is gone")
+
+		req := httptest.NewRequest("GET", directChatPath+"/"+user1.Username+"/history", nil)
+		req.Header.Add("Content-Type", "application/vnd.msgpack")
+		req.Header.Set("Cookie", user2.SessionCookie)
+
+		res, err := app.Test(req)
+		require.NoError(err)
+
+		if !assert.Equal(t, http.StatusOK, res.StatusCode) {
+			rb, err := errResBody(res.Body)
+			require.NoError(err)
+			t.Log("unexpected error:", rb)
+			return
+		}
+
+		rb, err := succResBody[[]map[string]any](res.Body)
+		require.NoError(err)
+
+		td.Cmp(td.Require(t), rb,
+			td.All(
+				td.Contains(td.SuperMapOf(map[string]any{
+					"id": user1SecondMsgId,
+					"content": td.SuperMapOf(map[string]any{
+						"type": "deleted",
+					}, nil),
+					"deleted_at": td.NotZero(),
+				}, nil)),
+				td.Not(td.Contains(td.SuperMapOf(map[string]any{
+					"id": user1NewMsgId,
+				}, nil))),
+			),
+		)
+	}
+
+	{
+		t.Log("Action: user1 opens his chat history with user2 | the message deleted for user2 is kept for user1")
+
+		req := httptest.NewRequest("GET", directChatPath+"/"+user2.Username+"/history", nil)
+		req.Header.Add("Content-Type", "application/vnd.msgpack")
+		req.Header.Set("Cookie", user1.SessionCookie)
+
+		res, err := app.Test(req)
+		require.NoError(err)
+
+		if !assert.Equal(t, http.StatusOK, res.StatusCode) {
+			rb, err := errResBody(res.Body)
+			require.NoError(err)
+			t.Log("unexpected error:", rb)
+			return
+		}
+
+		rb, err := succResBody[[]map[string]any](res.Body)
+		require.NoError(err)
+
+		td.Cmp(td.Require(t), rb,
+			td.All(
+				td.Contains(td.SuperMapOf(map[string]any{
+					"id": user1SecondMsgId,
+					"content": td.SuperMapOf(map[string]any{
+						"type": "deleted",
+					}, nil),
+					"deleted_at": td.NotZero(),
+				}, nil)),
+				td.Contains(td.SuperMapOf(map[string]any{
+					"id": user1NewMsgId,
+					"content": td.SuperMapOf(map[string]any{
+						"props": td.SuperMapOf(map[string]any{
+							"text_content": "Hi. How are you doing?",
+						}, nil),
+					}, nil),
+				}, nil)),
+			),
+		)
+	}
+
+	{
+		t.Log("Action: user2 blocks user1")
+
+		reqBody, err := makeReqBody(map[string]any{"username": user1.Username})
+		require.NoError(err)
+
+		req := httptest.NewRequest("POST", userPath+"/block_user", reqBody)
+		req.Header.Set("Cookie", user2.SessionCookie)
+		req.Header.Add("Content-Type", "application/vnd.msgpack")
+
+		res, err := app.Test(req)
+		require.NoError(err)
+
+		if !assert.Equal(t, http.StatusOK, res.StatusCode) {
+			rb, err := errResBody(res.Body)
+			require.NoError(err)
+			t.Log("unexpected error:", rb)
+			return
+		}
+
+		rb, err := succResBody[bool](res.Body)
+		require.NoError(err)
+		require.True(rb)
+	}
+
+	{
+		t.Log("Action: user1 sends, reacts to, and edits messages, and types, in the chat with user2, who blocked user1 | each action is rejected")
+
+		for _, action := range []map[string]any{
+			{
+				"action": "direct chat: send message",
+				"data": map[string]any{
+					"partnerUsername": user2.Username,
+					"msg": map[string]any{
+						"type": "text",
+						"props": map[string]any{
+							"text_content": "Why won't you answer?",
+						},
+					},
+					"at": time.Now().UTC().UnixMilli(),
+				},
+			},
+			{
+				"action": "direct chat: react to message",
+				"data": map[string]any{
+					"partnerUsername": user2.Username,
+					"msgId":           user2NewMsgId,
+					"emoji":           "🤨",
+					"at":              time.Now().UTC().UnixMilli(),
+				},
+			},
+			{
+				"action": "direct chat: edit message",
+				"data": map[string]any{
+					"partnerUsername": user2.Username,
+					"msgId":           user1NewMsgId,
+					"newText":         "Hi. Why won't you answer?",
+					"at":              time.Now().UTC().UnixMilli(),
+				},
+			},
+			{
+				"action": "direct chat: typing",
+				"data": map[string]any{
+					"partnerUsername": user2.Username,
+					"state":           "typing",
+				},
+			},
+		} {
+			err := wsWriteMsgPack(user1.WSConn, action)
+			require.NoError(err)
+
+			user1ServerReply := <-user1.ServerEventMsg
+
+			td.Cmp(td.Require(t), user1ServerReply, td.Map(map[string]any{
+				"event":    "server error",
+				"toAction": action["action"],
+				"data": td.Map(map[string]any{
+					"statusCode": td.Lax(http.StatusForbidden),
+					"errorMsg":   userErrors.BlockedUser,
+				}, nil),
+			}, nil))
+		}
+	}
+
+	{
+		t.Log("Action: user2 types in the chat with user1, whom user2 blocked | the action is rejected")
+
+		err := wsWriteMsgPack(user2.WSConn, map[string]any{
+			"action": "direct chat: typing",
+			"data": map[string]any{
+				"partnerUsername": user1.Username,
+				"state":           "typing",
+			},
+		})
+		require.NoError(err)
+
+		user2ServerReply := <-user2.ServerEventMsg
+
+		td.Cmp(td.Require(t), user2ServerReply, td.Map(map[string]any{
+			"event":    "server error",
+			"toAction": "direct chat: typing",
+			"data": td.Map(map[string]any{
+				"statusCode": td.Lax(http.StatusForbidden),
+				"errorMsg":   userErrors.BlockedUser,
+			}, nil),
+		}, nil))
+	}
+
+	{
+		t.Log("Action: user2 unblocks user1")
+
+		reqBody, err := makeReqBody(map[string]any{"username": user1.Username})
+		require.NoError(err)
+
+		req := httptest.NewRequest("POST", userPath+"/unblock_user", reqBody)
+		req.Header.Set("Cookie", user2.SessionCookie)
+		req.Header.Add("Content-Type", "application/vnd.msgpack")
+
+		res, err := app.Test(req)
+		require.NoError(err)
+
+		if !assert.Equal(t, http.StatusOK, res.StatusCode) {
+			rb, err := errResBody(res.Body)
+			require.NoError(err)
+			t.Log("unexpected error:", rb)
+			return
+		}
+
+		rb, err := succResBody[bool](res.Body)
+		require.NoError(err)
+		require.True(rb)
+	}
+
+	{
+		t.Log("Action: user1 sends message to user2, after the unblock | user2 receives the message")
+
+		err := wsWriteMsgPack(user1.WSConn, map[string]any{
+			"action": "direct chat: send message",
+			"data": map[string]any{
+				"partnerUsername": user2.Username,
+				"msg": map[string]any{
+					"type": "text",
+					"props": map[string]any{
+						"text_content": "Thanks for unblocking me",
+					},
+				},
+				"at": time.Now().UTC().UnixMilli(),
+			},
+		})
+		require.NoError(err)
+
+		user1ServerReply := <-user1.ServerEventMsg
+
+		td.Cmp(td.Require(t), user1ServerReply, td.Map(map[string]any{
+			"event":    "server reply",
+			"toAction": "direct chat: send message",
+			"data": td.Map(map[string]any{
+				"new_msg_id": td.Ignore(),
+				"che_cursor": td.Ignore(),
+			}, nil),
+		}, nil))
+
+		user2NewMsgReceived := <-user2.ServerEventMsg
+
+		td.Cmp(td.Require(t), user2NewMsgReceived, td.Map(map[string]any{
+			"event": "direct chat: new che: message",
+			"data": td.SuperMapOf(map[string]any{
+				"id": user1ServerReply["data"].(map[string]any)["new_msg_id"],
+			}, nil),
+		}, nil))
+	}
+
+	{
+		t.Log("Action: user1 and user2 search their messages | each finds the matching messages of their chats, except those deleted for everyone, or for the searcher")
+
+		foundMsg := func(partnerUsername string, message map[string]any) td.TestDeep {
+			return td.SuperMapOf(map[string]any{
+				"chat_type":  "direct",
+				"chat_ident": partnerUsername,
+				"message": td.SuperMapOf(message, td.MapEntries{
+					"cursor": td.NotZero(),
+				}),
+			}, nil)
+		}
+
+		searches := []struct {
+			desc   string
+			cookie string
+			query  url.Values
+			expect td.TestDeep
+		}{
+			{
+				desc:   "user1 searches the words of the message user1 edited | it's found by its edited text",
+				cookie: user1.SessionCookie,
+				query:  url.Values{"q": {"how doing"}},
+				expect: td.All(td.Len(1), td.Contains(foundMsg(user2.Username, map[string]any{
+					"id": user1NewMsgId,
+					"content": td.SuperMapOf(map[string]any{
+						"props": td.SuperMapOf(map[string]any{
+							"text_content": "Hi. How are you doing?",
+						}, nil),
+					}, nil),
+				}))),
+			},
+			{
+				desc:   "user2 searches the same words | the message, deleted for user2, isn't found",
+				cookie: user2.SessionCookie,
+				query:  url.Values{"q": {"how doing"}},
+				expect: td.Empty(),
+			},
+			{
+				desc:   "user1 searches the message deleted for everyone | it isn't found",
+				cookie: user1.SessionCookie,
+				query:  url.Values{"q": {"are you there"}},
+				expect: td.Empty(),
+			},
+			{
+				desc:   "user2 searches an incomplete last word | the message is found by the word's prefix",
+				cookie: user2.SessionCookie,
+				query:  url.Values{"q": {"thanks unbl"}},
+				expect: td.All(td.Len(1), td.Contains(foundMsg(user1.Username, map[string]any{
+					"content": td.SuperMapOf(map[string]any{
+						"props": td.SuperMapOf(map[string]any{
+							"text_content": "Thanks for unblocking me",
+						}, nil),
+					}, nil),
+				}))),
+			},
+			{
+				desc:   "user1 searches a photo's caption, in the chat with user2 | the photo message is found",
+				cookie: user1.SessionCookie,
+				query:  url.Values{"q": {"guuud"}, "chat_type": {"direct"}, "chat_ident": {user2.Username}},
+				expect: td.All(td.Len(1), td.Contains(foundMsg(user2.Username, map[string]any{
+					"id": user2NewMsgId,
+				}))),
+			},
+			{
+				desc:   "user1 searches the same caption, in another chat | nothing is found",
+				cookie: user1.SessionCookie,
+				query:  url.Values{"q": {"guuud"}, "chat_type": {"direct"}, "chat_ident": {"harveyspecter"}},
+				expect: td.Empty(),
+			},
+		}
+
+		for _, search := range searches {
+			t.Log(search.desc)
+
+			req := httptest.NewRequest("GET", userPath+"/search_messages?"+search.query.Encode(), nil)
+			req.Header.Set("Cookie", search.cookie)
+
+			res, err := app.Test(req)
+			require.NoError(err)
+
+			if !assert.Equal(t, http.StatusOK, res.StatusCode) {
+				rb, err := errResBody(res.Body)
+				require.NoError(err)
+				t.Log("unexpected error:", rb)
+				return
+			}
+
+			rb, err := succResBody[[]map[string]any](res.Body)
+			require.NoError(err)
+
+			td.Cmp(td.Require(t), rb, search.expect)
+		}
+	}
+
+	{
+		<-(time.NewTimer(100 * time.Millisecond).C)
+
+		t.Log("Action: user1 reads windows of the chat history with user2, before, after, and around a message | each window holds the entries next to the position")
+
+		readHistory := func(query url.Values) *http.Response {
+			req := httptest.NewRequest("GET", directChatPath+"/"+user2.Username+"/history?"+query.Encode(), nil)
+			req.Header.Set("Cookie", user1.SessionCookie)
+
+			res, err := app.Test(req)
+			require.NoError(err)
+
+			return res
+		}
+
+		historyIds := func(query url.Values) []any {
+			res := readHistory(query)
+
+			if !assert.Equal(t, http.StatusOK, res.StatusCode) {
+				rb, err := errResBody(res.Body)
+				require.NoError(err)
+				t.Log("unexpected error:", rb)
+				t.FailNow()
+			}
+
+			rb, err := succResBody[[]map[string]any](res.Body)
+			require.NoError(err)
+
+			ids := make([]any, len(rb))
+			for i, che := range rb {
+				ids[i] = che["id"]
+			}
+
+			return ids
+		}
+
+		// the whole history, latest first
+		history := historyIds(url.Values{"limit": {"50"}})
+
+		require.GreaterOrEqual(len(history), 4)
+
+		photoMsgIndex := slices.Index(history, any(user2NewMsgId))
+		firstMsgIndex := slices.Index(history, any(user1NewMsgId))
+		tombstoneIndex := slices.Index(history, any(user1SecondMsgId))
+
+		require.Equal(len(history)-1, firstMsgIndex)
+		require.Equal(firstMsgIndex-1, photoMsgIndex)
+		require.Less(tombstoneIndex, photoMsgIndex)
+
+		t.Log("around the photo message, with an odd limit | the message, with one older and one newer entry")
+
+		td.Cmp(td.Require(t), historyIds(url.Values{"mode": {"around"}, "msg_id": {user2NewMsgId}, "limit": {"3"}}), history[photoMsgIndex-1:photoMsgIndex+2])
+
+		t.Log("around the photo message, with a limit of one | the message alone")
+
+		td.Cmp(td.Require(t), historyIds(url.Values{"mode": {"around"}, "msg_id": {user2NewMsgId}, "limit": {"1"}}), []any{user2NewMsgId})
+
+		t.Log("around the first message | no older entry is before it")
+
+		td.Cmp(td.Require(t), historyIds(url.Values{"mode": {"around"}, "msg_id": {user1NewMsgId}, "limit": {"4"}}), history[firstMsgIndex-2:])
+
+		t.Log("after the first message | the entries next newer than it, latest first")
+
+		td.Cmp(td.Require(t), historyIds(url.Values{"mode": {"after"}, "msg_id": {user1NewMsgId}, "limit": {"2"}}), history[firstMsgIndex-2:firstMsgIndex])
+
+		t.Log("before the message deleted for everyone | the entries next older than it")
+
+		td.Cmp(td.Require(t), historyIds(url.Values{"mode": {"before"}, "msg_id": {user1SecondMsgId}, "limit": {"2"}}), history[tombstoneIndex+1:tombstoneIndex+3])
+
+		t.Log("after the latest entry | nothing newer")
+
+		td.Cmp(td.Require(t), historyIds(url.Values{"mode": {"after"}, "msg_id": {history[0].(string)}, "limit": {"10"}}), td.Empty())
+
+		t.Log("around a message not in the chat | not found")
+
+		res := readHistory(url.Values{"mode": {"around"}, "msg_id": {"00000000-0000-4000-8000-000000000000"}})
+
+		require.Equal(http.StatusNotFound, res.StatusCode)
+	}
+
+	pinMsgIds := make([]string, 6)
+
+	{
+		t.Log("Action: user1 sends six messages to user2, to pin | user2 receives the messages")
+
+		for i := range pinMsgIds {
+			err := wsWriteMsgPack(user1.WSConn, map[string]any{
+				"action": "direct chat: send message",
+				"data": map[string]any{
+					"partnerUsername": user2.Username,
+					"msg": map[string]any{
+						"type": "text",
+						"props": map[string]any{
+							"text_content": fmt.Sprintf("Pin me, %d", i+1),
+						},
+					},
+					"at": time.Now().UTC().UnixMilli(),
+				},
+			})
+			require.NoError(err)
+
+			user1ServerReply := <-user1.ServerEventMsg
+
+			td.Cmp(td.Require(t), user1ServerReply, td.Map(map[string]any{
+				"event":    "server reply",
+				"toAction": "direct chat: send message",
+				"data": td.Map(map[string]any{
+					"new_msg_id": td.Ignore(),
+					"che_cursor": td.Ignore(),
+				}, nil),
+			}, nil))
+
+			pinMsgIds[i] = user1ServerReply["data"].(map[string]any)["new_msg_id"].(string)
+
+			td.Cmp(td.Require(t), <-user2.ServerEventMsg, td.Map(map[string]any{
+				"event": "direct chat: new che: message",
+				"data": td.SuperMapOf(map[string]any{
+					"id": pinMsgIds[i],
+				}, nil),
+			}, nil))
+		}
+	}
+
+	{
+		t.Log("Action: user1 pins five messages, the limit | user2 is notified of each")
+
+		for _, msgId := range pinMsgIds[:5] {
+			err := wsWriteMsgPack(user1.WSConn, map[string]any{
+				"action": "direct chat: pin message",
+				"data": map[string]any{
+					"partnerUsername": user2.Username,
+					"msgId":           msgId,
+				},
+			})
+			require.NoError(err)
+
+			td.Cmp(td.Require(t), <-user1.ServerEventMsg, td.Map(map[string]any{
+				"event":    "server reply",
+				"toAction": "direct chat: pin message",
+				"data":     true,
+			}, nil))
+
+			td.Cmp(td.Require(t), <-user2.ServerEventMsg, td.Map(map[string]any{
+				"event": "direct chat: message pinned",
+				"data": td.Map(map[string]any{
+					"chat_partner": user1.Username,
+					"msg_id":       msgId,
+					"pinned_at":    td.NotZero(),
+				}, nil),
+			}, nil))
+		}
+	}
+
+	{
+		t.Log("Action: user1 pins a sixth message | the message isn't pinned")
+
+		err := wsWriteMsgPack(user1.WSConn, map[string]any{
+			"action": "direct chat: pin message",
+			"data": map[string]any{
+				"partnerUsername": user2.Username,
+				"msgId":           pinMsgIds[5],
+			},
+		})
+		require.NoError(err)
+
+		td.Cmp(td.Require(t), <-user1.ServerEventMsg, td.Map(map[string]any{
+			"event":    "server error",
+			"toAction": "direct chat: pin message",
+			"data": td.Map(map[string]any{
+				"statusCode": td.Lax(http.StatusBadRequest),
+				"errorMsg":   "you can only pin up to 5 messages in a chat; unpin one first",
+			}, nil),
+		}, nil))
+	}
+
+	{
+		t.Log("Action: user2 unpins the first pinned message | user1 is notified")
+
+		err := wsWriteMsgPack(user2.WSConn, map[string]any{
+			"action": "direct chat: unpin message",
+			"data": map[string]any{
+				"partnerUsername": user1.Username,
+				"msgId":           pinMsgIds[0],
+			},
+		})
+		require.NoError(err)
+
+		td.Cmp(td.Require(t), <-user2.ServerEventMsg, td.Map(map[string]any{
+			"event":    "server reply",
+			"toAction": "direct chat: unpin message",
+			"data":     true,
+		}, nil))
+
+		td.Cmp(td.Require(t), <-user1.ServerEventMsg, td.Map(map[string]any{
+			"event": "direct chat: message unpinned",
+			"data": td.Map(map[string]any{
+				"chat_partner": user2.Username,
+				"msg_id":       pinMsgIds[0],
+			}, nil),
+		}, nil))
+	}
+
+	{
+		t.Log("Action: user1 pins the sixth message, now below the limit | user2 is notified")
+
+		err := wsWriteMsgPack(user1.WSConn, map[string]any{
+			"action": "direct chat: pin message",
+			"data": map[string]any{
+				"partnerUsername": user2.Username,
+				"msgId":           pinMsgIds[5],
+			},
+		})
+		require.NoError(err)
+
+		td.Cmp(td.Require(t), <-user1.ServerEventMsg, td.Map(map[string]any{
+			"event":    "server reply",
+			"toAction": "direct chat: pin message",
+			"data":     true,
+		}, nil))
+
+		td.Cmp(td.Require(t), <-user2.ServerEventMsg, td.Map(map[string]any{
+			"event": "direct chat: message pinned",
+			"data": td.SuperMapOf(map[string]any{
+				"msg_id": pinMsgIds[5],
+			}, nil),
+		}, nil))
+	}
+
+	{
+		t.Log("Action: user2 lists the chat's pinned messages | the five pinned are listed")
+
+		req := httptest.NewRequest("GET", directChatPath+"/"+user1.Username+"/pinned_messages", nil)
+		req.Header.Set("Cookie", user2.SessionCookie)
+
+		res, err := app.Test(req)
+		require.NoError(err)
+
+		if !assert.Equal(t, http.StatusOK, res.StatusCode) {
+			rb, err := errResBody(res.Body)
+			require.NoError(err)
+			t.Log("unexpected error:", rb)
+			return
+		}
+
+		rb, err := succResBody[[]map[string]any](res.Body)
+		require.NoError(err)
+
+		pinnedIds := make([]any, len(rb))
+		for i, pinned := range rb {
+			pinnedIds[i] = pinned["message"].(map[string]any)["id"]
+		}
+
+		td.Cmp(td.Require(t), pinnedIds, td.Bag(pinMsgIds[1], pinMsgIds[2], pinMsgIds[3], pinMsgIds[4], pinMsgIds[5]))
+	}
 }
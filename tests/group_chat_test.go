@@ -1,9 +1,12 @@
 package tests
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
-	"i9chat/src/appGlobals"
 	"i9chat/src/helpers"
+	"i9chat/src/models/db"
+	"i9chat/src/services/cloudStorageService"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -286,7 +289,7 @@ func TestGroupChat(t *testing.T) {
 			require.NoError(err)
 
 			for _, smlGroupPicCn := range varGroupPicCloudName {
-				err := appGlobals.GCSClient.Bucket(os.Getenv("GCS_BUCKET_NAME")).Object(smlGroupPicCn).Delete(t.Context())
+				err := cloudStorageService.Backend().Delete(t.Context(), smlGroupPicCn)
 				require.NoError(err)
 			}
 		}(groupPicCloudName)
@@ -308,6 +311,7 @@ func TestGroupChat(t *testing.T) {
 			"name":             newGroup.Name,
 			"description":      newGroup.Description,
 			"pictureCloudName": newGroup.PictureCloudName,
+			"isPublic":         true,
 			"initUsers":        []string{user2.Username},
 			"createdAt":        time.Now().UTC().UnixMilli(),
 		})
@@ -851,6 +855,65 @@ func TestGroupChat(t *testing.T) {
 		}, nil))
 	}
 
+	{
+		t.Log("Action: user1 transfers group ownership to user2 | user2 & other members are notified")
+
+		reqBody, err := makeReqBody(map[string]any{
+			"user": user2.Username,
+		})
+		require.NoError(err)
+
+		req := httptest.NewRequest("POST", groupChatPath+"/"+newGroup.Id+"/execute_action/transfer-ownership", reqBody)
+		req.Header.Add("Content-Type", "application/vnd.msgpack")
+		req.Header.Set("Cookie", user1.SessionCookie)
+
+		res, err := app.Test(req)
+		require.NoError(err)
+
+		if !assert.Equal(t, http.StatusOK, res.StatusCode) {
+			rb, err := errResBody(res.Body)
+			require.NoError(err)
+			t.Log("unexpected error:", rb)
+			return
+		}
+
+		rb, err := succResBody[map[string]any](res.Body)
+		require.NoError(err)
+
+		td.Cmp(td.Require(t), rb, td.SuperMapOf(map[string]any{
+			"che_type": "group activity",
+			"info":     fmt.Sprintf("You transferred group ownership to %s", user2.Username),
+		}, nil))
+
+		user2GCOwnershipNotif := <-user2.ServerEventMsg
+
+		td.Cmp(td.Require(t), user2GCOwnershipNotif, td.Map(map[string]any{
+			"event": "group chat: new che: group activity",
+			"data": td.Map(map[string]any{
+				"group_id": newGroup.Id,
+				"che": td.SuperMapOf(map[string]any{
+					"che_type": "group activity",
+					"info":     fmt.Sprintf("%s transferred group ownership to you", user1.Username),
+				}, nil),
+			}, nil),
+		}, nil))
+
+		for _, user := range []*UserT{&user3, &user4, &user5} {
+			userGCOwnershipNotif := <-user.ServerEventMsg
+
+			td.Cmp(td.Require(t), userGCOwnershipNotif, td.Map(map[string]any{
+				"event": "group chat: new che: group activity",
+				"data": td.Map(map[string]any{
+					"group_id": newGroup.Id,
+					"che": td.SuperMapOf(map[string]any{
+						"che_type": "group activity",
+						"info":     fmt.Sprintf("%s transferred group ownership to %s", user1.Username, user2.Username),
+					}, nil),
+				}, nil),
+			}, nil))
+		}
+	}
+
 	{
 		t.Log("Action: user2 removes user1 from group admins | user1 & other members are notified")
 
@@ -1088,13 +1151,14 @@ func TestGroupChat(t *testing.T) {
 	{
 		<-(time.NewTimer(500 * time.Millisecond).C)
 
-		t.Log("user2 requests group info")
+		t.Log("user2 requests group info | the reply echoes the request's id")
 
 		err := wsWriteMsgPack(user2.WSConn, map[string]any{
 			"action": "group: get info",
 			"data": map[string]any{
 				"groupId": newGroup.Id,
 			},
+			"reqId": "group-info-1",
 		})
 		require.NoError(err)
 
@@ -1104,6 +1168,7 @@ func TestGroupChat(t *testing.T) {
 		td.Cmp(td.Require(t), user2ServerReply, td.Map(map[string]any{
 			"event":    "server reply",
 			"toAction": "group: get info",
+			"reqId":    "group-info-1",
 			"data": td.SuperMapOf(map[string]any{
 				"name":                 newGroup.Name,
 				"description":          newGroup.Description,
@@ -1113,6 +1178,29 @@ func TestGroupChat(t *testing.T) {
 		}, nil))
 	}
 
+	{
+		t.Log("user2 requests group info without a group id | the error reply echoes the request's id")
+
+		err := wsWriteMsgPack(user2.WSConn, map[string]any{
+			"action": "group: get info",
+			"data":   map[string]any{},
+			"reqId":  "group-info-2",
+		})
+		require.NoError(err)
+
+		user2ServerReply := <-user2.ServerEventMsg
+
+		td.Cmp(td.Require(t), user2ServerReply, td.Map(map[string]any{
+			"event":    "server error",
+			"toAction": "group: get info",
+			"reqId":    "group-info-2",
+			"data": td.Map(map[string]any{
+				"statusCode": td.Lax(http.StatusBadRequest),
+				"errorMsg":   td.NotEmpty(),
+			}, nil),
+		}, nil))
+	}
+
 	user4NewMsgId := ""
 
 	{
@@ -1387,4 +1475,1809 @@ func TestGroupChat(t *testing.T) {
 			),
 		)
 	}
+
+	{
+		t.Log("Action: user4 edits his message in group | other members are notified")
+
+		err := wsWriteMsgPack(user4.WSConn, map[string]any{
+			"action": "group chat: edit message",
+			"data": map[string]any{
+				"groupId": newGroup.Id,
+				"msgId":   user4NewMsgId,
+				"newText": "Hi. How are you doing?",
+				"at":      time.Now().UTC().UnixMilli(),
+			},
+		})
+		require.NoError(err)
+
+		user4ServerReply := <-user4.ServerEventMsg
+
+		td.Cmp(td.Require(t), user4ServerReply, td.Map(map[string]any{
+			"event":    "server reply",
+			"toAction": "group chat: edit message",
+			"data": td.Map(map[string]any{
+				"edited_at": td.NotZero(),
+			}, nil),
+		}, nil))
+
+		for _, user := range []UserT{user2, user5} {
+			userMsgEdited := <-user.ServerEventMsg
+
+			td.Cmp(td.Require(t), userMsgEdited, td.SuperMapOf(map[string]any{
+				"event": "group chat: message edited",
+				"data": td.Map(map[string]any{
+					"group_id": newGroup.Id,
+					"msg_id":   user4NewMsgId,
+					"content": td.SuperMapOf(map[string]any{
+						"type": "text",
+						"props": td.SuperMapOf(map[string]any{
+							"text_content": "Hi. How are you doing?",
+						}, nil),
+					}, nil),
+					"edited_at": td.Lax(user4ServerReply["data"].(map[string]any)["edited_at"]),
+				}, nil),
+			}, nil))
+		}
+	}
+
+	{
+		t.Log("Action: user2, the group owner, edits user4's message | the message isn't edited")
+
+		err := wsWriteMsgPack(user2.WSConn, map[string]any{
+			"action": "group chat: edit message",
+			"data": map[string]any{
+				"groupId": newGroup.Id,
+				"msgId":   user4NewMsgId,
+				"newText": "Hi. How do you do?",
+				"at":      time.Now().UTC().UnixMilli(),
+			},
+		})
+		require.NoError(err)
+
+		user2ServerReply := <-user2.ServerEventMsg
+
+		td.Cmp(td.Require(t), user2ServerReply, td.Map(map[string]any{
+			"event":    "server reply",
+			"toAction": "group chat: edit message",
+			"data":     nil,
+		}, nil))
+	}
+
+	{
+		t.Log("Action: user4 edits his message after the edit window | the message isn't edited")
+
+		t.Setenv("MSG_EDIT_WINDOW", "1ms")
+
+		<-(time.NewTimer(10 * time.Millisecond).C)
+
+		err := wsWriteMsgPack(user4.WSConn, map[string]any{
+			"action": "group chat: edit message",
+			"data": map[string]any{
+				"groupId": newGroup.Id,
+				"msgId":   user4NewMsgId,
+				"newText": "Hi. How do you do?",
+				"at":      time.Now().UTC().UnixMilli(),
+			},
+		})
+		require.NoError(err)
+
+		user4ServerReply := <-user4.ServerEventMsg
+
+		td.Cmp(td.Require(t), user4ServerReply, td.Map(map[string]any{
+			"event":    "server reply",
+			"toAction": "group chat: edit message",
+			"data":     nil,
+		}, nil))
+	}
+
+	{
+		t.Log("Check: the message's edit history keeps its replaced content")
+
+		res, err := db.Query(context.Background(),
+			`MATCH (message:GroupMessage{ id: $message_id }) RETURN message.edit_history AS edit_history`,
+			map[string]any{"message_id": user4NewMsgId},
+		)
+		require.NoError(err)
+		require.Len(res.Records, 1)
+
+		editHistory, _ := res.Records[0].Get("edit_history")
+
+		td.Cmp(td.Require(t), editHistory, td.Len(1))
+
+		var editRecord map[string]any
+
+		require.NoError(json.Unmarshal([]byte(editHistory.([]any)[0].(string)), &editRecord))
+
+		td.Cmp(td.Require(t), editRecord, td.Map(map[string]any{
+			"content": td.SuperMapOf(map[string]any{
+				"type": "text",
+				"props": td.SuperMapOf(map[string]any{
+					"text_content": "Hi. How're you doing?",
+				}, nil),
+			}, nil),
+			"replaced_at": td.NotZero(),
+		}, nil))
+	}
+
+	{
+		<-(time.NewTimer(100 * time.Millisecond).C)
+
+		t.Log("Action: user5 opens group chat history | the message is edited")
+
+		req := httptest.NewRequest("GET", groupChatPath+"/"+newGroup.Id+"/history", nil)
+		req.Header.Add("Content-Type", "application/vnd.msgpack")
+		req.Header.Set("Cookie", user5.SessionCookie)
+
+		res, err := app.Test(req)
+		require.NoError(err)
+
+		if !assert.Equal(t, http.StatusOK, res.StatusCode) {
+			rb, err := errResBody(res.Body)
+			require.NoError(err)
+			t.Log("unexpected error:", rb)
+			return
+		}
+
+		rb, err := succResBody[[]map[string]any](res.Body)
+		require.NoError(err)
+
+		td.Cmp(td.Require(t), rb, td.Contains(td.SuperMapOf(map[string]any{
+			"che_type": "message",
+			"id":       user4NewMsgId,
+			"content": td.SuperMapOf(map[string]any{
+				"type": "text",
+				"props": td.SuperMapOf(map[string]any{
+					"text_content": "Hi. How are you doing?",
+				}, nil),
+			}, nil),
+			"delivery_status": "read",
+			"edited_at":       td.NotZero(),
+		}, nil)))
+	}
+
+	user5NewMsgId := ""
+
+	{
+		t.Log("Action: user5 sends message to group | other members receive the message")
+
+		err := wsWriteMsgPack(user5.WSConn, map[string]any{
+			"action": "group chat: send message",
+			"data": map[string]any{
+				"groupId": newGroup.Id,
+				"msg": map[string]any{
+					"type": "text",
+					"props": map[string]any{
+						"text_content": "Something nobody should see",
+					},
+				},
+				"at": time.Now().UTC().UnixMilli(),
+			},
+		})
+		require.NoError(err)
+
+		user5ServerReply := <-user5.ServerEventMsg
+
+		td.Cmp(td.Require(t), user5ServerReply, td.Map(map[string]any{
+			"event":    "server reply",
+			"toAction": "group chat: send message",
+			"data": td.Map(map[string]any{
+				"new_msg_id": td.Ignore(),
+				"che_cursor": td.Ignore(),
+			}, nil),
+		}, nil))
+
+		user5NewMsgId = user5ServerReply["data"].(map[string]any)["new_msg_id"].(string)
+
+		for _, user := range []UserT{user2, user4} {
+			userNewMsgReceived := <-user.ServerEventMsg
+
+			td.Cmp(td.Require(t), userNewMsgReceived, td.Map(map[string]any{
+				"event": "group chat: new che: message",
+				"data": td.Map(map[string]any{
+					"group_id": newGroup.Id,
+					"che": td.SuperMapOf(map[string]any{
+						"id": user5NewMsgId,
+					}, nil),
+				}, nil),
+			}, nil))
+		}
+	}
+
+	{
+		t.Log("Action: user4 deletes user5's message for everyone, without being an admin | the message isn't deleted")
+
+		err := wsWriteMsgPack(user4.WSConn, map[string]any{
+			"action": "group chat: delete message for everyone",
+			"data": map[string]any{
+				"groupId": newGroup.Id,
+				"msgId":   user5NewMsgId,
+				"at":      time.Now().UTC().UnixMilli(),
+			},
+		})
+		require.NoError(err)
+
+		user4ServerReply := <-user4.ServerEventMsg
+
+		td.Cmp(td.Require(t), user4ServerReply, td.Map(map[string]any{
+			"event":    "server reply",
+			"toAction": "group chat: delete message for everyone",
+			"data":     nil,
+		}, nil))
+	}
+
+	{
+		t.Log("Action: user2, the group owner, deletes user5's message for everyone | other members are notified")
+
+		err := wsWriteMsgPack(user2.WSConn, map[string]any{
+			"action": "group chat: delete message for everyone",
+			"data": map[string]any{
+				"groupId": newGroup.Id,
+				"msgId":   user5NewMsgId,
+				"at":      time.Now().UTC().UnixMilli(),
+			},
+		})
+		require.NoError(err)
+
+		user2ServerReply := <-user2.ServerEventMsg
+
+		td.Cmp(td.Require(t), user2ServerReply, td.Map(map[string]any{
+			"event":    "server reply",
+			"toAction": "group chat: delete message for everyone",
+			"data": td.Map(map[string]any{
+				"deleted_at": td.NotZero(),
+			}, nil),
+		}, nil))
+
+		for _, user := range []UserT{user4, user5} {
+			userMsgDeleted := <-user.ServerEventMsg
+
+			td.Cmp(td.Require(t), userMsgDeleted, td.SuperMapOf(map[string]any{
+				"event": "group chat: message deleted",
+				"data": td.Map(map[string]any{
+					"group_id":   newGroup.Id,
+					"msg_id":     user5NewMsgId,
+					"deleted_by": user2.Username,
+					"deleted_at": td.Lax(user2ServerReply["data"].(map[string]any)["deleted_at"]),
+				}, nil),
+			}, nil))
+		}
+	}
+
+	{
+		t.Log("Action: user4 deletes his message for everyone after the delete window | the message isn't deleted")
+
+		t.Setenv("MSG_DELETE_WINDOW", "1ms")
+
+		<-(time.NewTimer(10 * time.Millisecond).C)
+
+		err := wsWriteMsgPack(user4.WSConn, map[string]any{
+			"action": "group chat: delete message for everyone",
+			"data": map[string]any{
+				"groupId": newGroup.Id,
+				"msgId":   user4NewMsgId,
+				"at":      time.Now().UTC().UnixMilli(),
+			},
+		})
+		require.NoError(err)
+
+		user4ServerReply := <-user4.ServerEventMsg
+
+		td.Cmp(td.Require(t), user4ServerReply, td.Map(map[string]any{
+			"event":    "server reply",
+			"toAction": "group chat: delete message for everyone",
+			"data":     nil,
+		}, nil))
+	}
+
+	{
+		t.Log("Action: user2 deletes user4's message for user2 only")
+
+		err := wsWriteMsgPack(user2.WSConn, map[string]any{
+			"action": "group chat: delete messages for me",
+			"data": map[string]any{
+				"groupId": newGroup.Id,
+				"msgIds":  []string{user4NewMsgId},
+			},
+		})
+		require.NoError(err)
+
+		user2ServerReply := <-user2.ServerEventMsg
+
+		td.Cmp(td.Require(t), user2ServerReply, td.Map(map[string]any{
+			"event":    "server reply",
+			"toAction": "group chat: delete messages for me",
+			"data":     true,
+		}, nil))
+	}
+
+	{
+		<-(time.NewTimer(100 * time.Millisecond).C)
+
+		t.Log("Action: user2 opens group chat history | the message deleted for everyone is a tombstone, the one deleted for him is gone")
+
+		req := httptest.NewRequest("GET", groupChatPath+"/"+newGroup.Id+"/history", nil)
+		req.Header.Add("Content-Type", "application/vnd.msgpack")
+		req.Header.Set("Cookie", user2.SessionCookie)
+
+		res, err := app.Test(req)
+		require.NoError(err)
+
+		if !assert.Equal(t, http.StatusOK, res.StatusCode) {
+			rb, err := errResBody(res.Body)
+			require.NoError(err)
+			t.Log("unexpected error:", rb)
+			return
+		}
+
+		rb, err := succResBody[[]map[string]any](res.Body)
+		require.NoError(err)
+
+		td.Cmp(td.Require(t), rb,
+			td.All(
+				td.Contains(td.SuperMapOf(map[string]any{
+					"che_type": "message",
+					"id":       user5NewMsgId,
+					"content": td.SuperMapOf(map[string]any{
+						"type": "deleted",
+					}, nil),
+					"deleted_at": td.NotZero(),
+				}, nil)),
+				td.Not(td.Contains(td.SuperMapOf(map[string]any{
+					"id": user4NewMsgId,
+				}, nil))),
+			),
+		)
+	}
+
+	{
+		t.Log("Action: user5 opens group chat history | the message deleted for user2 is kept for user5")
+
+		req := httptest.NewRequest("GET", groupChatPath+"/"+newGroup.Id+"/history", nil)
+		req.Header.Add("Content-Type", "application/vnd.msgpack")
+		req.Header.Set("Cookie", user5.SessionCookie)
+
+		res, err := app.Test(req)
+		require.NoError(err)
+
+		if !assert.Equal(t, http.StatusOK, res.StatusCode) {
+			rb, err := errResBody(res.Body)
+			require.NoError(err)
+			t.Log("unexpected error:", rb)
+			return
+		}
+
+		rb, err := succResBody[[]map[string]any](res.Body)
+		require.NoError(err)
+
+		td.Cmp(td.Require(t), rb,
+			td.All(
+				td.Contains(td.SuperMapOf(map[string]any{
+					"che_type": "message",
+					"id":       user5NewMsgId,
+					"content": td.SuperMapOf(map[string]any{
+						"type": "deleted",
+					}, nil),
+					"deleted_at": td.NotZero(),
+				}, nil)),
+				td.Contains(td.SuperMapOf(map[string]any{
+					"che_type": "message",
+					"id":       user4NewMsgId,
+				}, nil)),
+			),
+		)
+	}
+
+	groupPinMsgIds := make([]string, 11)
+
+	{
+		t.Log("Action: user4 sends eleven messages to group, to pin | other members receive the messages")
+
+		for i := range groupPinMsgIds {
+			err := wsWriteMsgPack(user4.WSConn, map[string]any{
+				"action": "group chat: send message",
+				"data": map[string]any{
+					"groupId": newGroup.Id,
+					"msg": map[string]any{
+						"type": "text",
+						"props": map[string]any{
+							"text_content": fmt.Sprintf("Pin me, %d", i+1),
+						},
+					},
+					"at": time.Now().UTC().UnixMilli(),
+				},
+			})
+			require.NoError(err)
+
+			user4ServerReply := <-user4.ServerEventMsg
+
+			td.Cmp(td.Require(t), user4ServerReply, td.Map(map[string]any{
+				"event":    "server reply",
+				"toAction": "group chat: send message",
+				"data": td.Map(map[string]any{
+					"new_msg_id": td.Ignore(),
+					"che_cursor": td.Ignore(),
+				}, nil),
+			}, nil))
+
+			groupPinMsgIds[i] = user4ServerReply["data"].(map[string]any)["new_msg_id"].(string)
+
+			for _, user := range []UserT{user2, user5} {
+				td.Cmp(td.Require(t), <-user.ServerEventMsg, td.Map(map[string]any{
+					"event": "group chat: new che: message",
+					"data": td.Map(map[string]any{
+						"group_id": newGroup.Id,
+						"che": td.SuperMapOf(map[string]any{
+							"id": groupPinMsgIds[i],
+						}, nil),
+					}, nil),
+				}, nil))
+			}
+		}
+	}
+
+	// the events of a pin, or an unpin, reach each other member in any order
+	pinEvents := func(event, pinnerUsername, msgId string) td.TestDeep {
+		return td.Bag(
+			td.Map(map[string]any{
+				"event": "group chat: message " + event,
+				"data": td.SuperMapOf(map[string]any{
+					"group_id": newGroup.Id,
+					"msg_id":   msgId,
+				}, nil),
+			}, nil),
+			td.Map(map[string]any{
+				"event": "group chat: new che: group activity",
+				"data": td.Map(map[string]any{
+					"group_id": newGroup.Id,
+					"che": td.SuperMapOf(map[string]any{
+						"che_type": "group activity",
+						"info":     pinnerUsername + " " + event + " a message",
+					}, nil),
+				}, nil),
+			}, nil),
+		)
+	}
+
+	{
+		t.Log("Action: user4 pins a message, without permission | the message isn't pinned")
+
+		reqBody, err := makeReqBody(map[string]any{"msgId": groupPinMsgIds[0]})
+		require.NoError(err)
+
+		req := httptest.NewRequest("POST", groupChatPath+"/"+newGroup.Id+"/execute_action/pin-message", reqBody)
+		req.Header.Add("Content-Type", "application/vnd.msgpack")
+		req.Header.Set("Cookie", user4.SessionCookie)
+
+		res, err := app.Test(req)
+		require.NoError(err)
+
+		if !assert.Equal(t, http.StatusOK, res.StatusCode) {
+			rb, err := errResBody(res.Body)
+			require.NoError(err)
+			t.Log("unexpected error:", rb)
+			return
+		}
+
+		rb, err := succResBody[map[string]any](res.Body)
+		require.NoError(err)
+
+		td.Cmp(td.Require(t), rb, td.SuperMapOf(map[string]any{
+			"che_type": "",
+		}, nil))
+	}
+
+	{
+		t.Log("Action: user2, the group owner, pins ten messages, the limit | other members are notified of each")
+
+		for _, msgId := range groupPinMsgIds[:10] {
+			reqBody, err := makeReqBody(map[string]any{"msgId": msgId})
+			require.NoError(err)
+
+			req := httptest.NewRequest("POST", groupChatPath+"/"+newGroup.Id+"/execute_action/pin-message", reqBody)
+			req.Header.Add("Content-Type", "application/vnd.msgpack")
+			req.Header.Set("Cookie", user2.SessionCookie)
+
+			res, err := app.Test(req)
+			require.NoError(err)
+
+			if !assert.Equal(t, http.StatusOK, res.StatusCode) {
+				rb, err := errResBody(res.Body)
+				require.NoError(err)
+				t.Log("unexpected error:", rb)
+				return
+			}
+
+			rb, err := succResBody[map[string]any](res.Body)
+			require.NoError(err)
+
+			td.Cmp(td.Require(t), rb, td.SuperMapOf(map[string]any{
+				"che_type": "group activity",
+				"info":     "You pinned a message",
+			}, nil))
+
+			for _, user := range []UserT{user4, user5} {
+				td.Cmp(td.Require(t), []map[string]any{<-user.ServerEventMsg, <-user.ServerEventMsg}, pinEvents("pinned", user2.Username, msgId))
+			}
+		}
+	}
+
+	{
+		t.Log("Action: user2 pins an eleventh message | the message isn't pinned")
+
+		reqBody, err := makeReqBody(map[string]any{"msgId": groupPinMsgIds[10]})
+		require.NoError(err)
+
+		req := httptest.NewRequest("POST", groupChatPath+"/"+newGroup.Id+"/execute_action/pin-message", reqBody)
+		req.Header.Add("Content-Type", "application/vnd.msgpack")
+		req.Header.Set("Cookie", user2.SessionCookie)
+
+		res, err := app.Test(req)
+		require.NoError(err)
+
+		if !assert.Equal(t, http.StatusBadRequest, res.StatusCode) {
+			rb, err := errResBody(res.Body)
+			require.NoError(err)
+			t.Log("unexpected error:", rb)
+			return
+		}
+
+		rb, err := errResBody(res.Body)
+		require.NoError(err)
+
+		require.Equal("you can only pin up to 10 messages in a group; unpin one first", rb)
+	}
+
+	{
+		t.Log("Action: user2 allows all members to pin messages | other members are notified")
+
+		reqBody, err := makeReqBody(map[string]any{"pinPermission": "all"})
+		require.NoError(err)
+
+		req := httptest.NewRequest("POST", groupChatPath+"/"+newGroup.Id+"/execute_action/change-pin-permission", reqBody)
+		req.Header.Add("Content-Type", "application/vnd.msgpack")
+		req.Header.Set("Cookie", user2.SessionCookie)
+
+		res, err := app.Test(req)
+		require.NoError(err)
+
+		if !assert.Equal(t, http.StatusOK, res.StatusCode) {
+			rb, err := errResBody(res.Body)
+			require.NoError(err)
+			t.Log("unexpected error:", rb)
+			return
+		}
+
+		rb, err := succResBody[map[string]any](res.Body)
+		require.NoError(err)
+
+		td.Cmp(td.Require(t), rb, td.SuperMapOf(map[string]any{
+			"che_type": "group activity",
+			"info":     "You allowed all members to pin messages",
+		}, nil))
+
+		for _, user := range []UserT{user4, user5} {
+			td.Cmp(td.Require(t), <-user.ServerEventMsg, td.Map(map[string]any{
+				"event": "group chat: new che: group activity",
+				"data": td.Map(map[string]any{
+					"group_id": newGroup.Id,
+					"che": td.SuperMapOf(map[string]any{
+						"che_type": "group activity",
+						"info":     user2.Username + " allowed all members to pin messages",
+					}, nil),
+				}, nil),
+			}, nil))
+		}
+	}
+
+	{
+		t.Log("Action: user4 unpins the first pinned message, then pins the eleventh | other members are notified of each")
+
+		for _, action := range []struct{ name, event, msgId string }{
+			{"unpin-message", "unpinned", groupPinMsgIds[0]},
+			{"pin-message", "pinned", groupPinMsgIds[10]},
+		} {
+			reqBody, err := makeReqBody(map[string]any{"msgId": action.msgId})
+			require.NoError(err)
+
+			req := httptest.NewRequest("POST", groupChatPath+"/"+newGroup.Id+"/execute_action/"+action.name, reqBody)
+			req.Header.Add("Content-Type", "application/vnd.msgpack")
+			req.Header.Set("Cookie", user4.SessionCookie)
+
+			res, err := app.Test(req)
+			require.NoError(err)
+
+			if !assert.Equal(t, http.StatusOK, res.StatusCode) {
+				rb, err := errResBody(res.Body)
+				require.NoError(err)
+				t.Log("unexpected error:", rb)
+				return
+			}
+
+			rb, err := succResBody[map[string]any](res.Body)
+			require.NoError(err)
+
+			td.Cmp(td.Require(t), rb, td.SuperMapOf(map[string]any{
+				"che_type": "group activity",
+				"info":     "You " + action.event + " a message",
+			}, nil))
+
+			for _, user := range []UserT{user2, user5} {
+				td.Cmp(td.Require(t), []map[string]any{<-user.ServerEventMsg, <-user.ServerEventMsg}, pinEvents(action.event, user4.Username, action.msgId))
+			}
+		}
+	}
+
+	{
+		t.Log("Action: user5 lists the group's pinned messages | the ten pinned are listed")
+
+		req := httptest.NewRequest("GET", groupChatPath+"/"+newGroup.Id+"/pinned_messages", nil)
+		req.Header.Set("Cookie", user5.SessionCookie)
+
+		res, err := app.Test(req)
+		require.NoError(err)
+
+		if !assert.Equal(t, http.StatusOK, res.StatusCode) {
+			rb, err := errResBody(res.Body)
+			require.NoError(err)
+			t.Log("unexpected error:", rb)
+			return
+		}
+
+		rb, err := succResBody[[]map[string]any](res.Body)
+		require.NoError(err)
+
+		pinnedIds := make([]any, len(rb))
+		for i, pinned := range rb {
+			pinnedIds[i] = pinned["message"].(map[string]any)["id"]
+		}
+
+		expectedIds := make([]any, 0, 10)
+		for _, msgId := range groupPinMsgIds[1:] {
+			expectedIds = append(expectedIds, msgId)
+		}
+
+		td.Cmp(td.Require(t), pinnedIds, td.Bag(expectedIds...))
+	}
+
+	inviteLinkCode := ""
+
+	{
+		t.Log("Action: user2 creates an invite link for a single join")
+
+		reqBody, err := makeReqBody(map[string]any{"maxUses": 1})
+		require.NoError(err)
+
+		req := httptest.NewRequest("POST", groupChatPath+"/"+newGroup.Id+"/execute_action/create-invite-link", reqBody)
+		req.Header.Add("Content-Type", "application/vnd.msgpack")
+		req.Header.Set("Cookie", user2.SessionCookie)
+
+		res, err := app.Test(req)
+		require.NoError(err)
+
+		if !assert.Equal(t, http.StatusOK, res.StatusCode) {
+			rb, err := errResBody(res.Body)
+			require.NoError(err)
+			t.Log("unexpected error:", rb)
+			return
+		}
+
+		rb, err := succResBody[map[string]any](res.Body)
+		require.NoError(err)
+
+		td.Cmp(td.Require(t), rb, td.SuperMapOf(map[string]any{
+			"code":       td.NotEmpty(),
+			"created_by": user2.Username,
+			"max_uses":   td.Lax(1),
+			"uses":       td.Lax(0),
+		}, nil))
+
+		inviteLinkCode = rb["code"].(string)
+	}
+
+	{
+		t.Log("Action: user3 previews the group by the invite link")
+
+		req := httptest.NewRequest("GET", groupChatPath+"/invite_links/"+inviteLinkCode+"/preview", nil)
+		req.Header.Set("Cookie", user3.SessionCookie)
+
+		res, err := app.Test(req)
+		require.NoError(err)
+
+		if !assert.Equal(t, http.StatusOK, res.StatusCode) {
+			rb, err := errResBody(res.Body)
+			require.NoError(err)
+			t.Log("unexpected error:", rb)
+			return
+		}
+
+		rb, err := succResBody[map[string]any](res.Body)
+		require.NoError(err)
+
+		td.Cmp(td.Require(t), rb, td.SuperMapOf(map[string]any{
+			"name":        newGroup.Name,
+			"description": newGroup.Description,
+		}, nil))
+	}
+
+	{
+		t.Log("Action: user3 joins group via the invite link | other members are notified")
+
+		reqBody, err := makeReqBody(map[string]any{})
+		require.NoError(err)
+
+		req := httptest.NewRequest("POST", groupChatPath+"/invite_links/"+inviteLinkCode+"/join", reqBody)
+		req.Header.Add("Content-Type", "application/vnd.msgpack")
+		req.Header.Set("Cookie", user3.SessionCookie)
+
+		res, err := app.Test(req)
+		require.NoError(err)
+
+		if !assert.Equal(t, http.StatusOK, res.StatusCode) {
+			rb, err := errResBody(res.Body)
+			require.NoError(err)
+			t.Log("unexpected error:", rb)
+			return
+		}
+
+		rb, err := succResBody[map[string]any](res.Body)
+		require.NoError(err)
+
+		td.Cmp(td.Require(t), rb, td.SuperMapOf(map[string]any{
+			"chat": td.SuperMapOf(map[string]any{
+				"type": "group",
+				"group": td.SuperMapOf(map[string]any{
+					"id":   newGroup.Id,
+					"name": newGroup.Name,
+				}, nil),
+				"cursor": td.Ignore(),
+			}, nil),
+			"history": td.Contains(td.SuperMapOf(map[string]any{
+				"che_type": "group activity",
+				"info":     "You joined via invite link from " + user2.Username,
+			}, nil)),
+		}, nil))
+
+		for _, user := range []UserT{user2, user4, user5} {
+			userGCJoinNotif := <-user.ServerEventMsg
+
+			td.Cmp(td.Require(t), userGCJoinNotif, td.Map(map[string]any{
+				"event": "group chat: new che: group activity",
+				"data": td.Map(map[string]any{
+					"group_id": newGroup.Id,
+					"che": td.SuperMapOf(map[string]any{
+						"che_type": "group activity",
+						"info":     user3.Username + " joined via invite link from " + user2.Username,
+					}, nil),
+				}, nil),
+			}, nil))
+		}
+	}
+
+	{
+		t.Log("Action: user1 previews the group by the used up invite link | it is no longer valid")
+
+		req := httptest.NewRequest("GET", groupChatPath+"/invite_links/"+inviteLinkCode+"/preview", nil)
+		req.Header.Set("Cookie", user1.SessionCookie)
+
+		res, err := app.Test(req)
+		require.NoError(err)
+
+		if !assert.Equal(t, http.StatusNotFound, res.StatusCode) {
+			rb, err := errResBody(res.Body)
+			require.NoError(err)
+			t.Log("unexpected error:", rb)
+			return
+		}
+
+		rb, err := errResBody(res.Body)
+		require.NoError(err)
+
+		require.Equal("this invite link is invalid, expired, or used up", rb)
+	}
+
+	{
+		t.Log("Action: user2 creates an invite link that expires shortly | it is no longer valid after expiry")
+
+		reqBody, err := makeReqBody(map[string]any{"expiresAt": time.Now().UTC().Add(time.Second).UnixMilli()})
+		require.NoError(err)
+
+		req := httptest.NewRequest("POST", groupChatPath+"/"+newGroup.Id+"/execute_action/create-invite-link", reqBody)
+		req.Header.Add("Content-Type", "application/vnd.msgpack")
+		req.Header.Set("Cookie", user2.SessionCookie)
+
+		res, err := app.Test(req)
+		require.NoError(err)
+
+		if !assert.Equal(t, http.StatusOK, res.StatusCode) {
+			rb, err := errResBody(res.Body)
+			require.NoError(err)
+			t.Log("unexpected error:", rb)
+			return
+		}
+
+		rb, err := succResBody[map[string]any](res.Body)
+		require.NoError(err)
+
+		td.Cmp(td.Require(t), rb, td.SuperMapOf(map[string]any{
+			"code":       td.NotEmpty(),
+			"expires_at": td.NotZero(),
+		}, nil))
+
+		<-(time.NewTimer(1500 * time.Millisecond).C)
+
+		req = httptest.NewRequest("GET", groupChatPath+"/invite_links/"+rb["code"].(string)+"/preview", nil)
+		req.Header.Set("Cookie", user1.SessionCookie)
+
+		res, err = app.Test(req)
+		require.NoError(err)
+
+		if !assert.Equal(t, http.StatusNotFound, res.StatusCode) {
+			rb, err := errResBody(res.Body)
+			require.NoError(err)
+			t.Log("unexpected error:", rb)
+			return
+		}
+	}
+
+	{
+		t.Log("Action: user2 creates an invite link, then revokes it | it is no longer listed or valid")
+
+		reqBody, err := makeReqBody(map[string]any{})
+		require.NoError(err)
+
+		req := httptest.NewRequest("POST", groupChatPath+"/"+newGroup.Id+"/execute_action/create-invite-link", reqBody)
+		req.Header.Add("Content-Type", "application/vnd.msgpack")
+		req.Header.Set("Cookie", user2.SessionCookie)
+
+		res, err := app.Test(req)
+		require.NoError(err)
+
+		if !assert.Equal(t, http.StatusOK, res.StatusCode) {
+			rb, err := errResBody(res.Body)
+			require.NoError(err)
+			t.Log("unexpected error:", rb)
+			return
+		}
+
+		newLink, err := succResBody[map[string]any](res.Body)
+		require.NoError(err)
+
+		reqBody, err = makeReqBody(map[string]any{"code": newLink["code"]})
+		require.NoError(err)
+
+		req = httptest.NewRequest("POST", groupChatPath+"/"+newGroup.Id+"/execute_action/revoke-invite-link", reqBody)
+		req.Header.Add("Content-Type", "application/vnd.msgpack")
+		req.Header.Set("Cookie", user2.SessionCookie)
+
+		res, err = app.Test(req)
+		require.NoError(err)
+
+		if !assert.Equal(t, http.StatusOK, res.StatusCode) {
+			rb, err := errResBody(res.Body)
+			require.NoError(err)
+			t.Log("unexpected error:", rb)
+			return
+		}
+
+		rb, err := succResBody[bool](res.Body)
+		require.NoError(err)
+
+		require.True(rb)
+
+		req = httptest.NewRequest("GET", groupChatPath+"/"+newGroup.Id+"/invite_links", nil)
+		req.Header.Set("Cookie", user2.SessionCookie)
+
+		res, err = app.Test(req)
+		require.NoError(err)
+
+		if !assert.Equal(t, http.StatusOK, res.StatusCode) {
+			rb, err := errResBody(res.Body)
+			require.NoError(err)
+			t.Log("unexpected error:", rb)
+			return
+		}
+
+		links, err := succResBody[[]map[string]any](res.Body)
+		require.NoError(err)
+
+		td.Cmp(td.Require(t), links, td.All(
+			td.Not(td.Contains(td.SuperMapOf(map[string]any{"code": newLink["code"]}, nil))),
+			td.Contains(td.SuperMapOf(map[string]any{"code": inviteLinkCode, "uses": td.Lax(1)}, nil)),
+		))
+
+		req = httptest.NewRequest("GET", groupChatPath+"/invite_links/"+newLink["code"].(string)+"/preview", nil)
+		req.Header.Set("Cookie", user1.SessionCookie)
+
+		res, err = app.Test(req)
+		require.NoError(err)
+
+		if !assert.Equal(t, http.StatusNotFound, res.StatusCode) {
+			rb, err := errResBody(res.Body)
+			require.NoError(err)
+			t.Log("unexpected error:", rb)
+			return
+		}
+	}
+
+	{
+		t.Log("Action: user2 turns on announcement mode | other members are notified")
+
+		reqBody, err := makeReqBody(map[string]any{"onlyAdminsCanSendMessages": true})
+		require.NoError(err)
+
+		req := httptest.NewRequest("POST", groupChatPath+"/"+newGroup.Id+"/execute_action/change-settings", reqBody)
+		req.Header.Add("Content-Type", "application/vnd.msgpack")
+		req.Header.Set("Cookie", user2.SessionCookie)
+
+		res, err := app.Test(req)
+		require.NoError(err)
+
+		if !assert.Equal(t, http.StatusOK, res.StatusCode) {
+			rb, err := errResBody(res.Body)
+			require.NoError(err)
+			t.Log("unexpected error:", rb)
+			return
+		}
+
+		rb, err := succResBody[map[string]any](res.Body)
+		require.NoError(err)
+
+		td.Cmp(td.Require(t), rb, td.SuperMapOf(map[string]any{
+			"che_type": "group activity",
+			"info":     "You allowed only admins to send messages",
+		}, nil))
+
+		for _, user := range []UserT{user3, user4, user5} {
+			userGCSettingsNotif := <-user.ServerEventMsg
+
+			td.Cmp(td.Require(t), userGCSettingsNotif, td.Map(map[string]any{
+				"event": "group chat: new che: group activity",
+				"data": td.Map(map[string]any{
+					"group_id": newGroup.Id,
+					"che": td.SuperMapOf(map[string]any{
+						"che_type": "group activity",
+						"info":     user2.Username + " allowed only admins to send messages",
+					}, nil),
+				}, nil),
+			}, nil))
+		}
+	}
+
+	{
+		t.Log("Action: user4 sends message to group in announcement mode | the message isn't sent")
+
+		err := wsWriteMsgPack(user4.WSConn, map[string]any{
+			"action": "group chat: send message",
+			"data": map[string]any{
+				"groupId": newGroup.Id,
+				"msg": map[string]any{
+					"type": "text",
+					"props": map[string]any{
+						"text_content": "Can anyone hear me?",
+					},
+				},
+				"at": time.Now().UTC().UnixMilli(),
+			},
+		})
+		require.NoError(err)
+
+		user4ServerReply := <-user4.ServerEventMsg
+
+		td.Cmp(td.Require(t), user4ServerReply, td.Map(map[string]any{
+			"event":    "server reply",
+			"toAction": "group chat: send message",
+			"data":     td.Nil(),
+		}, nil))
+	}
+
+	{
+		t.Log("Action: user4 changes group description, without permission | the description isn't changed")
+
+		reqBody, err := makeReqBody(map[string]any{
+			"newDescription": "Announcements only!",
+		})
+		require.NoError(err)
+
+		req := httptest.NewRequest("POST", groupChatPath+"/"+newGroup.Id+"/execute_action/change-description", reqBody)
+		req.Header.Add("Content-Type", "application/vnd.msgpack")
+		req.Header.Set("Cookie", user4.SessionCookie)
+
+		res, err := app.Test(req)
+		require.NoError(err)
+
+		if !assert.Equal(t, http.StatusOK, res.StatusCode) {
+			rb, err := errResBody(res.Body)
+			require.NoError(err)
+			t.Log("unexpected error:", rb)
+			return
+		}
+
+		rb, err := succResBody[map[string]any](res.Body)
+		require.NoError(err)
+
+		td.Cmp(td.Require(t), rb, td.SuperMapOf(map[string]any{
+			"che_type": "",
+		}, nil))
+	}
+
+	{
+		t.Log("Action: user2 allows members to edit group info | other members are notified")
+
+		reqBody, err := makeReqBody(map[string]any{"membersCanEditInfo": true})
+		require.NoError(err)
+
+		req := httptest.NewRequest("POST", groupChatPath+"/"+newGroup.Id+"/execute_action/change-settings", reqBody)
+		req.Header.Add("Content-Type", "application/vnd.msgpack")
+		req.Header.Set("Cookie", user2.SessionCookie)
+
+		res, err := app.Test(req)
+		require.NoError(err)
+
+		if !assert.Equal(t, http.StatusOK, res.StatusCode) {
+			rb, err := errResBody(res.Body)
+			require.NoError(err)
+			t.Log("unexpected error:", rb)
+			return
+		}
+
+		rb, err := succResBody[map[string]any](res.Body)
+		require.NoError(err)
+
+		td.Cmp(td.Require(t), rb, td.SuperMapOf(map[string]any{
+			"che_type": "group activity",
+			"info":     "You allowed all members to edit group info",
+		}, nil))
+
+		for _, user := range []UserT{user3, user4, user5} {
+			userGCSettingsNotif := <-user.ServerEventMsg
+
+			td.Cmp(td.Require(t), userGCSettingsNotif, td.Map(map[string]any{
+				"event": "group chat: new che: group activity",
+				"data": td.Map(map[string]any{
+					"group_id": newGroup.Id,
+					"che": td.SuperMapOf(map[string]any{
+						"che_type": "group activity",
+						"info":     user2.Username + " allowed all members to edit group info",
+					}, nil),
+				}, nil),
+			}, nil))
+		}
+	}
+
+	{
+		t.Log("Action: user4 changes group description | other members are notified")
+
+		oldGroupDescription := newGroup.Description
+
+		newGroup.Description = "Announcements only!"
+
+		reqBody, err := makeReqBody(map[string]any{
+			"newDescription": newGroup.Description,
+		})
+		require.NoError(err)
+
+		req := httptest.NewRequest("POST", groupChatPath+"/"+newGroup.Id+"/execute_action/change-description", reqBody)
+		req.Header.Add("Content-Type", "application/vnd.msgpack")
+		req.Header.Set("Cookie", user4.SessionCookie)
+
+		res, err := app.Test(req)
+		require.NoError(err)
+
+		if !assert.Equal(t, http.StatusOK, res.StatusCode) {
+			rb, err := errResBody(res.Body)
+			require.NoError(err)
+			t.Log("unexpected error:", rb)
+			return
+		}
+
+		rb, err := succResBody[map[string]any](res.Body)
+		require.NoError(err)
+
+		td.Cmp(td.Require(t), rb, td.SuperMapOf(map[string]any{
+			"che_type": "group activity",
+			"info":     fmt.Sprintf("You changed group description from %s to %s", oldGroupDescription, newGroup.Description),
+		}, nil))
+
+		for _, user := range []UserT{user2, user3, user5} {
+			userGCDescriptionChangeNotif := <-user.ServerEventMsg
+
+			td.Cmp(td.Require(t), userGCDescriptionChangeNotif, td.Map(map[string]any{
+				"event": "group chat: new che: group activity",
+				"data": td.Map(map[string]any{
+					"group_id": newGroup.Id,
+					"che": td.SuperMapOf(map[string]any{
+						"che_type": "group activity",
+						"info":     fmt.Sprintf("%s changed group description from %s to %s", user4.Username, oldGroupDescription, newGroup.Description),
+					}, nil),
+				}, nil),
+			}, nil))
+		}
+	}
+
+	{
+		t.Log("Action: user4 adds user1, without permission | user1 isn't added")
+
+		reqBody, err := makeReqBody(map[string]any{
+			"newUsers": []string{user1.Username},
+		})
+		require.NoError(err)
+
+		req := httptest.NewRequest("POST", groupChatPath+"/"+newGroup.Id+"/execute_action/add-users", reqBody)
+		req.Header.Add("Content-Type", "application/vnd.msgpack")
+		req.Header.Set("Cookie", user4.SessionCookie)
+
+		res, err := app.Test(req)
+		require.NoError(err)
+
+		if !assert.Equal(t, http.StatusOK, res.StatusCode) {
+			rb, err := errResBody(res.Body)
+			require.NoError(err)
+			t.Log("unexpected error:", rb)
+			return
+		}
+
+		rb, err := succResBody[map[string]any](res.Body)
+		require.NoError(err)
+
+		td.Cmp(td.Require(t), rb, td.SuperMapOf(map[string]any{
+			"che_type": "",
+		}, nil))
+	}
+
+	{
+		t.Log("Action: user2 allows members to add members | other members are notified")
+
+		reqBody, err := makeReqBody(map[string]any{"membersCanAddMembers": true})
+		require.NoError(err)
+
+		req := httptest.NewRequest("POST", groupChatPath+"/"+newGroup.Id+"/execute_action/change-settings", reqBody)
+		req.Header.Add("Content-Type", "application/vnd.msgpack")
+		req.Header.Set("Cookie", user2.SessionCookie)
+
+		res, err := app.Test(req)
+		require.NoError(err)
+
+		if !assert.Equal(t, http.StatusOK, res.StatusCode) {
+			rb, err := errResBody(res.Body)
+			require.NoError(err)
+			t.Log("unexpected error:", rb)
+			return
+		}
+
+		rb, err := succResBody[map[string]any](res.Body)
+		require.NoError(err)
+
+		td.Cmp(td.Require(t), rb, td.SuperMapOf(map[string]any{
+			"che_type": "group activity",
+			"info":     "You allowed all members to add members",
+		}, nil))
+
+		for _, user := range []UserT{user3, user4, user5} {
+			userGCSettingsNotif := <-user.ServerEventMsg
+
+			td.Cmp(td.Require(t), userGCSettingsNotif, td.Map(map[string]any{
+				"event": "group chat: new che: group activity",
+				"data": td.Map(map[string]any{
+					"group_id": newGroup.Id,
+					"che": td.SuperMapOf(map[string]any{
+						"che_type": "group activity",
+						"info":     user2.Username + " allowed all members to add members",
+					}, nil),
+				}, nil),
+			}, nil))
+		}
+	}
+
+	{
+		t.Log("Action: user1 blocks user4")
+
+		reqBody, err := makeReqBody(map[string]any{"username": user4.Username})
+		require.NoError(err)
+
+		req := httptest.NewRequest("POST", userPath+"/block_user", reqBody)
+		req.Header.Set("Cookie", user1.SessionCookie)
+		req.Header.Add("Content-Type", "application/vnd.msgpack")
+
+		res, err := app.Test(req)
+		require.NoError(err)
+
+		if !assert.Equal(t, http.StatusOK, res.StatusCode) {
+			rb, err := errResBody(res.Body)
+			require.NoError(err)
+			t.Log("unexpected error:", rb)
+			return
+		}
+
+		rb, err := succResBody[bool](res.Body)
+		require.NoError(err)
+		require.True(rb)
+	}
+
+	{
+		t.Log("Action: user4 adds user1, who blocked user4 | user1 isn't added")
+
+		reqBody, err := makeReqBody(map[string]any{
+			"newUsers": []string{user1.Username},
+		})
+		require.NoError(err)
+
+		req := httptest.NewRequest("POST", groupChatPath+"/"+newGroup.Id+"/execute_action/add-users", reqBody)
+		req.Header.Add("Content-Type", "application/vnd.msgpack")
+		req.Header.Set("Cookie", user4.SessionCookie)
+
+		res, err := app.Test(req)
+		require.NoError(err)
+
+		if !assert.Equal(t, http.StatusOK, res.StatusCode) {
+			rb, err := errResBody(res.Body)
+			require.NoError(err)
+			t.Log("unexpected error:", rb)
+			return
+		}
+
+		rb, err := succResBody[map[string]any](res.Body)
+		require.NoError(err)
+
+		td.Cmp(td.Require(t), rb, td.SuperMapOf(map[string]any{
+			"che_type": "",
+		}, nil))
+	}
+
+	{
+		t.Log("Action: user1 unblocks user4")
+
+		reqBody, err := makeReqBody(map[string]any{"username": user4.Username})
+		require.NoError(err)
+
+		req := httptest.NewRequest("POST", userPath+"/unblock_user", reqBody)
+		req.Header.Set("Cookie", user1.SessionCookie)
+		req.Header.Add("Content-Type", "application/vnd.msgpack")
+
+		res, err := app.Test(req)
+		require.NoError(err)
+
+		if !assert.Equal(t, http.StatusOK, res.StatusCode) {
+			rb, err := errResBody(res.Body)
+			require.NoError(err)
+			t.Log("unexpected error:", rb)
+			return
+		}
+
+		rb, err := succResBody[bool](res.Body)
+		require.NoError(err)
+		require.True(rb)
+	}
+
+	{
+		t.Log("Action: user4 adds user1 | user1 and other members are notified")
+
+		reqBody, err := makeReqBody(map[string]any{
+			"newUsers": []string{user1.Username},
+		})
+		require.NoError(err)
+
+		req := httptest.NewRequest("POST", groupChatPath+"/"+newGroup.Id+"/execute_action/add-users", reqBody)
+		req.Header.Add("Content-Type", "application/vnd.msgpack")
+		req.Header.Set("Cookie", user4.SessionCookie)
+
+		res, err := app.Test(req)
+		require.NoError(err)
+
+		if !assert.Equal(t, http.StatusOK, res.StatusCode) {
+			rb, err := errResBody(res.Body)
+			require.NoError(err)
+			t.Log("unexpected error:", rb)
+			return
+		}
+
+		rb, err := succResBody[map[string]any](res.Body)
+		require.NoError(err)
+
+		td.Cmp(td.Require(t), rb, td.SuperMapOf(map[string]any{
+			"che_type": "group activity",
+			"info":     "You added " + user1.Username,
+		}, nil))
+
+		user1GCUserAddedNotif := <-user1.ServerEventMsg
+
+		td.Cmp(td.Require(t), user1GCUserAddedNotif, td.Map(map[string]any{
+			"event": "new group chat",
+			"data": td.SuperMapOf(map[string]any{
+				"chat": td.SuperMapOf(map[string]any{
+					"type": "group",
+					"group": td.SuperMapOf(map[string]any{
+						"id":          newGroup.Id,
+						"name":        newGroup.Name,
+						"description": newGroup.Description,
+					}, nil),
+					"cursor": td.Ignore(),
+				}, nil),
+				"history": td.Contains(td.SuperMapOf(map[string]any{
+					"che_type": "group activity",
+					"info":     "You were added",
+				}, nil)),
+			}, nil),
+		}, nil))
+
+		for _, user := range []UserT{user2, user3, user5} {
+			userGCNewUsersAddedNotif := <-user.ServerEventMsg
+
+			td.Cmp(td.Require(t), userGCNewUsersAddedNotif, td.Map(map[string]any{
+				"event": "group chat: new che: group activity",
+				"data": td.Map(map[string]any{
+					"group_id": newGroup.Id,
+					"che": td.SuperMapOf(map[string]any{
+						"che_type": "group activity",
+						"info":     fmt.Sprintf("%s added %s", user4.Username, user1.Username),
+					}, nil),
+				}, nil),
+			}, nil))
+		}
+	}
+
+	{
+		t.Log("Action: user2 makes user4 group admin | user4 & other members are notified")
+
+		reqBody, err := makeReqBody(map[string]any{
+			"user": user4.Username,
+		})
+		require.NoError(err)
+
+		req := httptest.NewRequest("POST", groupChatPath+"/"+newGroup.Id+"/execute_action/make-user-admin", reqBody)
+		req.Header.Add("Content-Type", "application/vnd.msgpack")
+		req.Header.Set("Cookie", user2.SessionCookie)
+
+		res, err := app.Test(req)
+		require.NoError(err)
+
+		if !assert.Equal(t, http.StatusOK, res.StatusCode) {
+			rb, err := errResBody(res.Body)
+			require.NoError(err)
+			t.Log("unexpected error:", rb)
+			return
+		}
+
+		rb, err := succResBody[map[string]any](res.Body)
+		require.NoError(err)
+
+		td.Cmp(td.Require(t), rb, td.SuperMapOf(map[string]any{
+			"che_type": "group activity",
+			"info":     fmt.Sprintf("You made %s group admin", user4.Username),
+		}, nil))
+
+		user4GCNewAdminNotif := <-user4.ServerEventMsg
+
+		td.Cmp(td.Require(t), user4GCNewAdminNotif, td.Map(map[string]any{
+			"event": "group chat: new che: group activity",
+			"data": td.Map(map[string]any{
+				"group_id": newGroup.Id,
+				"che": td.SuperMapOf(map[string]any{
+					"che_type": "group activity",
+					"info":     fmt.Sprintf("%s made you group admin", user2.Username),
+				}, nil),
+			}, nil),
+		}, nil))
+
+		for _, user := range []UserT{user1, user3, user5} {
+			userGCNewAdminNotif := <-user.ServerEventMsg
+
+			td.Cmp(td.Require(t), userGCNewAdminNotif, td.Map(map[string]any{
+				"event": "group chat: new che: group activity",
+				"data": td.Map(map[string]any{
+					"group_id": newGroup.Id,
+					"che": td.SuperMapOf(map[string]any{
+						"che_type": "group activity",
+						"info":     fmt.Sprintf("%s made %s group admin", user2.Username, user4.Username),
+					}, nil),
+				}, nil),
+			}, nil))
+		}
+	}
+
+	{
+		t.Log("Action: user4 removes user2, the group owner, from group admins, then from group | the owner is neither demoted nor removed")
+
+		for _, action := range []string{"remove-user-from-admins", "remove-user"} {
+			reqBody, err := makeReqBody(map[string]any{
+				"user": user2.Username,
+			})
+			require.NoError(err)
+
+			req := httptest.NewRequest("POST", groupChatPath+"/"+newGroup.Id+"/execute_action/"+action, reqBody)
+			req.Header.Add("Content-Type", "application/vnd.msgpack")
+			req.Header.Set("Cookie", user4.SessionCookie)
+
+			res, err := app.Test(req)
+			require.NoError(err)
+
+			if !assert.Equal(t, http.StatusOK, res.StatusCode) {
+				rb, err := errResBody(res.Body)
+				require.NoError(err)
+				t.Log("unexpected error:", rb)
+				return
+			}
+
+			rb, err := succResBody[map[string]any](res.Body)
+			require.NoError(err)
+
+			td.Cmp(td.Require(t), rb, td.SuperMapOf(map[string]any{
+				"che_type": "",
+			}, nil))
+		}
+	}
+
+	{
+		t.Log("Action: user2, the group owner, leaves group | user4, the only admin, succeeds user2 as owner; other members are notified")
+
+		reqBody, err := makeReqBody(map[string]any{})
+		require.NoError(err)
+
+		req := httptest.NewRequest("POST", groupChatPath+"/"+newGroup.Id+"/execute_action/leave", reqBody)
+		req.Header.Add("Content-Type", "application/vnd.msgpack")
+		req.Header.Set("Cookie", user2.SessionCookie)
+
+		res, err := app.Test(req)
+		require.NoError(err)
+
+		if !assert.Equal(t, http.StatusOK, res.StatusCode) {
+			rb, err := errResBody(res.Body)
+			require.NoError(err)
+			t.Log("unexpected error:", rb)
+			return
+		}
+
+		rb, err := succResBody[map[string]any](res.Body)
+		require.NoError(err)
+
+		td.Cmp(td.Require(t), rb, td.SuperMapOf(map[string]any{
+			"che_type": "group activity",
+			"info":     "You left",
+		}, nil))
+
+		for _, user := range []UserT{user1, user3, user4, user5} {
+			userGCLeaveNotif := <-user.ServerEventMsg
+
+			td.Cmp(td.Require(t), userGCLeaveNotif, td.Map(map[string]any{
+				"event": "group chat: new che: group activity",
+				"data": td.Map(map[string]any{
+					"group_id": newGroup.Id,
+					"che": td.SuperMapOf(map[string]any{
+						"che_type": "group activity",
+						"info":     fmt.Sprintf("%s left, and %s is now the group owner", user2.Username, user4.Username),
+					}, nil),
+				}, nil),
+			}, nil))
+		}
+	}
+
+	{
+		t.Log("Action: user4, the new group owner, can't be removed from group admins")
+
+		reqBody, err := makeReqBody(map[string]any{
+			"user": user4.Username,
+		})
+		require.NoError(err)
+
+		req := httptest.NewRequest("POST", groupChatPath+"/"+newGroup.Id+"/execute_action/remove-user-from-admins", reqBody)
+		req.Header.Add("Content-Type", "application/vnd.msgpack")
+		req.Header.Set("Cookie", user4.SessionCookie)
+
+		res, err := app.Test(req)
+		require.NoError(err)
+
+		if !assert.Equal(t, http.StatusOK, res.StatusCode) {
+			rb, err := errResBody(res.Body)
+			require.NoError(err)
+			t.Log("unexpected error:", rb)
+			return
+		}
+
+		rb, err := succResBody[map[string]any](res.Body)
+		require.NoError(err)
+
+		td.Cmp(td.Require(t), rb, td.SuperMapOf(map[string]any{
+			"che_type": "",
+		}, nil))
+	}
+
+	{
+		t.Log("Action: user4 deletes group, archiving members' chats | other members are notified")
+
+		reqBody, err := makeReqBody(map[string]any{"archive": true})
+		require.NoError(err)
+
+		req := httptest.NewRequest("POST", groupChatPath+"/"+newGroup.Id+"/execute_action/delete-group", reqBody)
+		req.Header.Add("Content-Type", "application/vnd.msgpack")
+		req.Header.Set("Cookie", user4.SessionCookie)
+
+		res, err := app.Test(req)
+		require.NoError(err)
+
+		if !assert.Equal(t, http.StatusOK, res.StatusCode) {
+			rb, err := errResBody(res.Body)
+			require.NoError(err)
+			t.Log("unexpected error:", rb)
+			return
+		}
+
+		rb, err := succResBody[map[string]any](res.Body)
+		require.NoError(err)
+
+		td.Cmp(td.Require(t), rb, td.SuperMapOf(map[string]any{
+			"che_type": "group activity",
+			"info":     "You deleted " + newGroup.Name,
+		}, nil))
+
+		for _, user := range []UserT{user1, user3, user5} {
+			userGCDeletedNotif := <-user.ServerEventMsg
+
+			td.Cmp(td.Require(t), userGCDeletedNotif, td.Map(map[string]any{
+				"event": "group chat: group deleted",
+				"data": td.Map(map[string]any{
+					"group_id": newGroup.Id,
+					"archived": true,
+					"che": td.SuperMapOf(map[string]any{
+						"che_type": "group activity",
+						"info":     fmt.Sprintf("%s deleted %s", user4.Username, newGroup.Name),
+					}, nil),
+				}, nil),
+			}, nil))
+		}
+	}
+
+	{
+		<-(time.NewTimer(500 * time.Millisecond).C)
+
+		t.Log("Action: user5 opens the archived group chat history | its messages are kept, with the deletion")
+
+		req := httptest.NewRequest("GET", groupChatPath+"/"+newGroup.Id+"/history", nil)
+		req.Header.Add("Content-Type", "application/vnd.msgpack")
+		req.Header.Set("Cookie", user5.SessionCookie)
+
+		res, err := app.Test(req)
+		require.NoError(err)
+
+		if !assert.Equal(t, http.StatusOK, res.StatusCode) {
+			rb, err := errResBody(res.Body)
+			require.NoError(err)
+			t.Log("unexpected error:", rb)
+			return
+		}
+
+		rb, err := succResBody[[]map[string]any](res.Body)
+		require.NoError(err)
+
+		td.Cmp(td.Require(t), rb,
+			td.All(
+				td.Contains(td.SuperMapOf(map[string]any{
+					"che_type": "group activity",
+					"info":     fmt.Sprintf("%s deleted %s", user4.Username, newGroup.Name),
+				}, nil)),
+				td.Contains(td.SuperMapOf(map[string]any{
+					"che_type": "message",
+					"id":       user4NewMsgId,
+				}, nil)),
+			),
+		)
+	}
+
+	otherGroup := struct {
+		Id   string
+		Name string
+	}{Name: "Harrigans' Corner"}
+
+	{
+		t.Log("Action: user1 creates another group chat with user3 | user3 receives the new group")
+
+		reqBody, err := makeReqBody(map[string]any{
+			"name":             otherGroup.Name,
+			"description":      "Just the two of us",
+			"pictureCloudName": newGroup.PictureCloudName,
+			"initUsers":        []string{user3.Username},
+			"createdAt":        time.Now().UTC().UnixMilli(),
+		})
+		require.NoError(err)
+
+		req := httptest.NewRequest("POST", groupChatPath+"/new", reqBody)
+		req.Header.Add("Content-Type", "application/vnd.msgpack")
+		req.Header.Set("Cookie", user1.SessionCookie)
+
+		res, err := app.Test(req)
+		require.NoError(err)
+
+		if !assert.Equal(t, http.StatusCreated, res.StatusCode) {
+			rb, err := errResBody(res.Body)
+			require.NoError(err)
+			t.Log("unexpected error:", rb)
+			return
+		}
+
+		rb, err := succResBody[map[string]any](res.Body)
+		require.NoError(err)
+
+		otherGroup.Id = rb["chat"].(map[string]any)["group"].(map[string]any)["id"].(string)
+
+		user3RecvNewGroup := <-user3.ServerEventMsg
+
+		td.Cmp(td.Require(t), user3RecvNewGroup, td.Map(map[string]any{
+			"event": "new group chat",
+			"data": td.SuperMapOf(map[string]any{
+				"chat": td.SuperMapOf(map[string]any{
+					"type": "group",
+					"group": td.SuperMapOf(map[string]any{
+						"id":   otherGroup.Id,
+						"name": otherGroup.Name,
+					}, nil),
+					"cursor": td.Ignore(),
+				}, nil),
+				"history": td.Ignore(),
+			}, nil),
+		}, nil))
+	}
+
+	user3NewMsgId := ""
+
+	{
+		t.Log("Action: user3 sends message to the other group | user1 receives the message")
+
+		err := wsWriteMsgPack(user3.WSConn, map[string]any{
+			"action": "group chat: send message",
+			"data": map[string]any{
+				"groupId": otherGroup.Id,
+				"msg": map[string]any{
+					"type": "text",
+					"props": map[string]any{
+						"text_content": "Worth remembering!",
+					},
+				},
+				"at": time.Now().UTC().UnixMilli(),
+			},
+		})
+		require.NoError(err)
+
+		user3ServerReply := <-user3.ServerEventMsg
+
+		td.Cmp(td.Require(t), user3ServerReply, td.Map(map[string]any{
+			"event":    "server reply",
+			"toAction": "group chat: send message",
+			"data": td.Map(map[string]any{
+				"new_msg_id": td.Ignore(),
+				"che_cursor": td.Ignore(),
+			}, nil),
+		}, nil))
+
+		user3NewMsgId = user3ServerReply["data"].(map[string]any)["new_msg_id"].(string)
+
+		user1NewMsgReceived := <-user1.ServerEventMsg
+
+		td.Cmp(td.Require(t), user1NewMsgReceived, td.Map(map[string]any{
+			"event": "group chat: new che: message",
+			"data": td.Map(map[string]any{
+				"group_id": otherGroup.Id,
+				"che": td.SuperMapOf(map[string]any{
+					"che_type": "message",
+					"id":       user3NewMsgId,
+				}, nil),
+			}, nil),
+		}, nil))
+	}
+
+	{
+		t.Log("Action: user1 stars user3's message")
+
+		reqBody, err := makeReqBody(map[string]any{"msg_id": user3NewMsgId})
+		require.NoError(err)
+
+		req := httptest.NewRequest("POST", userPath+"/star_message", reqBody)
+		req.Header.Add("Content-Type", "application/vnd.msgpack")
+		req.Header.Set("Cookie", user1.SessionCookie)
+
+		res, err := app.Test(req)
+		require.NoError(err)
+
+		if !assert.Equal(t, http.StatusOK, res.StatusCode) {
+			rb, err := errResBody(res.Body)
+			require.NoError(err)
+			t.Log("unexpected error:", rb)
+			return
+		}
+
+		rb, err := succResBody[bool](res.Body)
+		require.NoError(err)
+
+		require.True(rb)
+	}
+
+	{
+		t.Log("Action: user1 deletes the other group, removing members' chats | user3 is notified")
+
+		reqBody, err := makeReqBody(map[string]any{"archive": false})
+		require.NoError(err)
+
+		req := httptest.NewRequest("POST", groupChatPath+"/"+otherGroup.Id+"/execute_action/delete-group", reqBody)
+		req.Header.Add("Content-Type", "application/vnd.msgpack")
+		req.Header.Set("Cookie", user1.SessionCookie)
+
+		res, err := app.Test(req)
+		require.NoError(err)
+
+		if !assert.Equal(t, http.StatusOK, res.StatusCode) {
+			rb, err := errResBody(res.Body)
+			require.NoError(err)
+			t.Log("unexpected error:", rb)
+			return
+		}
+
+		rb, err := succResBody[map[string]any](res.Body)
+		require.NoError(err)
+
+		td.Cmp(td.Require(t), rb, td.SuperMapOf(map[string]any{
+			"che_type": "group activity",
+			"info":     "You deleted " + otherGroup.Name,
+		}, nil))
+
+		user3GCDeletedNotif := <-user3.ServerEventMsg
+
+		td.Cmp(td.Require(t), user3GCDeletedNotif, td.Map(map[string]any{
+			"event": "group chat: group deleted",
+			"data": td.Map(map[string]any{
+				"group_id": otherGroup.Id,
+				"archived": false,
+				"che": td.SuperMapOf(map[string]any{
+					"che_type": "group activity",
+					"info":     fmt.Sprintf("%s deleted %s", user1.Username, otherGroup.Name),
+				}, nil),
+			}, nil),
+		}, nil))
+	}
+
+	{
+		<-(time.NewTimer(1 * time.Second).C)
+
+		t.Log("Action: user3 opens the removed group chat history | it is empty")
+
+		req := httptest.NewRequest("GET", groupChatPath+"/"+otherGroup.Id+"/history", nil)
+		req.Header.Add("Content-Type", "application/vnd.msgpack")
+		req.Header.Set("Cookie", user3.SessionCookie)
+
+		res, err := app.Test(req)
+		require.NoError(err)
+
+		if !assert.Equal(t, http.StatusOK, res.StatusCode) {
+			rb, err := errResBody(res.Body)
+			require.NoError(err)
+			t.Log("unexpected error:", rb)
+			return
+		}
+
+		rb, err := succResBody[[]map[string]any](res.Body)
+		require.NoError(err)
+
+		td.Cmp(td.Require(t), rb, td.Empty())
+	}
+
+	{
+		t.Log("Action: user1 opens starred messages | the removed group's message is no longer starred")
+
+		req := httptest.NewRequest("GET", userPath+"/starred_messages", nil)
+		req.Header.Add("Content-Type", "application/vnd.msgpack")
+		req.Header.Set("Cookie", user1.SessionCookie)
+
+		res, err := app.Test(req)
+		require.NoError(err)
+
+		if !assert.Equal(t, http.StatusOK, res.StatusCode) {
+			rb, err := errResBody(res.Body)
+			require.NoError(err)
+			t.Log("unexpected error:", rb)
+			return
+		}
+
+		rb, err := succResBody[[]map[string]any](res.Body)
+		require.NoError(err)
+
+		td.Cmp(td.Require(t), rb, td.Not(td.Contains(td.SuperMapOf(map[string]any{
+			"chat_ident": otherGroup.Id,
+		}, nil))))
+	}
 }
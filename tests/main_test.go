@@ -8,6 +8,7 @@ import (
 	"i9chat/src/initializers"
 	"i9chat/src/routes/appRoutes"
 	"i9chat/src/routes/authRoutes"
+	"i9chat/src/routes/storageRoutes"
 	"io"
 	"log"
 	"net/http"
@@ -80,6 +81,7 @@ func TestMain(m *testing.M) {
 
 	app.Route("/api/auth", authRoutes.Route)
 	app.Route("/api/app", appRoutes.Route)
+	app.Route("/api/storage", storageRoutes.Route)
 
 	var PORT string
 
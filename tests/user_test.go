@@ -2,15 +2,18 @@ package tests
 
 import (
 	"fmt"
-	"i9chat/src/appGlobals"
+	"i9chat/src/services/cloudStorageService"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"testing"
+	"time"
 
+	"github.com/fasthttp/websocket"
 	"github.com/maxatome/go-testdeep/td"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
 )
 
 func TestUserOps(t *testing.T) {
@@ -317,7 +320,7 @@ func TestUserOps(t *testing.T) {
 				require.NoError(err)
 
 				for _, smlPPicCn := range varPPicCloudName {
-					err := appGlobals.GCSClient.Bucket(os.Getenv("GCS_BUCKET_NAME")).Object(smlPPicCn).Delete(t.Context())
+					err := cloudStorageService.Backend().Delete(t.Context(), smlPPicCn)
 					require.NoError(err)
 				}
 			}(profilePicCloudName)
@@ -346,4 +349,459 @@ func TestUserOps(t *testing.T) {
 		require.NoError(err)
 		require.True(rb)
 	}
+
+	{
+		t.Log("Action: user1 blocks user1 | the request is rejected")
+
+		reqBody, err := makeReqBody(map[string]any{"username": user1.Username})
+		require.NoError(err)
+
+		req := httptest.NewRequest("POST", userPath+"/block_user", reqBody)
+		req.Header.Set("Cookie", user1.SessionCookie)
+		req.Header.Add("Content-Type", "application/vnd.msgpack")
+
+		res, err := app.Test(req)
+		require.NoError(err)
+
+		if !assert.Equal(t, http.StatusBadRequest, res.StatusCode) {
+			rb, err := errResBody(res.Body)
+			require.NoError(err)
+			t.Log("unexpected error:", rb)
+			return
+		}
+	}
+
+	{
+		t.Log("Action: user1 blocks user2")
+
+		reqBody, err := makeReqBody(map[string]any{"username": user2.Username})
+		require.NoError(err)
+
+		req := httptest.NewRequest("POST", userPath+"/block_user", reqBody)
+		req.Header.Set("Cookie", user1.SessionCookie)
+		req.Header.Add("Content-Type", "application/vnd.msgpack")
+
+		res, err := app.Test(req)
+		require.NoError(err)
+
+		if !assert.Equal(t, http.StatusOK, res.StatusCode) {
+			rb, err := errResBody(res.Body)
+			require.NoError(err)
+			t.Log("unexpected error:", rb)
+			return
+		}
+
+		rb, err := succResBody[bool](res.Body)
+		require.NoError(err)
+		require.True(rb)
+	}
+
+	{
+		t.Log("Action: user1 lists the blocked users | user2 is listed")
+
+		req := httptest.NewRequest("GET", userPath+"/blocked_users", nil)
+		req.Header.Set("Cookie", user1.SessionCookie)
+
+		res, err := app.Test(req)
+		require.NoError(err)
+
+		if !assert.Equal(t, http.StatusOK, res.StatusCode) {
+			rb, err := errResBody(res.Body)
+			require.NoError(err)
+			t.Log("unexpected error:", rb)
+			return
+		}
+
+		rb, err := succResBody[[]map[string]any](res.Body)
+		require.NoError(err)
+
+		td.Cmp(td.Require(t), rb, td.All(
+			td.Len(1),
+			td.Contains(td.SuperMapOf(map[string]any{
+				"username": user2.Username,
+			}, nil)),
+		))
+	}
+
+	{
+		t.Log("Action: user1 finds users nearby | user2, whom user1 blocked, isn't found")
+
+		x, y, radius := user1.Geolocation.X, user1.Geolocation.Y, 350000.0
+
+		req := httptest.NewRequest("GET", fmt.Sprintf("%s%s?x=%f&y=%f&radius=%f", userPath, "/find_nearby_users", x, y, radius), nil)
+		req.Header.Set("Cookie", user1.SessionCookie)
+
+		res, err := app.Test(req)
+		require.NoError(err)
+
+		if !assert.Equal(t, http.StatusOK, res.StatusCode) {
+			rb, err := errResBody(res.Body)
+			require.NoError(err)
+			t.Log("unexpected error:", rb)
+			return
+		}
+
+		rb, err := succResBody[[]map[string]any](res.Body)
+		require.NoError(err)
+
+		td.Cmp(td.Require(t), rb, td.Not(td.Contains(td.SuperMapOf(map[string]any{
+			"username": user2.Username,
+		}, nil))))
+	}
+
+	{
+		t.Log("Action: user2 finds users nearby | user1, who blocked user2, isn't found")
+
+		x, y, radius := user2.Geolocation.X, user2.Geolocation.Y, 350000.0
+
+		req := httptest.NewRequest("GET", fmt.Sprintf("%s%s?x=%f&y=%f&radius=%f", userPath, "/find_nearby_users", x, y, radius), nil)
+		req.Header.Set("Cookie", user2.SessionCookie)
+
+		res, err := app.Test(req)
+		require.NoError(err)
+
+		if !assert.Equal(t, http.StatusOK, res.StatusCode) {
+			rb, err := errResBody(res.Body)
+			require.NoError(err)
+			t.Log("unexpected error:", rb)
+			return
+		}
+
+		rb, err := succResBody[[]map[string]any](res.Body)
+		require.NoError(err)
+
+		td.Cmp(td.Require(t), rb, td.Not(td.Contains(td.SuperMapOf(map[string]any{
+			"username": user1.Username,
+		}, nil))))
+	}
+
+	{
+		t.Log("Action: user1 unblocks user2")
+
+		reqBody, err := makeReqBody(map[string]any{"username": user2.Username})
+		require.NoError(err)
+
+		req := httptest.NewRequest("POST", userPath+"/unblock_user", reqBody)
+		req.Header.Set("Cookie", user1.SessionCookie)
+		req.Header.Add("Content-Type", "application/vnd.msgpack")
+
+		res, err := app.Test(req)
+		require.NoError(err)
+
+		if !assert.Equal(t, http.StatusOK, res.StatusCode) {
+			rb, err := errResBody(res.Body)
+			require.NoError(err)
+			t.Log("unexpected error:", rb)
+			return
+		}
+
+		rb, err := succResBody[bool](res.Body)
+		require.NoError(err)
+		require.True(rb)
+	}
+
+	{
+		t.Log("Action: user1 lists the blocked users | none is listed")
+
+		req := httptest.NewRequest("GET", userPath+"/blocked_users", nil)
+		req.Header.Set("Cookie", user1.SessionCookie)
+
+		res, err := app.Test(req)
+		require.NoError(err)
+
+		if !assert.Equal(t, http.StatusOK, res.StatusCode) {
+			rb, err := errResBody(res.Body)
+			require.NoError(err)
+			t.Log("unexpected error:", rb)
+			return
+		}
+
+		rb, err := succResBody[[]map[string]any](res.Body)
+		require.NoError(err)
+
+		td.Cmp(td.Require(t), rb, td.Empty())
+	}
+
+	{
+		t.Log("Action: user1 finds users nearby | user2 is found again")
+
+		x, y, radius := user1.Geolocation.X, user1.Geolocation.Y, 350000.0
+
+		req := httptest.NewRequest("GET", fmt.Sprintf("%s%s?x=%f&y=%f&radius=%f", userPath, "/find_nearby_users", x, y, radius), nil)
+		req.Header.Set("Cookie", user1.SessionCookie)
+
+		res, err := app.Test(req)
+		require.NoError(err)
+
+		if !assert.Equal(t, http.StatusOK, res.StatusCode) {
+			rb, err := errResBody(res.Body)
+			require.NoError(err)
+			t.Log("unexpected error:", rb)
+			return
+		}
+
+		rb, err := succResBody[[]map[string]any](res.Body)
+		require.NoError(err)
+
+		td.Cmp(td.Require(t), rb, td.Contains(td.SuperMapOf(map[string]any{
+			"username": user2.Username,
+		}, nil)))
+	}
+
+	otherSessionCookie := ""
+
+	{
+		t.Log("Action: user1 signs in on another device")
+
+		reqBody, err := makeReqBody(map[string]any{
+			"emailOrUsername": user1.Username,
+			"password":        user1.Password,
+		})
+		require.NoError(err)
+
+		req := httptest.NewRequest("POST", signinPath, reqBody)
+		req.Header.Add("Content-Type", "application/vnd.msgpack")
+
+		res, err := app.Test(req)
+		require.NoError(err)
+
+		if !assert.Equal(t, http.StatusOK, res.StatusCode) {
+			rb, err := errResBody(res.Body)
+			require.NoError(err)
+			t.Log("unexpected error:", rb)
+			return
+		}
+
+		otherSessionCookie = res.Header.Get("Set-Cookie")
+	}
+
+	otherSessionId := ""
+
+	{
+		t.Log("Action: user1 lists the active sessions | both sessions are listed, the current one marked")
+
+		req := httptest.NewRequest("GET", userPath+"/sessions", nil)
+		req.Header.Set("Cookie", user1.SessionCookie)
+
+		res, err := app.Test(req)
+		require.NoError(err)
+
+		if !assert.Equal(t, http.StatusOK, res.StatusCode) {
+			rb, err := errResBody(res.Body)
+			require.NoError(err)
+			t.Log("unexpected error:", rb)
+			return
+		}
+
+		rb, err := succResBody[[]map[string]any](res.Body)
+		require.NoError(err)
+
+		td.Cmp(td.Require(t), rb, td.All(
+			td.Len(2),
+			td.Contains(td.SuperMapOf(map[string]any{
+				"id":           td.NotEmpty(),
+				"created_at":   td.NotZero(),
+				"last_used_at": td.NotZero(),
+				"current":      true,
+			}, nil)),
+			td.Contains(td.SuperMapOf(map[string]any{
+				"id":      td.NotEmpty(),
+				"current": false,
+			}, nil)),
+		))
+
+		for _, session := range rb {
+			if session["current"] == false {
+				otherSessionId = session["id"].(string)
+			}
+		}
+	}
+
+	var otherSessionWSConn *websocket.Conn
+
+	{
+		t.Log("Setup: user1 opens a socket with the other session")
+
+		header := http.Header{}
+		header.Set("Cookie", otherSessionCookie)
+		wsConn, res, err := websocket.DefaultDialer.Dial(wsPath, header)
+		require.NoError(err)
+
+		if !assert.Equal(t, http.StatusSwitchingProtocols, res.StatusCode) {
+			rb, err := errResBody(res.Body)
+			require.NoError(err)
+			t.Log("unexpected error:", rb)
+			return
+		}
+
+		otherSessionWSConn = wsConn
+	}
+
+	{
+		t.Log("Action: user1 revokes the other session | its socket is told so, and closed")
+
+		reqBody, err := makeReqBody(map[string]any{"session_id": otherSessionId})
+		require.NoError(err)
+
+		req := httptest.NewRequest("POST", userPath+"/revoke_session", reqBody)
+		req.Header.Set("Cookie", user1.SessionCookie)
+		req.Header.Add("Content-Type", "application/vnd.msgpack")
+
+		res, err := app.Test(req)
+		require.NoError(err)
+
+		if !assert.Equal(t, http.StatusOK, res.StatusCode) {
+			rb, err := errResBody(res.Body)
+			require.NoError(err)
+			t.Log("unexpected error:", rb)
+			return
+		}
+
+		rb, err := succResBody[bool](res.Body)
+		require.NoError(err)
+		require.True(rb)
+
+		require.NoError(otherSessionWSConn.SetReadDeadline(time.Now().Add(5 * time.Second)))
+
+		var wsMsg map[string]any
+
+		// events sent to the socket before the revocation may precede it
+		for wsMsg["event"] != "session revoked" {
+			_, wsMsgBt, err := otherSessionWSConn.ReadMessage()
+			require.NoError(err)
+
+			wsMsg = nil
+			require.NoError(msgpack.Unmarshal(wsMsgBt, &wsMsg))
+		}
+
+		_, _, err = otherSessionWSConn.ReadMessage()
+		require.Error(err)
+	}
+
+	{
+		t.Log("Action: user1 reuses the revoked session | it is rejected")
+
+		req := httptest.NewRequest("GET", userPath+"/session_user", nil)
+		req.Header.Set("Cookie", otherSessionCookie)
+
+		res, err := app.Test(req)
+		require.NoError(err)
+
+		if !assert.Equal(t, http.StatusUnauthorized, res.StatusCode) {
+			rb, err := errResBody(res.Body)
+			require.NoError(err)
+			t.Log("unexpected error:", rb)
+			return
+		}
+	}
+
+	{
+		t.Log("Action: user1 lists the active sessions | only the current session is listed")
+
+		req := httptest.NewRequest("GET", userPath+"/sessions", nil)
+		req.Header.Set("Cookie", user1.SessionCookie)
+
+		res, err := app.Test(req)
+		require.NoError(err)
+
+		if !assert.Equal(t, http.StatusOK, res.StatusCode) {
+			rb, err := errResBody(res.Body)
+			require.NoError(err)
+			t.Log("unexpected error:", rb)
+			return
+		}
+
+		rb, err := succResBody[[]map[string]any](res.Body)
+		require.NoError(err)
+
+		td.Cmp(td.Require(t), rb, td.All(
+			td.Len(1),
+			td.Contains(td.SuperMapOf(map[string]any{
+				"id":      td.Not(otherSessionId),
+				"current": true,
+			}, nil)),
+		))
+	}
+
+	anotherSessionCookie := ""
+
+	{
+		t.Log("Action: user1 signs in on yet another device")
+
+		reqBody, err := makeReqBody(map[string]any{
+			"emailOrUsername": user1.Username,
+			"password":        user1.Password,
+		})
+		require.NoError(err)
+
+		req := httptest.NewRequest("POST", signinPath, reqBody)
+		req.Header.Add("Content-Type", "application/vnd.msgpack")
+
+		res, err := app.Test(req)
+		require.NoError(err)
+
+		if !assert.Equal(t, http.StatusOK, res.StatusCode) {
+			rb, err := errResBody(res.Body)
+			require.NoError(err)
+			t.Log("unexpected error:", rb)
+			return
+		}
+
+		anotherSessionCookie = res.Header.Get("Set-Cookie")
+	}
+
+	{
+		t.Log("Action: user1 revokes all sessions")
+
+		req := httptest.NewRequest("POST", userPath+"/revoke_all_sessions", nil)
+		req.Header.Set("Cookie", user1.SessionCookie)
+
+		res, err := app.Test(req)
+		require.NoError(err)
+
+		if !assert.Equal(t, http.StatusOK, res.StatusCode) {
+			rb, err := errResBody(res.Body)
+			require.NoError(err)
+			t.Log("unexpected error:", rb)
+			return
+		}
+
+		rb, err := succResBody[bool](res.Body)
+		require.NoError(err)
+		require.True(rb)
+	}
+
+	{
+		t.Log("Action: user1 reuses the current session, after revoking all | it is rejected")
+
+		req := httptest.NewRequest("GET", userPath+"/session_user", nil)
+		req.Header.Set("Cookie", user1.SessionCookie)
+
+		res, err := app.Test(req)
+		require.NoError(err)
+
+		if !assert.Equal(t, http.StatusUnauthorized, res.StatusCode) {
+			rb, err := errResBody(res.Body)
+			require.NoError(err)
+			t.Log("unexpected error:", rb)
+			return
+		}
+	}
+
+	{
+		t.Log("Action: user1 reuses the session of the other device, after revoking all | it is rejected")
+
+		req := httptest.NewRequest("GET", userPath+"/session_user", nil)
+		req.Header.Set("Cookie", anotherSessionCookie)
+
+		res, err := app.Test(req)
+		require.NoError(err)
+
+		if !assert.Equal(t, http.StatusUnauthorized, res.StatusCode) {
+			rb, err := errResBody(res.Body)
+			require.NoError(err)
+			t.Log("unexpected error:", rb)
+			return
+		}
+	}
 }